@@ -0,0 +1,131 @@
+// Command evaluate runs a labeled corpus of human and AI texts through the
+// detection pipeline and reports how well the scores separate them.
+//
+//	go run ./cmd/evaluate -corpus corpus.jsonl -out reports/
+//
+// By default only the offline heuristic detector runs. Include llm in -weights
+// (with GEMINI_API_KEY set) or pass -local-model to evaluate those backends.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"github.com/victor-butita/rephrase/internal/detector"
+	"github.com/victor-butita/rephrase/internal/evaluation"
+	"github.com/victor-butita/rephrase/internal/services"
+)
+
+func main() {
+	corpus := flag.String("corpus", "", "labeled corpus: a JSONL file or a directory with ai/ and human/ subdirectories")
+	out := flag.String("out", "evaluation", "directory for report.json and report.html")
+	weights := flag.String("weights", "heuristic=1", "detector weights, e.g. llm=0.5,heuristic=0.5")
+	localModel := flag.String("local-model", "", "URL of a local classifier endpoint")
+	modelPath := flag.String("model", "", "heuristic model JSON to evaluate instead of the built-in one")
+	thresholdSpec := flag.String("thresholds", "30,50,70,90", "comma-separated score thresholds for precision/recall")
+	bins := flag.Int("bins", 10, "number of calibration bins")
+	workers := flag.Int("workers", 4, "documents scored concurrently")
+	fitPath := flag.String("fit", "", "fit heuristic model coefficients on the corpus and write them to this file")
+	flag.Parse()
+
+	if *corpus == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	thresholds, err := parseThresholds(*thresholdSpec)
+	if err != nil {
+		log.Fatal(err)
+	}
+	docs, err := evaluation.LoadCorpus(*corpus)
+	if err != nil {
+		log.Fatalf("Could not load corpus: %v", err)
+	}
+
+	var model *detector.Model
+	if *modelPath != "" {
+		if model, err = detector.LoadModel(*modelPath); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *fitPath != "" {
+		if model, err = fit(docs, *fitPath); err != nil {
+			log.Fatalf("Could not fit model: %v", err)
+		}
+		log.Printf("Wrote fitted heuristic model to %s (metrics below are in-sample)", *fitPath)
+	}
+
+	_ = godotenv.Load()
+	var gemini *services.GeminiService
+	if key := os.Getenv("GEMINI_API_KEY"); key != "" {
+		gemini = services.NewGeminiService(key)
+	}
+	ensemble, err := services.NewDetectorEnsemble(gemini, *weights, *localModel, model)
+	if err != nil {
+		log.Fatalf("Invalid detector configuration: %v", err)
+	}
+
+	log.Printf("Scoring %d documents with %s", len(docs), strings.Join(ensemble.Names(), ", "))
+	report := evaluation.Run(docs, ensemble, thresholds, *bins, *workers)
+	report.Corpus = *corpus
+	report.Weights = *weights
+
+	if err := os.MkdirAll(*out, 0o755); err != nil {
+		log.Fatal(err)
+	}
+	if err := report.WriteJSON(filepath.Join(*out, "report.json")); err != nil {
+		log.Fatal(err)
+	}
+	if err := report.WriteHTML(filepath.Join(*out, "report.html")); err != nil {
+		log.Fatal(err)
+	}
+
+	for _, name := range report.Names() {
+		m := report.Metrics[name]
+		fmt.Printf("%-12s n=%-5d auc=%.3f brier=%.3f ece=%.3f\n", name, m.Samples, m.AUC, m.Brier, m.ECE)
+	}
+	if report.Failed > 0 {
+		fmt.Printf("%d document(s) failed; see report.json for errors\n", report.Failed)
+	}
+	fmt.Printf("Reports written to %s\n", *out)
+}
+
+func parseThresholds(spec string) ([]float64, error) {
+	var thresholds []float64
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		t, err := strconv.ParseFloat(part, 64)
+		if err != nil || t < 0 || t > 100 {
+			return nil, fmt.Errorf("invalid threshold %q: must be a number between 0 and 100", part)
+		}
+		thresholds = append(thresholds, t)
+	}
+	return thresholds, nil
+}
+
+func fit(docs []evaluation.Document, path string) (*detector.Model, error) {
+	features := make([]detector.Features, len(docs))
+	labels := make([]bool, len(docs))
+	for i, d := range docs {
+		features[i] = detector.ComputeFeatures(d.Text)
+		labels[i] = d.Label == evaluation.LabelAI
+	}
+	model, err := detector.Fit(features, labels)
+	if err != nil {
+		return nil, err
+	}
+	b, err := json.MarshalIndent(model, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return model, os.WriteFile(path, b, 0o644)
+}
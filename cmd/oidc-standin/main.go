@@ -0,0 +1,50 @@
+// Command oidc-standin is a minimal OpenID Connect provider for trying out
+// and testing single sign-on locally, without a real identity provider.
+//
+//	go run ./cmd/oidc-standin -addr localhost:9000
+//
+// Point the server at it with
+//
+//	OIDC_ISSUER=http://localhost:9000 OIDC_CLIENT_ID=rephrase OIDC_CLIENT_SECRET=standin-secret
+//
+// Its sign-in page asks for a username, email and groups instead of a
+// password and issues signed ID tokens for whatever is entered, so it must
+// never be exposed beyond the local machine.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/victor-butita/rephrase/internal/auth/oidctest"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:9000", "address to listen on")
+	issuer := flag.String("issuer", "", "issuer URL; defaults to http://<addr>")
+	clientID := flag.String("client-id", "rephrase", "client ID the server signs in with")
+	clientSecret := flag.String("client-secret", "standin-secret", "client secret the server signs in with")
+	redirectURL := flag.String("redirect-url", "http://localhost:8080/api/auth/oidc/callback", "the only redirect URL accepted")
+	groupsInUserinfo := flag.Bool("groups-in-userinfo", false, "list groups at the userinfo endpoint only, not in the ID token")
+	groupsAsString := flag.Bool("groups-as-string", false, "list groups as one comma-separated string rather than a list")
+	flag.Parse()
+
+	if *issuer == "" {
+		*issuer = "http://" + *addr
+	}
+	p, err := oidctest.New(*clientID, *clientSecret, *redirectURL)
+	if err != nil {
+		log.Fatal(err)
+	}
+	p.Issuer = strings.TrimRight(*issuer, "/")
+	p.GroupsInUserinfo = *groupsInUserinfo
+	p.GroupsAsString = *groupsAsString
+
+	fmt.Printf("OpenID Connect stand-in for client %q at %s\n", p.ClientID, p.Issuer)
+	if err := http.ListenAndServe(*addr, p); err != nil {
+		log.Fatalf("Server failed to start: %v", err)
+	}
+}
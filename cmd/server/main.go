@@ -1,13 +1,21 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
+	"github.com/victor-butita/rephrase/internal/auth"
+	"github.com/victor-butita/rephrase/internal/detector"
 	"github.com/victor-butita/rephrase/internal/handlers" // Use your module path
+	"github.com/victor-butita/rephrase/internal/history"
+	"github.com/victor-butita/rephrase/internal/plagiarism"
+	"github.com/victor-butita/rephrase/internal/research"
 	"github.com/victor-butita/rephrase/internal/services" // Use your module path
 )
 
@@ -24,6 +32,117 @@ func main() {
 
 	// --- Dependency Injection ---
 	geminiService := services.NewGeminiService(geminiAPIKey)
+	styleGuideStore := services.NewStyleGuideStore()
+	glossaryStore := services.NewGlossaryStore()
+	corpus := plagiarism.NewIndex()
+	library := research.NewLibrary()
+	researchSessions := services.NewResearchSessionStore()
+	var historyStore *history.Store
+	if path := os.Getenv("HISTORY_DB"); path != "off" {
+		if path == "" {
+			path = "rephrase.db"
+		}
+		if historyStore, err = history.Open(path); err != nil {
+			log.Fatalf("Could not open history database: %v", err)
+		}
+		defer historyStore.Close()
+	}
+	// Accounts live in their own database so that HISTORY_DB=off never
+	// turns authentication off with it.
+	var authStore *auth.Store
+	var authenticator *auth.Authenticator
+	if path := os.Getenv("AUTH_DB"); path != "off" {
+		if path == "" {
+			path = "rephrase-auth.db"
+		}
+		if authStore, err = auth.Open(path); err != nil {
+			log.Fatalf("Could not open accounts database: %v", err)
+		}
+		defer authStore.Close()
+		if username := os.Getenv("ADMIN_USERNAME"); username != "" {
+			created, err := authStore.EnsureUser(username, os.Getenv("ADMIN_PASSWORD"), auth.RoleAdmin)
+			if err != nil {
+				log.Fatalf("Could not create admin account %q: %v", username, err)
+			}
+			if created {
+				log.Printf("Created admin account %q", username)
+			}
+		}
+		if n, err := authStore.CountUsers(); err == nil && n == 0 {
+			log.Println("No user accounts exist yet; set ADMIN_USERNAME and ADMIN_PASSWORD to create the first admin")
+		}
+		authenticator = auth.NewAuthenticator(authStore)
+		if historyStore != nil {
+			claimUnownedHistory(historyStore, authStore)
+		}
+	} else {
+		log.Println("AUTH_DB=off: authentication is disabled and anyone who can reach the server can use it")
+	}
+	// Submissions are loaded once history saved without accounts has been
+	// given an owner. A nil history store keeps them in memory only.
+	submissionStore, err := services.NewSubmissionStore(historyStore)
+	if err != nil {
+		log.Fatalf("Could not load submissions: %v", err)
+	}
+	// Single sign-on through an OpenID Connect provider sits alongside the
+	// local accounts.
+	var sso *auth.OIDC
+	if issuer := os.Getenv("OIDC_ISSUER"); issuer != "" {
+		if authenticator == nil {
+			log.Fatal("OIDC_ISSUER is set but AUTH_DB=off; single sign-on needs the accounts database")
+		}
+		redirectURL := os.Getenv("OIDC_REDIRECT_URL")
+		if redirectURL == "" {
+			redirectURL = "http://localhost:8080/api/auth/oidc/callback"
+		}
+		sso, err = auth.NewOIDC(context.Background(), auth.OIDCConfig{
+			Name:          os.Getenv("OIDC_NAME"),
+			Issuer:        issuer,
+			ClientID:      os.Getenv("OIDC_CLIENT_ID"),
+			ClientSecret:  os.Getenv("OIDC_CLIENT_SECRET"),
+			RedirectURL:   redirectURL,
+			GroupsClaim:   os.Getenv("OIDC_GROUPS_CLAIM"),
+			AdminGroups:   splitList(os.Getenv("OIDC_ADMIN_GROUPS")),
+			AllowedGroups: splitList(os.Getenv("OIDC_ALLOWED_GROUPS")),
+		}, authenticator)
+		if err != nil {
+			log.Fatalf("Invalid single sign-on configuration: %v", err)
+		}
+		log.Printf("Single sign-on enabled with %s", issuer)
+	}
+	var heuristicModel *detector.Model
+	if path := os.Getenv("HEURISTIC_MODEL_PATH"); path != "" {
+		if heuristicModel, err = detector.LoadModel(path); err != nil {
+			log.Fatalf("Could not load heuristic detector model: %v", err)
+		}
+	}
+	detectors, err := services.NewDetectorEnsemble(geminiService, os.Getenv("DETECTOR_WEIGHTS"), os.Getenv("LOCAL_DETECTOR_URL"), heuristicModel)
+	if err != nil {
+		log.Fatalf("Invalid detector configuration: %v", err)
+	}
+
+	var paraphraseThreshold float64
+	if v := os.Getenv("PARAPHRASE_THRESHOLD"); v != "" {
+		if paraphraseThreshold, err = strconv.ParseFloat(v, 64); err != nil || paraphraseThreshold <= 0 || paraphraseThreshold > 1 {
+			log.Fatalf("PARAPHRASE_THRESHOLD must be a number in (0, 1], got %q", v)
+		}
+	}
+	plagiarismChecker, err := services.NewPlagiarismChecker(geminiService, corpus, os.Getenv("PLAGIARISM_SOURCES"), os.Getenv("SEARCH_ENGINE"), plagiarism.SearchConfig{
+		URL:        os.Getenv("SEARCH_URL"),
+		Index:      os.Getenv("SEARCH_INDEX"),
+		APIKey:     os.Getenv("SEARCH_API_KEY"),
+		TextField:  os.Getenv("SEARCH_TEXT_FIELD"),
+		TitleField: os.Getenv("SEARCH_TITLE_FIELD"),
+		URLField:   os.Getenv("SEARCH_URL_FIELD"),
+	}, services.EmbeddingConfig{
+		URL:       os.Getenv("EMBEDDING_URL"),
+		Model:     os.Getenv("EMBEDDING_MODEL"),
+		Provider:  os.Getenv("EMBEDDING_PROVIDER"),
+		Threshold: paraphraseThreshold,
+	})
+	if err != nil {
+		log.Fatalf("Invalid plagiarism configuration: %v", err)
+	}
 
 	// Create the Hub and StatsTracker
 	hub := handlers.NewHub()
@@ -33,15 +152,54 @@ func main() {
 	go hub.Run()
 
 	// Inject the StatsTracker into the ProcessHandler
-	processHandler := handlers.NewProcessHandler(geminiService, statsTracker)
+	processHandler := handlers.NewProcessHandler(geminiService, statsTracker, styleGuideStore, glossaryStore, detectors, corpus, plagiarismChecker, submissionStore, library, researchSessions, historyStore, authStore)
 
 	// --- Routing ---
+	// Everything under /api/ except logging in and out needs a signed-in
+	// user when accounts are enabled. /api/process also takes API keys.
+	protect := func(h http.Handler) http.Handler { return h }
+	protectWithKeys := protect
+	if authenticator != nil {
+		protect, protectWithKeys = authenticator.Require, authenticator.RequireWithKeys
+	}
+	api := http.NewServeMux()
+	api.Handle("/api/style-guides", handlers.NewStyleGuideHandler(styleGuideStore))
+	api.Handle("/api/glossary", handlers.NewGlossaryHandler(glossaryStore))
+	api.Handle("/api/corpus", handlers.NewCorpusHandler(corpus))
+	api.Handle("/api/submissions", handlers.NewSubmissionHandler(submissionStore))
+	api.Handle("/api/collections", handlers.NewCollectionHandler(library))
+	api.Handle("/api/collections/documents", handlers.NewCollectionDocumentHandler(library))
+	api.Handle("/api/export", handlers.NewExportHandler())
+	api.Handle("/api/upload", handlers.NewUploadHandler())
+	api.Handle("/api/research/sessions", handlers.NewResearchSessionHandler(geminiService, researchSessions, library))
+
 	mux := http.NewServeMux()
-	mux.Handle("/api/process", processHandler)
+	mux.Handle("/api/", protect(api))
+	mux.Handle("/api/process", protectWithKeys(processHandler))
+	if authenticator != nil {
+		mux.Handle("/api/auth/login", handlers.NewLoginHandler(authenticator))
+		mux.Handle("/api/auth/logout", handlers.NewLogoutHandler(authenticator))
+		mux.Handle("/api/auth/providers", handlers.NewAuthProvidersHandler(sso))
+		api.Handle("/api/auth/me", handlers.NewCurrentUserHandler())
+		api.Handle("/api/keys", handlers.NewAPIKeyHandler(authStore))
+		mux.Handle("/api/users", authenticator.RequireRole(auth.RoleAdmin, handlers.NewUserHandler(authStore)))
+	}
+	if sso != nil {
+		mux.Handle("/api/auth/oidc/login", handlers.NewOIDCLoginHandler(sso))
+		mux.Handle("/api/auth/oidc/callback", handlers.NewOIDCCallbackHandler(sso))
+	}
+	if historyStore != nil {
+		api.Handle("/api/history", handlers.NewHistoryHandler(historyStore))
+		api.Handle("/api/history/revisions", handlers.NewRevisionHandler(historyStore))
+		api.Handle("/api/workspaces", handlers.NewWorkspaceHandler(historyStore))
+		api.Handle("/api/shares", handlers.NewShareHandler(historyStore))
+		// Shared links are public by design; the token is the credential.
+		mux.Handle("/s/", handlers.NewSharedResultHandler(historyStore, "./web/index.html"))
+	}
 	// **CORRECTED:** The ServeWs handler is now a closure to pass the statsTracker.
-	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+	mux.Handle("/ws", protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		hub.ServeWs(w, r, statsTracker)
-	})
+	})))
 	mux.Handle("/", http.FileServer(http.Dir("./web")))
 
 	// --- Start Server ---
@@ -51,3 +209,46 @@ func main() {
 		log.Fatalf("Server failed to start: %v", err)
 	}
 }
+
+// splitList splits a comma-separated setting, dropping empty entries.
+func splitList(v string) []string {
+	var out []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// claimUnownedHistory gives the history saved while accounts were disabled,
+// which no signed-in user can see, to the account named by HISTORY_OWNER or
+// else to the bootstrap admin. Without either, the links shared from it are
+// revoked, since no one could revoke them otherwise.
+func claimUnownedHistory(historyStore *history.Store, authStore *auth.Store) {
+	username := os.Getenv("HISTORY_OWNER")
+	if username == "" {
+		username = os.Getenv("ADMIN_USERNAME")
+	}
+	if username == "" {
+		n, err := historyStore.RevokeUnownedShares()
+		if err != nil {
+			log.Fatalf("Could not revoke unowned shared links: %v", err)
+		}
+		if n > 0 {
+			log.Printf("Revoked %d shared links created without accounts; set HISTORY_OWNER to give their history to an account", n)
+		}
+		return
+	}
+	u, err := authStore.UserByName(username)
+	if err != nil {
+		log.Fatalf("Could not find HISTORY_OWNER account %q: %v", username, err)
+	}
+	n, err := historyStore.ClaimUnowned(u.ID)
+	if err != nil {
+		log.Fatalf("Could not give unowned history to %q: %v", username, err)
+	}
+	if n > 0 {
+		log.Printf("Gave %d documents saved without accounts to %q", n, username)
+	}
+}
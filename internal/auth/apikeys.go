@@ -0,0 +1,235 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/victor-butita/rephrase/internal/storeutil"
+)
+
+const (
+	// keyPrefix marks API keys so they are recognisable in configs and
+	// secret scanners.
+	keyPrefix = "rph_"
+	// ScopeAll lets a key run every action.
+	ScopeAll = "*"
+)
+
+var (
+	ErrKeyNotFound = errors.New("API key not found")
+	ErrInvalidKey  = errors.New("invalid, expired or revoked API key")
+)
+
+// APIKey lets a program act as its user on /api/process, for the actions in
+// Scopes. Only a hash of the key is stored; Prefix is the start of the key,
+// kept to tell keys apart.
+type APIKey struct {
+	ID         string        `json:"id"`
+	UserID     string        `json:"user_id"`
+	Name       string        `json:"name"`
+	Prefix     string        `json:"prefix"`
+	Scopes     []string      `json:"scopes"`
+	CreatedAt  time.Time     `json:"created_at"`
+	ExpiresAt  *time.Time    `json:"expires_at,omitempty"`
+	RevokedAt  *time.Time    `json:"revoked_at,omitempty"`
+	LastUsedAt *time.Time    `json:"last_used_at,omitempty"`
+	Usage      []ActionUsage `json:"usage"`
+}
+
+// ActionUsage counts the requests a key made for one action. Failures are
+// the requests that did not succeed, including those outside the key's
+// scopes.
+type ActionUsage struct {
+	Action     string    `json:"action"`
+	Requests   int       `json:"requests"`
+	Failures   int       `json:"failures"`
+	LastUsedAt time.Time `json:"last_used_at"`
+}
+
+// Allows reports whether the key may run action.
+func (k *APIKey) Allows(action string) bool {
+	for _, s := range k.Scopes {
+		if s == ScopeAll || s == action {
+			return true
+		}
+	}
+	return false
+}
+
+// Active reports whether the key can still be used at now.
+func (k *APIKey) Active(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+// CreateAPIKey issues a key for a user and returns it with its secret, which
+// is not stored and cannot be shown again. A zero ttl never expires.
+func (s *Store) CreateAPIKey(userID, name string, scopes []string, ttl time.Duration) (*APIKey, string, error) {
+	if len(scopes) == 0 {
+		return nil, "", fmt.Errorf("an API key needs at least one scope")
+	}
+	now := time.Now().UTC()
+	secret := keyPrefix + newToken()
+	k := &APIKey{
+		ID:        storeutil.NewID(),
+		UserID:    userID,
+		Name:      strings.TrimSpace(name),
+		Prefix:    secret[:len(keyPrefix)+8],
+		Scopes:    scopes,
+		CreatedAt: now,
+		Usage:     []ActionUsage{},
+	}
+	if ttl > 0 {
+		expires := now.Add(ttl)
+		k.ExpiresAt = &expires
+	}
+	var expires int64
+	if k.ExpiresAt != nil {
+		expires = k.ExpiresAt.UnixMilli()
+	}
+	_, err := s.db.Exec(`INSERT INTO api_keys (id, user_id, name, prefix, key_hash, scopes, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		k.ID, k.UserID, k.Name, k.Prefix, hashToken(secret), strings.Join(scopes, ","), now.UnixMilli(), expires)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to save API key: %w", err)
+	}
+	return k, secret, nil
+}
+
+// APIKeys lists the keys of a user, or of everyone when userID is empty,
+// newest first and with their usage.
+func (s *Store) APIKeys(userID string) ([]APIKey, error) {
+	query := `SELECT ` + keyColumns + ` FROM api_keys`
+	var args []interface{}
+	if userID != "" {
+		query += ` WHERE user_id = ?`
+		args = append(args, userID)
+	}
+	rows, err := s.db.Query(query+` ORDER BY created_at DESC, rowid DESC`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+	keys := []APIKey{}
+	index := map[string]int{}
+	for rows.Next() {
+		k, err := scanKey(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		index[k.ID] = len(keys)
+		keys = append(keys, *k)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	usage, err := s.db.Query(`SELECT key_id, action, requests, failures, last_used_at FROM api_key_usage ORDER BY key_id, action`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load API key usage: %w", err)
+	}
+	defer usage.Close()
+	for usage.Next() {
+		var keyID string
+		var u ActionUsage
+		var at int64
+		if err := usage.Scan(&keyID, &u.Action, &u.Requests, &u.Failures, &at); err != nil {
+			return nil, err
+		}
+		if i, ok := index[keyID]; ok {
+			u.LastUsedAt = storeutil.FromMillis(at)
+			keys[i].Usage = append(keys[i].Usage, u)
+		}
+	}
+	return keys, usage.Err()
+}
+
+// APIKey returns a single key, without its usage.
+func (s *Store) APIKey(id string) (*APIKey, error) {
+	k, err := scanKey(s.db.QueryRow(`SELECT `+keyColumns+` FROM api_keys WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load API key: %w", err)
+	}
+	return k, nil
+}
+
+// RevokeAPIKey stops a key from working. Revoking twice is not an error.
+func (s *Store) RevokeAPIKey(id string) error {
+	res, err := s.db.Exec(`UPDATE api_keys SET revoked_at = CASE WHEN revoked_at = 0 THEN ? ELSE revoked_at END WHERE id = ?`, time.Now().UnixMilli(), id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrKeyNotFound
+	}
+	return nil
+}
+
+// AuthenticateKey returns an active key and the user it belongs to.
+func (s *Store) AuthenticateKey(secret string) (*APIKey, *User, error) {
+	if !strings.HasPrefix(secret, keyPrefix) {
+		return nil, nil, ErrInvalidKey
+	}
+	k, err := scanKey(s.db.QueryRow(`SELECT `+keyColumns+` FROM api_keys WHERE key_hash = ?`, hashToken(secret)))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, ErrInvalidKey
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load API key: %w", err)
+	}
+	if !k.Active(time.Now()) {
+		return nil, nil, ErrInvalidKey
+	}
+	u, err := s.UserByID(k.UserID)
+	if errors.Is(err, ErrUserNotFound) {
+		return nil, nil, ErrInvalidKey
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return k, u, nil
+}
+
+// RecordKeyUsage counts one request made with a key.
+func (s *Store) RecordKeyUsage(keyID, action string, ok bool) error {
+	now := time.Now().UnixMilli()
+	failed := 0
+	if !ok {
+		failed = 1
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`INSERT INTO api_key_usage (key_id, action, requests, failures, last_used_at) VALUES (?, ?, 1, ?, ?)
+		ON CONFLICT (key_id, action) DO UPDATE SET requests = requests + 1, failures = failures + excluded.failures, last_used_at = excluded.last_used_at`,
+		keyID, action, failed, now); err != nil {
+		return fmt.Errorf("failed to record API key usage: %w", err)
+	}
+	if _, err := tx.Exec(`UPDATE api_keys SET last_used_at = ? WHERE id = ?`, now, keyID); err != nil {
+		return fmt.Errorf("failed to record API key usage: %w", err)
+	}
+	return tx.Commit()
+}
+
+const keyColumns = "id, user_id, name, prefix, scopes, created_at, expires_at, revoked_at, last_used_at"
+
+func scanKey(row storeutil.RowScanner) (*APIKey, error) {
+	var k APIKey
+	var scopes string
+	var created, expires, revoked, used int64
+	if err := row.Scan(&k.ID, &k.UserID, &k.Name, &k.Prefix, &scopes, &created, &expires, &revoked, &used); err != nil {
+		return nil, err
+	}
+	k.Scopes = strings.Split(scopes, ",")
+	k.CreatedAt = storeutil.FromMillis(created)
+	k.ExpiresAt, k.RevokedAt, k.LastUsedAt = storeutil.OptionalMillis(expires), storeutil.OptionalMillis(revoked), storeutil.OptionalMillis(used)
+	k.Usage = []ActionUsage{}
+	return &k, nil
+}
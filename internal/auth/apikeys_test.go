@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAPIKeyAllows(t *testing.T) {
+	tests := []struct {
+		scopes []string
+		action string
+		want   bool
+	}{
+		{[]string{"humanize"}, "humanize", true},
+		{[]string{"humanize"}, "detect", false},
+		{[]string{"detect", "plagiarize"}, "plagiarize", true},
+		{[]string{ScopeAll}, "research", true},
+		{[]string{"humanize"}, "", false},
+	}
+	for _, tt := range tests {
+		k := &APIKey{Scopes: tt.scopes}
+		if got := k.Allows(tt.action); got != tt.want {
+			t.Errorf("key with scopes %v: Allows(%q) = %v, want %v", tt.scopes, tt.action, got, tt.want)
+		}
+	}
+}
+
+func TestCreateAPIKeyNeedsScope(t *testing.T) {
+	s := newTestStore(t)
+	if _, _, err := s.CreateAPIKey("someone", "ci", nil, 0); err == nil {
+		t.Error("CreateAPIKey without scopes succeeded")
+	}
+}
+
+func TestAuthenticateKey(t *testing.T) {
+	s := newTestStore(t)
+	u, err := s.CreateUser("alice", "correct horse", RoleUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKey := func(ttl time.Duration) (*APIKey, string) {
+		t.Helper()
+		k, secret, err := s.CreateAPIKey(u.ID, "ci", []string{"humanize"}, ttl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k, secret
+	}
+
+	k, secret := newKey(0)
+	got, user, err := s.AuthenticateKey(secret)
+	if err != nil {
+		t.Fatalf("AuthenticateKey: %v", err)
+	}
+	if got.ID != k.ID || user.ID != u.ID {
+		t.Errorf("AuthenticateKey = key %s of user %s, want key %s of user %s", got.ID, user.ID, k.ID, u.ID)
+	}
+	if got.Allows("detect") {
+		t.Error("stored key lost its scopes")
+	}
+
+	for _, secret := range []string{"", "not-a-key", keyPrefix + "unknown", secret + "x"} {
+		if _, _, err := s.AuthenticateKey(secret); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("AuthenticateKey(%q) = %v, want ErrInvalidKey", secret, err)
+		}
+	}
+
+	t.Run("revoked", func(t *testing.T) {
+		k, secret := newKey(0)
+		if err := s.RevokeAPIKey(k.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := s.AuthenticateKey(secret); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("AuthenticateKey = %v, want ErrInvalidKey", err)
+		}
+		// Revoking twice keeps the original revocation time.
+		first, err := s.APIKey(k.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.RevokeAPIKey(k.ID); err != nil {
+			t.Fatalf("second RevokeAPIKey: %v", err)
+		}
+		second, err := s.APIKey(k.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !first.RevokedAt.Equal(*second.RevokedAt) {
+			t.Errorf("revocation time moved from %v to %v", first.RevokedAt, second.RevokedAt)
+		}
+		if err := s.RevokeAPIKey("unknown"); !errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("RevokeAPIKey of an unknown key = %v, want ErrKeyNotFound", err)
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		k, secret := newKey(time.Hour)
+		if k.ExpiresAt == nil {
+			t.Fatal("key with a ttl has no expiry")
+		}
+		if _, _, err := s.AuthenticateKey(secret); err != nil {
+			t.Fatalf("AuthenticateKey before expiry: %v", err)
+		}
+		past := time.Now().Add(-time.Minute).UnixMilli()
+		if _, err := s.db.Exec(`UPDATE api_keys SET expires_at = ? WHERE id = ?`, past, k.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := s.AuthenticateKey(secret); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("AuthenticateKey after expiry = %v, want ErrInvalidKey", err)
+		}
+	})
+
+	t.Run("deleted user", func(t *testing.T) {
+		bob, err := s.CreateUser("bob", "correct horse", RoleUser)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, secret, err := s.CreateAPIKey(bob.ID, "ci", []string{ScopeAll}, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.DeleteUser(bob.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := s.AuthenticateKey(secret); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("AuthenticateKey = %v, want ErrInvalidKey", err)
+		}
+	})
+}
@@ -0,0 +1,252 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	SessionCookie = "rephrase_session"
+	SessionTTL    = 14 * 24 * time.Hour
+
+	// After maxLoginFailures failed logins for one username from one address
+	// within loginWindow, further attempts are refused until it has passed.
+	maxLoginFailures = 5
+	loginWindow      = 15 * time.Minute
+)
+
+var ErrTooManyAttempts = errors.New("too many failed logins; try again later")
+
+type contextKey struct{}
+
+type apiKeyContextKey struct{}
+
+// UserFromContext returns the user an authenticated request was made by.
+func UserFromContext(ctx context.Context) (*User, bool) {
+	u, ok := ctx.Value(contextKey{}).(*User)
+	return u, ok
+}
+
+// WithUser returns a copy of ctx carrying u.
+func WithUser(ctx context.Context, u *User) context.Context {
+	return context.WithValue(ctx, contextKey{}, u)
+}
+
+// KeyFromContext returns the API key a request was authenticated with, if it
+// was made with one rather than a session.
+func KeyFromContext(ctx context.Context) (*APIKey, bool) {
+	k, ok := ctx.Value(apiKeyContextKey{}).(*APIKey)
+	return k, ok
+}
+
+// Authenticator logs users in and out with session cookies and guards
+// handlers that need a signed-in user.
+type Authenticator struct {
+	Store *Store
+
+	mu       sync.Mutex
+	failures map[string]*loginFailures
+}
+
+type loginFailures struct {
+	count int
+	first time.Time
+}
+
+func NewAuthenticator(store *Store) *Authenticator {
+	return &Authenticator{Store: store, failures: make(map[string]*loginFailures)}
+}
+
+// Login checks credentials and, when they match, starts a session and sets
+// its cookie on w. Repeated failures for the same username and address are
+// throttled.
+func (a *Authenticator) Login(w http.ResponseWriter, r *http.Request, username, password string) (*User, error) {
+	key := strings.ToLower(strings.TrimSpace(username)) + "|" + clientIP(r)
+	if a.throttled(key) {
+		return nil, ErrTooManyAttempts
+	}
+	u, err := a.Store.Authenticate(username, password)
+	if errors.Is(err, ErrInvalidCredentials) {
+		a.recordFailure(key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	a.clearFailures(key)
+	if err := a.StartSession(w, r, u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// StartSession signs u in on the client making r.
+func (a *Authenticator) StartSession(w http.ResponseWriter, r *http.Request, u *User) error {
+	token, expires, err := a.Store.CreateSession(u.ID, SessionTTL)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		// Lax keeps the cookie off cross-site POSTs, which is what stops
+		// other sites from spending the quota on a user's behalf.
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+// Logout ends the session r was made with and clears its cookie.
+func (a *Authenticator) Logout(w http.ResponseWriter, r *http.Request) error {
+	if c, err := r.Cookie(SessionCookie); err == nil {
+		if err := a.Store.DeleteSession(c.Value); err != nil {
+			return err
+		}
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookie,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+// User returns the signed-in user r was made by.
+func (a *Authenticator) User(r *http.Request) (*User, error) {
+	c, err := r.Cookie(SessionCookie)
+	if err != nil || c.Value == "" {
+		return nil, ErrNoSession
+	}
+	return a.Store.SessionUser(c.Value)
+}
+
+// Require lets requests from signed-in users through to next, with the user
+// in the request context, and answers everything else with 401.
+func (a *Authenticator) Require(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := bearerToken(r); ok {
+			writeError(w, "API keys can only be used with /api/process", http.StatusForbidden)
+			return
+		}
+		u, err := a.User(r)
+		if err != nil {
+			if !errors.Is(err, ErrNoSession) {
+				log.Printf("Failed to check session: %v", err)
+			}
+			writeError(w, "Authentication required", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithUser(r.Context(), u)))
+	})
+}
+
+// RequireWithKeys is like Require but also accepts an API key sent as
+// "Authorization: Bearer <key>". The key's user goes in the request context
+// along with the key, whose scopes are left to next to check.
+func (a *Authenticator) RequireWithKeys(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secret, ok := bearerToken(r)
+		if !ok {
+			a.Require(next).ServeHTTP(w, r)
+			return
+		}
+		k, u, err := a.Store.AuthenticateKey(secret)
+		if err != nil {
+			if !errors.Is(err, ErrInvalidKey) {
+				log.Printf("Failed to check API key: %v", err)
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			writeError(w, "Invalid, expired or revoked API key", http.StatusUnauthorized)
+			return
+		}
+		ctx := context.WithValue(WithUser(r.Context(), u), apiKeyContextKey{}, k)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequireRole is like Require but also needs the user to have role.
+func (a *Authenticator) RequireRole(role string, next http.Handler) http.Handler {
+	return a.Require(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, _ := UserFromContext(r.Context()); u.Role != role {
+			writeError(w, "You do not have permission to do this", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}))
+}
+
+func (a *Authenticator) throttled(key string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	f, ok := a.failures[key]
+	if !ok {
+		return false
+	}
+	if time.Since(f.first) > loginWindow {
+		delete(a.failures, key)
+		return false
+	}
+	return f.count >= maxLoginFailures
+}
+
+func (a *Authenticator) recordFailure(key string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := time.Now()
+	for k, f := range a.failures {
+		if now.Sub(f.first) > loginWindow {
+			delete(a.failures, k)
+		}
+	}
+	f, ok := a.failures[key]
+	if !ok {
+		f = &loginFailures{first: now}
+		a.failures[key] = f
+	}
+	f.count++
+}
+
+func (a *Authenticator) clearFailures(key string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.failures, key)
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func isHTTPS(r *http.Request) bool {
+	return r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+}
+
+func writeError(w http.ResponseWriter, message string, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLoginThrottling(t *testing.T) {
+	s := newTestStore(t)
+	if _, err := s.CreateUser("alice", "correct horse", RoleUser); err != nil {
+		t.Fatal(err)
+	}
+	a := NewAuthenticator(s)
+	login := func(addr, username, password string) error {
+		r := httptest.NewRequest(http.MethodPost, "/api/auth/login", nil)
+		r.RemoteAddr = addr
+		_, err := a.Login(httptest.NewRecorder(), r, username, password)
+		return err
+	}
+	const addr, other = "192.0.2.1:1234", "192.0.2.2:1234"
+
+	for i := 0; i < maxLoginFailures; i++ {
+		if err := login(addr, "alice", "wrong password"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("failed login %d = %v, want ErrInvalidCredentials", i+1, err)
+		}
+	}
+	// Once throttled, even the right password is refused, whatever the
+	// username's case, but only from that address.
+	if err := login(addr, "alice", "correct horse"); !errors.Is(err, ErrTooManyAttempts) {
+		t.Errorf("login after %d failures = %v, want ErrTooManyAttempts", maxLoginFailures, err)
+	}
+	if err := login("192.0.2.1:5678", "ALICE", "correct horse"); !errors.Is(err, ErrTooManyAttempts) {
+		t.Errorf("login from another port = %v, want ErrTooManyAttempts", err)
+	}
+	if err := login(other, "alice", "correct horse"); err != nil {
+		t.Errorf("login from another address: %v", err)
+	}
+
+	// A successful login forgets earlier failures.
+	for i := 0; i < maxLoginFailures-1; i++ {
+		login(other, "alice", "wrong password")
+	}
+	if err := login(other, "alice", "correct horse"); err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if err := login(other, "alice", "wrong password"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("failed login after a success = %v, want ErrInvalidCredentials", err)
+	}
+}
+
+func TestLoginSetsSessionCookie(t *testing.T) {
+	s := newTestStore(t)
+	u, err := s.CreateUser("alice", "correct horse", RoleUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := NewAuthenticator(s)
+	w := httptest.NewRecorder()
+	if _, err := a.Login(w, httptest.NewRequest(http.MethodPost, "/api/auth/login", nil), "alice", "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != SessionCookie || !cookies[0].HttpOnly {
+		t.Fatalf("cookies = %v, want one HTTP-only session cookie", cookies)
+	}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(cookies[0])
+	got, err := a.User(r)
+	if err != nil || got.ID != u.ID {
+		t.Errorf("User = %v, %v, want %s", got, err, u.ID)
+	}
+}
+
+func TestRequire(t *testing.T) {
+	s := newTestStore(t)
+	alice, err := s.CreateUser("alice", "correct horse", RoleUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin, err := s.CreateUser("root", "correct horse", RoleAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := func(u *User) *http.Cookie {
+		token, _, err := s.CreateSession(u.ID, SessionTTL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &http.Cookie{Name: SessionCookie, Value: token}
+	}
+	aliceSession, adminSession := session(alice), session(admin)
+	_, secret, err := s.CreateAPIKey(alice.ID, "ci", []string{"humanize"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked, revokedSecret, err := s.CreateAPIKey(alice.ID, "old", []string{"humanize"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RevokeAPIKey(revoked.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	a := NewAuthenticator(s)
+	// The handler reports who the request reached it as, and whether it came
+	// with an API key.
+	var user string
+	var viaKey bool
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, _ := UserFromContext(r.Context())
+		_, viaKey = KeyFromContext(r.Context())
+		user = u.ID
+	})
+	tests := []struct {
+		name    string
+		handler http.Handler
+		cookie  *http.Cookie
+		bearer  string
+		status  int
+		user    string
+		viaKey  bool
+	}{
+		{"Require: no credentials", a.Require(next), nil, "", http.StatusUnauthorized, "", false},
+		{"Require: unknown session", a.Require(next), &http.Cookie{Name: SessionCookie, Value: "unknown"}, "", http.StatusUnauthorized, "", false},
+		{"Require: session", a.Require(next), aliceSession, "", http.StatusOK, alice.ID, false},
+		{"Require: API key", a.Require(next), nil, secret, http.StatusForbidden, "", false},
+		{"Require: API key with a session", a.Require(next), aliceSession, secret, http.StatusForbidden, "", false},
+
+		{"RequireWithKeys: no credentials", a.RequireWithKeys(next), nil, "", http.StatusUnauthorized, "", false},
+		{"RequireWithKeys: session", a.RequireWithKeys(next), aliceSession, "", http.StatusOK, alice.ID, false},
+		{"RequireWithKeys: API key", a.RequireWithKeys(next), nil, secret, http.StatusOK, alice.ID, true},
+		{"RequireWithKeys: revoked key", a.RequireWithKeys(next), nil, revokedSecret, http.StatusUnauthorized, "", false},
+		{"RequireWithKeys: invalid key with a session", a.RequireWithKeys(next), aliceSession, "rph_unknown", http.StatusUnauthorized, "", false},
+
+		{"RequireRole: other role", a.RequireRole(RoleAdmin, next), aliceSession, "", http.StatusForbidden, "", false},
+		{"RequireRole: role", a.RequireRole(RoleAdmin, next), adminSession, "", http.StatusOK, admin.ID, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, viaKey = "", false
+			r := httptest.NewRequest(http.MethodPost, "/api/process", nil)
+			if tt.cookie != nil {
+				r.AddCookie(tt.cookie)
+			}
+			if tt.bearer != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.bearer)
+			}
+			w := httptest.NewRecorder()
+			tt.handler.ServeHTTP(w, r)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d (%s)", w.Code, tt.status, w.Body.String())
+			}
+			if user != tt.user || viaKey != tt.viaKey {
+				t.Errorf("handler saw user %q (via key %v), want %q (via key %v)", user, viaKey, tt.user, tt.viaKey)
+			}
+			if tt.status == http.StatusUnauthorized && tt.bearer != "" && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("a rejected key got no WWW-Authenticate header")
+			}
+		})
+	}
+}
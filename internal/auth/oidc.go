@@ -0,0 +1,240 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// oidcCookie carries the state, nonce and PKCE verifier of a sign-in in
+	// progress from the redirect to the provider to the callback.
+	oidcCookie = "rephrase_oidc"
+	oidcTTL    = 10 * time.Minute
+
+	DefaultGroupsClaim = "groups"
+)
+
+var (
+	ErrSignInExpired = errors.New("the sign-in expired or was started in another browser; please try again")
+	ErrNotPermitted  = errors.New("your account is not in a group that may use this app")
+)
+
+// OIDCConfig describes an OpenID Connect provider and how its users map to
+// accounts here.
+type OIDCConfig struct {
+	// Name is what the login page calls the provider, e.g. "Company SSO".
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is this server's /api/auth/oidc/callback as the provider
+	// reaches it, and must be registered with the provider.
+	RedirectURL string
+	// GroupsClaim names the ID token or userinfo claim that lists the
+	// user's groups.
+	GroupsClaim string
+	// Members of any of AdminGroups sign in as admins. When AllowedGroups
+	// is set, everyone else must be in one of them to sign in at all.
+	AdminGroups   []string
+	AllowedGroups []string
+}
+
+// OIDC signs users in through an OpenID Connect provider with the
+// authorization code flow, using PKCE and a nonce. Users it signs in get
+// their own accounts, next to the local ones, and the same session cookies.
+type OIDC struct {
+	Config OIDCConfig
+
+	auth     *Authenticator
+	provider *oidc.Provider
+	verifier *oidc.IDTokenVerifier
+	oauth    oauth2.Config
+}
+
+// NewOIDC looks up the provider's endpoints and keys through its discovery
+// document.
+func NewOIDC(ctx context.Context, cfg OIDCConfig, a *Authenticator) (*OIDC, error) {
+	if cfg.Issuer == "" || cfg.ClientID == "" || cfg.RedirectURL == "" {
+		return nil, fmt.Errorf("an issuer, client ID and redirect URL are required")
+	}
+	if cfg.Name == "" {
+		cfg.Name = "single sign-on"
+	}
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = DefaultGroupsClaim
+	}
+	provider, err := oidc.NewProvider(ctx, cfg.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover OpenID provider %s: %w", cfg.Issuer, err)
+	}
+	return &OIDC{
+		Config:   cfg,
+		auth:     a,
+		provider: provider,
+		verifier: provider.Verifier(&oidc.Config{ClientID: cfg.ClientID}),
+		oauth: oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			Endpoint:     provider.Endpoint(),
+			RedirectURL:  cfg.RedirectURL,
+			Scopes:       []string{oidc.ScopeOpenID, "profile", "email"},
+		},
+	}, nil
+}
+
+type oidcAttempt struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+}
+
+// Begin sends the browser to the provider to sign in.
+func (o *OIDC) Begin(w http.ResponseWriter, r *http.Request) {
+	attempt := oidcAttempt{State: newToken(), Nonce: newToken(), Verifier: oauth2.GenerateVerifier()}
+	value, _ := json.Marshal(attempt)
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcCookie,
+		Value:    base64.RawURLEncoding.EncodeToString(value),
+		Path:     "/api/auth/oidc/",
+		MaxAge:   int(oidcTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		// The provider sends the browser back with a top-level GET, which
+		// Lax still sends the cookie with.
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, o.oauth.AuthCodeURL(attempt.State, oidc.Nonce(attempt.Nonce), oauth2.S256ChallengeOption(attempt.Verifier)), http.StatusFound)
+}
+
+// Finish completes a sign-in the provider has sent the browser back from:
+// it checks the request belongs to the attempt Begin started, redeems the
+// code, verifies the ID token, and starts a session for the matching
+// account.
+func (o *OIDC) Finish(w http.ResponseWriter, r *http.Request) (*User, error) {
+	attempt, err := o.attempt(r)
+	http.SetCookie(w, &http.Cookie{Name: oidcCookie, Path: "/api/auth/oidc/", MaxAge: -1, HttpOnly: true, Secure: isHTTPS(r), SameSite: http.SameSiteLaxMode})
+	if err != nil {
+		return nil, err
+	}
+	query := r.URL.Query()
+	if query.Get("state") != attempt.State {
+		return nil, ErrSignInExpired
+	}
+	if e := query.Get("error"); e != "" {
+		if d := query.Get("error_description"); d != "" {
+			e += ": " + d
+		}
+		return nil, fmt.Errorf("the identity provider refused the sign-in (%s)", e)
+	}
+
+	ctx := r.Context()
+	token, err := o.oauth.Exchange(ctx, query.Get("code"), oauth2.VerifierOption(attempt.Verifier))
+	if err != nil {
+		return nil, fmt.Errorf("failed to redeem the authorization code: %w", err)
+	}
+	rawID, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, fmt.Errorf("the identity provider returned no ID token")
+	}
+	idToken, err := o.verifier.Verify(ctx, rawID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID token: %w", err)
+	}
+	if idToken.Nonce != attempt.Nonce {
+		return nil, fmt.Errorf("invalid ID token: nonce does not match")
+	}
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("invalid ID token claims: %w", err)
+	}
+	// Some providers only list groups at the userinfo endpoint.
+	if _, ok := claims[o.Config.GroupsClaim]; !ok {
+		if info, err := o.provider.UserInfo(ctx, oauth2.StaticTokenSource(token)); err == nil && info.Subject == idToken.Subject {
+			var extra map[string]interface{}
+			if info.Claims(&extra) == nil {
+				for k, v := range extra {
+					if _, ok := claims[k]; !ok {
+						claims[k] = v
+					}
+				}
+			}
+		}
+	}
+
+	role, err := o.role(stringList(claims[o.Config.GroupsClaim]))
+	if err != nil {
+		return nil, err
+	}
+	name, _ := claims["preferred_username"].(string)
+	if name == "" {
+		name, _ = claims["email"].(string)
+	}
+	u, err := o.auth.Store.ExternalUser(idToken.Issuer, idToken.Subject, name, role)
+	if err != nil {
+		return nil, err
+	}
+	if err := o.auth.StartSession(w, r, u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func (o *OIDC) attempt(r *http.Request) (*oidcAttempt, error) {
+	c, err := r.Cookie(oidcCookie)
+	if err != nil {
+		return nil, ErrSignInExpired
+	}
+	value, err := base64.RawURLEncoding.DecodeString(c.Value)
+	if err != nil {
+		return nil, ErrSignInExpired
+	}
+	var attempt oidcAttempt
+	if err := json.Unmarshal(value, &attempt); err != nil || attempt.State == "" {
+		return nil, ErrSignInExpired
+	}
+	return &attempt, nil
+}
+
+// role maps a user's groups to the role they sign in with.
+func (o *OIDC) role(groups []string) (string, error) {
+	in := func(names []string) bool {
+		return slices.ContainsFunc(groups, func(g string) bool { return slices.Contains(names, g) })
+	}
+	switch {
+	case in(o.Config.AdminGroups):
+		return RoleAdmin, nil
+	case len(o.Config.AllowedGroups) == 0 || in(o.Config.AllowedGroups):
+		return RoleUser, nil
+	default:
+		return "", ErrNotPermitted
+	}
+}
+
+// stringList reads a claim that holds either a list of strings or a single
+// space- or comma-separated string.
+func stringList(v interface{}) []string {
+	switch v := v.(type) {
+	case []interface{}:
+		var out []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	case string:
+		return strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == ' ' })
+	default:
+		return nil
+	}
+}
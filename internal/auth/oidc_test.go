@@ -0,0 +1,206 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/victor-butita/rephrase/internal/auth/oidctest"
+)
+
+const testRedirectURL = "http://rephrase.test/api/auth/oidc/callback"
+
+// newTestOIDC serves a stand-in provider, configured by configure, and signs
+// in through it with admins in rephrase-admins and everyone else required to
+// be in staff.
+func newTestOIDC(t *testing.T, s *Store, configure func(*oidctest.Provider)) *OIDC {
+	t.Helper()
+	p, err := oidctest.New("rephrase", "secret", testRedirectURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if configure != nil {
+		configure(p)
+	}
+	srv := httptest.NewServer(p)
+	t.Cleanup(srv.Close)
+	p.Issuer = srv.URL
+
+	o, err := NewOIDC(context.Background(), OIDCConfig{
+		Issuer:        srv.URL,
+		ClientID:      "rephrase",
+		ClientSecret:  "secret",
+		RedirectURL:   testRedirectURL,
+		AdminGroups:   []string{"rephrase-admins"},
+		AllowedGroups: []string{"staff"},
+	}, NewAuthenticator(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
+
+// signIn goes through a whole sign-in as the browser would, submitting the
+// provider's sign-in form with form. When set, tamperAuthorize and
+// tamperCallback change the parameters sent to the provider and back to
+// the callback.
+func signIn(t *testing.T, o *OIDC, form url.Values, tamperAuthorize, tamperCallback func(url.Values)) (*User, error) {
+	t.Helper()
+	begin := httptest.NewRecorder()
+	o.Begin(begin, httptest.NewRequest(http.MethodGet, "/api/auth/oidc/login", nil))
+	authorize, err := url.Parse(begin.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := authorize.Query()
+	if tamperAuthorize != nil {
+		tamperAuthorize(params)
+	}
+	for k, v := range form {
+		params[k] = v
+	}
+	authorize.RawQuery = ""
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.PostForm(authorize.String(), params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("provider sign-in: status %d", resp.StatusCode)
+	}
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tamperCallback != nil {
+		query := callback.Query()
+		tamperCallback(query)
+		callback.RawQuery = query.Encode()
+	}
+
+	r := httptest.NewRequest(http.MethodGet, callback.String(), nil)
+	for _, c := range begin.Result().Cookies() {
+		r.AddCookie(c)
+	}
+	return o.Finish(httptest.NewRecorder(), r)
+}
+
+func TestOIDCSignIn(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(*oidctest.Provider)
+		groups    string
+		wantRole  string
+		wantErr   error
+	}{
+		{name: "admin", groups: "staff, rephrase-admins", wantRole: RoleAdmin},
+		{name: "user", groups: "staff", wantRole: RoleUser},
+		{name: "refused", groups: "contractors", wantErr: ErrNotPermitted},
+		{name: "no groups", wantErr: ErrNotPermitted},
+		{
+			name:      "groups as a string",
+			configure: func(p *oidctest.Provider) { p.GroupsAsString = true },
+			groups:    "contractors,rephrase-admins",
+			wantRole:  RoleAdmin,
+		},
+		{
+			name:      "groups as a string refused",
+			configure: func(p *oidctest.Provider) { p.GroupsAsString = true },
+			groups:    "contractors",
+			wantErr:   ErrNotPermitted,
+		},
+		{
+			name:      "groups in userinfo",
+			configure: func(p *oidctest.Provider) { p.GroupsInUserinfo = true },
+			groups:    "rephrase-admins",
+			wantRole:  RoleAdmin,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStore(t)
+			o := newTestOIDC(t, s, tt.configure)
+			u, err := signIn(t, o, url.Values{"username": {"alice"}, "groups": {tt.groups}}, nil, nil)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("sign-in = %v, want %v", err, tt.wantErr)
+				}
+				if n, _ := s.CountUsers(); n != 0 {
+					t.Errorf("refused sign-in created %d accounts", n)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("sign-in: %v", err)
+			}
+			if u.Username != "alice" || u.Role != tt.wantRole || !u.SSO {
+				t.Errorf("signed in as %q with role %q (SSO %v), want alice with role %q", u.Username, u.Role, u.SSO, tt.wantRole)
+			}
+		})
+	}
+}
+
+func TestOIDCRoleFollowsProvider(t *testing.T) {
+	s := newTestStore(t)
+	o := newTestOIDC(t, s, nil)
+	first, err := signIn(t, o, url.Values{"username": {"alice"}, "groups": {"rephrase-admins"}}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := signIn(t, o, url.Values{"username": {"alice"}, "groups": {"staff"}}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != first.ID || again.Role != RoleUser {
+		t.Errorf("second sign-in = account %s with role %q, want %s with role %q", again.ID, again.Role, first.ID, RoleUser)
+	}
+}
+
+func TestOIDCRejectsForgedCallback(t *testing.T) {
+	s := newTestStore(t)
+	o := newTestOIDC(t, s, nil)
+	form := url.Values{"username": {"alice"}, "groups": {"staff"}}
+
+	_, err := signIn(t, o, form, nil, func(q url.Values) { q.Set("state", "forged") })
+	if !errors.Is(err, ErrSignInExpired) {
+		t.Errorf("sign-in with another state = %v, want ErrSignInExpired", err)
+	}
+	_, err = signIn(t, o, form, func(q url.Values) { q.Set("nonce", "replayed") }, nil)
+	if err == nil || !strings.Contains(err.Error(), "nonce") {
+		t.Errorf("sign-in with another nonce = %v, want a nonce error", err)
+	}
+	if n, _ := s.CountUsers(); n != 0 {
+		t.Errorf("rejected sign-ins created %d accounts", n)
+	}
+}
+
+func TestOIDCKeepsLocalAccount(t *testing.T) {
+	s := newTestStore(t)
+	local, err := s.CreateUser("alice", "correct horse", RoleAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := newTestOIDC(t, s, nil)
+	u, err := signIn(t, o, url.Values{"username": {"alice"}, "groups": {"staff"}}, nil, nil)
+	if err != nil {
+		t.Fatalf("sign-in: %v", err)
+	}
+	if u.ID == local.ID || !strings.HasPrefix(u.Username, "alice-") || u.Role != RoleUser {
+		t.Errorf("signed in as %s %q with role %q, want a new user account named alice-...", u.ID, u.Username, u.Role)
+	}
+
+	// The local account keeps its password and role.
+	got, err := s.Authenticate("alice", "correct horse")
+	if err != nil {
+		t.Fatalf("local login: %v", err)
+	}
+	if got.ID != local.ID || got.Role != RoleAdmin || got.SSO {
+		t.Errorf("local login = %s with role %q (SSO %v), want %s as admin", got.ID, got.Role, got.SSO, local.ID)
+	}
+}
@@ -0,0 +1,328 @@
+// Package oidctest is a minimal OpenID Connect provider for trying out and
+// testing single sign-on without a real identity provider. Its sign-in page
+// asks for a username, email and groups instead of a password and issues
+// signed ID tokens for whatever is entered, so it must never be exposed
+// beyond the local machine.
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+const (
+	codeTTL  = time.Minute
+	tokenTTL = time.Hour
+)
+
+// Provider is an OpenID Connect provider that signs in whoever its sign-in
+// form names. Set Issuer to the URL it is served at, without a trailing
+// slash, before using it.
+type Provider struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the only redirect URL the provider accepts.
+	RedirectURL string
+	// GroupsInUserinfo leaves groups out of the ID token so that only the
+	// userinfo endpoint lists them, as some providers do.
+	GroupsInUserinfo bool
+	// GroupsAsString lists groups as one comma-separated string rather
+	// than a list of strings, as some providers do.
+	GroupsAsString bool
+
+	signer jose.Signer
+	keys   jose.JSONWebKeySet
+	mux    *http.ServeMux
+
+	mu     sync.Mutex
+	codes  map[string]*grant
+	tokens map[string]map[string]interface{}
+}
+
+// New creates a provider for one client, with a fresh signing key.
+func New(clientID, clientSecret, redirectURL string) (*Provider, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	kid := randomString(8)
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", kid))
+	if err != nil {
+		return nil, err
+	}
+	p := &Provider{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+		signer:       signer,
+		keys:         jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: &key.PublicKey, KeyID: kid, Algorithm: "RS256", Use: "sig"}}},
+		codes:        make(map[string]*grant),
+		tokens:       make(map[string]map[string]interface{}),
+	}
+	p.mux = http.NewServeMux()
+	p.mux.HandleFunc("/.well-known/openid-configuration", p.discovery)
+	p.mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) { writeJSON(w, p.keys, http.StatusOK) })
+	p.mux.HandleFunc("/authorize", p.authorize)
+	p.mux.HandleFunc("/token", p.token)
+	p.mux.HandleFunc("/userinfo", p.userinfo)
+	return p, nil
+}
+
+func (p *Provider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mux.ServeHTTP(w, r)
+}
+
+// grant is an authorization code waiting to be redeemed.
+type grant struct {
+	claims        map[string]interface{}
+	nonce         string
+	codeChallenge string
+	redirectURI   string
+	expires       time.Time
+}
+
+func (p *Provider) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]interface{}{
+		"issuer":                                p.Issuer,
+		"authorization_endpoint":                p.Issuer + "/authorize",
+		"token_endpoint":                        p.Issuer + "/token",
+		"userinfo_endpoint":                     p.Issuer + "/userinfo",
+		"jwks_uri":                              p.Issuer + "/keys",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"scopes_supported":                      []string{"openid", "profile", "email"},
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"claims_supported":                      []string{"sub", "preferred_username", "email", "name", "groups"},
+	}, http.StatusOK)
+}
+
+var signInPage = template.Must(template.New("signin").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="UTF-8"><title>OpenID Connect stand-in</title>
+<style>body{font-family:sans-serif;max-width:28rem;margin:4rem auto}label{display:block;margin-top:1rem}input{width:100%;padding:.4rem}button{margin-top:1.5rem;padding:.5rem 1rem}</style>
+</head>
+<body>
+<h1>Sign in</h1>
+<p>Local OpenID Connect stand-in: whoever you enter here is signed in.</p>
+<form method="post" action="/authorize">
+{{range $name, $value := .Params}}<input type="hidden" name="{{$name}}" value="{{$value}}">
+{{end}}<label>Username <input name="username" required autofocus></label>
+<label>Email <input name="email" type="email"></label>
+<label>Groups (comma-separated) <input name="groups"></label>
+<button type="submit">Sign in</button>
+</form>
+</body>
+</html>
+`))
+
+// authorize shows the sign-in page for an authorization request and, when
+// it is submitted, redirects back to the client with a code.
+func (p *Provider) authorize(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	params := map[string]string{}
+	for _, name := range []string{"client_id", "redirect_uri", "response_type", "scope", "state", "nonce", "code_challenge", "code_challenge_method"} {
+		params[name] = r.Form.Get(name)
+	}
+	switch {
+	case params["client_id"] != p.ClientID:
+		http.Error(w, "Unknown client_id", http.StatusBadRequest)
+		return
+	case params["redirect_uri"] != p.RedirectURL:
+		http.Error(w, "redirect_uri is not registered", http.StatusBadRequest)
+		return
+	case params["response_type"] != "code":
+		http.Error(w, "Only response_type=code is supported", http.StatusBadRequest)
+		return
+	case params["code_challenge"] != "" && params["code_challenge_method"] != "S256":
+		http.Error(w, "Only the S256 code challenge method is supported", http.StatusBadRequest)
+		return
+	}
+	if r.Method == http.MethodGet {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		signInPage.Execute(w, struct{ Params map[string]string }{params})
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	username := strings.TrimSpace(r.Form.Get("username"))
+	if username == "" {
+		http.Error(w, "A username is required", http.StatusBadRequest)
+		return
+	}
+	claims := map[string]interface{}{
+		"sub":                "standin|" + username,
+		"preferred_username": username,
+		"name":               username,
+	}
+	if email := strings.TrimSpace(r.Form.Get("email")); email != "" {
+		claims["email"] = email
+		claims["email_verified"] = true
+	}
+	groups := []string{}
+	for _, g := range strings.Split(r.Form.Get("groups"), ",") {
+		if g = strings.TrimSpace(g); g != "" {
+			groups = append(groups, g)
+		}
+	}
+	if p.GroupsAsString {
+		claims["groups"] = strings.Join(groups, ",")
+	} else {
+		claims["groups"] = groups
+	}
+
+	code := randomString(24)
+	p.mu.Lock()
+	p.codes[code] = &grant{
+		claims:        claims,
+		nonce:         params["nonce"],
+		codeChallenge: params["code_challenge"],
+		redirectURI:   params["redirect_uri"],
+		expires:       time.Now().Add(codeTTL),
+	}
+	p.mu.Unlock()
+
+	redirect, _ := url.Parse(params["redirect_uri"])
+	query := redirect.Query()
+	query.Set("code", code)
+	if params["state"] != "" {
+		query.Set("state", params["state"])
+	}
+	redirect.RawQuery = query.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+// token redeems a code for an access token and a signed ID token.
+func (p *Provider) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, map[string]string{"error": "invalid_request"}, http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, map[string]string{"error": "invalid_request"}, http.StatusBadRequest)
+		return
+	}
+	id, secret, ok := r.BasicAuth()
+	if ok {
+		// Basic credentials are form-encoded before they are combined.
+		id, _ = url.QueryUnescape(id)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		id, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if id != p.ClientID || secret != p.ClientSecret {
+		writeJSON(w, map[string]string{"error": "invalid_client"}, http.StatusUnauthorized)
+		return
+	}
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, map[string]string{"error": "unsupported_grant_type"}, http.StatusBadRequest)
+		return
+	}
+
+	p.mu.Lock()
+	g := p.codes[r.PostForm.Get("code")]
+	delete(p.codes, r.PostForm.Get("code"))
+	p.mu.Unlock()
+	if g == nil || time.Now().After(g.expires) || g.redirectURI != r.PostForm.Get("redirect_uri") || !verifyChallenge(g.codeChallenge, r.PostForm.Get("code_verifier")) {
+		writeJSON(w, map[string]string{"error": "invalid_grant"}, http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	idClaims := map[string]interface{}{
+		"iss": p.Issuer,
+		"aud": p.ClientID,
+		"iat": now.Unix(),
+		"exp": now.Add(tokenTTL).Unix(),
+	}
+	for k, v := range g.claims {
+		if k == "groups" && p.GroupsInUserinfo {
+			continue
+		}
+		idClaims[k] = v
+	}
+	if g.nonce != "" {
+		idClaims["nonce"] = g.nonce
+	}
+	payload, _ := json.Marshal(idClaims)
+	signed, err := p.signer.Sign(payload)
+	if err != nil {
+		writeJSON(w, map[string]string{"error": "server_error"}, http.StatusInternalServerError)
+		return
+	}
+	idToken, err := signed.CompactSerialize()
+	if err != nil {
+		writeJSON(w, map[string]string{"error": "server_error"}, http.StatusInternalServerError)
+		return
+	}
+
+	accessToken := randomString(24)
+	p.mu.Lock()
+	p.tokens[accessToken] = g.claims
+	p.mu.Unlock()
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, map[string]interface{}{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int(tokenTTL.Seconds()),
+		"id_token":     idToken,
+	}, http.StatusOK)
+}
+
+func (p *Provider) userinfo(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	p.mu.Lock()
+	claims, ok := p.tokens[token]
+	p.mu.Unlock()
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		writeJSON(w, map[string]string{"error": "invalid_token"}, http.StatusUnauthorized)
+		return
+	}
+	writeJSON(w, claims, http.StatusOK)
+}
+
+// verifyChallenge checks a PKCE verifier against the S256 challenge sent
+// with the authorization request. Requests made without PKCE need none.
+func verifyChallenge(challenge, verifier string) bool {
+	if challenge == "" {
+		return true
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:]) == challenge
+}
+
+func randomString(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(v)
+}
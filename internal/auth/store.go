@@ -0,0 +1,436 @@
+// Package auth manages user accounts and their login sessions, and provides
+// the middleware that keeps the API behind a login.
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/victor-butita/rephrase/internal/storeutil"
+	"golang.org/x/crypto/bcrypt"
+	_ "modernc.org/sqlite"
+)
+
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+
+	minPasswordLength = 8
+	// bcrypt ignores everything past 72 bytes, so longer passwords are
+	// refused rather than silently truncated.
+	maxPasswordLength = 72
+)
+
+var (
+	ErrUserNotFound       = errors.New("user not found")
+	ErrUserExists         = errors.New("username is already taken")
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrNoSession          = errors.New("no valid session")
+)
+
+var validUsername = regexp.MustCompile(`^[A-Za-z0-9._@-]{3,64}$`)
+
+// migrations are applied in order; the database's user_version records how
+// many have run.
+var migrations = []string{
+	`CREATE TABLE users (
+		id            TEXT PRIMARY KEY,
+		username      TEXT NOT NULL UNIQUE COLLATE NOCASE,
+		password_hash TEXT NOT NULL,
+		role          TEXT NOT NULL,
+		created_at    INTEGER NOT NULL,
+		last_login_at INTEGER NOT NULL DEFAULT 0
+	);
+	CREATE TABLE sessions (
+		token_hash TEXT PRIMARY KEY,
+		user_id    TEXT NOT NULL,
+		created_at INTEGER NOT NULL,
+		expires_at INTEGER NOT NULL
+	);
+	CREATE INDEX sessions_user ON sessions (user_id);`,
+	`CREATE TABLE api_keys (
+		id           TEXT PRIMARY KEY,
+		user_id      TEXT NOT NULL,
+		name         TEXT NOT NULL,
+		prefix       TEXT NOT NULL,
+		key_hash     TEXT NOT NULL UNIQUE,
+		scopes       TEXT NOT NULL,
+		created_at   INTEGER NOT NULL,
+		expires_at   INTEGER NOT NULL DEFAULT 0,
+		revoked_at   INTEGER NOT NULL DEFAULT 0,
+		last_used_at INTEGER NOT NULL DEFAULT 0
+	);
+	CREATE INDEX api_keys_user ON api_keys (user_id);
+	CREATE TABLE api_key_usage (
+		key_id       TEXT NOT NULL,
+		action       TEXT NOT NULL,
+		requests     INTEGER NOT NULL DEFAULT 0,
+		failures     INTEGER NOT NULL DEFAULT 0,
+		last_used_at INTEGER NOT NULL,
+		PRIMARY KEY (key_id, action)
+	);`,
+	// Accounts signed in through an OpenID Connect provider are tied to the
+	// provider's subject and have no password.
+	`ALTER TABLE users ADD COLUMN oidc_issuer TEXT NOT NULL DEFAULT '';
+	ALTER TABLE users ADD COLUMN oidc_subject TEXT NOT NULL DEFAULT '';
+	CREATE UNIQUE INDEX users_oidc ON users (oidc_issuer, oidc_subject) WHERE oidc_subject != '';`,
+}
+
+type User struct {
+	ID          string     `json:"id"`
+	Username    string     `json:"username"`
+	Role        string     `json:"role"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+	// SSO is set for accounts that sign in through single sign-on.
+	SSO bool `json:"sso,omitempty"`
+}
+
+// ValidRole reports whether role is one the server knows.
+func ValidRole(role string) bool {
+	return role == RoleAdmin || role == RoleUser
+}
+
+type Store struct {
+	db *sql.DB
+	// dummyHash is compared against when a login names an unknown user, so
+	// that unknown and known usernames take equally long to reject.
+	dummyHash []byte
+}
+
+// Open opens or creates the SQLite database at path and brings its schema up
+// to date.
+func Open(path string) (*Store, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open accounts database: %w", err)
+	}
+	db.SetMaxOpenConns(1)
+	dummy, err := bcrypt.GenerateFromPassword([]byte(newToken()), bcrypt.DefaultCost)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	s := &Store{db: db, dummyHash: dummy}
+	if err := storeutil.Migrate(db, "accounts", migrations); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// CreateUser adds an account with a bcrypt hash of password.
+func (s *Store) CreateUser(username, password, role string) (*User, error) {
+	username = strings.TrimSpace(username)
+	if !validUsername.MatchString(username) {
+		return nil, fmt.Errorf("username must be 3-64 letters, digits or . _ @ -")
+	}
+	if err := checkPassword(password); err != nil {
+		return nil, err
+	}
+	if !ValidRole(role) {
+		return nil, fmt.Errorf("unknown role %q", role)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+	u := &User{ID: storeutil.NewID(), Username: username, Role: role, CreatedAt: time.Now().UTC()}
+	_, err = s.db.Exec(`INSERT INTO users (id, username, password_hash, role, created_at) VALUES (?, ?, ?, ?, ?)`,
+		u.ID, u.Username, string(hash), u.Role, u.CreatedAt.UnixMilli())
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return nil, ErrUserExists
+		}
+		return nil, fmt.Errorf("failed to save user: %w", err)
+	}
+	return u, nil
+}
+
+func checkPassword(password string) error {
+	if len(password) < minPasswordLength {
+		return fmt.Errorf("password must be at least %d characters", minPasswordLength)
+	}
+	if len(password) > maxPasswordLength {
+		return fmt.Errorf("password must be at most %d bytes", maxPasswordLength)
+	}
+	return nil
+}
+
+// EnsureUser creates an account unless one with that username already
+// exists, in which case it is left as it is. It reports whether an account
+// was created.
+func (s *Store) EnsureUser(username, password, role string) (bool, error) {
+	if _, err := s.UserByName(username); err == nil {
+		return false, nil
+	} else if !errors.Is(err, ErrUserNotFound) {
+		return false, err
+	}
+	if _, err := s.CreateUser(username, password, role); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// CountUsers returns the number of accounts.
+func (s *Store) CountUsers() (int, error) {
+	var n int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM users`).Scan(&n); err != nil {
+		return 0, fmt.Errorf("failed to count users: %w", err)
+	}
+	return n, nil
+}
+
+// Authenticate checks a username and password and records the login.
+// Single sign-on accounts have no password and never match.
+func (s *Store) Authenticate(username, password string) (*User, error) {
+	if len(password) > maxPasswordLength {
+		// bcrypt only compares the first 72 bytes, so a longer password
+		// would match the one it starts with. The comparison still runs to
+		// take as long as any other failed login.
+		bcrypt.CompareHashAndPassword(s.dummyHash, []byte(password[:maxPasswordLength]))
+		return nil, ErrInvalidCredentials
+	}
+	var hash string
+	u, err := scanUser(s.db.QueryRow(`SELECT `+userColumns+`, password_hash FROM users WHERE username = ?`, strings.TrimSpace(username)), &hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		bcrypt.CompareHashAndPassword(s.dummyHash, []byte(password))
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load user: %w", err)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return nil, ErrInvalidCredentials
+	}
+	now := time.Now().UTC()
+	if _, err := s.db.Exec(`UPDATE users SET last_login_at = ? WHERE id = ?`, now.UnixMilli(), u.ID); err != nil {
+		return nil, fmt.Errorf("failed to record login: %w", err)
+	}
+	u.LastLoginAt = &now
+	return u, nil
+}
+
+// ExternalUser returns the account for a user the identity provider issuer
+// knows as subject, creating it on their first sign-in, and records the
+// login. The role is set on every sign-in, since the provider decides it.
+// When username is taken by another account, a suffix derived from the
+// subject is added to it.
+func (s *Store) ExternalUser(issuer, subject, username, role string) (*User, error) {
+	if issuer == "" || subject == "" {
+		return nil, fmt.Errorf("an issuer and subject are required")
+	}
+	if !ValidRole(role) {
+		return nil, fmt.Errorf("unknown role %q", role)
+	}
+	now := time.Now().UTC()
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	u, err := scanUser(tx.QueryRow(`SELECT `+userColumns+` FROM users WHERE oidc_issuer = ? AND oidc_subject = ?`, issuer, subject))
+	switch {
+	case err == nil:
+		if _, err := tx.Exec(`UPDATE users SET role = ?, last_login_at = ? WHERE id = ?`, role, now.UnixMilli(), u.ID); err != nil {
+			return nil, fmt.Errorf("failed to record login: %w", err)
+		}
+		u.Role = role
+	case errors.Is(err, sql.ErrNoRows):
+		u = &User{ID: storeutil.NewID(), Username: externalUsername(username, subject), Role: role, CreatedAt: now, SSO: true}
+		var taken int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM users WHERE username = ?`, u.Username).Scan(&taken); err != nil {
+			return nil, fmt.Errorf("failed to load user: %w", err)
+		}
+		if taken > 0 {
+			suffix := "-" + hashToken(issuer + " " + subject)[:6]
+			u.Username = strings.TrimRight(u.Username[:min(len(u.Username), 64-len(suffix))], ".-") + suffix
+		}
+		_, err = tx.Exec(`INSERT INTO users (id, username, password_hash, role, created_at, last_login_at, oidc_issuer, oidc_subject)
+			VALUES (?, ?, '', ?, ?, ?, ?, ?)`, u.ID, u.Username, u.Role, u.CreatedAt.UnixMilli(), now.UnixMilli(), issuer, subject)
+		if err != nil {
+			if strings.Contains(err.Error(), "UNIQUE") {
+				return nil, ErrUserExists
+			}
+			return nil, fmt.Errorf("failed to save user: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("failed to load user: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	u.LastLoginAt = &now
+	return u, nil
+}
+
+// externalUsername turns the name a provider offers into a valid username,
+// falling back to the subject when the name has nothing usable in it.
+func externalUsername(name, subject string) string {
+	clean := func(s string) string {
+		s = strings.Map(func(r rune) rune {
+			if r < 128 && validUsername.MatchString(strings.Repeat(string(r), 3)) {
+				return r
+			}
+			return '_'
+		}, strings.TrimSpace(s))
+		if len(s) > 64 {
+			s = s[:64]
+		}
+		return s
+	}
+	if n := clean(name); len(n) >= 3 && strings.Trim(n, "_") != "" {
+		return n
+	}
+	if n := clean(subject); len(n) >= 3 {
+		return n
+	}
+	return "user-" + hashToken(subject)[:8]
+}
+
+// Users lists every account, oldest first.
+func (s *Store) Users() ([]User, error) {
+	rows, err := s.db.Query(`SELECT ` + userColumns + ` FROM users ORDER BY created_at, rowid`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	defer rows.Close()
+	users := []User{}
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *u)
+	}
+	return users, rows.Err()
+}
+
+// UserByID returns the account with the given id.
+func (s *Store) UserByID(id string) (*User, error) {
+	u, err := scanUser(s.db.QueryRow(`SELECT `+userColumns+` FROM users WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load user: %w", err)
+	}
+	return u, nil
+}
+
+// UserByName returns the account with the given username.
+func (s *Store) UserByName(username string) (*User, error) {
+	u, err := scanUser(s.db.QueryRow(`SELECT `+userColumns+` FROM users WHERE username = ?`, strings.TrimSpace(username)))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load user: %w", err)
+	}
+	return u, nil
+}
+
+// DeleteUser removes an account, signs it out everywhere and deletes its API
+// keys.
+func (s *Store) DeleteUser(id string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	res, err := tx.Exec(`DELETE FROM users WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrUserNotFound
+	}
+	if _, err := tx.Exec(`DELETE FROM sessions WHERE user_id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete sessions: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM api_key_usage WHERE key_id IN (SELECT id FROM api_keys WHERE user_id = ?)`, id); err != nil {
+		return fmt.Errorf("failed to delete API key usage: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM api_keys WHERE user_id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete API keys: %w", err)
+	}
+	return tx.Commit()
+}
+
+// CreateSession starts a login session for a user and returns its token.
+// Only a hash of the token is stored. Expired sessions are cleared out on
+// the way.
+func (s *Store) CreateSession(userID string, ttl time.Duration) (string, time.Time, error) {
+	now := time.Now().UTC()
+	token, expires := newToken(), now.Add(ttl)
+	if _, err := s.db.Exec(`DELETE FROM sessions WHERE expires_at <= ?`, now.UnixMilli()); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to clear expired sessions: %w", err)
+	}
+	_, err := s.db.Exec(`INSERT INTO sessions (token_hash, user_id, created_at, expires_at) VALUES (?, ?, ?, ?)`,
+		hashToken(token), userID, now.UnixMilli(), expires.UnixMilli())
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to save session: %w", err)
+	}
+	return token, expires, nil
+}
+
+// SessionUser returns the user a session token belongs to.
+func (s *Store) SessionUser(token string) (*User, error) {
+	u, err := scanUser(s.db.QueryRow(`SELECT `+storeutil.PrefixColumns("u.", userColumns)+` FROM sessions s JOIN users u ON u.id = s.user_id
+		WHERE s.token_hash = ? AND s.expires_at > ?`, hashToken(token), time.Now().UnixMilli()))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoSession
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load session: %w", err)
+	}
+	return u, nil
+}
+
+// DeleteSession ends a session. Ending an unknown session is not an error.
+func (s *Store) DeleteSession(token string) error {
+	if _, err := s.db.Exec(`DELETE FROM sessions WHERE token_hash = ?`, hashToken(token)); err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	return nil
+}
+
+const userColumns = "id, username, role, created_at, last_login_at, oidc_subject != ''"
+
+// scanUser reads userColumns followed by any extra columns into extra.
+func scanUser(row storeutil.RowScanner, extra ...interface{}) (*User, error) {
+	var u User
+	var created, lastLogin int64
+	if err := row.Scan(append([]interface{}{&u.ID, &u.Username, &u.Role, &created, &lastLogin, &u.SSO}, extra...)...); err != nil {
+		return nil, err
+	}
+	u.CreatedAt, u.LastLoginAt = storeutil.FromMillis(created), storeutil.OptionalMillis(lastLogin)
+	return &u, nil
+}
+
+// hashToken is what is stored in place of a token. Tokens are random, so a
+// plain SHA-256 is enough; a leaked database does not yield usable tokens.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func newToken() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
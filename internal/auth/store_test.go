@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	s, err := Open(filepath.Join(t.TempDir(), "auth.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestCreateUserPasswordLength(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		ok       bool
+	}{
+		{"too short", "1234567", false},
+		{"shortest", "12345678", true},
+		{"longest", strings.Repeat("a", 72), true},
+		{"too long", strings.Repeat("a", 73), false},
+		// The limit is in bytes, since that is what bcrypt reads: 24
+		// three-byte characters fit, 25 do not.
+		{"multibyte at the limit", strings.Repeat("€", 24), true},
+		{"multibyte over the limit", strings.Repeat("€", 25), false},
+	}
+	s := newTestStore(t)
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			username := "user" + string(rune('a'+i))
+			_, err := s.CreateUser(username, tt.password, RoleUser)
+			if (err == nil) != tt.ok {
+				t.Fatalf("CreateUser error = %v, want ok %v", err, tt.ok)
+			}
+			if !tt.ok {
+				return
+			}
+			if _, err := s.Authenticate(username, tt.password); err != nil {
+				t.Fatalf("Authenticate with the same password: %v", err)
+			}
+			// A password one byte longer must not match, as it would if
+			// bcrypt had silently truncated it.
+			if _, err := s.Authenticate(username, tt.password+"x"); !errors.Is(err, ErrInvalidCredentials) {
+				t.Fatalf("Authenticate with a longer password: %v, want ErrInvalidCredentials", err)
+			}
+		})
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	s := newTestStore(t)
+	if _, err := s.CreateUser("alice", "correct horse", RoleUser); err != nil {
+		t.Fatal(err)
+	}
+	u, err := s.Authenticate(" alice ", "correct horse")
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if u.LastLoginAt == nil {
+		t.Error("login was not recorded")
+	}
+	for _, c := range []struct{ username, password string }{
+		{"alice", "wrong password"},
+		{"bob", "correct horse"},
+	} {
+		if _, err := s.Authenticate(c.username, c.password); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("Authenticate(%q, %q) = %v, want ErrInvalidCredentials", c.username, c.password, err)
+		}
+	}
+}
+
+func TestSessionExpiry(t *testing.T) {
+	s := newTestStore(t)
+	u, err := s.CreateUser("alice", "correct horse", RoleUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, expires, err := s.CreateSession(u.ID, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Until(expires); d < 59*time.Minute || d > time.Hour {
+		t.Errorf("session expires in %v, want an hour", d)
+	}
+	got, err := s.SessionUser(token)
+	if err != nil {
+		t.Fatalf("SessionUser: %v", err)
+	}
+	if got.ID != u.ID {
+		t.Errorf("session belongs to %s, want %s", got.ID, u.ID)
+	}
+
+	expired, _, err := s.CreateSession(u.ID, -time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SessionUser(expired); !errors.Is(err, ErrNoSession) {
+		t.Errorf("SessionUser with an expired session = %v, want ErrNoSession", err)
+	}
+	// Starting a session clears out expired ones.
+	if _, _, err := s.CreateSession(u.ID, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM sessions WHERE token_hash = ?`, hashToken(expired)).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Error("expired session was not cleared out")
+	}
+
+	if err := s.DeleteSession(token); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SessionUser(token); !errors.Is(err, ErrNoSession) {
+		t.Errorf("SessionUser after DeleteSession = %v, want ErrNoSession", err)
+	}
+	if _, err := s.SessionUser("unknown"); !errors.Is(err, ErrNoSession) {
+		t.Errorf("SessionUser with an unknown token = %v, want ErrNoSession", err)
+	}
+}
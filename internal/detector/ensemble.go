@@ -0,0 +1,236 @@
+package detector
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/victor-butita/rephrase/internal/textutil"
+)
+
+// Detector is one source of evidence in an Ensemble. Every detector scores the
+// same sentence segmentation so that per-sentence scores can be combined.
+type Detector interface {
+	Name() string
+	Score(text string, sentences []textutil.Span) (*Verdict, error)
+}
+
+// Verdict is a single detector's opinion. Scores are 0-100 likelihoods of AI
+// authorship. SentenceScores is either nil or aligned with the sentences the
+// detector was given.
+type Verdict struct {
+	Score          float64
+	SentenceScores []float64
+	Analysis       string
+	Details        interface{}
+}
+
+type DetectorScore struct {
+	Name     string      `json:"name"`
+	Weight   float64     `json:"weight"`
+	Score    *float64    `json:"score"`
+	Analysis string      `json:"analysis,omitempty"`
+	Error    string      `json:"error,omitempty"`
+	Details  interface{} `json:"details,omitempty"`
+}
+
+type EnsembleSentence struct {
+	Index     int                `json:"index"`
+	Text      string             `json:"text"`
+	Start     int                `json:"start"`
+	End       int                `json:"end"`
+	Score     float64            `json:"score"`
+	Detectors map[string]float64 `json:"detectors"`
+}
+
+type EnsembleResult struct {
+	Score     float64            `json:"score"`
+	Sentences []EnsembleSentence `json:"sentences"`
+	Detectors []DetectorScore    `json:"detectors"`
+}
+
+type member struct {
+	detector Detector
+	weight   float64
+}
+
+// Ensemble combines detectors by weighted average. Weights are renormalized
+// over the detectors that succeed, so one failing backend degrades the result
+// instead of failing the request.
+type Ensemble struct {
+	members []member
+}
+
+func NewEnsemble() *Ensemble {
+	return &Ensemble{}
+}
+
+// Add registers a detector. Detectors with a zero weight are ignored.
+func (e *Ensemble) Add(d Detector, weight float64) *Ensemble {
+	if weight > 0 {
+		e.members = append(e.members, member{detector: d, weight: weight})
+	}
+	return e
+}
+
+func (e *Ensemble) Names() []string {
+	names := make([]string, len(e.members))
+	for i, m := range e.members {
+		names[i] = m.detector.Name()
+	}
+	return names
+}
+
+// Detect runs every detector not listed in exclude concurrently and merges
+// their verdicts.
+func (e *Ensemble) Detect(text string, exclude ...string) (*EnsembleResult, error) {
+	skip := make(map[string]bool)
+	for _, name := range exclude {
+		skip[name] = true
+	}
+	var members []member
+	for _, m := range e.members {
+		if !skip[m.detector.Name()] {
+			members = append(members, m)
+		}
+	}
+	if len(members) == 0 {
+		return nil, fmt.Errorf("no detectors are enabled")
+	}
+
+	sentences := textutil.Sentences(text)
+	verdicts := make([]*Verdict, len(members))
+	errs := make([]error, len(members))
+	var wg sync.WaitGroup
+	for i, m := range members {
+		wg.Add(1)
+		go func(i int, d Detector) {
+			defer wg.Done()
+			verdicts[i], errs[i] = d.Score(text, sentences)
+		}(i, m.detector)
+	}
+	wg.Wait()
+
+	result := &EnsembleResult{Sentences: make([]EnsembleSentence, len(sentences))}
+	for i, s := range sentences {
+		result.Sentences[i] = EnsembleSentence{
+			Index:     i,
+			Text:      s.Text,
+			Start:     textutil.CharOffset(text, s.Start),
+			End:       textutil.CharOffset(text, s.End),
+			Detectors: make(map[string]float64),
+		}
+	}
+
+	var totalWeight, weighted float64
+	sentenceWeight := make([]float64, len(sentences))
+	sentenceSum := make([]float64, len(sentences))
+	var failures []string
+	for i, m := range members {
+		name := m.detector.Name()
+		ds := DetectorScore{Name: name, Weight: m.weight}
+		if errs[i] != nil {
+			ds.Error = errs[i].Error()
+			failures = append(failures, fmt.Sprintf("%s: %v", name, errs[i]))
+			result.Detectors = append(result.Detectors, ds)
+			continue
+		}
+		v := verdicts[i]
+		score := clampScore(v.Score)
+		ds.Score, ds.Analysis, ds.Details = &score, v.Analysis, v.Details
+		result.Detectors = append(result.Detectors, ds)
+
+		totalWeight += m.weight
+		weighted += m.weight * score
+		if len(v.SentenceScores) == len(sentences) {
+			for j, ss := range v.SentenceScores {
+				ss = clampScore(ss)
+				result.Sentences[j].Detectors[name] = ss
+				sentenceSum[j] += m.weight * ss
+				sentenceWeight[j] += m.weight
+			}
+		}
+	}
+	if totalWeight == 0 {
+		return nil, fmt.Errorf("all detectors failed: %s", strings.Join(failures, "; "))
+	}
+
+	result.Score = round(weighted/totalWeight, 1)
+	for j := range result.Sentences {
+		if sentenceWeight[j] > 0 {
+			result.Sentences[j].Score = round(sentenceSum[j]/sentenceWeight[j], 1)
+		} else {
+			result.Sentences[j].Score = result.Score
+		}
+	}
+	return result, nil
+}
+
+func clampScore(v float64) float64 {
+	if math.IsNaN(v) {
+		return 0
+	}
+	return math.Max(0, math.Min(100, v))
+}
+
+// ParseWeights parses a "name=weight,name=weight" list such as
+// "llm=0.5,heuristic=0.3,local_model=0.2".
+func ParseWeights(spec string) (map[string]float64, error) {
+	weights := make(map[string]float64)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid detector weight %q: expected name=weight", part)
+		}
+		w, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || w < 0 {
+			return nil, fmt.Errorf("invalid detector weight %q: weight must be a non-negative number", part)
+		}
+		weights[strings.TrimSpace(name)] = w
+	}
+	return weights, nil
+}
+
+// HeuristicDetector adapts the statistical Model to the Detector interface.
+type HeuristicDetector struct {
+	Model *Model
+}
+
+func (d *HeuristicDetector) Name() string { return "heuristic" }
+
+func (d *HeuristicDetector) Score(text string, sentences []textutil.Span) (*Verdict, error) {
+	model := d.Model
+	if model == nil {
+		model = DefaultModel
+	}
+	report := model.Analyze(text)
+	scores := make([]float64, len(report.Sentences))
+	for i, s := range report.Sentences {
+		scores[i] = s.Score
+	}
+	if len(scores) != len(sentences) {
+		scores = nil
+	}
+	return &Verdict{Score: report.Score, SentenceScores: scores, Analysis: report.Summary, Details: report}, nil
+}
+
+// SortedWeights lists weights by name, for logging.
+func SortedWeights(weights map[string]float64) string {
+	names := make([]string, 0, len(weights))
+	for name := range weights {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s=%g", name, weights[name])
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,199 @@
+package detector
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/victor-butita/rephrase/internal/textutil"
+)
+
+// fixedDetector gives every text the same score, and each sentence its
+// score too unless perSentence is false.
+type fixedDetector struct {
+	name        string
+	score       float64
+	perSentence bool
+	err         error
+}
+
+func (d *fixedDetector) Name() string { return d.name }
+
+func (d *fixedDetector) Score(text string, sentences []textutil.Span) (*Verdict, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	v := &Verdict{Score: d.score}
+	if d.perSentence {
+		v.SentenceScores = make([]float64, len(sentences))
+		for i := range sentences {
+			v.SentenceScores[i] = d.score
+		}
+	}
+	return v, nil
+}
+
+func TestEnsembleDetect(t *testing.T) {
+	const text = "Zoë wrote this. Then — 🚀 — she left!"
+	down := errors.New("backend unavailable")
+	tests := []struct {
+		name          string
+		detectors     []*fixedDetector
+		weights       []float64
+		exclude       []string
+		wantScore     float64
+		wantSentence  float64
+		wantErr       bool
+		wantFailed    string
+		wantDetectors int
+	}{
+		{
+			name:          "weighted average",
+			detectors:     []*fixedDetector{{name: "a", score: 80, perSentence: true}, {name: "b", score: 20, perSentence: true}},
+			weights:       []float64{0.75, 0.25},
+			wantScore:     65,
+			wantSentence:  65,
+			wantDetectors: 2,
+		},
+		{
+			name:          "failed detector re-weighted away",
+			detectors:     []*fixedDetector{{name: "a", score: 80, perSentence: true}, {name: "b", err: down}, {name: "c", score: 20, perSentence: true}},
+			weights:       []float64{0.5, 0.3, 0.2},
+			wantScore:     62.9, // (0.5*80 + 0.2*20) / 0.7
+			wantSentence:  62.9,
+			wantFailed:    "b",
+			wantDetectors: 3,
+		},
+		{
+			name:          "sentences fall back to detectors that score them",
+			detectors:     []*fixedDetector{{name: "a", score: 80, perSentence: true}, {name: "b", score: 20}},
+			weights:       []float64{0.5, 0.5},
+			wantScore:     50,
+			wantSentence:  80,
+			wantDetectors: 2,
+		},
+		{
+			name:          "scores clamped",
+			detectors:     []*fixedDetector{{name: "a", score: 150, perSentence: true}, {name: "b", score: -40, perSentence: true}},
+			weights:       []float64{0.5, 0.5},
+			wantScore:     50,
+			wantSentence:  50,
+			wantDetectors: 2,
+		},
+		{
+			name:          "excluded",
+			detectors:     []*fixedDetector{{name: "a", score: 80, perSentence: true}, {name: "b", score: 20, perSentence: true}},
+			weights:       []float64{0.5, 0.5},
+			exclude:       []string{"a"},
+			wantScore:     20,
+			wantSentence:  20,
+			wantDetectors: 1,
+		},
+		{
+			name:      "all failed",
+			detectors: []*fixedDetector{{name: "a", err: down}, {name: "b", err: down}},
+			weights:   []float64{0.5, 0.5},
+			wantErr:   true,
+		},
+		{
+			name:      "all excluded",
+			detectors: []*fixedDetector{{name: "a", score: 80}},
+			weights:   []float64{1},
+			exclude:   []string{"a"},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewEnsemble()
+			for i, d := range tt.detectors {
+				e.Add(d, tt.weights[i])
+			}
+			result, err := e.Detect(text, tt.exclude...)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Detect = %+v, want an error", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Score != tt.wantScore {
+				t.Errorf("score = %v, want %v", result.Score, tt.wantScore)
+			}
+			if len(result.Detectors) != tt.wantDetectors {
+				t.Errorf("%d detector scores, want %d", len(result.Detectors), tt.wantDetectors)
+			}
+			for _, ds := range result.Detectors {
+				if failed := ds.Name == tt.wantFailed; failed != (ds.Error != "") || failed != (ds.Score == nil) {
+					t.Errorf("detector %s: score %v, error %q", ds.Name, ds.Score, ds.Error)
+				}
+			}
+
+			runes := []rune(text)
+			if len(result.Sentences) != 2 {
+				t.Fatalf("%d sentences, want 2", len(result.Sentences))
+			}
+			for _, s := range result.Sentences {
+				if s.Score != tt.wantSentence {
+					t.Errorf("sentence %d score = %v, want %v", s.Index, s.Score, tt.wantSentence)
+				}
+				if got := string(runes[s.Start:s.End]); got != s.Text {
+					t.Errorf("sentence %d: text[%d:%d] = %q, want %q", s.Index, s.Start, s.End, got, s.Text)
+				}
+				if _, ok := s.Detectors[tt.wantFailed]; ok {
+					t.Errorf("sentence %d has a score from failed detector %s", s.Index, tt.wantFailed)
+				}
+			}
+		})
+	}
+}
+
+func TestHeuristicDetectorMatchesSentences(t *testing.T) {
+	for _, tt := range detectorTexts {
+		result, err := NewEnsemble().Add(&HeuristicDetector{}, 1).Detect(tt.text)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		report := Analyze(tt.text)
+		if result.Score != report.Score || len(result.Sentences) != len(report.Sentences) {
+			t.Fatalf("%s: ensemble score %v over %d sentences, heuristic %v over %d", tt.name, result.Score, len(result.Sentences), report.Score, len(report.Sentences))
+		}
+		for i, s := range result.Sentences {
+			if want := report.Sentences[i]; s.Start != want.Start || s.End != want.End || s.Detectors["heuristic"] != want.Score {
+				t.Errorf("%s: sentence %d = [%d, %d) scored %v, heuristic [%d, %d) scored %v", tt.name, i, s.Start, s.End, s.Detectors["heuristic"], want.Start, want.End, want.Score)
+			}
+		}
+	}
+}
+
+func TestParseWeights(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    map[string]float64
+		wantErr bool
+	}{
+		{spec: "llm=0.5, heuristic=0.3,local_model=0.2", want: map[string]float64{"llm": 0.5, "heuristic": 0.3, "local_model": 0.2}},
+		{spec: "", want: map[string]float64{}},
+		{spec: "llm=0", want: map[string]float64{"llm": 0}},
+		{spec: "llm", wantErr: true},
+		{spec: "llm=-1", wantErr: true},
+		{spec: "llm=high", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseWeights(tt.spec)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseWeights(%q) = %v, want an error", tt.spec, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseWeights(%q): %v", tt.spec, err)
+			continue
+		}
+		if SortedWeights(got) != SortedWeights(tt.want) {
+			t.Errorf("ParseWeights(%q) = %s, want %s", tt.spec, SortedWeights(got), SortedWeights(tt.want))
+		}
+	}
+}
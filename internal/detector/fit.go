@@ -0,0 +1,117 @@
+package detector
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+)
+
+const (
+	fitIterations   = 5000
+	fitLearningRate = 0.1
+	fitL2           = 0.01
+)
+
+// LoadModel reads a Model written as JSON, for example by cmd/evaluate -fit.
+// Features the file omits fall back to DefaultModel's values.
+func LoadModel(path string) (*Model, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m Model
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("invalid detector model %s: %w", path, err)
+	}
+	for _, params := range []struct{ dst, def *map[string]float64 }{
+		{&m.Weights, &DefaultModel.Weights},
+		{&m.Means, &DefaultModel.Means},
+		{&m.Scales, &DefaultModel.Scales},
+	} {
+		if *params.dst == nil {
+			*params.dst = make(map[string]float64)
+		}
+		for _, name := range featureNames {
+			if _, ok := (*params.dst)[name]; !ok {
+				(*params.dst)[name] = (*params.def)[name]
+			}
+		}
+	}
+	return &m, nil
+}
+
+// Fit trains a Model on labeled feature vectors. Means and scales come from
+// the corpus itself; the weights are found by L2-regularized logistic
+// regression with batch gradient descent, so results are reproducible.
+func Fit(features []Features, isAI []bool) (*Model, error) {
+	if len(features) != len(isAI) {
+		return nil, fmt.Errorf("got %d feature vectors but %d labels", len(features), len(isAI))
+	}
+	if len(features) < 2 {
+		return nil, fmt.Errorf("need at least two samples to fit a model")
+	}
+
+	m := &Model{
+		Weights: make(map[string]float64),
+		Means:   make(map[string]float64),
+		Scales:  make(map[string]float64),
+	}
+	raw := make([]map[string]float64, len(features))
+	for i := range features {
+		raw[i] = features[i].byName()
+	}
+	for _, name := range featureNames {
+		values := make([]float64, len(raw))
+		for i, r := range raw {
+			values[i] = r[name]
+		}
+		mean, variance := meanVariance(values)
+		scale := math.Sqrt(variance)
+		if scale == 0 {
+			scale = 1
+		}
+		m.Means[name], m.Scales[name] = round(mean, 4), round(scale, 4)
+	}
+
+	x := make([][]float64, len(raw))
+	y := make([]float64, len(raw))
+	for i, r := range raw {
+		x[i] = make([]float64, len(featureNames))
+		for j, name := range featureNames {
+			x[i][j] = m.standardize(name, r[name])
+		}
+		if isAI[i] {
+			y[i] = 1
+		}
+	}
+
+	w := make([]float64, len(featureNames))
+	var b float64
+	n := float64(len(x))
+	for iter := 0; iter < fitIterations; iter++ {
+		grad := make([]float64, len(w))
+		var gradB float64
+		for i := range x {
+			z := b
+			for j := range w {
+				z += w[j] * x[i][j]
+			}
+			diff := sigmoid(z) - y[i]
+			for j := range w {
+				grad[j] += diff * x[i][j]
+			}
+			gradB += diff
+		}
+		for j := range w {
+			w[j] -= fitLearningRate * (grad[j]/n + fitL2*w[j])
+		}
+		b -= fitLearningRate * gradB / n
+	}
+
+	m.Intercept = round(b, 4)
+	for j, name := range featureNames {
+		m.Weights[name] = round(w[j], 4)
+	}
+	return m, nil
+}
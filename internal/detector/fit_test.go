@@ -0,0 +1,86 @@
+package detector
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func fitCorpus() ([]Features, []bool) {
+	var features []Features
+	var isAI []bool
+	for i := 0; i < 10; i++ {
+		step := float64(i) / 100
+		// AI-like samples: many transitions, uniform sentence lengths.
+		features = append(features, Features{TransitionDensity: 0.4 + step, SentenceLengthCV: 0.2 + step, TypeTokenRatio: 0.8})
+		isAI = append(isAI, true)
+		features = append(features, Features{TransitionDensity: 0.02 + step, SentenceLengthCV: 0.7 - step, TypeTokenRatio: 0.85})
+		isAI = append(isAI, false)
+	}
+	return features, isAI
+}
+
+func TestFit(t *testing.T) {
+	features, isAI := fitCorpus()
+	m, err := Fit(features, isAI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := Fit(features, isAI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m, again) {
+		t.Errorf("refitting gave %+v, want %+v", again, m)
+	}
+	if m.Weights[FeatureTransitionDensity] <= 0 || m.Weights[FeatureSentenceLengthCV] >= 0 {
+		t.Errorf("weights = %v, want transitions positive and sentence length variation negative", m.Weights)
+	}
+
+	score := func(f Features) float64 {
+		logit := m.Intercept
+		values := f.byName()
+		for _, name := range featureNames {
+			logit += m.Weights[name] * m.standardize(name, values[name])
+		}
+		return sigmoid(logit)
+	}
+	for i, f := range features {
+		if p := score(f); (p > 0.5) != isAI[i] {
+			t.Errorf("sample %d: p = %.3f, labeled AI = %v", i, p, isAI[i])
+		}
+	}
+}
+
+func TestFitRejectsBadInput(t *testing.T) {
+	features, isAI := fitCorpus()
+	if _, err := Fit(features, isAI[1:]); err == nil {
+		t.Error("fit with mismatched labels succeeded")
+	}
+	if _, err := Fit(features[:1], isAI[:1]); err == nil {
+		t.Error("fit with one sample succeeded")
+	}
+}
+
+func TestLoadModelFillsDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "model.json")
+	if err := os.WriteFile(path, []byte(`{"intercept": 0.5, "weights": {"burstiness": -2}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	m, err := LoadModel(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Intercept != 0.5 || m.Weights[FeatureBurstiness] != -2 {
+		t.Errorf("loaded intercept %v and burstiness weight %v, want 0.5 and -2", m.Intercept, m.Weights[FeatureBurstiness])
+	}
+	for _, name := range featureNames {
+		if name != FeatureBurstiness && m.Weights[name] != DefaultModel.Weights[name] {
+			t.Errorf("weight %s = %v, want the default %v", name, m.Weights[name], DefaultModel.Weights[name])
+		}
+		if m.Means[name] != DefaultModel.Means[name] || m.Scales[name] != DefaultModel.Scales[name] {
+			t.Errorf("%s mean and scale = %v, %v, want the defaults", name, m.Means[name], m.Scales[name])
+		}
+	}
+}
@@ -0,0 +1,505 @@
+// Package detector estimates whether text was machine-generated using
+// deterministic, locally computed statistics. Unlike the LLM verdict, the same
+// input always produces the same score.
+package detector
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/victor-butita/rephrase/internal/textutil"
+)
+
+const (
+	FeatureBurstiness             = "burstiness"
+	FeatureSentenceLengthCV       = "sentence_length_cv"
+	FeatureTypeTokenRatio         = "type_token_ratio"
+	FeatureFunctionWordDivergence = "function_word_divergence"
+	FeatureRepeatedNgramRate      = "repeated_ngram_rate"
+	FeatureTransitionDensity      = "transition_density"
+
+	// Below this many words the statistics are too noisy to trust.
+	minReliableWords = 60
+	mattrWindow      = 50
+	ngramSize        = 3
+)
+
+type Features struct {
+	WordCount              int     `json:"word_count"`
+	SentenceCount          int     `json:"sentence_count"`
+	Burstiness             float64 `json:"burstiness"`
+	SentenceLengthMean     float64 `json:"sentence_length_mean"`
+	SentenceLengthVariance float64 `json:"sentence_length_variance"`
+	SentenceLengthCV       float64 `json:"sentence_length_cv"`
+	TypeTokenRatio         float64 `json:"type_token_ratio"`
+	FunctionWordRatio      float64 `json:"function_word_ratio"`
+	FunctionWordDivergence float64 `json:"function_word_divergence"`
+	RepeatedNgramRate      float64 `json:"repeated_ngram_rate"`
+	TransitionDensity      float64 `json:"transition_density"`
+}
+
+var featureNames = []string{
+	FeatureBurstiness,
+	FeatureSentenceLengthCV,
+	FeatureTypeTokenRatio,
+	FeatureFunctionWordDivergence,
+	FeatureRepeatedNgramRate,
+	FeatureTransitionDensity,
+}
+
+func (f *Features) byName() map[string]float64 {
+	return map[string]float64{
+		FeatureBurstiness:             f.Burstiness,
+		FeatureSentenceLengthCV:       f.SentenceLengthCV,
+		FeatureTypeTokenRatio:         f.TypeTokenRatio,
+		FeatureFunctionWordDivergence: f.FunctionWordDivergence,
+		FeatureRepeatedNgramRate:      f.RepeatedNgramRate,
+		FeatureTransitionDensity:      f.TransitionDensity,
+	}
+}
+
+// Model is a logistic model over standardized features. Positive weights push
+// toward "AI-generated".
+type Model struct {
+	Intercept float64            `json:"intercept"`
+	Weights   map[string]float64 `json:"weights"`
+	Means     map[string]float64 `json:"means"`
+	Scales    map[string]float64 `json:"scales"`
+}
+
+// DefaultModel's coefficients are hand-calibrated against typical values for
+// 100-300 word English passages. cmd/evaluate -fit derives a replacement from
+// a labeled corpus, which the server loads from HEURISTIC_MODEL_PATH.
+var DefaultModel = &Model{
+	Intercept: 0,
+	Weights: map[string]float64{
+		FeatureBurstiness:             -0.5,
+		FeatureSentenceLengthCV:       -0.9,
+		FeatureTypeTokenRatio:         -0.3,
+		FeatureFunctionWordDivergence: 0.3,
+		FeatureRepeatedNgramRate:      0.4,
+		FeatureTransitionDensity:      1.0,
+	},
+	Means: map[string]float64{
+		FeatureBurstiness:             0.0,
+		FeatureSentenceLengthCV:       0.42,
+		FeatureTypeTokenRatio:         0.84,
+		FeatureFunctionWordDivergence: 0.30,
+		FeatureRepeatedNgramRate:      0.03,
+		FeatureTransitionDensity:      0.15,
+	},
+	Scales: map[string]float64{
+		FeatureBurstiness:             0.15,
+		FeatureSentenceLengthCV:       0.15,
+		FeatureTypeTokenRatio:         0.05,
+		FeatureFunctionWordDivergence: 0.10,
+		FeatureRepeatedNgramRate:      0.03,
+		FeatureTransitionDensity:      0.15,
+	},
+}
+
+type SentenceScore struct {
+	Index int     `json:"index"`
+	Text  string  `json:"text"`
+	Start int     `json:"start"`
+	End   int     `json:"end"`
+	Score float64 `json:"score"`
+	// Contribution is how many points this sentence moves the text away from
+	// a neutral 50, weighted by its share of the words.
+	Contribution float64  `json:"contribution"`
+	Signals      []string `json:"signals,omitempty"`
+}
+
+type Report struct {
+	Score    float64  `json:"score"`
+	Reliable bool     `json:"reliable"`
+	Summary  string   `json:"summary"`
+	Features Features `json:"features"`
+	// FeatureContributions are each feature's additive share of the logit.
+	FeatureContributions map[string]float64 `json:"feature_contributions"`
+	Sentences            []SentenceScore    `json:"sentences"`
+}
+
+// Analyze scores text with DefaultModel.
+func Analyze(text string) *Report {
+	return DefaultModel.Analyze(text)
+}
+
+func (m *Model) Analyze(text string) *Report {
+	sentences := textutil.Sentences(text)
+	words := lowerWords(text)
+	features := ComputeFeatures(text)
+
+	contributions := make(map[string]float64)
+	logit := m.Intercept
+	values := features.byName()
+	for _, name := range featureNames {
+		c := m.Weights[name] * m.standardize(name, values[name])
+		contributions[name] = round(c, 3)
+		logit += c
+	}
+
+	report := &Report{
+		Score:                round(100*sigmoid(logit), 1),
+		Reliable:             features.WordCount >= minReliableWords && features.SentenceCount >= 3,
+		Features:             features,
+		FeatureContributions: contributions,
+	}
+	report.Sentences = m.scoreSentences(text, sentences, words, features, logit)
+	report.Summary = summarize(report)
+	return report
+}
+
+func (m *Model) standardize(name string, value float64) float64 {
+	scale := m.Scales[name]
+	if scale == 0 {
+		scale = 1
+	}
+	z := (value - m.Means[name]) / scale
+	return math.Max(-3, math.Min(3, z))
+}
+
+// ComputeFeatures extracts the raw statistics the model is built on.
+func ComputeFeatures(text string) Features {
+	sentences := textutil.Sentences(text)
+	words := lowerWords(text)
+	f := Features{WordCount: len(words), SentenceCount: len(sentences)}
+	if len(words) == 0 {
+		return f
+	}
+
+	lengths := make([]float64, 0, len(sentences))
+	for _, s := range sentences {
+		lengths = append(lengths, float64(len(textutil.Words(s.Text))))
+	}
+	f.SentenceLengthMean, f.SentenceLengthVariance = meanVariance(lengths)
+	if f.SentenceLengthMean > 0 {
+		f.SentenceLengthCV = math.Sqrt(f.SentenceLengthVariance) / f.SentenceLengthMean
+	}
+
+	f.Burstiness = burstiness(words)
+	f.TypeTokenRatio = movingTypeTokenRatio(words, mattrWindow)
+	f.FunctionWordRatio, f.FunctionWordDivergence = functionWordProfile(words)
+	f.RepeatedNgramRate = repeatedNgramRate(words, ngramSize)
+	if len(sentences) > 0 {
+		f.TransitionDensity = float64(len(findTransitions(text))) / float64(len(sentences))
+	}
+
+	f.Burstiness = round(f.Burstiness, 4)
+	f.SentenceLengthMean = round(f.SentenceLengthMean, 2)
+	f.SentenceLengthVariance = round(f.SentenceLengthVariance, 2)
+	f.SentenceLengthCV = round(f.SentenceLengthCV, 4)
+	f.TypeTokenRatio = round(f.TypeTokenRatio, 4)
+	f.FunctionWordRatio = round(f.FunctionWordRatio, 4)
+	f.FunctionWordDivergence = round(f.FunctionWordDivergence, 4)
+	f.RepeatedNgramRate = round(f.RepeatedNgramRate, 4)
+	f.TransitionDensity = round(f.TransitionDensity, 4)
+	return f
+}
+
+func (m *Model) scoreSentences(text string, sentences []textutil.Span, words []string, f Features, docLogit float64) []SentenceScore {
+	repeated := repeatedNgrams(words, ngramSize)
+	std := math.Sqrt(f.SentenceLengthVariance)
+	scores := make([]SentenceScore, 0, len(sentences))
+	for i, s := range sentences {
+		sentenceWords := lowerWords(s.Text)
+		var signals []string
+		logit := 0.5 * docLogit
+
+		transitions := findTransitions(s.Text)
+		if len(transitions) > 0 {
+			logit += 1.2 * float64(len(transitions))
+			signals = append(signals, "stock transition phrase: "+strings.Join(transitions, ", "))
+		}
+
+		if grams := ngrams(sentenceWords, ngramSize); len(grams) > 0 {
+			hits := 0
+			for _, g := range grams {
+				if repeated[g] {
+					hits++
+				}
+			}
+			if hits > 0 {
+				share := float64(hits) / float64(len(grams))
+				logit += 2.0 * share
+				signals = append(signals, fmt.Sprintf("reuses phrasing found elsewhere in the text (%d%% of word triples)", int(share*100)))
+			}
+		}
+
+		if len(sentences) >= 3 && std > 0 {
+			z := math.Abs(float64(len(sentenceWords))-f.SentenceLengthMean) / std
+			logit -= 0.4 * math.Min(z, 3)
+			if z < 0.5 && f.SentenceLengthCV < m.Means[FeatureSentenceLengthCV] {
+				logit += 0.3
+				signals = append(signals, "length matches the text's uniform sentence rhythm")
+			}
+		}
+
+		score := 100 * sigmoid(logit)
+		share := 0.0
+		if len(words) > 0 {
+			share = float64(len(sentenceWords)) / float64(len(words))
+		}
+		scores = append(scores, SentenceScore{
+			Index:        i,
+			Text:         s.Text,
+			Start:        textutil.CharOffset(text, s.Start),
+			End:          textutil.CharOffset(text, s.End),
+			Score:        round(score, 1),
+			Contribution: round((score-50)*share, 2),
+			Signals:      signals,
+		})
+	}
+	return scores
+}
+
+func summarize(r *Report) string {
+	type driver struct {
+		name  string
+		value float64
+	}
+	var drivers []driver
+	for name, c := range r.FeatureContributions {
+		drivers = append(drivers, driver{name, c})
+	}
+	sort.Slice(drivers, func(i, j int) bool {
+		if math.Abs(drivers[i].value) != math.Abs(drivers[j].value) {
+			return math.Abs(drivers[i].value) > math.Abs(drivers[j].value)
+		}
+		return drivers[i].name < drivers[j].name
+	})
+
+	var parts []string
+	for _, d := range drivers {
+		if len(parts) == 2 || math.Abs(d.value) < 0.2 {
+			break
+		}
+		direction := "suggests AI"
+		if d.value < 0 {
+			direction = "suggests human"
+		}
+		parts = append(parts, fmt.Sprintf("%s %s", strings.ReplaceAll(d.name, "_", " "), direction))
+	}
+
+	summary := fmt.Sprintf("Statistical score %.0f/100.", r.Score)
+	if len(parts) > 0 {
+		summary += " Main signals: " + strings.Join(parts, "; ") + "."
+	}
+	if !r.Reliable {
+		summary += " The text is short, so this estimate is unreliable."
+	}
+	return summary
+}
+
+func lowerWords(text string) []string {
+	spans := textutil.Words(text)
+	words := make([]string, len(spans))
+	for i, s := range spans {
+		words[i] = strings.ToLower(strings.ReplaceAll(s.Text, "’", "'"))
+	}
+	return words
+}
+
+// burstiness is the Goh–Barabási coefficient (σ-μ)/(σ+μ) of the gaps between
+// repeated occurrences of the same word, averaged over recurring words and
+// weighted by frequency. Human writing clusters topics (positive values); text
+// that spreads vocabulary evenly scores near or below zero.
+func burstiness(words []string) float64 {
+	positions := make(map[string][]int)
+	for i, w := range words {
+		if _, isFunction := functionWordFrequencies[w]; isFunction {
+			continue
+		}
+		positions[w] = append(positions[w], i)
+	}
+
+	var total, weight float64
+	for _, pos := range positions {
+		if len(pos) < 3 {
+			continue
+		}
+		gaps := make([]float64, len(pos)-1)
+		for i := 1; i < len(pos); i++ {
+			gaps[i-1] = float64(pos[i] - pos[i-1])
+		}
+		mean, variance := meanVariance(gaps)
+		sd := math.Sqrt(variance)
+		if sd+mean == 0 {
+			continue
+		}
+		total += float64(len(pos)) * (sd - mean) / (sd + mean)
+		weight += float64(len(pos))
+	}
+	if weight == 0 {
+		return 0
+	}
+	return total / weight
+}
+
+// movingTypeTokenRatio (MATTR) averages the type-token ratio over a sliding
+// window so that the result does not shrink as the text gets longer.
+func movingTypeTokenRatio(words []string, window int) float64 {
+	if len(words) == 0 {
+		return 0
+	}
+	if len(words) <= window {
+		return float64(len(uniqueCount(words))) / float64(len(words))
+	}
+	counts := uniqueCount(words[:window])
+	sum := float64(len(counts)) / float64(window)
+	for i := window; i < len(words); i++ {
+		out := words[i-window]
+		if counts[out]--; counts[out] == 0 {
+			delete(counts, out)
+		}
+		counts[words[i]]++
+		sum += float64(len(counts)) / float64(window)
+	}
+	return sum / float64(len(words)-window+1)
+}
+
+func uniqueCount(words []string) map[string]int {
+	counts := make(map[string]int)
+	for _, w := range words {
+		counts[w]++
+	}
+	return counts
+}
+
+// functionWordProfile returns the share of function words in the text and
+// the Jensen–Shannon divergence between their distribution and the reference
+// human distribution.
+func functionWordProfile(words []string) (float64, float64) {
+	observed := make(map[string]float64)
+	var hits float64
+	for _, w := range words {
+		if _, ok := functionWordFrequencies[w]; ok {
+			observed[w]++
+			hits++
+		}
+	}
+	if hits == 0 {
+		return 0, 0
+	}
+
+	var refTotal float64
+	for _, f := range functionWordFrequencies {
+		refTotal += f
+	}
+	var jsd float64
+	for w, ref := range functionWordFrequencies {
+		p := observed[w] / hits
+		q := ref / refTotal
+		mid := (p + q) / 2
+		if p > 0 {
+			jsd += 0.5 * p * math.Log2(p/mid)
+		}
+		jsd += 0.5 * q * math.Log2(q/mid)
+	}
+	return hits / float64(len(words)), jsd
+}
+
+func ngrams(words []string, n int) []string {
+	if len(words) < n {
+		return nil
+	}
+	grams := make([]string, 0, len(words)-n+1)
+	for i := 0; i+n <= len(words); i++ {
+		grams = append(grams, strings.Join(words[i:i+n], " "))
+	}
+	return grams
+}
+
+func repeatedNgrams(words []string, n int) map[string]bool {
+	counts := make(map[string]int)
+	for _, g := range ngrams(words, n) {
+		counts[g]++
+	}
+	repeated := make(map[string]bool)
+	for g, c := range counts {
+		if c > 1 {
+			repeated[g] = true
+		}
+	}
+	return repeated
+}
+
+// repeatedNgramRate is the share of word n-gram occurrences whose n-gram
+// appears more than once in the text.
+func repeatedNgramRate(words []string, n int) float64 {
+	grams := ngrams(words, n)
+	if len(grams) == 0 {
+		return 0
+	}
+	repeated := repeatedNgrams(words, n)
+	hits := 0
+	for _, g := range grams {
+		if repeated[g] {
+			hits++
+		}
+	}
+	return float64(hits) / float64(len(grams))
+}
+
+// findTransitions lists every stock phrase in text. Longer phrases win, so
+// "plays a crucial role" is not also counted as "crucial".
+func findTransitions(text string) []string {
+	type hit struct {
+		phrase string
+		span   textutil.Span
+	}
+	var hits []hit
+	for _, phrase := range transitionPhrasesByLength {
+		for _, m := range textutil.FindWord(text, phrase) {
+			overlaps := false
+			for _, h := range hits {
+				if m.Start < h.span.End && h.span.Start < m.End {
+					overlaps = true
+					break
+				}
+			}
+			if !overlaps {
+				hits = append(hits, hit{phrase, m})
+			}
+		}
+	}
+	sort.Slice(hits, func(i, j int) bool { return hits[i].span.Start < hits[j].span.Start })
+	found := make([]string, len(hits))
+	for i, h := range hits {
+		found[i] = h.phrase
+	}
+	return found
+}
+
+var transitionPhrasesByLength = func() []string {
+	phrases := append([]string(nil), transitionPhrases...)
+	sort.SliceStable(phrases, func(i, j int) bool { return len(phrases[i]) > len(phrases[j]) })
+	return phrases
+}()
+
+func meanVariance(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	var sq float64
+	for _, v := range values {
+		sq += (v - mean) * (v - mean)
+	}
+	return mean, sq / float64(len(values))
+}
+
+func sigmoid(x float64) float64 {
+	return 1 / (1 + math.Exp(-x))
+}
+
+func round(v float64, places int) float64 {
+	p := math.Pow(10, float64(places))
+	return math.Round(v*p)/p + 0 // + 0 normalizes -0
+}
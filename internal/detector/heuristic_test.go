@@ -0,0 +1,101 @@
+package detector
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var detectorTexts = []struct {
+	name string
+	text string
+}{
+	{name: "empty", text: ""},
+	{name: "one word", text: "Hello"},
+	{
+		name: "stock phrasing",
+		text: "Furthermore, it is important to note that the results are significant. " +
+			"Moreover, it is important to note that the approach is robust. " +
+			"In conclusion, it is important to note that the findings are promising. " +
+			"Additionally, the method is efficient and the method is scalable.",
+	},
+	{
+		name: "varied human prose",
+		text: "I missed the bus again. Typical! So I walked the four miles home, " +
+			"past the bakery that never seems to close, and by the time I got in " +
+			"my socks were soaked through.\nMum laughed. I didn't.",
+	},
+	{
+		name: "multi-byte",
+		text: "Café owners in Zürich — naïvely, perhaps — expected 🚀 growth. " +
+			"„Nobody,“ said Zoë, “reads the fine print.” 日本語の文も混ざる。 " +
+			"Dr. Müller disagreed! The numbers, e.g. revenue, told another story.",
+	},
+}
+
+func TestAnalyzeIsDeterministic(t *testing.T) {
+	for _, tt := range detectorTexts {
+		t.Run(tt.name, func(t *testing.T) {
+			first := Analyze(tt.text)
+			for i := 0; i < 3; i++ {
+				if again := Analyze(tt.text); !reflect.DeepEqual(again, first) {
+					t.Fatalf("run %d = %+v, want %+v", i+2, again, first)
+				}
+			}
+		})
+	}
+}
+
+func TestAnalyzeScoresInRange(t *testing.T) {
+	extreme := &Model{Intercept: 50, Weights: map[string]float64{FeatureTransitionDensity: 1e6}}
+	for _, tt := range detectorTexts {
+		for _, m := range []*Model{DefaultModel, extreme} {
+			report := m.Analyze(tt.text)
+			if report.Score < 0 || report.Score > 100 {
+				t.Errorf("%s: score %v outside 0-100", tt.name, report.Score)
+			}
+			for _, s := range report.Sentences {
+				if s.Score < 0 || s.Score > 100 {
+					t.Errorf("%s: sentence %d score %v outside 0-100", tt.name, s.Index, s.Score)
+				}
+			}
+		}
+	}
+}
+
+// Sentence offsets count characters, not bytes, so that clients can slice
+// the text they sent with them.
+func TestAnalyzeSentenceOffsets(t *testing.T) {
+	for _, tt := range detectorTexts {
+		t.Run(tt.name, func(t *testing.T) {
+			runes := []rune(tt.text)
+			report := Analyze(tt.text)
+			end := 0
+			for i, s := range report.Sentences {
+				if s.Index != i {
+					t.Errorf("sentence %d has index %d", i, s.Index)
+				}
+				if s.Start < end || s.End < s.Start || s.End > len(runes) {
+					t.Fatalf("sentence %d spans [%d, %d) after %d in %d characters", i, s.Start, s.End, end, len(runes))
+				}
+				if got := string(runes[s.Start:s.End]); got != s.Text {
+					t.Errorf("sentence %d: text[%d:%d] = %q, want %q", i, s.Start, s.End, got, s.Text)
+				}
+				end = s.End
+			}
+			if strings.TrimSpace(tt.text) != "" && len(report.Sentences) == 0 {
+				t.Error("no sentences")
+			}
+		})
+	}
+}
+
+func TestAnalyzeFlagsStockPhrasing(t *testing.T) {
+	stock, human := Analyze(detectorTexts[2].text), Analyze(detectorTexts[3].text)
+	if stock.Score <= human.Score {
+		t.Errorf("stock phrasing scored %v, not above varied prose at %v", stock.Score, human.Score)
+	}
+	if stock.Features.TransitionDensity == 0 || stock.Features.RepeatedNgramRate == 0 {
+		t.Errorf("stock phrasing features = %+v, want transitions and repeated n-grams", stock.Features)
+	}
+}
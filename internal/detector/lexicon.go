@@ -0,0 +1,30 @@
+package detector
+
+// functionWordFrequencies are relative frequencies of common English function
+// words in general human-written prose (approximated from the Brown corpus).
+// They are normalized over this set before use.
+var functionWordFrequencies = map[string]float64{
+	"the": 6.9, "of": 3.6, "and": 2.9, "to": 2.6, "a": 2.3, "in": 2.1, "that": 1.05, "is": 1.0,
+	"was": 0.98, "he": 0.96, "for": 0.94, "it": 0.9, "with": 0.72, "as": 0.71, "his": 0.69,
+	"on": 0.67, "be": 0.63, "at": 0.54, "by": 0.52, "i": 0.51, "this": 0.51, "had": 0.51,
+	"not": 0.46, "are": 0.44, "but": 0.44, "from": 0.43, "or": 0.41, "have": 0.39, "an": 0.37,
+	"they": 0.36, "which": 0.35, "one": 0.33, "you": 0.33, "were": 0.33, "her": 0.31, "all": 0.3,
+	"she": 0.28, "there": 0.27, "would": 0.27, "their": 0.26, "we": 0.26, "him": 0.25, "been": 0.24,
+	"has": 0.24, "when": 0.21, "who": 0.21, "will": 0.22, "more": 0.22, "if": 0.22, "so": 0.2,
+	"can": 0.18, "these": 0.14, "such": 0.11, "its": 0.15, "also": 0.12, "our": 0.11,
+}
+
+// transitionPhrases are connectives and stock phrases that language models
+// overuse relative to human writers.
+var transitionPhrases = []string{
+	"moreover", "furthermore", "additionally", "in addition", "in conclusion", "to summarize",
+	"in summary", "overall", "ultimately", "notably", "importantly", "consequently",
+	"it is important to note", "it's important to note", "it is worth noting", "it's worth noting",
+	"delve", "delves", "delving", "in today's", "fast-paced", "ever-evolving", "ever-changing",
+	"plays a crucial role", "plays a vital role", "plays a pivotal role", "crucial", "pivotal",
+	"navigate", "navigating", "landscape", "realm", "tapestry", "testament", "underscore",
+	"underscores", "seamless", "seamlessly", "leverage", "leveraging", "robust", "foster",
+	"fostering", "holistic", "multifaceted", "nuanced", "paramount", "embark", "unlock",
+	"harness", "in the realm of", "a myriad of", "myriad", "not only", "on the other hand",
+	"when it comes to", "as a result", "in essence", "first and foremost",
+}
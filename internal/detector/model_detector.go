@@ -0,0 +1,66 @@
+package detector
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/victor-butita/rephrase/internal/textutil"
+)
+
+// ModelDetector calls a locally hosted classifier (for example a fine-tuned
+// RoBERTa detector behind a small HTTP wrapper). The endpoint receives
+//
+//	{"text": "...", "sentences": ["...", ...]}
+//
+// and must answer with
+//
+//	{"score": <0-100>, "sentence_scores": [<0-100>, ...]}
+//
+// where sentence_scores is optional but, if present, aligned with sentences.
+type ModelDetector struct {
+	URL        string
+	HTTPClient *http.Client
+}
+
+func NewModelDetector(url string) *ModelDetector {
+	return &ModelDetector{URL: url, HTTPClient: &http.Client{Timeout: 30 * time.Second}}
+}
+
+func (d *ModelDetector) Name() string { return "local_model" }
+
+func (d *ModelDetector) Score(text string, sentences []textutil.Span) (*Verdict, error) {
+	texts := make([]string, len(sentences))
+	for i, s := range sentences {
+		texts[i] = s.Text
+	}
+	body, err := json.Marshal(map[string]interface{}{"text": text, "sentences": texts})
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling request body: %w", err)
+	}
+
+	resp, err := d.HTTPClient.Post(d.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("local model request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("local model error (status %d): %s", resp.StatusCode, string(msg))
+	}
+
+	var out struct {
+		Score          float64   `json:"score"`
+		SentenceScores []float64 `json:"sentence_scores"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("could not parse local model response: %w", err)
+	}
+	if len(out.SentenceScores) != len(sentences) {
+		out.SentenceScores = nil
+	}
+	return &Verdict{Score: out.Score, SentenceScores: out.SentenceScores}, nil
+}
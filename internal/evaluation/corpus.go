@@ -0,0 +1,110 @@
+package evaluation
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	LabelAI    = "ai"
+	LabelHuman = "human"
+)
+
+type Document struct {
+	ID    string `json:"id"`
+	Text  string `json:"text"`
+	Label string `json:"label"`
+}
+
+// LoadCorpus reads a labeled corpus from either
+//
+//   - a JSONL file with one {"id", "text", "label": "ai"|"human"} per line, or
+//   - a directory containing "ai" and "human" subdirectories of .txt/.md files.
+func LoadCorpus(path string) ([]Document, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	var docs []Document
+	if info.IsDir() {
+		docs, err = loadDirectory(path)
+	} else {
+		docs, err = loadJSONL(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var ai, human int
+	for _, d := range docs {
+		if d.Label == LabelAI {
+			ai++
+		} else {
+			human++
+		}
+	}
+	if ai == 0 || human == 0 {
+		return nil, fmt.Errorf("corpus needs both ai and human texts (found %d ai, %d human)", ai, human)
+	}
+	return docs, nil
+}
+
+func loadJSONL(path string) ([]Document, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var docs []Document
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		raw := strings.TrimSpace(scanner.Text())
+		if raw == "" {
+			continue
+		}
+		var d Document
+		if err := json.Unmarshal([]byte(raw), &d); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		d.Label = strings.ToLower(strings.TrimSpace(d.Label))
+		if d.Label != LabelAI && d.Label != LabelHuman {
+			return nil, fmt.Errorf("%s:%d: label must be %q or %q", path, line, LabelAI, LabelHuman)
+		}
+		if d.ID == "" {
+			d.ID = fmt.Sprintf("line-%d", line)
+		}
+		docs = append(docs, d)
+	}
+	return docs, scanner.Err()
+}
+
+func loadDirectory(root string) ([]Document, error) {
+	var docs []Document
+	for _, label := range []string{LabelAI, LabelHuman} {
+		dir := filepath.Join(root, label)
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", dir, err)
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+		for _, e := range entries {
+			ext := strings.ToLower(filepath.Ext(e.Name()))
+			if e.IsDir() || (ext != ".txt" && ext != ".md") {
+				continue
+			}
+			b, err := os.ReadFile(filepath.Join(dir, e.Name()))
+			if err != nil {
+				return nil, err
+			}
+			docs = append(docs, Document{ID: label + "/" + e.Name(), Text: string(b), Label: label})
+		}
+	}
+	return docs, nil
+}
@@ -0,0 +1,196 @@
+// Package evaluation measures how well detection scores separate labeled
+// human and AI texts.
+package evaluation
+
+import (
+	"math"
+	"sort"
+)
+
+// Sample is one scored corpus item. Score is a 0-100 AI likelihood.
+type Sample struct {
+	Score float64
+	IsAI  bool
+}
+
+type ROCPoint struct {
+	Threshold float64 `json:"threshold"`
+	FPR       float64 `json:"fpr"`
+	TPR       float64 `json:"tpr"`
+}
+
+type ThresholdMetrics struct {
+	Threshold float64 `json:"threshold"`
+	Precision float64 `json:"precision"`
+	Recall    float64 `json:"recall"`
+	F1        float64 `json:"f1"`
+	Accuracy  float64 `json:"accuracy"`
+	FPR       float64 `json:"false_positive_rate"`
+	TP        int     `json:"tp"`
+	FP        int     `json:"fp"`
+	TN        int     `json:"tn"`
+	FN        int     `json:"fn"`
+}
+
+type CalibrationBin struct {
+	Lower         float64 `json:"lower"`
+	Upper         float64 `json:"upper"`
+	Count         int     `json:"count"`
+	MeanPredicted float64 `json:"mean_predicted"`
+	ObservedAI    float64 `json:"observed_ai_rate"`
+}
+
+type Metrics struct {
+	Samples     int                `json:"samples"`
+	Positives   int                `json:"ai_samples"`
+	Negatives   int                `json:"human_samples"`
+	AUC         float64            `json:"roc_auc"`
+	Brier       float64            `json:"brier_score"`
+	ECE         float64            `json:"expected_calibration_error"`
+	ROC         []ROCPoint         `json:"roc"`
+	Thresholds  []ThresholdMetrics `json:"thresholds"`
+	Calibration []CalibrationBin   `json:"calibration"`
+}
+
+// Compute evaluates samples at the given thresholds with bins calibration
+// buckets.
+func Compute(samples []Sample, thresholds []float64, bins int) Metrics {
+	m := Metrics{Samples: len(samples)}
+	for _, s := range samples {
+		if s.IsAI {
+			m.Positives++
+		} else {
+			m.Negatives++
+		}
+	}
+	m.ROC, m.AUC = roc(samples, m.Positives, m.Negatives)
+	for _, t := range thresholds {
+		m.Thresholds = append(m.Thresholds, atThreshold(samples, t))
+	}
+	m.Calibration, m.ECE = calibration(samples, bins)
+	m.Brier = brier(samples)
+	return m
+}
+
+// roc sweeps the threshold from high to low. Samples with equal scores are
+// stepped over together, which makes the trapezoidal AUC equal to the
+// Mann-Whitney probability with ties counted as one half.
+func roc(samples []Sample, positives, negatives int) ([]ROCPoint, float64) {
+	if positives == 0 || negatives == 0 {
+		return nil, 0
+	}
+	sorted := append([]Sample(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Score > sorted[j].Score })
+
+	// Scores are 0-100, so a threshold above 100 classifies nothing as AI.
+	points := []ROCPoint{{Threshold: 101, FPR: 0, TPR: 0}}
+	var tp, fp int
+	var auc float64
+	for i := 0; i < len(sorted); {
+		score := sorted[i].Score
+		for i < len(sorted) && sorted[i].Score == score {
+			if sorted[i].IsAI {
+				tp++
+			} else {
+				fp++
+			}
+			i++
+		}
+		prev := points[len(points)-1]
+		p := ROCPoint{Threshold: score, FPR: float64(fp) / float64(negatives), TPR: float64(tp) / float64(positives)}
+		auc += (p.FPR - prev.FPR) * (p.TPR + prev.TPR) / 2
+		points = append(points, p)
+	}
+	return points, auc
+}
+
+// atThreshold classifies scores >= threshold as AI.
+func atThreshold(samples []Sample, threshold float64) ThresholdMetrics {
+	m := ThresholdMetrics{Threshold: threshold}
+	for _, s := range samples {
+		predicted := s.Score >= threshold
+		switch {
+		case predicted && s.IsAI:
+			m.TP++
+		case predicted && !s.IsAI:
+			m.FP++
+		case !predicted && s.IsAI:
+			m.FN++
+		default:
+			m.TN++
+		}
+	}
+	m.Precision = ratio(m.TP, m.TP+m.FP)
+	m.Recall = ratio(m.TP, m.TP+m.FN)
+	m.FPR = ratio(m.FP, m.FP+m.TN)
+	m.Accuracy = ratio(m.TP+m.TN, len(samples))
+	if m.Precision+m.Recall > 0 {
+		m.F1 = 2 * m.Precision * m.Recall / (m.Precision + m.Recall)
+	}
+	return m
+}
+
+// calibration buckets scores into equal-width bins and compares the mean
+// predicted probability with the observed share of AI texts in each bin. The
+// expected calibration error is the count-weighted mean gap.
+func calibration(samples []Sample, bins int) ([]CalibrationBin, float64) {
+	if bins <= 0 {
+		bins = 10
+	}
+	out := make([]CalibrationBin, bins)
+	sums := make([]float64, bins)
+	ais := make([]int, bins)
+	for i := range out {
+		out[i].Lower = float64(i) / float64(bins)
+		out[i].Upper = float64(i+1) / float64(bins)
+	}
+	for _, s := range samples {
+		p := clamp01(s.Score / 100)
+		b := int(p * float64(bins))
+		if b == bins {
+			b--
+		}
+		out[b].Count++
+		sums[b] += p
+		if s.IsAI {
+			ais[b]++
+		}
+	}
+	var ece float64
+	for i := range out {
+		if out[i].Count == 0 {
+			continue
+		}
+		out[i].MeanPredicted = sums[i] / float64(out[i].Count)
+		out[i].ObservedAI = float64(ais[i]) / float64(out[i].Count)
+		ece += float64(out[i].Count) / float64(len(samples)) * math.Abs(out[i].MeanPredicted-out[i].ObservedAI)
+	}
+	return out, ece
+}
+
+func brier(samples []Sample) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, s := range samples {
+		outcome := 0.0
+		if s.IsAI {
+			outcome = 1
+		}
+		d := clamp01(s.Score/100) - outcome
+		sum += d * d
+	}
+	return sum / float64(len(samples))
+}
+
+func ratio(a, b int) float64 {
+	if b == 0 {
+		return 0
+	}
+	return float64(a) / float64(b)
+}
+
+func clamp01(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
@@ -0,0 +1,117 @@
+package evaluation
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"os"
+	"strings"
+)
+
+// WriteJSON writes the full report, including per-document scores.
+func (r *Report) WriteJSON(path string) error {
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0o644)
+}
+
+// WriteHTML writes a self-contained page with ROC and calibration charts for
+// every scored pipeline.
+func (r *Report) WriteHTML(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return reportTemplate.Execute(f, r)
+}
+
+const chartSize = 240
+
+// rocPoints and calibrationPoints map unit-square data to SVG coordinates
+// with the origin in the bottom-left corner.
+func rocPoints(points []ROCPoint) string {
+	parts := make([]string, len(points))
+	for i, p := range points {
+		parts[i] = svgPoint(p.FPR, p.TPR)
+	}
+	return strings.Join(parts, " ")
+}
+
+func calibrationPoints(bins []CalibrationBin) string {
+	var parts []string
+	for _, b := range bins {
+		if b.Count > 0 {
+			parts = append(parts, svgPoint(b.MeanPredicted, b.ObservedAI))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func svgPoint(x, y float64) string {
+	return fmt.Sprintf("%.1f,%.1f", x*chartSize, (1-y)*chartSize)
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"rocPoints":         rocPoints,
+	"calibrationPoints": calibrationPoints,
+	"metrics":           func(r *Report, name string) Metrics { return r.Metrics[name] },
+	"pct":               func(v float64) string { return fmt.Sprintf("%.1f%%", v*100) },
+	"num":               func(v float64) string { return fmt.Sprintf("%.3f", v) },
+	"size":              func() int { return chartSize },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="UTF-8">
+<title>Detection evaluation</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif; margin: 2rem; color: #1f2937; }
+h2 { margin-top: 2.5rem; border-bottom: 1px solid #e5e7eb; padding-bottom: .3rem; }
+table { border-collapse: collapse; margin: 1rem 0; }
+th, td { border: 1px solid #e5e7eb; padding: .3rem .7rem; text-align: right; }
+th { background: #f9fafb; }
+.charts { display: flex; gap: 2rem; flex-wrap: wrap; }
+figure { margin: 0; }
+svg { background: #f9fafb; border: 1px solid #e5e7eb; overflow: visible; }
+.diag { stroke: #9ca3af; stroke-dasharray: 4 4; }
+.curve { fill: none; stroke: #4f46e5; stroke-width: 2; }
+</style>
+</head>
+<body>
+<h1>Detection evaluation</h1>
+<p>Corpus <code>{{.Corpus}}</code>, weights <code>{{.Weights}}</code>, generated {{.GeneratedAt.Format "2006-01-02 15:04 MST"}}.
+{{if .Failed}}<strong>{{.Failed}} document(s) could not be scored.</strong>{{end}}</p>
+{{range $name := .Names}}{{with metrics $ $name}}
+<h2>{{$name}}</h2>
+<p>{{.Samples}} texts ({{.Positives}} AI, {{.Negatives}} human).
+ROC-AUC <strong>{{num .AUC}}</strong>, Brier score {{num .Brier}}, expected calibration error {{num .ECE}}.</p>
+<table>
+<tr><th>Threshold</th><th>Precision</th><th>Recall</th><th>F1</th><th>Accuracy</th><th>False positive rate</th><th>TP</th><th>FP</th><th>TN</th><th>FN</th></tr>
+{{range .Thresholds}}<tr><td>{{.Threshold}}</td><td>{{pct .Precision}}</td><td>{{pct .Recall}}</td><td>{{num .F1}}</td><td>{{pct .Accuracy}}</td><td>{{pct .FPR}}</td><td>{{.TP}}</td><td>{{.FP}}</td><td>{{.TN}}</td><td>{{.FN}}</td></tr>
+{{end}}</table>
+<div class="charts">
+<figure>
+<svg width="{{size}}" height="{{size}}" viewBox="0 0 {{size}} {{size}}">
+<line class="diag" x1="0" y1="{{size}}" x2="{{size}}" y2="0"/>
+<polyline class="curve" points="{{rocPoints .ROC}}"/>
+</svg>
+<figcaption>ROC curve (false positive rate vs. true positive rate)</figcaption>
+</figure>
+<figure>
+<svg width="{{size}}" height="{{size}}" viewBox="0 0 {{size}} {{size}}">
+<line class="diag" x1="0" y1="{{size}}" x2="{{size}}" y2="0"/>
+<polyline class="curve" points="{{calibrationPoints .Calibration}}"/>
+</svg>
+<figcaption>Calibration (mean predicted vs. observed AI rate)</figcaption>
+</figure>
+</div>
+<table>
+<tr><th>Bin</th><th>Texts</th><th>Mean predicted</th><th>Observed AI rate</th></tr>
+{{range .Calibration}}<tr><td>{{num .Lower}}–{{num .Upper}}</td><td>{{.Count}}</td><td>{{num .MeanPredicted}}</td><td>{{num .ObservedAI}}</td></tr>
+{{end}}</table>
+{{end}}{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,110 @@
+package evaluation
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/victor-butita/rephrase/internal/detector"
+)
+
+// EnsembleName is the key the combined score is reported under.
+const EnsembleName = "ensemble"
+
+type ScoredDocument struct {
+	ID        string             `json:"id"`
+	Label     string             `json:"label"`
+	Score     *float64           `json:"score"`
+	Detectors map[string]float64 `json:"detectors,omitempty"`
+	Errors    map[string]string  `json:"errors,omitempty"`
+}
+
+type Report struct {
+	GeneratedAt time.Time          `json:"generated_at"`
+	Corpus      string             `json:"corpus"`
+	Weights     string             `json:"weights"`
+	Failed      int                `json:"failed_documents"`
+	Metrics     map[string]Metrics `json:"metrics"`
+	Documents   []ScoredDocument   `json:"documents"`
+}
+
+// Names lists the scored pipelines with the ensemble first.
+func (r *Report) Names() []string {
+	names := make([]string, 0, len(r.Metrics))
+	for name := range r.Metrics {
+		if name != EnsembleName {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if _, ok := r.Metrics[EnsembleName]; ok {
+		names = append([]string{EnsembleName}, names...)
+	}
+	return names
+}
+
+// Run scores every document with the ensemble and computes metrics for the
+// combined score and for each detector on its own. Documents the ensemble
+// could not score at all are counted in Failed and left out of the metrics.
+func Run(docs []Document, ensemble *detector.Ensemble, thresholds []float64, bins, workers int) *Report {
+	if workers < 1 {
+		workers = 1
+	}
+	scored := make([]ScoredDocument, len(docs))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				scored[j] = score(docs[j], ensemble)
+			}
+		}()
+	}
+	for i := range docs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	samples := make(map[string][]Sample)
+	report := &Report{GeneratedAt: time.Now().UTC(), Metrics: make(map[string]Metrics), Documents: scored}
+	for _, d := range scored {
+		isAI := d.Label == LabelAI
+		if d.Score == nil {
+			report.Failed++
+			continue
+		}
+		samples[EnsembleName] = append(samples[EnsembleName], Sample{Score: *d.Score, IsAI: isAI})
+		for name, s := range d.Detectors {
+			samples[name] = append(samples[name], Sample{Score: s, IsAI: isAI})
+		}
+	}
+	for name, s := range samples {
+		report.Metrics[name] = Compute(s, thresholds, bins)
+	}
+	return report
+}
+
+func score(doc Document, ensemble *detector.Ensemble) ScoredDocument {
+	out := ScoredDocument{ID: doc.ID, Label: doc.Label}
+	result, err := ensemble.Detect(doc.Text)
+	if err != nil {
+		out.Errors = map[string]string{EnsembleName: err.Error()}
+		return out
+	}
+	out.Score = &result.Score
+	out.Detectors = make(map[string]float64)
+	for _, ds := range result.Detectors {
+		if ds.Score != nil {
+			out.Detectors[ds.Name] = *ds.Score
+			continue
+		}
+		if out.Errors == nil {
+			out.Errors = make(map[string]string)
+		}
+		out.Errors[ds.Name] = ds.Error
+	}
+	return out
+}
@@ -0,0 +1,230 @@
+// Package export renders results as Markdown, standalone HTML, DOCX and
+// PDF. Results are first built into a small format-neutral Document, which
+// each renderer then writes out; all rendering is pure Go and offline.
+package export
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+const (
+	FormatMarkdown = "md"
+	FormatHTML     = "html"
+	FormatDOCX     = "docx"
+	FormatPDF      = "pdf"
+)
+
+// Mark is a semantic highlight; each renderer picks its own colors.
+type Mark string
+
+const (
+	MarkNone   Mark = ""
+	MarkHigh   Mark = "high"
+	MarkMedium Mark = "medium"
+	MarkMatch  Mark = "match"
+	MarkIssue  Mark = "issue"
+)
+
+// Run is a span of text with uniform formatting. A "\n" inside Text is a
+// line break.
+type Run struct {
+	Text   string
+	Bold   bool
+	Italic bool
+	Mark   Mark
+	// Note explains a highlight (a tooltip in HTML).
+	Note string
+	Link string
+}
+
+type BlockKind int
+
+const (
+	Heading BlockKind = iota
+	Paragraph
+	BulletList
+	NumberedList
+	Quote
+)
+
+// Block is a heading, paragraph or quote made of Runs, or a list whose
+// Items are each a sequence of runs.
+type Block struct {
+	Kind  BlockKind
+	Level int
+	Runs  []Run
+	Items [][]Run
+}
+
+type Document struct {
+	Title  string
+	Blocks []Block
+}
+
+func (d *Document) Heading(level int, text string) {
+	d.Blocks = append(d.Blocks, Block{Kind: Heading, Level: level, Runs: []Run{{Text: text}}})
+}
+
+func (d *Document) Paragraph(runs ...Run) {
+	d.Blocks = append(d.Blocks, Block{Kind: Paragraph, Runs: runs})
+}
+
+func (d *Document) Quote(runs ...Run) {
+	d.Blocks = append(d.Blocks, Block{Kind: Quote, Runs: runs})
+}
+
+// PlainText returns the blocks' text without formatting, blocks separated by
+// blank lines and list items on lines of their own. The title is left out.
+func (d *Document) PlainText() string {
+	runsText := func(runs []Run) string {
+		var b strings.Builder
+		for _, r := range runs {
+			b.WriteString(r.Text)
+		}
+		return b.String()
+	}
+	parts := make([]string, 0, len(d.Blocks))
+	for _, block := range d.Blocks {
+		if block.Kind == BulletList || block.Kind == NumberedList {
+			items := make([]string, len(block.Items))
+			for i, item := range block.Items {
+				items[i] = runsText(item)
+			}
+			parts = append(parts, strings.Join(items, "\n"))
+			continue
+		}
+		parts = append(parts, runsText(block.Runs))
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// Text adds one paragraph per blank-line-separated block of text.
+func (d *Document) Text(text string) {
+	for _, p := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n\n") {
+		if p = strings.TrimSpace(p); p != "" {
+			d.Paragraph(Run{Text: p})
+		}
+	}
+}
+
+func (d *Document) List(numbered bool, items ...[]Run) {
+	if len(items) == 0 {
+		return
+	}
+	kind := BulletList
+	if numbered {
+		kind = NumberedList
+	}
+	d.Blocks = append(d.Blocks, Block{Kind: kind, Items: items})
+}
+
+// Bullets adds a bulleted list of plain-text items.
+func (d *Document) Bullets(items []string) {
+	var runs [][]Run
+	for _, item := range items {
+		runs = append(runs, []Run{{Text: item}})
+	}
+	d.List(false, runs...)
+}
+
+// Span marks the character (rune) offsets [Start, End) of a text.
+type Span struct {
+	Start int
+	End   int
+	Mark  Mark
+	Note  string
+}
+
+// Highlighted adds text as paragraphs with spans marked. Spans that are out
+// of range or overlap an earlier span are ignored.
+func (d *Document) Highlighted(text string, spans []Span) {
+	chars := []rune(strings.ReplaceAll(text, "\r\n", "\n"))
+	sorted := append([]Span{}, spans...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+
+	var runs []Run
+	last := 0
+	for _, s := range sorted {
+		if s.Start < last || s.End <= s.Start || s.End > len(chars) {
+			continue
+		}
+		if s.Start > last {
+			runs = append(runs, Run{Text: string(chars[last:s.Start])})
+		}
+		runs = append(runs, Run{Text: string(chars[s.Start:s.End]), Mark: s.Mark, Note: s.Note})
+		last = s.End
+	}
+	if last < len(chars) {
+		runs = append(runs, Run{Text: string(chars[last:])})
+	}
+
+	// Split the runs into paragraphs at blank lines.
+	var current []Run
+	flush := func() {
+		for len(current) > 0 && strings.TrimSpace(current[0].Text) == "" {
+			current = current[1:]
+		}
+		if len(current) > 0 {
+			current[0].Text = strings.TrimLeft(current[0].Text, " \n")
+			current[len(current)-1].Text = strings.TrimRight(current[len(current)-1].Text, " \n")
+			d.Paragraph(current...)
+		}
+		current = nil
+	}
+	for _, r := range runs {
+		parts := strings.Split(r.Text, "\n\n")
+		for i, part := range parts {
+			if i > 0 {
+				flush()
+			}
+			if part != "" {
+				piece := r
+				piece.Text = part
+				current = append(current, piece)
+			}
+		}
+	}
+	flush()
+}
+
+// Render writes doc in the given format.
+func Render(w io.Writer, doc *Document, format string) error {
+	switch format {
+	case FormatMarkdown:
+		return RenderMarkdown(w, doc)
+	case FormatHTML:
+		return RenderHTML(w, doc)
+	case FormatDOCX:
+		return RenderDOCX(w, doc)
+	case FormatPDF:
+		return RenderPDF(w, doc)
+	default:
+		return fmt.Errorf("unknown export format '%s'", format)
+	}
+}
+
+// ContentType returns the MIME type of a format.
+func ContentType(format string) string {
+	switch format {
+	case FormatMarkdown:
+		return "text/markdown; charset=utf-8"
+	case FormatHTML:
+		return "text/html; charset=utf-8"
+	case FormatDOCX:
+		return "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	case FormatPDF:
+		return "application/pdf"
+	}
+	return ""
+}
+
+func ValidFormat(format string) bool {
+	return ContentType(format) != ""
+}
+
+func percent(f float64) string {
+	return fmt.Sprintf("%.0f%%", f*100)
+}
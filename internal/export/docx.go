@@ -0,0 +1,180 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// docxHighlights maps marks to the highlight colors Word supports.
+var docxHighlights = map[Mark]string{
+	MarkHigh:   "red",
+	MarkMedium: "yellow",
+	MarkMatch:  "lightGray",
+	MarkIssue:  "cyan",
+}
+
+const docxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/>
+<Override PartName="/word/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.styles+xml"/>
+<Override PartName="/docProps/core.xml" ContentType="application/vnd.openxmlformats-package.core-properties+xml"/>
+</Types>`
+
+const docxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="word/document.xml"/>
+<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/package/2006/relationships/metadata/core-properties" Target="docProps/core.xml"/>
+</Relationships>`
+
+const docxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:styles xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+<w:docDefaults><w:rPrDefault><w:rPr><w:rFonts w:ascii="Calibri" w:hAnsi="Calibri" w:cs="Calibri" w:eastAsia="Calibri"/><w:sz w:val="22"/></w:rPr></w:rPrDefault><w:pPrDefault><w:pPr><w:spacing w:after="160" w:line="276" w:lineRule="auto"/></w:pPr></w:pPrDefault></w:docDefaults>
+<w:style w:type="paragraph" w:default="1" w:styleId="Normal"><w:name w:val="Normal"/></w:style>
+<w:style w:type="paragraph" w:styleId="Title"><w:name w:val="Title"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:pPr><w:spacing w:after="240"/></w:pPr><w:rPr><w:b/><w:sz w:val="48"/></w:rPr></w:style>
+<w:style w:type="paragraph" w:styleId="Heading1"><w:name w:val="heading 1"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:pPr><w:keepNext/><w:spacing w:before="360" w:after="120"/><w:outlineLvl w:val="0"/></w:pPr><w:rPr><w:b/><w:sz w:val="32"/></w:rPr></w:style>
+<w:style w:type="paragraph" w:styleId="Heading2"><w:name w:val="heading 2"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:pPr><w:keepNext/><w:spacing w:before="240" w:after="80"/><w:outlineLvl w:val="1"/></w:pPr><w:rPr><w:b/><w:sz w:val="26"/></w:rPr></w:style>
+<w:style w:type="paragraph" w:styleId="Heading3"><w:name w:val="heading 3"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:pPr><w:keepNext/><w:spacing w:before="200" w:after="60"/><w:outlineLvl w:val="2"/></w:pPr><w:rPr><w:b/><w:sz w:val="22"/></w:rPr></w:style>
+<w:style w:type="paragraph" w:styleId="Quote"><w:name w:val="Quote"/><w:basedOn w:val="Normal"/><w:pPr><w:ind w:left="567"/></w:pPr><w:rPr><w:i/><w:color w:val="404040"/></w:rPr></w:style>
+<w:style w:type="paragraph" w:styleId="ListParagraph"><w:name w:val="List Paragraph"/><w:basedOn w:val="Normal"/><w:pPr><w:spacing w:after="60"/><w:ind w:left="567" w:hanging="283"/></w:pPr></w:style>
+<w:style w:type="character" w:styleId="Hyperlink"><w:name w:val="Hyperlink"/><w:rPr><w:color w:val="4F46E5"/><w:u w:val="single"/></w:rPr></w:style>
+</w:styles>`
+
+// RenderDOCX writes an Office Open XML document. Lists are written as
+// indented paragraphs with literal markers so no numbering part is needed.
+func RenderDOCX(w io.Writer, doc *Document) error {
+	dw := &docxWriter{}
+	dw.paragraph("Title", []Run{{Text: doc.Title}}, "")
+	for _, b := range doc.Blocks {
+		switch b.Kind {
+		case Heading:
+			dw.paragraph(fmt.Sprintf("Heading%d", min(b.Level, 3)), b.Runs, "")
+		case Paragraph:
+			dw.paragraph("", b.Runs, "")
+		case Quote:
+			dw.paragraph("Quote", b.Runs, "")
+		case BulletList, NumberedList:
+			for i, item := range b.Items {
+				marker := "•\t"
+				if b.Kind == NumberedList {
+					marker = fmt.Sprintf("%d.\t", i+1)
+				}
+				dw.paragraph("ListParagraph", item, marker)
+			}
+		}
+	}
+
+	var document bytes.Buffer
+	document.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><w:body>`)
+	document.Write(dw.body.Bytes())
+	document.WriteString(`<w:sectPr><w:pgSz w:w="11906" w:h="16838"/><w:pgMar w:top="1440" w:right="1440" w:bottom="1440" w:left="1440" w:header="708" w:footer="708" w:gutter="0"/></w:sectPr></w:body></w:document>`)
+
+	var rels bytes.Buffer
+	rels.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rIdStyles" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>
+`)
+	for i, link := range dw.links {
+		fmt.Fprintf(&rels, `<Relationship Id="rIdLink%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/hyperlink" Target="%s" TargetMode="External"/>`+"\n", i+1, xmlEscape(link))
+	}
+	rels.WriteString(`</Relationships>`)
+
+	core := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/"><dc:title>%s</dc:title><dc:creator>Rephrase</dc:creator></cp:coreProperties>`, xmlEscape(doc.Title))
+
+	zw := zip.NewWriter(w)
+	parts := []struct {
+		name string
+		data []byte
+	}{
+		{"[Content_Types].xml", []byte(docxContentTypes)},
+		{"_rels/.rels", []byte(docxRootRels)},
+		{"docProps/core.xml", []byte(core)},
+		{"word/document.xml", document.Bytes()},
+		{"word/styles.xml", []byte(docxStyles)},
+		{"word/_rels/document.xml.rels", rels.Bytes()},
+	}
+	for _, p := range parts {
+		f, err := zw.Create(p.name)
+		if err != nil {
+			return fmt.Errorf("writing %s: %w", p.name, err)
+		}
+		if _, err := f.Write(p.data); err != nil {
+			return fmt.Errorf("writing %s: %w", p.name, err)
+		}
+	}
+	return zw.Close()
+}
+
+type docxWriter struct {
+	body  bytes.Buffer
+	links []string
+}
+
+func (dw *docxWriter) paragraph(style string, runs []Run, marker string) {
+	dw.body.WriteString("<w:p>")
+	if style != "" {
+		fmt.Fprintf(&dw.body, `<w:pPr><w:pStyle w:val="%s"/></w:pPr>`, style)
+	}
+	if marker != "" {
+		dw.run(Run{Text: marker})
+	}
+	for _, r := range runs {
+		if r.Link != "" {
+			dw.links = append(dw.links, r.Link)
+			fmt.Fprintf(&dw.body, `<w:hyperlink r:id="rIdLink%d">`, len(dw.links))
+			dw.run(r)
+			dw.body.WriteString("</w:hyperlink>")
+			continue
+		}
+		dw.run(r)
+	}
+	dw.body.WriteString("</w:p>")
+}
+
+func (dw *docxWriter) run(r Run) {
+	dw.body.WriteString("<w:r>")
+	var props strings.Builder
+	if r.Link != "" {
+		props.WriteString(`<w:rStyle w:val="Hyperlink"/>`)
+	}
+	if r.Bold {
+		props.WriteString("<w:b/>")
+	}
+	if r.Italic {
+		props.WriteString("<w:i/>")
+	}
+	if color, ok := docxHighlights[r.Mark]; ok {
+		fmt.Fprintf(&props, `<w:highlight w:val="%s"/>`, color)
+	}
+	if props.Len() > 0 {
+		dw.body.WriteString("<w:rPr>" + props.String() + "</w:rPr>")
+	}
+	for i, line := range strings.Split(r.Text, "\n") {
+		if i > 0 {
+			dw.body.WriteString("<w:br/>")
+		}
+		parts := strings.Split(line, "\t")
+		for j, part := range parts {
+			if j > 0 {
+				dw.body.WriteString("<w:tab/>")
+			}
+			if part != "" {
+				fmt.Fprintf(&dw.body, `<w:t xml:space="preserve">%s</w:t>`, xmlEscape(part))
+			}
+		}
+	}
+	dw.body.WriteString("</w:r>")
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
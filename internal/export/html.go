@@ -0,0 +1,76 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+const htmlStyle = `body { font-family: -apple-system, "Segoe UI", Roboto, Helvetica, Arial, sans-serif; max-width: 46rem; margin: 2.5rem auto; padding: 0 1.25rem; line-height: 1.65; color: #1f2937; }
+h1, h2, h3, h4 { line-height: 1.3; }
+h2 { border-bottom: 1px solid #e5e7eb; padding-bottom: 0.3rem; }
+blockquote { margin: 0.75rem 0; padding: 0.5rem 1rem; border-left: 4px solid #d1d5db; background: #f9fafb; }
+mark { padding: 0 0.1em; border-radius: 3px; }
+mark.high { background: #fecaca; }
+mark.medium { background: #fef08a; }
+mark.match { background: #fed7aa; }
+mark.issue { background: #bfdbfe; }
+a { color: #4f46e5; }
+footer { margin-top: 3rem; font-size: 0.8rem; color: #6b7280; }`
+
+// RenderHTML writes a standalone HTML page with inline styles.
+func RenderHTML(w io.Writer, doc *Document) error {
+	bw := bufio.NewWriter(w)
+	title := html.EscapeString(doc.Title)
+	fmt.Fprintf(bw, "<!DOCTYPE html>\n<html lang=\"en\">\n<head>\n<meta charset=\"utf-8\">\n<meta name=\"viewport\" content=\"width=device-width, initial-scale=1\">\n<title>%s</title>\n<style>\n%s\n</style>\n</head>\n<body>\n<h1>%s</h1>\n", title, htmlStyle, title)
+	for _, b := range doc.Blocks {
+		switch b.Kind {
+		case Heading:
+			level := min(b.Level+1, 6)
+			fmt.Fprintf(bw, "<h%d>%s</h%d>\n", level, htmlRuns(b.Runs), level)
+		case Paragraph:
+			fmt.Fprintf(bw, "<p>%s</p>\n", htmlRuns(b.Runs))
+		case Quote:
+			fmt.Fprintf(bw, "<blockquote>%s</blockquote>\n", htmlRuns(b.Runs))
+		case BulletList, NumberedList:
+			tag := "ul"
+			if b.Kind == NumberedList {
+				tag = "ol"
+			}
+			fmt.Fprintf(bw, "<%s>\n", tag)
+			for _, item := range b.Items {
+				fmt.Fprintf(bw, "<li>%s</li>\n", htmlRuns(item))
+			}
+			fmt.Fprintf(bw, "</%s>\n", tag)
+		}
+	}
+	bw.WriteString("<footer>Exported from Rephrase.</footer>\n</body>\n</html>\n")
+	return bw.Flush()
+}
+
+func htmlRuns(runs []Run) string {
+	var b strings.Builder
+	for _, r := range runs {
+		text := strings.ReplaceAll(html.EscapeString(r.Text), "\n", "<br>")
+		if r.Link != "" {
+			text = fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(r.Link), text)
+		}
+		if r.Italic {
+			text = "<em>" + text + "</em>"
+		}
+		if r.Bold {
+			text = "<strong>" + text + "</strong>"
+		}
+		if r.Mark != MarkNone {
+			title := ""
+			if r.Note != "" {
+				title = fmt.Sprintf(` title="%s"`, html.EscapeString(r.Note))
+			}
+			text = fmt.Sprintf(`<mark class="%s"%s>%s</mark>`, r.Mark, title, text)
+		}
+		b.WriteString(text)
+	}
+	return b.String()
+}
@@ -0,0 +1,78 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var markdownEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "]", `\]`, "<", "&lt;", ">", "&gt;", "#", `\#`)
+
+// RenderMarkdown writes GitHub-flavored Markdown. Highlights become <mark>
+// elements, which most Markdown viewers display.
+func RenderMarkdown(w io.Writer, doc *Document) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# %s\n", markdownEscaper.Replace(doc.Title))
+	for _, b := range doc.Blocks {
+		bw.WriteString("\n")
+		switch b.Kind {
+		case Heading:
+			fmt.Fprintf(bw, "%s %s\n", strings.Repeat("#", b.Level+1), markdownRuns(b.Runs, ""))
+		case Paragraph:
+			fmt.Fprintf(bw, "%s\n", markdownRuns(b.Runs, ""))
+		case Quote:
+			fmt.Fprintf(bw, "> %s\n", markdownRuns(b.Runs, "> "))
+		case BulletList, NumberedList:
+			for i, item := range b.Items {
+				marker := "-"
+				if b.Kind == NumberedList {
+					marker = fmt.Sprintf("%d.", i+1)
+				}
+				indent := strings.Repeat(" ", len(marker)+1)
+				fmt.Fprintf(bw, "%s %s\n", marker, markdownRuns(item, indent))
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// markdownRuns renders runs inline; line breaks continue with prefix.
+func markdownRuns(runs []Run, prefix string) string {
+	var b strings.Builder
+	for _, r := range runs {
+		lines := strings.Split(r.Text, "\n")
+		for i, line := range lines {
+			if i > 0 {
+				b.WriteString("  \n" + prefix)
+			}
+			if strings.TrimSpace(line) == "" {
+				b.WriteString(line)
+				continue
+			}
+			// Emphasis markers must hug the text, so keep surrounding spaces outside.
+			core := strings.TrimSpace(line)
+			lead := line[:strings.Index(line, core)]
+			trail := line[len(lead)+len(core):]
+			text := markdownEscaper.Replace(core)
+			if r.Link != "" {
+				text = fmt.Sprintf("[%s](%s)", text, strings.ReplaceAll(r.Link, ")", "%29"))
+			}
+			if r.Italic {
+				text = "_" + text + "_"
+			}
+			if r.Bold {
+				text = "**" + text + "**"
+			}
+			if r.Mark != MarkNone {
+				title := ""
+				if r.Note != "" {
+					title = fmt.Sprintf(` title="%s"`, strings.ReplaceAll(markdownEscaper.Replace(r.Note), `"`, "&quot;"))
+				}
+				text = fmt.Sprintf(`<mark class="%s"%s>%s</mark>`, r.Mark, title, text)
+			}
+			b.WriteString(lead + text + trail)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,327 @@
+package export
+
+import (
+	"embed"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/go-pdf/fpdf"
+)
+
+const (
+	pdfMargin     = 20.0
+	pdfFontSize   = 11.0
+	pdfLineHeight = 5.8
+
+	// pdfFont covers Latin, Greek, Cyrillic, Arabic and Hebrew; pdfCJKFont
+	// covers Chinese, Japanese and Korean, and has no bold or italic faces.
+	pdfFont    = "dejavu"
+	pdfCJKFont = "wqy"
+)
+
+//go:embed fonts/*.ttf
+var pdfFonts embed.FS
+
+var pdfFontFiles = map[string]string{
+	pdfFont:        "DejaVuSansCondensed.ttf",
+	pdfFont + "B":  "DejaVuSansCondensed-Bold.ttf",
+	pdfFont + "I":  "DejaVuSansCondensed-Oblique.ttf",
+	pdfFont + "BI": "DejaVuSansCondensed-BoldOblique.ttf",
+	pdfCJKFont:     "WenQuanYiMicroHei.ttf",
+}
+
+var pdfHighlights = map[Mark][3]int{
+	MarkHigh:   {254, 202, 202},
+	MarkMedium: {254, 240, 138},
+	MarkMatch:  {254, 215, 170},
+	MarkIssue:  {191, 219, 254},
+}
+
+// RenderPDF writes an A4 PDF. Text is set in embedded Unicode fonts, so
+// results in any language print without network access; right-to-left
+// paragraphs are right-aligned.
+func RenderPDF(w io.Writer, doc *Document) error {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pw := &pdfWriter{pdf: pdf, loaded: map[string]bool{}}
+	pdf.SetMargins(pdfMargin, pdfMargin, pdfMargin)
+	pdf.SetAutoPageBreak(true, pdfMargin)
+	pdf.SetTitle(doc.Title, true)
+	pdf.SetCreator("Rephrase", true)
+	pdf.AliasNbPages("")
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-12)
+		pw.setFont(pdfFont, "", 8)
+		pdf.SetTextColor(107, 114, 128)
+		pdf.CellFormat(0, 5, fmt.Sprintf("%d / {nb}", pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+	pdf.AddPage()
+
+	pw.heading(doc.Title, 20)
+	for _, b := range doc.Blocks {
+		switch b.Kind {
+		case Heading:
+			size := map[int]float64{1: 15, 2: 12.5}[b.Level]
+			if size == 0 {
+				size = 11.5
+			}
+			pdf.Ln(2)
+			pw.heading(b.Runs[0].Text, size)
+		case Paragraph:
+			pw.runs(b.Runs, 0, "")
+			pdf.Ln(2.5)
+		case Quote:
+			pdf.SetTextColor(64, 64, 64)
+			pw.runs(b.Runs, 8, "")
+			pdf.SetTextColor(31, 41, 55)
+			pdf.Ln(2.5)
+		case BulletList, NumberedList:
+			for i, item := range b.Items {
+				marker := "•"
+				if b.Kind == NumberedList {
+					marker = fmt.Sprintf("%d.", i+1)
+				}
+				pw.runs(item, 7, marker)
+				pdf.Ln(1)
+			}
+			pdf.Ln(1.5)
+		}
+	}
+	if err := pdf.Error(); err != nil {
+		return fmt.Errorf("rendering pdf: %w", err)
+	}
+	return pdf.Output(w)
+}
+
+type pdfWriter struct {
+	pdf *fpdf.Fpdf
+	// loaded records the font faces added to the document. Faces are only
+	// added once used, since parsing the CJK font takes a while.
+	loaded map[string]bool
+}
+
+func (pw *pdfWriter) setFont(family, style string, size float64) {
+	if key := family + style; !pw.loaded[key] {
+		pw.loaded[key] = true
+		data, err := pdfFonts.ReadFile("fonts/" + pdfFontFiles[key])
+		if err != nil {
+			pw.pdf.SetError(err)
+			return
+		}
+		pw.pdf.AddUTF8FontFromBytes(family, style, data)
+	}
+	pw.pdf.SetFont(family, style, size)
+}
+
+func (pw *pdfWriter) heading(text string, size float64) {
+	pw.pdf.SetTextColor(17, 24, 39)
+	pw.layout([]Run{{Text: text, Bold: true}}, size, size*0.5, 0, "")
+	pw.pdf.Ln(1.5)
+	pw.pdf.SetTextColor(31, 41, 55)
+}
+
+func (pw *pdfWriter) runs(runs []Run, indent float64, marker string) {
+	pw.layout(runs, pdfFontSize, pdfLineHeight, indent, marker)
+}
+
+// pdfItem is a word or a space, ready to draw.
+type pdfItem struct {
+	text   string
+	family string
+	style  string
+	width  float64
+	space  bool
+	// dir is 1 for left-to-right words, -1 for right-to-left ones and 0 for
+	// spaces and punctuation, which take the direction around them.
+	dir  int
+	mark Mark
+	link string
+}
+
+// layout sets text word by word so that highlighted words get a filled
+// background, which MultiCell cannot do. indent shifts the block in from the
+// side the paragraph starts on; marker is printed in the indent of the first
+// line.
+func (pw *pdfWriter) layout(runs []Run, size, lineHeight, indent float64, marker string) {
+	pdf := pw.pdf
+	var text strings.Builder
+	for _, r := range runs {
+		text.WriteString(r.Text)
+	}
+	rtl := firstStrongRTL(text.String())
+	pageWidth, _ := pdf.GetPageSize()
+	left, right := pdfMargin+indent, pageWidth-pdfMargin
+	if rtl {
+		left, right = pdfMargin, pageWidth-pdfMargin-indent
+	}
+
+	for i, line := range pw.lines(runs, size, right-left) {
+		if i == 0 && marker != "" {
+			pw.setFont(pdfFont, "", size)
+			if rtl {
+				pdf.SetX(right)
+				pdf.CellFormat(5.5, lineHeight, marker, "", 0, "R", false, 0, "")
+			} else {
+				pdf.SetX(left - 5.5)
+				pdf.CellFormat(5.5, lineHeight, marker, "", 0, "L", false, 0, "")
+			}
+		}
+		line = visualLine(line, rtl)
+		x := left
+		if rtl {
+			x = right
+			for _, item := range line {
+				x -= item.width
+			}
+		}
+		pdf.SetX(x)
+		for _, item := range line {
+			pw.setFont(item.family, item.style, size)
+			fill := false
+			if c, ok := pdfHighlights[item.mark]; ok {
+				pdf.SetFillColor(c[0], c[1], c[2])
+				fill = true
+			}
+			r, g, b := pdf.GetTextColor()
+			if item.link != "" {
+				pdf.SetTextColor(79, 70, 229)
+			}
+			// A word wider than the line is left to overflow into the margin.
+			pdf.CellFormat(item.width, lineHeight, item.text, "", 0, "L", fill, 0, item.link)
+			pdf.SetTextColor(r, g, b)
+		}
+		pdf.Ln(lineHeight)
+	}
+}
+
+// lines breaks runs into lines no wider than width. There is always at least
+// one line, even if it is empty.
+func (pw *pdfWriter) lines(runs []Run, size, width float64) [][]pdfItem {
+	var lines [][]pdfItem
+	var line []pdfItem
+	x := 0.0
+	endLine := func() {
+		for len(line) > 0 && line[len(line)-1].space {
+			line = line[:len(line)-1]
+		}
+		lines = append(lines, line)
+		line, x = nil, 0
+	}
+	for _, r := range runs {
+		for _, token := range pdfTokens(r.Text) {
+			if token == "\n" {
+				endLine()
+				continue
+			}
+			item := pw.item(r, token, size)
+			switch {
+			case item.space && (len(line) == 0 || x+item.width > width):
+				continue
+			case !item.space && len(line) > 0 && x+item.width > width:
+				endLine()
+			}
+			line = append(line, item)
+			x += item.width
+		}
+	}
+	endLine()
+	return lines
+}
+
+// item picks the font for a token and puts right-to-left text into the order
+// its glyphs are drawn.
+func (pw *pdfWriter) item(r Run, token string, size float64) pdfItem {
+	item := pdfItem{text: token, family: pdfFont, mark: r.Mark, link: r.Link}
+	switch {
+	case strings.TrimSpace(token) == "":
+		item.text, item.space = " ", true
+	case strings.IndexFunc(token, isCJK) >= 0:
+		item.family, item.dir = pdfCJKFont, 1
+	case strings.IndexFunc(token, isRTL) >= 0:
+		item.text, item.dir = visualOrder(shapeArabic(token)), -1
+	case strings.IndexFunc(token, isLTR) >= 0:
+		item.dir = 1
+	}
+	if item.family == pdfFont {
+		if r.Bold {
+			item.style += "B"
+		}
+		// The oblique faces have no Arabic or Hebrew letters.
+		if r.Italic && item.dir != -1 {
+			item.style += "I"
+		}
+	}
+	pw.setFont(item.family, item.style, size)
+	item.width = pw.pdf.GetStringWidth(item.text)
+	return item
+}
+
+// visualLine returns a line's items in the order they are drawn, left to
+// right: words running against the paragraph's direction, and the spaces and
+// punctuation between them, are reversed as a group, and a right-to-left
+// paragraph is then reversed as a whole.
+func visualLine(line []pdfItem, rtl bool) []pdfItem {
+	against := -1
+	if rtl {
+		against = 1
+	}
+	out := append([]pdfItem(nil), line...)
+	for i := 0; i < len(out); i++ {
+		if out[i].dir != against {
+			continue
+		}
+		end := i
+		for j := i + 1; j < len(out) && out[j].dir != -against; j++ {
+			if out[j].dir == against {
+				end = j
+			}
+		}
+		reverseItems(out[i : end+1])
+		i = end
+	}
+	if rtl {
+		reverseItems(out)
+	}
+	return out
+}
+
+func reverseItems(items []pdfItem) {
+	for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+		items[i], items[j] = items[j], items[i]
+	}
+}
+
+// pdfTokens splits text into words, runs of spaces, and line breaks. Each
+// Chinese, Japanese or Korean character is a token of its own, since lines
+// may break between any two of them.
+func pdfTokens(text string) []string {
+	var tokens []string
+	var current strings.Builder
+	space := false
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, current.String())
+			current.Reset()
+		}
+	}
+	for _, r := range text {
+		if r == '\n' || isCJK(r) {
+			flush()
+			tokens = append(tokens, string(r))
+			space = false
+			continue
+		}
+		isSpace := unicode.IsSpace(r)
+		if isSpace != space {
+			flush()
+			space = isSpace
+		}
+		if isSpace {
+			r = ' '
+		}
+		current.WriteRune(r)
+	}
+	flush()
+	return tokens
+}
@@ -0,0 +1,333 @@
+package export
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/victor-butita/rephrase/internal/services"
+)
+
+// Result is an /api/process response to export. Source is the text that
+// was submitted; reports that point into it are shown with highlights.
+type Result struct {
+	ResultType        string
+	Text              string
+	Source            string
+	DetectionResult   *services.AIDetectionResult
+	PlagiarismResult  *services.PlagiarismResult
+	ResearchResult    *services.ResearchResult
+	SummaryResult     *services.SummaryResult
+	TranslationResult *services.TranslationResult
+	ProofreadResult   *services.ProofreadResult
+	StyleReport       *services.StyleReport
+	GlossaryReport    *services.GlossaryReport
+	LengthReport      *services.LengthReport
+}
+
+// Build turns a result into a document.
+func Build(r Result) (*Document, error) {
+	doc := &Document{}
+	switch r.ResultType {
+	case "humanize":
+		doc.Title = "Rewritten Text"
+		doc.Text(r.Text)
+		lengthSection(doc, r.LengthReport)
+		styleSection(doc, r.StyleReport)
+		glossarySection(doc, r.GlossaryReport)
+	case "consistency":
+		doc.Title = "Consistency Check"
+		doc.Text(r.Text)
+		glossarySection(doc, r.GlossaryReport)
+	case "detect":
+		if r.DetectionResult == nil {
+			return nil, fmt.Errorf("detection result is missing")
+		}
+		detection(doc, r.DetectionResult, r.Source)
+	case "plagiarize":
+		if r.PlagiarismResult == nil {
+			return nil, fmt.Errorf("plagiarism result is missing")
+		}
+		plagiarismReport(doc, r.PlagiarismResult, r.Source)
+	case "research":
+		if r.ResearchResult == nil {
+			return nil, fmt.Errorf("research result is missing")
+		}
+		researchBriefing(doc, r.ResearchResult)
+	case "summarize":
+		if r.SummaryResult == nil {
+			return nil, fmt.Errorf("summary result is missing")
+		}
+		doc.Title = "Summary"
+		doc.Text(r.SummaryResult.Summary)
+		doc.Bullets(r.SummaryResult.Points)
+	case "translate":
+		if r.TranslationResult == nil {
+			return nil, fmt.Errorf("translation result is missing")
+		}
+		t := r.TranslationResult
+		doc.Title = "Translation"
+		doc.Paragraph(Run{Text: fmt.Sprintf("%s → %s", t.SourceLanguage, t.TargetLanguage), Italic: true})
+		doc.Text(t.Text)
+		if len(t.MissingKeywords) > 0 {
+			doc.Heading(1, "Keywords Not Preserved")
+			doc.Bullets(t.MissingKeywords)
+		}
+	case "proofread":
+		if r.ProofreadResult == nil {
+			return nil, fmt.Errorf("proofread result is missing")
+		}
+		proofreadReport(doc, r.ProofreadResult, r.Source)
+	default:
+		return nil, fmt.Errorf("cannot export results of type '%s'", r.ResultType)
+	}
+	return doc, nil
+}
+
+func detection(doc *Document, d *services.AIDetectionResult, source string) {
+	doc.Title = "AI Detection Report"
+	doc.Paragraph(Run{Text: "Overall AI likelihood: ", Bold: true}, Run{Text: fmt.Sprintf("%d%%", d.OverallScore)})
+	doc.Text(d.Analysis)
+
+	if source != "" && len(d.Sentences) > 0 {
+		doc.Heading(1, "Sentence Scores")
+		doc.Paragraph(
+			Run{Text: "Likely AI", Mark: MarkHigh}, Run{Text: " above 65%, "},
+			Run{Text: "uncertain", Mark: MarkMedium}, Run{Text: " above 35%."},
+		)
+		var spans []Span
+		for _, s := range d.Sentences {
+			mark := MarkNone
+			switch {
+			case s.Score > 65:
+				mark = MarkHigh
+			case s.Score > 35:
+				mark = MarkMedium
+			}
+			if mark != MarkNone {
+				spans = append(spans, Span{Start: s.Start, End: s.End, Mark: mark, Note: fmt.Sprintf("%.0f%% AI likelihood", s.Score)})
+			}
+		}
+		doc.Highlighted(source, spans)
+	}
+	if len(d.RedFlags) > 0 {
+		doc.Heading(1, "Red Flags")
+		doc.Bullets(d.RedFlags)
+	}
+	if len(d.Detectors) > 0 {
+		doc.Heading(1, "Detectors")
+		var items [][]Run
+		for _, det := range d.Detectors {
+			score := "failed"
+			if det.Score != nil {
+				score = fmt.Sprintf("%.0f%%", *det.Score)
+			} else if det.Error != "" {
+				score = "failed: " + det.Error
+			}
+			items = append(items, []Run{{Text: det.Name, Bold: true}, {Text: fmt.Sprintf(" (weight %g): %s", det.Weight, score)}})
+		}
+		doc.List(false, items...)
+	}
+}
+
+func plagiarismReport(doc *Document, p *services.PlagiarismResult, source string) {
+	doc.Title = "Plagiarism Report"
+	if !p.IsSimilarityFound {
+		doc.Paragraph(Run{Text: "No matching passages found.", Bold: true})
+	} else {
+		doc.Paragraph(
+			Run{Text: "Coverage: ", Bold: true}, Run{Text: percent(float64(p.Coverage)) + " of the text matches known sources. "},
+			Run{Text: "Overall confidence: ", Bold: true}, Run{Text: percent(float64(p.OverallConfidence)) + "."},
+		)
+	}
+	doc.Paragraph(Run{Text: fmt.Sprintf("%d document(s) searched.", p.DocumentsSearched), Italic: true})
+
+	var spans []Span
+	for _, m := range p.Matches {
+		if m.Location != nil {
+			spans = append(spans, Span{Start: m.Location.Start, End: m.Location.End, Mark: MarkMatch, Note: m.PotentialSource})
+		}
+	}
+	if source != "" && len(spans) > 0 {
+		doc.Heading(1, "Submitted Text")
+		doc.Highlighted(source, spans)
+	}
+
+	if len(p.Matches) > 0 {
+		doc.Heading(1, "Matches")
+		for i, m := range p.Matches {
+			doc.Heading(2, fmt.Sprintf("Match %d", i+1))
+			doc.Quote(Run{Text: m.MatchingText})
+			details := []Run{{Text: "Source: ", Bold: true}}
+			if m.Location != nil && m.Location.URL != "" && !strings.HasPrefix(m.Location.URL, "/") {
+				details = append(details, Run{Text: m.PotentialSource, Link: m.Location.URL})
+			} else {
+				details = append(details, Run{Text: m.PotentialSource})
+			}
+			kind := m.Kind
+			if m.Kind == "paraphrased" {
+				kind = fmt.Sprintf("paraphrased, %s similar", percent(float64(m.Similarity)))
+			}
+			details = append(details, Run{Text: fmt.Sprintf(" · %s · confidence %s · found by %s", kind, percent(float64(m.Confidence)), m.Source)})
+			if len(m.CorroboratedBy) > 0 {
+				details = append(details, Run{Text: ", " + strings.Join(m.CorroboratedBy, ", ")})
+			}
+			doc.Paragraph(details...)
+			if m.Location != nil && m.Location.SourceText != "" && m.Location.SourceText != m.MatchingText {
+				doc.Paragraph(Run{Text: "Source passage:", Italic: true})
+				doc.Quote(Run{Text: m.Location.SourceText})
+			}
+		}
+	}
+
+	var failed []string
+	for _, s := range p.Sources {
+		if s.Error != "" {
+			failed = append(failed, fmt.Sprintf("%s: %s", s.Name, s.Error))
+		}
+	}
+	if len(failed) > 0 {
+		doc.Heading(1, "Unavailable Sources")
+		doc.Bullets(failed)
+	}
+}
+
+func researchBriefing(doc *Document, r *services.ResearchResult) {
+	doc.Title = "Research on: " + r.Topic
+	if r.Depth != "" {
+		doc.Paragraph(Run{Text: fmt.Sprintf("Depth: %s", r.Depth), Italic: true})
+	}
+	sections := r.Sections
+	if len(sections) == 0 {
+		// Briefings from before sections were configurable.
+		sections = []services.ResearchSection{
+			{Title: "Executive Summary", Format: "paragraph", Content: r.ExecutiveSummary},
+			{Title: "Historical Context", Format: "paragraph", Content: r.HistoricalContext},
+			{Title: "Core Concepts", Format: "list", Points: r.CoreConcepts},
+			{Title: "Controversies & Critiques", Format: "list", Points: r.ControversiesAndCritiques},
+			{Title: "Practical Applications", Format: "list", Points: r.PracticalApplications},
+		}
+	}
+	for _, s := range sections {
+		doc.Heading(1, s.Title)
+		if s.Format == "paragraph" {
+			if s.Content == "" {
+				doc.Paragraph(Run{Text: "Nothing found.", Italic: true})
+			}
+			doc.Text(s.Content)
+			continue
+		}
+		if len(s.Points) == 0 {
+			doc.Paragraph(Run{Text: "Nothing found.", Italic: true})
+		}
+		doc.Bullets(s.Points)
+	}
+	if len(r.SubQuestions) > 0 {
+		doc.Heading(1, "Sub-questions Researched")
+		for _, q := range r.SubQuestions {
+			doc.Heading(2, q.Question)
+			if q.Error != "" {
+				doc.Paragraph(Run{Text: q.Error, Italic: true})
+				continue
+			}
+			doc.Bullets(q.Findings)
+		}
+	}
+	if len(r.Citations) > 0 {
+		doc.Heading(1, "Sources")
+		for _, c := range r.Citations {
+			doc.Paragraph(Run{Text: fmt.Sprintf("[%d] %s", c.Number, c.DocumentTitle), Bold: true})
+			doc.Quote(Run{Text: c.Passage})
+		}
+	}
+}
+
+func proofreadReport(doc *Document, p *services.ProofreadResult, source string) {
+	doc.Title = "Proofreading Report"
+	doc.Paragraph(Run{Text: fmt.Sprintf("%s · %d issue(s)", p.Dialect, len(p.Issues)), Italic: true})
+	if source != "" && len(p.Issues) > 0 {
+		doc.Heading(1, "Text")
+		var spans []Span
+		for _, issue := range p.Issues {
+			spans = append(spans, Span{Start: issue.Start, End: issue.End, Mark: MarkIssue, Note: issue.Message})
+		}
+		doc.Highlighted(source, spans)
+	}
+	if len(p.Issues) > 0 {
+		doc.Heading(1, "Issues")
+		var items [][]Run
+		for _, issue := range p.Issues {
+			item := []Run{{Text: issue.Text, Bold: true}, {Text: fmt.Sprintf(" (%s): %s", issue.Category, issue.Message)}}
+			if len(issue.Suggestions) > 0 {
+				item = append(item, Run{Text: " Suggestions: " + strings.Join(issue.Suggestions, ", "), Italic: true})
+			}
+			items = append(items, item)
+		}
+		doc.List(true, items...)
+	}
+}
+
+func lengthSection(doc *Document, l *services.LengthReport) {
+	if l == nil {
+		return
+	}
+	status := "within"
+	if !l.WithinTolerance {
+		status = "outside"
+	}
+	doc.Heading(1, "Length")
+	doc.Paragraph(Run{Text: fmt.Sprintf("%d words (target %d, ±%d%%: %d-%d), %s tolerance; original had %d words.",
+		l.ActualWords, l.Words, l.Tolerance, l.MinWords, l.MaxWords, status, l.OriginalWords)})
+}
+
+func styleSection(doc *Document, s *services.StyleReport) {
+	if s == nil {
+		return
+	}
+	doc.Heading(1, "Style Guide: "+s.GuideName)
+	if len(s.Violations) == 0 {
+		doc.Paragraph(Run{Text: "No violations.", Italic: true})
+		return
+	}
+	var items [][]Run
+	for _, v := range s.Violations {
+		item := []Run{{Text: v.Text, Bold: true}, {Text: fmt.Sprintf(" (%s): %s", v.Rule, v.Message)}}
+		if v.Suggestion != "" {
+			item = append(item, Run{Text: " Suggestion: " + v.Suggestion, Italic: true})
+		}
+		items = append(items, item)
+	}
+	doc.List(false, items...)
+}
+
+func glossarySection(doc *Document, g *services.GlossaryReport) {
+	if g == nil || (len(g.Issues) == 0 && len(g.Substitutions) == 0) {
+		return
+	}
+	doc.Heading(1, "Glossary")
+	var items [][]Run
+	for _, s := range g.Substitutions {
+		items = append(items, []Run{{Text: "Replaced "}, {Text: s.Original, Bold: true}, {Text: " with "}, {Text: s.Replacement, Bold: true}})
+	}
+	for _, i := range g.Issues {
+		items = append(items, []Run{{Text: i.Text, Bold: true}, {Text: fmt.Sprintf(" should be %q", i.Preferred)}})
+	}
+	doc.List(false, items...)
+}
+
+// Filename suggests a download name for a result.
+func Filename(doc *Document, format string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(doc.Title) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case b.Len() > 0 && !strings.HasSuffix(b.String(), "-"):
+			b.WriteRune('-')
+		}
+	}
+	name := strings.Trim(b.String(), "-")
+	if name == "" {
+		name = "result"
+	}
+	return strings.TrimRight(name[:min(len(name), 60)], "-") + "." + format
+}
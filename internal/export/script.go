@@ -0,0 +1,176 @@
+package export
+
+import "unicode"
+
+// The PDF library draws glyphs left to right exactly as given, with no
+// shaping or bidirectional reordering. This file does the minimum needed for
+// Arabic and Hebrew to read correctly and for Chinese, Japanese and Korean
+// to wrap, which have no spaces between words.
+
+// isCJK reports whether r is written with the CJK font and may be broken
+// after, like a word.
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul, unicode.Bopomofo) ||
+		(r >= 0x3000 && r <= 0x303f) || // CJK punctuation
+		(r >= 0xff00 && r <= 0xffef) // full-width forms
+}
+
+// isRTL reports whether r belongs to a right-to-left script.
+func isRTL(r rune) bool {
+	return unicode.In(r, unicode.Arabic, unicode.Hebrew)
+}
+
+// isLTR reports whether r is strongly left to right. Digits count as left to
+// right, since numbers keep their order in right-to-left text.
+func isLTR(r rune) bool {
+	return unicode.IsDigit(r) || (unicode.IsLetter(r) && !isRTL(r))
+}
+
+// firstStrongRTL reports whether the first character with a direction is
+// right to left, which makes the paragraph right to left.
+func firstStrongRTL(text string) bool {
+	for _, r := range text {
+		switch {
+		case isRTL(r) && unicode.IsLetter(r):
+			return true
+		case isLTR(r):
+			return false
+		}
+	}
+	return false
+}
+
+// arabicForms maps each Arabic letter to its first presentation form and the
+// number of forms it has: 4 for letters that join on both sides (isolated,
+// final, initial, medial), 2 for letters that only join to the letter before
+// them (isolated, final) and 1 for letters that never join.
+var arabicForms = map[rune]struct {
+	first rune
+	n     int
+}{
+	0x0621: {0xfe80, 1}, 0x0622: {0xfe81, 2}, 0x0623: {0xfe83, 2}, 0x0624: {0xfe85, 2},
+	0x0625: {0xfe87, 2}, 0x0626: {0xfe89, 4}, 0x0627: {0xfe8d, 2}, 0x0628: {0xfe8f, 4},
+	0x0629: {0xfe93, 2}, 0x062a: {0xfe95, 4}, 0x062b: {0xfe99, 4}, 0x062c: {0xfe9d, 4},
+	0x062d: {0xfea1, 4}, 0x062e: {0xfea5, 4}, 0x062f: {0xfea9, 2}, 0x0630: {0xfeab, 2},
+	0x0631: {0xfead, 2}, 0x0632: {0xfeaf, 2}, 0x0633: {0xfeb1, 4}, 0x0634: {0xfeb5, 4},
+	0x0635: {0xfeb9, 4}, 0x0636: {0xfebd, 4}, 0x0637: {0xfec1, 4}, 0x0638: {0xfec5, 4},
+	0x0639: {0xfec9, 4}, 0x063a: {0xfecd, 4}, 0x0641: {0xfed1, 4}, 0x0642: {0xfed5, 4},
+	0x0643: {0xfed9, 4}, 0x0644: {0xfedd, 4}, 0x0645: {0xfee1, 4}, 0x0646: {0xfee5, 4},
+	0x0647: {0xfee9, 4}, 0x0648: {0xfeed, 2}, 0x0649: {0xfeef, 2}, 0x064a: {0xfef1, 4},
+	// Persian and Urdu letters.
+	0x067e: {0xfb56, 4}, 0x0686: {0xfb7a, 4}, 0x0698: {0xfb8a, 2}, 0x06a9: {0xfb8e, 4},
+	0x06af: {0xfb92, 4}, 0x06cc: {0xfbfc, 4},
+}
+
+// lamAlef maps the alef that follows a lam to the isolated form of their
+// ligature; the final form is the next code point.
+var lamAlef = map[rune]rune{0x0622: 0xfef5, 0x0623: 0xfef7, 0x0625: 0xfef9, 0x0627: 0xfefb}
+
+const (
+	arabicLam     = 0x0644
+	arabicTatweel = 0x0640
+)
+
+// joinsAfter reports whether r connects to the letter that follows it.
+func joinsAfter(r rune) bool {
+	return r == arabicTatweel || arabicForms[r].n == 4
+}
+
+// joinsBefore reports whether r connects to the letter before it.
+func joinsBefore(r rune) bool {
+	return r == arabicTatweel || arabicForms[r].n >= 2
+}
+
+// shapeArabic replaces Arabic letters with the presentation forms that fit
+// their neighbours, in logical order. Combining marks are skipped over when
+// looking for neighbours.
+func shapeArabic(text string) string {
+	rs := []rune(text)
+	letter := func(i, step int) int {
+		for i += step; i >= 0 && i < len(rs); i += step {
+			if !unicode.Is(unicode.Mn, rs[i]) {
+				return i
+			}
+		}
+		return -1
+	}
+	out := make([]rune, 0, len(rs))
+	for i := 0; i < len(rs); i++ {
+		r := rs[i]
+		forms, ok := arabicForms[r]
+		if !ok {
+			out = append(out, r)
+			continue
+		}
+		prev, next := letter(i, -1), letter(i, 1)
+		joinPrev := prev >= 0 && joinsAfter(rs[prev])
+		if r == arabicLam && next >= 0 {
+			if lig, ok := lamAlef[rs[next]]; ok {
+				if joinPrev {
+					lig++
+				}
+				out = append(out, lig)
+				out = append(out, rs[i+1:next]...)
+				i = next
+				continue
+			}
+		}
+		joinNext := forms.n == 4 && next >= 0 && joinsBefore(rs[next])
+		form := forms.first
+		switch {
+		case forms.n == 1:
+		case joinPrev && joinNext:
+			form += 3
+		case joinNext:
+			form += 2
+		case joinPrev:
+			form++
+		}
+		out = append(out, form)
+	}
+	return string(out)
+}
+
+var mirrored = map[rune]rune{'(': ')', ')': '(', '[': ']', ']': '[', '{': '}', '}': '{', '<': '>', '>': '<', '«': '»', '»': '«'}
+
+// visualOrder returns a word containing right-to-left text in the order its
+// characters are drawn, left to right. Runs of left-to-right letters and
+// digits inside it keep their order; combining marks stay after the
+// character they belong to.
+func visualOrder(word string) string {
+	rs := []rune(word)
+	type cluster []rune
+	var runs [][]cluster
+	var ltr []bool
+	for i := 0; i < len(rs); {
+		j := i + 1
+		for j < len(rs) && unicode.Is(unicode.Mn, rs[j]) {
+			j++
+		}
+		c := cluster(rs[i:j])
+		dir := isLTR(rs[i])
+		if len(runs) == 0 || ltr[len(ltr)-1] != dir {
+			runs = append(runs, nil)
+			ltr = append(ltr, dir)
+		}
+		runs[len(runs)-1] = append(runs[len(runs)-1], c)
+		i = j
+	}
+	out := make([]rune, 0, len(rs))
+	for k := len(runs) - 1; k >= 0; k-- {
+		if ltr[k] {
+			for _, c := range runs[k] {
+				out = append(out, c...)
+			}
+			continue
+		}
+		for i := len(runs[k]) - 1; i >= 0; i-- {
+			c := runs[k][i]
+			if m, ok := mirrored[c[0]]; ok {
+				c = append(cluster{m}, c[1:]...)
+			}
+			out = append(out, c...)
+		}
+	}
+	return string(out)
+}
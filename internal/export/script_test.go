@@ -0,0 +1,43 @@
+package export
+
+import "testing"
+
+func TestShapeArabic(t *testing.T) {
+	tests := []struct {
+		name, text, want string
+	}{
+		{"initial, medial and final forms", "مرحبا", "ﻣﺮﺣﺒﺎ"},
+		{"isolated letter", "و", "ﻭ"},
+		{"lam-alef ligature", "لا", "ﻻ"},
+		{"joined lam-alef ligature", "الله", "ﺍﻟﻠﻪ"},
+		{"ligature after a joining letter", "سلام", "ﺳﻼﻡ"},
+		{"marks do not break joins", "بَب", "ﺑَﺐ"},
+		{"other text is unchanged", "abc 12", "abc 12"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shapeArabic(tt.text); got != tt.want {
+				t.Errorf("shapeArabic(%q) = %+q, want %+q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVisualOrder(t *testing.T) {
+	tests := []struct {
+		name, word, want string
+	}{
+		{"hebrew", "שלום", "םולש"},
+		{"trailing punctuation", "שלום.", ".םולש"},
+		{"digits keep their order", "ש2024", "2024ש"},
+		{"brackets are mirrored", "(שלום)", "(םולש)"},
+		{"marks stay after their letter", "בַּת", "תבַּ"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := visualOrder(tt.word); got != tt.want {
+				t.Errorf("visualOrder(%q) = %q, want %q", tt.word, got, tt.want)
+			}
+		})
+	}
+}
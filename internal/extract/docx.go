@@ -0,0 +1,122 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// maxPartSize caps how much of a zipped part is read, so a small upload
+// cannot expand into gigabytes.
+const maxPartSize = 64 << 20
+
+// extractDOCX reads word/document.xml. Tracked deletions, field codes,
+// headers, footers and footnotes are not included; drawings (including
+// text boxes inside them) are skipped with a warning.
+func extractDOCX(data []byte, doc *Document) error {
+	part, err := zipPart(data, "word/document.xml")
+	if err != nil {
+		return err
+	}
+	f := &flow{doc: doc}
+	dec := xml.NewDecoder(bytes.NewReader(part))
+	inText, skip := false, 0
+	titleStyle := false
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("malformed document.xml: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if skip > 0 {
+				skip++
+				continue
+			}
+			switch t.Name.Local {
+			case "drawing", "pict", "object":
+				f.images++
+				skip = 1
+			case "t":
+				inText = true
+			case "tab":
+				f.text(" ")
+			case "br", "cr":
+				f.text(" ")
+			case "pStyle":
+				style := strings.ToLower(attr(t, "val"))
+				titleStyle = style == "title" || style == "heading1"
+			case "tbl":
+				f.startTable()
+			}
+		case xml.EndElement:
+			if skip > 0 {
+				skip--
+				continue
+			}
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				text := f.endParagraph()
+				if titleStyle && doc.Title == "" && text != "" {
+					doc.Title = text
+				}
+				titleStyle = false
+			case "tc":
+				f.endCell()
+			case "tr":
+				f.endRow()
+			case "tbl":
+				f.endTable()
+			}
+		case xml.CharData:
+			if inText && skip == 0 {
+				f.text(string(t))
+			}
+		}
+	}
+	f.finish("image(s) or drawing(s)")
+	return nil
+}
+
+func zipPart(data []byte, name string) ([]byte, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("not a valid zip archive: %w", err)
+	}
+	for _, zf := range zr.File {
+		if zf.Name != name {
+			continue
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		part, err := io.ReadAll(io.LimitReader(rc, maxPartSize+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(part) > maxPartSize {
+			return nil, fmt.Errorf("%s is too large", name)
+		}
+		return part, nil
+	}
+	return nil, fmt.Errorf("%s not found in archive", name)
+}
+
+func attr(e xml.StartElement, local string) string {
+	for _, a := range e.Attr {
+		if a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
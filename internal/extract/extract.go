@@ -0,0 +1,112 @@
+// Package extract turns uploaded documents into clean plain text split into
+// paragraphs. Everything runs locally; formats that cannot be read fully
+// produce warnings rather than silently dropping content.
+package extract
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+type Document struct {
+	Filename   string   `json:"filename"`
+	Format     string   `json:"format"`
+	Title      string   `json:"title"`
+	Paragraphs []string `json:"paragraphs"`
+	Warnings   []string `json:"warnings"`
+}
+
+// Text joins the paragraphs with blank lines.
+func (d *Document) Text() string {
+	return strings.Join(d.Paragraphs, "\n\n")
+}
+
+type extractor func(data []byte, doc *Document) error
+
+var extractors = map[string]extractor{
+	".txt":      extractText,
+	".md":       extractMarkdown,
+	".markdown": extractMarkdown,
+	".html":     extractHTML,
+	".htm":      extractHTML,
+	".pdf":      extractPDF,
+	".docx":     extractDOCX,
+	".odt":      extractODT,
+}
+
+// Supported reports whether filename has an extension Extract can read.
+func Supported(filename string) bool {
+	_, ok := extractors[strings.ToLower(filepath.Ext(filename))]
+	return ok
+}
+
+// Extensions lists the supported file extensions.
+func Extensions() []string {
+	exts := make([]string, 0, len(extractors))
+	for ext := range extractors {
+		exts = append(exts, ext)
+	}
+	return exts
+}
+
+// Extract reads data according to filename's extension.
+func Extract(filename string, data []byte) (*Document, error) {
+	ext := strings.ToLower(filepath.Ext(filename))
+	fn, ok := extractors[ext]
+	if !ok {
+		return nil, fmt.Errorf("unsupported file type %q", ext)
+	}
+	doc := &Document{Filename: filename, Format: strings.TrimPrefix(ext, "."), Paragraphs: []string{}, Warnings: []string{}}
+	if err := fn(data, doc); err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", filename, err)
+	}
+	if doc.Title == "" {
+		doc.Title = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	}
+	if len(doc.Paragraphs) == 0 {
+		doc.Warnings = append(doc.Warnings, "No text could be extracted.")
+	}
+	return doc, nil
+}
+
+func extractText(data []byte, doc *Document) error {
+	text, err := decodeUTF8(data, doc)
+	if err != nil {
+		return err
+	}
+	doc.Paragraphs = splitParagraphs(text)
+	return nil
+}
+
+// decodeUTF8 strips a byte-order mark and replaces invalid sequences,
+// warning when it had to.
+func decodeUTF8(data []byte, doc *Document) (string, error) {
+	text := strings.TrimPrefix(string(data), "\uFEFF")
+	if !utf8.ValidString(text) {
+		doc.Warnings = append(doc.Warnings, "The file is not valid UTF-8; unreadable characters were replaced.")
+		text = strings.ToValidUTF8(text, "\uFFFD")
+	}
+	return text, nil
+}
+
+var blankLines = regexp.MustCompile(`\n[ \t]*\n`)
+
+// splitParagraphs splits on blank lines and joins the lines of each
+// paragraph with single spaces.
+func splitParagraphs(text string) []string {
+	text = strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\r", "\n")
+	var paragraphs []string
+	for _, block := range blankLines.Split(text, -1) {
+		if p := collapseSpace(block); p != "" {
+			paragraphs = append(paragraphs, p)
+		}
+	}
+	return paragraphs
+}
+
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
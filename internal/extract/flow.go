@@ -0,0 +1,86 @@
+package extract
+
+import (
+	"fmt"
+	"strings"
+)
+
+// flow collects paragraphs from word-processor XML. Table rows are
+// flattened into single paragraphs with their cells separated by " | ";
+// nested tables become part of the enclosing cell's text.
+type flow struct {
+	doc       *Document
+	para      strings.Builder
+	cell      strings.Builder
+	cells     []string
+	tableDeep int
+	tables    int
+	images    int
+}
+
+func (f *flow) text(s string) {
+	f.para.WriteString(s)
+}
+
+func (f *flow) endParagraph() string {
+	text := collapseSpace(f.para.String())
+	f.para.Reset()
+	if text == "" {
+		return ""
+	}
+	if f.tableDeep > 0 {
+		f.cell.WriteString(text + " ")
+	} else {
+		f.doc.Paragraphs = append(f.doc.Paragraphs, text)
+	}
+	return text
+}
+
+func (f *flow) startTable() {
+	if f.tableDeep == 0 {
+		f.tables++
+	}
+	f.tableDeep++
+}
+
+func (f *flow) endTable() {
+	f.tableDeep--
+}
+
+func (f *flow) endCell() {
+	if f.tableDeep != 1 {
+		return
+	}
+	f.cells = append(f.cells, collapseSpace(f.cell.String()))
+	f.cell.Reset()
+}
+
+func (f *flow) endRow() {
+	if f.tableDeep != 1 {
+		return
+	}
+	var cells []string
+	for _, c := range f.cells {
+		if c != "" {
+			cells = append(cells, c)
+		}
+	}
+	if len(cells) > 0 {
+		f.doc.Paragraphs = append(f.doc.Paragraphs, strings.Join(cells, " | "))
+	}
+	f.cells = nil
+}
+
+// finish records warnings for content that was flattened or skipped.
+func (f *flow) finish(imageKind string) {
+	if f.tables > 0 {
+		f.doc.warnf("%d table(s) flattened: each row became one paragraph with cells separated by \" | \".", f.tables)
+	}
+	if f.images > 0 {
+		f.doc.warnf("%d %s skipped; only text is extracted.", f.images, imageKind)
+	}
+}
+
+func (d *Document) warnf(format string, args ...interface{}) {
+	d.Warnings = append(d.Warnings, fmt.Sprintf(format, args...))
+}
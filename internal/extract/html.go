@@ -0,0 +1,137 @@
+package extract
+
+import (
+	"bytes"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// blockElements start a new paragraph.
+var blockElements = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Li: true, atom.H1: true, atom.H2: true,
+	atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true, atom.Blockquote: true,
+	atom.Pre: true, atom.Tr: true, atom.Section: true, atom.Article: true,
+	atom.Header: true, atom.Footer: true, atom.Dd: true, atom.Dt: true,
+	atom.Figcaption: true, atom.Br: true,
+}
+
+// skippedElements never contain readable text.
+var skippedElements = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true,
+	atom.Head: true, atom.Svg: true, atom.Nav: true, atom.Iframe: true,
+}
+
+func extractHTML(data []byte, doc *Document) error {
+	text, err := decodeUTF8(data, doc)
+	if err != nil {
+		return err
+	}
+	root, err := html.Parse(strings.NewReader(text))
+	if err != nil {
+		return err
+	}
+	if title := findElement(root, atom.Title); title != nil {
+		doc.Title = collapseSpace(textContent(title))
+	}
+	if doc.Title == "" {
+		if h1 := findElement(root, atom.H1); h1 != nil {
+			doc.Title = collapseSpace(textContent(h1))
+		}
+	}
+
+	var buf bytes.Buffer
+	tables, images := 0, 0
+	flush := func() {
+		if p := collapseSpace(buf.String()); p != "" {
+			doc.Paragraphs = append(doc.Paragraphs, p)
+		}
+		buf.Reset()
+	}
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			buf.WriteString(n.Data)
+			return
+		case html.ElementNode:
+			if skippedElements[n.DataAtom] {
+				return
+			}
+			if blockElements[n.DataAtom] {
+				flush()
+				defer flush()
+			}
+			switch n.DataAtom {
+			case atom.Table:
+				tables++
+			case atom.Tr:
+				// Rows are flattened into one paragraph; cells are separated by " | ".
+				flush()
+				var cells []string
+				for c := n.FirstChild; c != nil; c = c.NextSibling {
+					if c.DataAtom == atom.Td || c.DataAtom == atom.Th {
+						if text := collapseSpace(textContent(c)); text != "" {
+							cells = append(cells, text)
+						}
+					}
+				}
+				buf.WriteString(strings.Join(cells, " | "))
+				flush()
+				return
+			case atom.Img:
+				alt := ""
+				for _, a := range n.Attr {
+					if a.Key == "alt" {
+						alt = strings.TrimSpace(a.Val)
+					}
+				}
+				if alt == "" {
+					images++
+				} else {
+					buf.WriteString(" " + alt + " ")
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	if body := findElement(root, atom.Body); body != nil {
+		walk(body)
+	} else {
+		walk(root)
+	}
+	flush()
+	if tables > 0 {
+		doc.warnf("%d table(s) flattened: each row became one paragraph with cells separated by \" | \".", tables)
+	}
+	if images > 0 {
+		doc.warnf("%d image(s) without alt text skipped; only text is extracted.", images)
+	}
+	return nil
+}
+
+func findElement(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findElement(c, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var sb strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		sb.WriteString(textContent(c))
+	}
+	return sb.String()
+}
@@ -0,0 +1,103 @@
+package extract
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	mdHeading   = regexp.MustCompile(`^#{1,6}\s+`)
+	mdListItem  = regexp.MustCompile(`^\s*(?:[-*+]|\d+[.)])\s+`)
+	mdQuote     = regexp.MustCompile(`^\s*>\s?`)
+	mdImage     = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	mdLink      = regexp.MustCompile(`\[([^\]]+)\]\([^)]*\)`)
+	mdEmphasis  = regexp.MustCompile(`(\*\*|__|\*|_|~~)([^*_~]+)(\*\*|__|\*|_|~~)`)
+	mdCode      = regexp.MustCompile("`([^`]+)`")
+	mdRule      = regexp.MustCompile(`^\s*([-*_])(\s*([-*_])){2,}\s*$`)
+	mdFrontEdge = "---"
+)
+
+// extractMarkdown removes Markdown syntax but keeps the words: headings,
+// list items and quotes become their own paragraphs, links keep their text,
+// and fenced code blocks are kept verbatim. The first heading is the title.
+func extractMarkdown(data []byte, doc *Document) error {
+	text, err := decodeUTF8(data, doc)
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+
+	// YAML front matter.
+	if len(lines) > 0 && strings.TrimSpace(lines[0]) == mdFrontEdge {
+		for i := 1; i < len(lines); i++ {
+			if strings.TrimSpace(lines[i]) == mdFrontEdge {
+				lines = lines[i+1:]
+				break
+			}
+		}
+	}
+
+	var current []string
+	flush := func() {
+		if p := collapseSpace(strings.Join(current, " ")); p != "" {
+			doc.Paragraphs = append(doc.Paragraphs, p)
+		}
+		current = nil
+	}
+	inFence := false
+	var fence []string
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			if inFence {
+				if code := strings.TrimRight(strings.Join(fence, "\n"), "\n"); code != "" {
+					doc.Paragraphs = append(doc.Paragraphs, code)
+				}
+				fence = nil
+			} else {
+				flush()
+			}
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			fence = append(fence, line)
+			continue
+		}
+		switch {
+		case trimmed == "" || mdRule.MatchString(trimmed):
+			flush()
+		case mdHeading.MatchString(trimmed):
+			flush()
+			heading := inlineMarkdown(mdHeading.ReplaceAllString(trimmed, ""))
+			if doc.Title == "" {
+				doc.Title = heading
+			}
+			current = []string{heading}
+			flush()
+		case mdListItem.MatchString(line):
+			flush()
+			current = []string{inlineMarkdown(mdListItem.ReplaceAllString(line, ""))}
+		default:
+			current = append(current, inlineMarkdown(mdQuote.ReplaceAllString(line, "")))
+		}
+	}
+	if inFence {
+		doc.Warnings = append(doc.Warnings, "A code block was not closed.")
+		if code := strings.Join(fence, "\n"); strings.TrimSpace(code) != "" {
+			doc.Paragraphs = append(doc.Paragraphs, code)
+		}
+	}
+	flush()
+	return nil
+}
+
+func inlineMarkdown(s string) string {
+	s = mdImage.ReplaceAllString(s, "$1")
+	s = mdLink.ReplaceAllString(s, "$1")
+	s = mdCode.ReplaceAllString(s, "$1")
+	for i := 0; i < 3; i++ {
+		s = mdEmphasis.ReplaceAllString(s, "$2")
+	}
+	return s
+}
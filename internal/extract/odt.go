@@ -0,0 +1,115 @@
+package extract
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// odtSkipped are content.xml elements whose text is not part of the body:
+// footnotes, comments, tracked changes, and frames (images, text boxes).
+var odtSkipped = map[string]bool{
+	"note": true, "annotation": true, "tracked-changes": true, "frame": true,
+	"sequence-decls": true, "variable-decls": true, "user-field-decls": true,
+}
+
+// extractODT reads content.xml of an OpenDocument text file, taking the
+// title from meta.xml when it has one.
+func extractODT(data []byte, doc *Document) error {
+	if meta, err := zipPart(data, "meta.xml"); err == nil {
+		doc.Title = odtTitle(meta)
+	}
+	part, err := zipPart(data, "content.xml")
+	if err != nil {
+		return err
+	}
+	f := &flow{doc: doc}
+	dec := xml.NewDecoder(bytes.NewReader(part))
+	inBody, paraDepth, skip := false, 0, 0
+	heading := false
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("malformed content.xml: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if skip > 0 {
+				skip++
+				continue
+			}
+			switch t.Name.Local {
+			case "text":
+				if t.Name.Space == odfOffice {
+					inBody = true
+				}
+			case "p", "h":
+				paraDepth++
+				heading = t.Name.Local == "h" && attr(t, "outline-level") == "1"
+			case "s":
+				n, _ := strconv.Atoi(attr(t, "c"))
+				f.text(strings.Repeat(" ", max(n, 1)))
+			case "tab", "line-break":
+				f.text(" ")
+			case "table":
+				f.startTable()
+			case "image":
+				f.images++
+			default:
+				if odtSkipped[t.Name.Local] {
+					if t.Name.Local == "frame" {
+						f.images++
+					}
+					skip = 1
+				}
+			}
+		case xml.EndElement:
+			if skip > 0 {
+				skip--
+				continue
+			}
+			switch t.Name.Local {
+			case "p", "h":
+				paraDepth--
+				if text := f.endParagraph(); heading && doc.Title == "" && text != "" {
+					doc.Title = text
+				}
+				heading = false
+			case "table-cell":
+				f.endCell()
+			case "table-row":
+				f.endRow()
+			case "table":
+				f.endTable()
+			case "text":
+				if t.Name.Space == odfOffice {
+					inBody = false
+				}
+			}
+		case xml.CharData:
+			if inBody && paraDepth > 0 && skip == 0 {
+				f.text(string(t))
+			}
+		}
+	}
+	f.finish("image(s) or frame(s)")
+	return nil
+}
+
+const odfOffice = "urn:oasis:names:tc:opendocument:xmlns:office:1.0"
+
+func odtTitle(meta []byte) string {
+	var m struct {
+		Title string `xml:"meta>title"`
+	}
+	if err := xml.Unmarshal(meta, &m); err != nil {
+		return ""
+	}
+	return collapseSpace(m.Title)
+}
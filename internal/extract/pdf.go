@@ -0,0 +1,138 @@
+package extract
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// extractPDF reads the text layer of every page. Lines are rebuilt from the
+// glyphs' baselines and a gap noticeably larger than the usual line
+// spacing starts a new paragraph. Scanned pages have no text layer and are
+// reported in the warnings.
+func extractPDF(data []byte, doc *Document) (err error) {
+	defer func() {
+		// The PDF parser panics on some malformed files.
+		if r := recover(); r != nil {
+			err = fmt.Errorf("malformed PDF: %v", r)
+		}
+	}()
+	reader, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return err
+	}
+	if title := reader.Trailer().Key("Info").Key("Title").Text(); strings.TrimSpace(title) != "" {
+		doc.Title = collapseSpace(title)
+	}
+
+	var empty []int
+	for i := 1; i <= reader.NumPage(); i++ {
+		paragraphs, err := pdfPage(reader.Page(i))
+		if err != nil {
+			doc.Warnings = append(doc.Warnings, fmt.Sprintf("Page %d could not be read: %v", i, err))
+			continue
+		}
+		if len(paragraphs) == 0 {
+			empty = append(empty, i)
+		}
+		doc.Paragraphs = append(doc.Paragraphs, paragraphs...)
+	}
+	if len(empty) > 0 {
+		doc.Warnings = append(doc.Warnings, fmt.Sprintf("%d page(s) contain no extractable text (scanned pages are not OCRed): %s", len(empty), pageList(empty)))
+	}
+	return nil
+}
+
+func pdfPage(page pdf.Page) (paragraphs []string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	if page.V.IsNull() || page.V.Key("Contents").Kind() == pdf.Null {
+		return nil, nil
+	}
+
+	// Glyphs arrive in drawing order; a change in baseline starts a new line.
+	type line struct {
+		y    float64
+		text strings.Builder
+	}
+	var lines []*line
+	for _, t := range page.Content().Text {
+		if len(lines) == 0 || math.Abs(lines[len(lines)-1].y-t.Y) > 1 {
+			lines = append(lines, &line{y: t.Y})
+		}
+		lines[len(lines)-1].text.WriteString(t.S)
+	}
+	var texts []string
+	var ys []float64
+	for _, l := range lines {
+		if s := collapseSpace(l.text.String()); s != "" {
+			texts = append(texts, s)
+			ys = append(ys, l.y)
+		}
+	}
+	if len(texts) == 0 {
+		return nil, nil
+	}
+
+	var gaps []float64
+	for i := 1; i < len(ys); i++ {
+		if gap := ys[i-1] - ys[i]; gap > 0 {
+			gaps = append(gaps, gap)
+		}
+	}
+	spacing := median(gaps)
+
+	current := []string{texts[0]}
+	for i := 1; i < len(texts); i++ {
+		gap := ys[i-1] - ys[i]
+		if gap < 0 || (spacing > 0 && gap > 1.5*spacing) {
+			paragraphs = append(paragraphs, joinLines(current))
+			current = nil
+		}
+		current = append(current, texts[i])
+	}
+	return append(paragraphs, joinLines(current)), nil
+}
+
+// joinLines rejoins words hyphenated across line breaks.
+func joinLines(lines []string) string {
+	var sb strings.Builder
+	for i, l := range lines {
+		if i > 0 {
+			prev := lines[i-1]
+			if strings.HasSuffix(prev, "-") && len(prev) > 1 {
+				s := sb.String()
+				sb.Reset()
+				sb.WriteString(strings.TrimSuffix(s, "-"))
+			} else {
+				sb.WriteByte(' ')
+			}
+		}
+		sb.WriteString(l)
+	}
+	return sb.String()
+}
+
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	return sorted[len(sorted)/2]
+}
+
+func pageList(pages []int) string {
+	parts := make([]string, len(pages))
+	for i, p := range pages {
+		parts[i] = fmt.Sprint(p)
+	}
+	return strings.Join(parts, ", ")
+}
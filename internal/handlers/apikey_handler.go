@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/victor-butita/rephrase/internal/auth"
+)
+
+const (
+	maxKeyNameLength = 100
+	maxKeyDays       = 3650
+)
+
+// APIKeyHandler lets signed-in users manage the keys their programs call
+// /api/process with:
+//
+//	GET    /api/keys                                                             list your keys with their usage
+//	GET    /api/keys?all=true                                                    list everyone's keys (admins)
+//	POST   /api/keys  {"name": "...", "scopes": ["detect"], "expires_in_days": 90}  create a key
+//	DELETE /api/keys?id=...                                                      revoke a key
+//
+// Scopes are the actions a key may run, or "*" for all of them. A key never
+// expires when expires_in_days is 0. Its secret is only returned on creation.
+type APIKeyHandler struct {
+	Store *auth.Store
+}
+
+func NewAPIKeyHandler(store *auth.Store) *APIKeyHandler {
+	return &APIKeyHandler{Store: store}
+}
+
+func (h *APIKeyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		respondError(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+	query := r.URL.Query()
+	switch r.Method {
+	case http.MethodGet:
+		owner := user.ID
+		if query.Get("all") == "true" {
+			if user.Role != auth.RoleAdmin {
+				respondError(w, "You do not have permission to do this", http.StatusForbidden)
+				return
+			}
+			owner = ""
+		}
+		keys, err := h.Store.APIKeys(owner)
+		if err != nil {
+			respondError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, keys, http.StatusOK)
+	case http.MethodPost:
+		h.create(w, r, user)
+	case http.MethodDelete:
+		key, err := h.Store.APIKey(query.Get("id"))
+		// Other users' keys are reported as missing rather than forbidden, so
+		// their ids cannot be probed.
+		if errors.Is(err, auth.ErrKeyNotFound) || (err == nil && key.UserID != user.ID && user.Role != auth.RoleAdmin) {
+			respondError(w, "API key not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			respondError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := h.Store.RevokeAPIKey(key.ID); err != nil {
+			respondError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		respondError(w, "Invalid request method", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *APIKeyHandler) create(w http.ResponseWriter, r *http.Request, user *auth.User) {
+	var payload struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays int      `json:"expires_in_days"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		respondError(w, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+	name := strings.TrimSpace(payload.Name)
+	if name == "" || len(name) > maxKeyNameLength {
+		respondError(w, fmt.Sprintf("name is required and must be at most %d characters", maxKeyNameLength), http.StatusBadRequest)
+		return
+	}
+	if len(payload.Scopes) == 0 {
+		respondError(w, `scopes must list the actions the key may run, or "*" for all`, http.StatusBadRequest)
+		return
+	}
+	seen := map[string]bool{}
+	var scopes []string
+	for _, scope := range payload.Scopes {
+		scope = strings.TrimSpace(scope)
+		if scope != auth.ScopeAll && !isProcessAction(scope) {
+			respondError(w, fmt.Sprintf("Unknown scope %q; use one of %s, or \"*\"", scope, strings.Join(processActions, ", ")), http.StatusBadRequest)
+			return
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+	if payload.ExpiresInDays < 0 || payload.ExpiresInDays > maxKeyDays {
+		respondError(w, fmt.Sprintf("expires_in_days must be between 0 and %d", maxKeyDays), http.StatusBadRequest)
+		return
+	}
+	key, secret, err := h.Store.CreateAPIKey(user.ID, name, scopes, time.Duration(payload.ExpiresInDays)*24*time.Hour)
+	if err != nil {
+		respondError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respondJSON(w, struct {
+		*auth.APIKey
+		Key string `json:"key"`
+	}{key, secret}, http.StatusCreated)
+}
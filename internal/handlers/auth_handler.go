@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/victor-butita/rephrase/internal/auth"
+)
+
+// LoginHandler signs a user in with a session cookie:
+//
+//	POST /api/auth/login  {"username": "...", "password": "..."}
+type LoginHandler struct {
+	Auth *auth.Authenticator
+}
+
+func NewLoginHandler(a *auth.Authenticator) *LoginHandler {
+	return &LoginHandler{Auth: a}
+}
+
+func (h *LoginHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	var creds struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
+		respondError(w, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+	u, err := h.Auth.Login(w, r, creds.Username, creds.Password)
+	switch {
+	case errors.Is(err, auth.ErrInvalidCredentials):
+		respondError(w, "Invalid username or password", http.StatusUnauthorized)
+	case errors.Is(err, auth.ErrTooManyAttempts):
+		respondError(w, "Too many failed logins. Try again in a few minutes.", http.StatusTooManyRequests)
+	case err != nil:
+		respondError(w, err.Error(), http.StatusInternalServerError)
+	default:
+		respondJSON(w, u, http.StatusOK)
+	}
+}
+
+// LogoutHandler ends the current session:
+//
+//	POST /api/auth/logout
+type LogoutHandler struct {
+	Auth *auth.Authenticator
+}
+
+func NewLogoutHandler(a *auth.Authenticator) *LogoutHandler {
+	return &LogoutHandler{Auth: a}
+}
+
+func (h *LogoutHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := h.Auth.Logout(w, r); err != nil {
+		respondError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AuthProvidersHandler tells the login page how users can sign in:
+//
+//	GET /api/auth/providers
+//
+// Password sign-in is always offered; single sign-on is listed when a
+// provider is configured.
+type AuthProvidersHandler struct {
+	OIDC *auth.OIDC
+}
+
+func NewAuthProvidersHandler(o *auth.OIDC) *AuthProvidersHandler {
+	return &AuthProvidersHandler{OIDC: o}
+}
+
+func (h *AuthProvidersHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	type ssoProvider struct {
+		Name     string `json:"name"`
+		LoginURL string `json:"login_url"`
+	}
+	providers := struct {
+		Password bool         `json:"password"`
+		SSO      *ssoProvider `json:"sso,omitempty"`
+	}{Password: true}
+	if h.OIDC != nil {
+		providers.SSO = &ssoProvider{Name: h.OIDC.Config.Name, LoginURL: "/api/auth/oidc/login"}
+	}
+	respondJSON(w, providers, http.StatusOK)
+}
+
+// OIDCLoginHandler starts a single sign-on by sending the browser to the
+// identity provider:
+//
+//	GET /api/auth/oidc/login
+type OIDCLoginHandler struct {
+	OIDC *auth.OIDC
+}
+
+func NewOIDCLoginHandler(o *auth.OIDC) *OIDCLoginHandler {
+	return &OIDCLoginHandler{OIDC: o}
+}
+
+func (h *OIDCLoginHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	h.OIDC.Begin(w, r)
+}
+
+// OIDCCallbackHandler is where the identity provider sends the browser back
+// to. It signs the user in and opens the app, or returns to the login page
+// with the reason it could not:
+//
+//	GET /api/auth/oidc/callback?code=...&state=...
+type OIDCCallbackHandler struct {
+	OIDC *auth.OIDC
+}
+
+func NewOIDCCallbackHandler(o *auth.OIDC) *OIDCCallbackHandler {
+	return &OIDCCallbackHandler{OIDC: o}
+}
+
+func (h *OIDCCallbackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	if _, err := h.OIDC.Finish(w, r); err != nil {
+		var message string
+		switch {
+		case errors.Is(err, auth.ErrSignInExpired):
+			message = "The sign-in expired or was started in another browser. Please try again."
+		case errors.Is(err, auth.ErrNotPermitted):
+			message = "Your account is not in a group that may use this app."
+		default:
+			log.Printf("Single sign-on failed: %v", err)
+			message = "Single sign-on failed. Please try again."
+		}
+		http.Redirect(w, r, "/login.html?error="+url.QueryEscape(message), http.StatusFound)
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+// CurrentUserHandler reports who is signed in, or 401:
+//
+//	GET /api/auth/me
+type CurrentUserHandler struct{}
+
+func NewCurrentUserHandler() *CurrentUserHandler {
+	return &CurrentUserHandler{}
+}
+
+func (h *CurrentUserHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	u, _ := auth.UserFromContext(r.Context())
+	respondJSON(w, u, http.StatusOK)
+}
+
+// UserHandler manages accounts; it is for admins only:
+//
+//	GET    /api/users                                                   list accounts
+//	POST   /api/users  {"username": "...", "password": "...", "role": "user"}  create an account
+//	DELETE /api/users?id=...                                            delete an account
+type UserHandler struct {
+	Store *auth.Store
+}
+
+func NewUserHandler(store *auth.Store) *UserHandler {
+	return &UserHandler{Store: store}
+}
+
+func (h *UserHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		users, err := h.Store.Users()
+		if err != nil {
+			respondError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, users, http.StatusOK)
+	case http.MethodPost:
+		var payload struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+			Role     string `json:"role"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			respondError(w, "Invalid JSON payload", http.StatusBadRequest)
+			return
+		}
+		if payload.Role == "" {
+			payload.Role = auth.RoleUser
+		}
+		u, err := h.Store.CreateUser(payload.Username, payload.Password, payload.Role)
+		switch {
+		case errors.Is(err, auth.ErrUserExists):
+			respondError(w, err.Error(), http.StatusConflict)
+		case err != nil:
+			respondError(w, err.Error(), http.StatusBadRequest)
+		default:
+			respondJSON(w, u, http.StatusCreated)
+		}
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		if me, _ := auth.UserFromContext(r.Context()); me != nil && me.ID == id {
+			respondError(w, "You cannot delete your own account", http.StatusBadRequest)
+			return
+		}
+		if err := h.Store.DeleteUser(id); err != nil {
+			if errors.Is(err, auth.ErrUserNotFound) {
+				respondError(w, "User not found", http.StatusNotFound)
+				return
+			}
+			respondError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		respondError(w, "Invalid request method", http.StatusMethodNotAllowed)
+	}
+}
+
+// ownerID returns the ID of the user r was made by. Whatever the request
+// creates belongs to them, and it only sees what they own. Without accounts
+// there is no user and everything belongs to "", so it is all shared.
+func ownerID(r *http.Request) string {
+	if u, ok := auth.UserFromContext(r.Context()); ok {
+		return u.ID
+	}
+	return ""
+}
+
+// mayChange reports whether the user r was made by may change or remove
+// something everyone can use but owner created: owners and admins may, and
+// anyone may without accounts.
+func mayChange(r *http.Request, owner string) bool {
+	u, ok := auth.UserFromContext(r.Context())
+	return !ok || u.ID == owner || u.Role == auth.RoleAdmin
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/victor-butita/rephrase/internal/research"
+)
+
+// CollectionHandler manages the signed-in user's research document
+// collections:
+//
+//	GET    /api/collections         list collections
+//	GET    /api/collections?id=...  fetch one collection with its documents
+//	POST   /api/collections         create a collection: {"name": "..."}
+//	DELETE /api/collections?id=...  remove a collection
+type CollectionHandler struct {
+	Library *research.Library
+}
+
+func NewCollectionHandler(library *research.Library) *CollectionHandler {
+	return &CollectionHandler{Library: library}
+}
+
+func (h *CollectionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	owner := ownerID(r)
+	switch r.Method {
+	case http.MethodGet:
+		if id == "" {
+			respondJSON(w, h.Library.List(owner), http.StatusOK)
+			return
+		}
+		c, ok := h.Library.Get(owner, id)
+		if !ok {
+			respondError(w, "Collection not found", http.StatusNotFound)
+			return
+		}
+		respondJSON(w, c, http.StatusOK)
+	case http.MethodPost:
+		var payload struct {
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			respondError(w, "Invalid JSON payload", http.StatusBadRequest)
+			return
+		}
+		c, err := h.Library.Create(owner, payload.Name)
+		if err != nil {
+			respondError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		respondJSON(w, c, http.StatusCreated)
+	case http.MethodDelete:
+		if !h.Library.Delete(owner, id) {
+			respondError(w, "Collection not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		respondError(w, "Invalid request method", http.StatusMethodNotAllowed)
+	}
+}
+
+// CollectionDocumentHandler adds and removes documents in a collection:
+//
+//	POST   /api/collections/documents?collection=...        upload one or more "file" parts
+//	DELETE /api/collections/documents?collection=...&id=... remove a document
+type CollectionDocumentHandler struct {
+	Library *research.Library
+}
+
+func NewCollectionDocumentHandler(library *research.Library) *CollectionDocumentHandler {
+	return &CollectionDocumentHandler{Library: library}
+}
+
+func (h *CollectionDocumentHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	collectionID := r.URL.Query().Get("collection")
+	owner := ownerID(r)
+	if _, ok := h.Library.Get(owner, collectionID); !ok {
+		respondError(w, "Collection not found", http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case http.MethodPost:
+		files, ok := parseUploads(w, r)
+		if !ok {
+			return
+		}
+		var added []*research.Document
+		for _, fh := range files {
+			doc, status, err := extractUpload(fh)
+			if err != nil {
+				respondError(w, err.Error(), status)
+				return
+			}
+			d, err := h.Library.AddDocument(owner, collectionID, doc)
+			if err != nil {
+				respondError(w, err.Error(), http.StatusUnprocessableEntity)
+				return
+			}
+			added = append(added, d)
+		}
+		respondJSON(w, added, http.StatusCreated)
+	case http.MethodDelete:
+		if !h.Library.RemoveDocument(owner, collectionID, r.URL.Query().Get("id")) {
+			respondError(w, "Document not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		respondError(w, "Invalid request method", http.StatusMethodNotAllowed)
+	}
+}
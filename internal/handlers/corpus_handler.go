@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/victor-butita/rephrase/internal/plagiarism"
+)
+
+// CorpusHandler manages the plagiarism reference corpus:
+//
+//	GET    /api/corpus         list documents (without text)
+//	GET    /api/corpus?id=...  fetch one document
+//	POST   /api/corpus         add a document: {"title": "...", "text": "..."}
+//	DELETE /api/corpus?id=...  remove a document
+//
+// The corpus is shared: every check searches all of it. A document can only
+// be removed by the user who added it or an admin.
+type CorpusHandler struct {
+	Index *plagiarism.Index
+}
+
+func NewCorpusHandler(index *plagiarism.Index) *CorpusHandler {
+	return &CorpusHandler{Index: index}
+}
+
+func (h *CorpusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	switch r.Method {
+	case http.MethodGet:
+		if id == "" {
+			respondJSON(w, h.Index.List(), http.StatusOK)
+			return
+		}
+		doc, ok := h.Index.Get(id)
+		if !ok {
+			respondError(w, "Document not found", http.StatusNotFound)
+			return
+		}
+		respondJSON(w, doc, http.StatusOK)
+	case http.MethodPost:
+		var payload struct {
+			Title string `json:"title"`
+			Text  string `json:"text"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			respondError(w, "Invalid JSON payload", http.StatusBadRequest)
+			return
+		}
+		doc, err := h.Index.Add(ownerID(r), payload.Title, payload.Text)
+		if err != nil {
+			respondError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		respondJSON(w, doc, http.StatusCreated)
+	case http.MethodDelete:
+		doc, ok := h.Index.Get(id)
+		if !ok {
+			respondError(w, "Document not found", http.StatusNotFound)
+			return
+		}
+		if !mayChange(r, doc.Owner) {
+			respondError(w, "Only the user who added this document can remove it", http.StatusForbidden)
+			return
+		}
+		h.Index.Delete(id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		respondError(w, "Invalid request method", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/victor-butita/rephrase/internal/export"
+)
+
+// ExportHandler renders a result from /api/process as a downloadable file:
+//
+//	POST /api/export?format=md|html|docx|pdf  {"result": <APIResponse>, "text": "<submitted text>"}
+//
+// The submitted text is optional; when present, detection, plagiarism and
+// proofreading reports include it with their findings highlighted.
+type ExportHandler struct{}
+
+func NewExportHandler() *ExportHandler {
+	return &ExportHandler{}
+}
+
+func (h *ExportHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if !export.ValidFormat(format) {
+		respondError(w, "Format must be md, html, docx, or pdf", http.StatusBadRequest)
+		return
+	}
+	var payload struct {
+		Result APIResponse `json:"result"`
+		Text   string      `json:"text"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		respondError(w, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+	doc, err := export.Build(payload.Result.exportResult(payload.Text))
+	if err != nil {
+		respondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := export.Render(&buf, doc, format); err != nil {
+		respondError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", export.ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", export.Filename(doc, format)))
+	w.Write(buf.Bytes())
+}
+
+// exportResult adapts a response for the export package. source is the
+// submitted text, or "" to leave it out.
+func (res APIResponse) exportResult(source string) export.Result {
+	return export.Result{
+		ResultType:        res.ResultType,
+		Text:              res.Text,
+		Source:            source,
+		DetectionResult:   res.DetectionResult,
+		PlagiarismResult:  res.PlagiarismResult,
+		ResearchResult:    res.ResearchResult,
+		SummaryResult:     res.SummaryResult,
+		TranslationResult: res.TranslationResult,
+		ProofreadResult:   res.ProofreadResult,
+		StyleReport:       res.StyleReport,
+		GlossaryReport:    res.GlossaryReport,
+		LengthReport:      res.LengthReport,
+	}
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/victor-butita/rephrase/internal/services"
+)
+
+// GlossaryHandler manages per-workspace terminology in the signed-in user's
+// workspaces:
+//
+//	GET    /api/glossary?workspace=...         list the workspace's entries
+//	PUT    /api/glossary?workspace=...         replace all entries
+//	POST   /api/glossary?workspace=...         add or update entries
+//	DELETE /api/glossary?workspace=...&term=   remove one entry
+type GlossaryHandler struct {
+	Store *services.GlossaryStore
+}
+
+func NewGlossaryHandler(store *services.GlossaryStore) *GlossaryHandler {
+	return &GlossaryHandler{Store: store}
+}
+
+func (h *GlossaryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	workspace := r.URL.Query().Get("workspace")
+	owner := ownerID(r)
+	switch r.Method {
+	case http.MethodGet:
+		glossary, _ := h.Store.Get(owner, workspace)
+		respondJSON(w, glossary, http.StatusOK)
+	case http.MethodPut, http.MethodPost:
+		var entries []services.GlossaryEntry
+		if err := json.NewDecoder(r.Body).Decode(&entries); err != nil {
+			respondError(w, "Invalid JSON payload: expected an array of {term, preferred} entries", http.StatusBadRequest)
+			return
+		}
+		save := h.Store.Upsert
+		if r.Method == http.MethodPut {
+			save = h.Store.Replace
+		}
+		glossary, err := save(owner, workspace, entries)
+		if err != nil {
+			respondError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		respondJSON(w, glossary, http.StatusOK)
+	case http.MethodDelete:
+		if !h.Store.Remove(owner, workspace, r.URL.Query().Get("term")) {
+			respondError(w, "Glossary term not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		respondError(w, "Invalid request method", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/victor-butita/rephrase/internal/export"
+	"github.com/victor-butita/rephrase/internal/history"
+	"github.com/victor-butita/rephrase/internal/services"
+)
+
+const historyTitleWords = 8
+
+// HistoryHandler serves the signed-in user's stored history of processed
+// requests:
+//
+//	GET    /api/history?workspace=...&action=...&q=...&limit=...&offset=...  list documents
+//	GET    /api/history?id=...                                               fetch a document with its revisions
+//	DELETE /api/history?id=...                                               delete a document
+type HistoryHandler struct {
+	Store *history.Store
+}
+
+func NewHistoryHandler(store *history.Store) *HistoryHandler {
+	return &HistoryHandler{Store: store}
+}
+
+func (h *HistoryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	id := query.Get("id")
+	owner := ownerID(r)
+	switch r.Method {
+	case http.MethodGet:
+		if id != "" {
+			doc, err := h.Store.Get(owner, id)
+			if err != nil {
+				respondHistoryError(w, err)
+				return
+			}
+			respondJSON(w, doc, http.StatusOK)
+			return
+		}
+		limit, _ := strconv.Atoi(query.Get("limit"))
+		offset, _ := strconv.Atoi(query.Get("offset"))
+		if limit < 0 || offset < 0 {
+			respondError(w, "limit and offset must not be negative", http.StatusBadRequest)
+			return
+		}
+		docs, total, err := h.Store.List(history.Query{
+			Owner:     owner,
+			Workspace: strings.TrimSpace(query.Get("workspace")),
+			Action:    query.Get("action"),
+			Search:    query.Get("q"),
+			Limit:     limit,
+			Offset:    offset,
+		})
+		if err != nil {
+			respondError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, map[string]interface{}{"documents": docs, "total": total}, http.StatusOK)
+	case http.MethodDelete:
+		if err := h.Store.Delete(owner, id); err != nil {
+			respondHistoryError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		respondError(w, "Invalid request method", http.StatusMethodNotAllowed)
+	}
+}
+
+// RevisionHandler serves single revisions of a document and moves its head:
+//
+//	GET  /api/history/revisions?id=...           fetch a revision
+//	GET  /api/history/revisions?from=...&to=...  diff two revisions
+//	POST /api/history/revisions?id=...           restore a revision as the document's head
+type RevisionHandler struct {
+	Store *history.Store
+}
+
+func NewRevisionHandler(store *history.Store) *RevisionHandler {
+	return &RevisionHandler{Store: store}
+}
+
+func (h *RevisionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	id := query.Get("id")
+	owner := ownerID(r)
+	switch r.Method {
+	case http.MethodGet:
+		if from, to := query.Get("from"), query.Get("to"); from != "" || to != "" {
+			if from == "" || to == "" {
+				respondError(w, "Both from and to revisions are required", http.StatusBadRequest)
+				return
+			}
+			diff, err := h.Store.Diff(owner, from, to)
+			if err != nil {
+				respondHistoryError(w, err)
+				return
+			}
+			respondJSON(w, diff, http.StatusOK)
+			return
+		}
+		rev, err := h.Store.Revision(owner, id)
+		if err != nil {
+			respondHistoryError(w, err)
+			return
+		}
+		respondJSON(w, rev, http.StatusOK)
+	case http.MethodPost:
+		doc, err := h.Store.Restore(owner, id)
+		if err != nil {
+			respondHistoryError(w, err)
+			return
+		}
+		respondJSON(w, doc, http.StatusOK)
+	default:
+		respondError(w, "Invalid request method", http.StatusMethodNotAllowed)
+	}
+}
+
+// WorkspaceHandler lists the signed-in user's workspaces that have stored
+// history:
+//
+//	GET /api/workspaces
+type WorkspaceHandler struct {
+	Store *history.Store
+}
+
+func NewWorkspaceHandler(store *history.Store) *WorkspaceHandler {
+	return &WorkspaceHandler{Store: store}
+}
+
+func (h *WorkspaceHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	workspaces, err := h.Store.Workspaces(ownerID(r))
+	if err != nil {
+		respondError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respondJSON(w, workspaces, http.StatusOK)
+}
+
+func respondHistoryError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, history.ErrNotFound):
+		respondError(w, "Document not found", http.StatusNotFound)
+		return
+	case errors.Is(err, history.ErrRevisionNotFound):
+		respondError(w, "Revision not found", http.StatusNotFound)
+		return
+	}
+	respondError(w, err.Error(), http.StatusInternalServerError)
+}
+
+// responseCapture holds back the response an action wrote, so it can be
+// recorded in history and tagged with its revision before it is sent.
+type responseCapture struct {
+	http.ResponseWriter
+	resp   *APIResponse
+	status int
+}
+
+// recordHistory stores a successful request and its result, either as a new
+// document or, for humanize runs, as a revision of an earlier one, and tags
+// resp with where it was stored. Failures are only logged so that a history
+// problem never costs the user their result.
+func (h *ProcessHandler) recordHistory(reqData APIRequest, resp *APIResponse) {
+	options := reqData
+	options.Text = ""
+	optionsJSON, err := json.Marshal(options)
+	if err != nil {
+		log.Printf("Failed to encode history options: %v", err)
+		return
+	}
+	resultJSON, err := json.Marshal(resp)
+	if err != nil {
+		log.Printf("Failed to encode history result: %v", err)
+		return
+	}
+	workspace := workspaceOrDefault(reqData.Workspace)
+	rev := &history.Revision{
+		Action:  reqData.Action,
+		Input:   reqData.Text,
+		Options: optionsJSON,
+		Result:  resultJSON,
+		Text:    resp.Text,
+		Output:  resultText(resp),
+	}
+	var doc *history.Document
+	documentID := ""
+	if reqData.Action == "humanize" {
+		documentID, rev.ParentID = h.revisionParent(workspace, reqData)
+	}
+	if documentID != "" {
+		doc, err = h.History.AddRevision(reqData.owner, documentID, rev)
+	} else {
+		doc, err = h.History.Record(reqData.owner, workspace, services.Excerpt(reqData.Text, historyTitleWords), rev)
+	}
+	if err != nil {
+		log.Printf("Failed to record history: %v", err)
+		return
+	}
+	resp.DocumentID, resp.RevisionID = doc.ID, rev.ID
+}
+
+// revisionParent places a humanize run in an existing document's revision
+// tree. An explicit revision_id rewrites that revision. Otherwise a run over
+// the text an earlier run produced continues from it, and a rerun over the
+// same input becomes a sibling of the earlier run. Anything else starts a new
+// document, signalled by an empty documentID.
+func (h *ProcessHandler) revisionParent(workspace string, reqData APIRequest) (documentID, parentID string) {
+	if reqData.RevisionID != "" {
+		rev, err := h.History.Revision(reqData.owner, reqData.RevisionID)
+		if err != nil {
+			log.Printf("Failed to load revision %s: %v", reqData.RevisionID, err)
+			return "", ""
+		}
+		return rev.DocumentID, rev.ID
+	}
+	rev, continued, err := h.History.FindRevision(reqData.owner, workspace, reqData.Action, reqData.Text)
+	if err != nil {
+		if !errors.Is(err, history.ErrRevisionNotFound) {
+			log.Printf("Failed to look up revision: %v", err)
+		}
+		return "", ""
+	}
+	if continued {
+		return rev.DocumentID, rev.ID
+	}
+	return rev.DocumentID, rev.ParentID
+}
+
+// resultText is a result as plain text, which is what history search runs
+// over.
+func resultText(resp *APIResponse) string {
+	doc, err := export.Build(resp.exportResult(""))
+	if err != nil {
+		return resp.Text
+	}
+	return doc.PlainText()
+}
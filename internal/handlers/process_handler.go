@@ -2,40 +2,117 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"net/url"
 	"strings"
 
+	"github.com/victor-butita/rephrase/internal/auth"
+	"github.com/victor-butita/rephrase/internal/detector"
+	"github.com/victor-butita/rephrase/internal/history"
+	"github.com/victor-butita/rephrase/internal/markup"
+	"github.com/victor-butita/rephrase/internal/plagiarism"
+	"github.com/victor-butita/rephrase/internal/research"
 	"github.com/victor-butita/rephrase/internal/services" // Use your module path
+	"github.com/victor-butita/rephrase/internal/textutil"
 )
 
 type ProcessHandler struct {
 	GeminiService *services.GeminiService
 	StatsTracker  *StatsTracker
+	StyleGuides   *services.StyleGuideStore
+	Glossaries    *services.GlossaryStore
+	Detectors     *detector.Ensemble
+	Corpus        *plagiarism.Index
+	Plagiarism    *plagiarism.Checker
+	Submissions   *services.SubmissionStore
+	Library       *research.Library
+	Sessions      *services.ResearchSessionStore
+	// History is nil when history is disabled.
+	History *history.Store
+	// APIKeys records the usage of requests made with API keys; it is nil
+	// when accounts are disabled.
+	APIKeys *auth.Store
 }
 
-func NewProcessHandler(gs *services.GeminiService, st *StatsTracker) *ProcessHandler {
+// processActions are the actions /api/process runs, which are also the
+// scopes an API key can be given.
+var processActions = []string{"humanize", "detect", "plagiarize", "research", "consistency", "summarize", "translate", "proofread"}
+
+func isProcessAction(action string) bool {
+	for _, a := range processActions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+func NewProcessHandler(gs *services.GeminiService, st *StatsTracker, sg *services.StyleGuideStore, gl *services.GlossaryStore, de *detector.Ensemble, corpus *plagiarism.Index, pc *plagiarism.Checker, sub *services.SubmissionStore, lib *research.Library, rss *services.ResearchSessionStore, hs *history.Store, keys *auth.Store) *ProcessHandler {
 	return &ProcessHandler{
 		GeminiService: gs,
 		StatsTracker:  st,
+		StyleGuides:   sg,
+		Glossaries:    gl,
+		Detectors:     de,
+		Corpus:        corpus,
+		Plagiarism:    pc,
+		Submissions:   sub,
+		Library:       lib,
+		Sessions:      rss,
+		History:       hs,
+		APIKeys:       keys,
 	}
 }
 
 type APIRequest struct {
-	Text           string `json:"text"`
-	Action         string `json:"action"`
-	Tone           string `json:"tone,omitempty"`
-	Complexity     string `json:"complexity,omitempty"`
-	Dialect        string `json:"dialect,omitempty"`
-	FreezeKeywords string `json:"freeze_keywords,omitempty"`
+	Text            string   `json:"text"`
+	Action          string   `json:"action"`
+	Tone            string   `json:"tone,omitempty"`
+	Complexity      string   `json:"complexity,omitempty"`
+	Dialect         string   `json:"dialect,omitempty"`
+	FreezeKeywords  string   `json:"freeze_keywords,omitempty"`
+	TextFormat      string   `json:"text_format,omitempty"`
+	StyleGuideID    string   `json:"style_guide_id,omitempty"`
+	Workspace       string   `json:"workspace,omitempty"`
+	TargetWords     int      `json:"target_words,omitempty"`
+	TargetPercent   int      `json:"target_percent,omitempty"`
+	LengthTolerance int      `json:"length_tolerance,omitempty"`
+	SummaryFormat   string   `json:"summary_format,omitempty"`
+	SourceLanguage  string   `json:"source_language,omitempty"`
+	TargetLanguage  string   `json:"target_language,omitempty"`
+	DetectionMode   string   `json:"detection_mode,omitempty"`
+	PlagiarismMode  string   `json:"plagiarism_mode,omitempty"`
+	CollectionID    string   `json:"collection_id,omitempty"`
+	ResearchDepth   string   `json:"research_depth,omitempty"`
+	Sections        []string `json:"research_sections,omitempty"`
+	Decompose       bool     `json:"decompose,omitempty"`
+	RevisionID      string   `json:"revision_id,omitempty"`
+
+	// owner is the signed-in user the request runs for; the workspace data
+	// it reads and the history it writes are theirs.
+	owner string
 }
 
 type APIResponse struct {
-	ResultType       string                      `json:"result_type"`
-	Text             string                      `json:"text,omitempty"`
-	DetectionResult  *services.AIDetectionResult `json:"detection_result,omitempty"`
-	PlagiarismResult *services.PlagiarismResult  `json:"plagiarism_result,omitempty"`
-	ResearchResult   *services.ResearchResult    `json:"research_result,omitempty"`
-	Error            string                      `json:"error,omitempty"`
+	ResultType        string                      `json:"result_type"`
+	Text              string                      `json:"text,omitempty"`
+	TextFormat        string                      `json:"text_format,omitempty"`
+	FormatWarnings    []string                    `json:"format_warnings,omitempty"`
+	DetectionResult   *services.AIDetectionResult `json:"detection_result,omitempty"`
+	PlagiarismResult  *services.PlagiarismResult  `json:"plagiarism_result,omitempty"`
+	ResearchResult    *services.ResearchResult    `json:"research_result,omitempty"`
+	SummaryResult     *services.SummaryResult     `json:"summary_result,omitempty"`
+	TranslationResult *services.TranslationResult `json:"translation_result,omitempty"`
+	ProofreadResult   *services.ProofreadResult   `json:"proofread_result,omitempty"`
+	StyleReport       *services.StyleReport       `json:"style_report,omitempty"`
+	GlossaryReport    *services.GlossaryReport    `json:"glossary_report,omitempty"`
+	LengthReport      *services.LengthReport      `json:"length_report,omitempty"`
+	DocumentID        string                      `json:"document_id,omitempty"`
+	RevisionID        string                      `json:"revision_id,omitempty"`
+	Error             string                      `json:"error,omitempty"`
 }
 
 func (h *ProcessHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -44,52 +121,326 @@ func (h *ProcessHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var reqData APIRequest
+	key, viaKey := auth.KeyFromContext(r.Context())
+	if viaKey && h.APIKeys != nil {
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		w = rec
+		defer func() { h.recordKeyUsage(key, reqData.Action, rec.status) }()
+	}
 	if err := json.NewDecoder(r.Body).Decode(&reqData); err != nil {
 		h.writeError(w, "Invalid JSON payload", http.StatusBadRequest)
 		return
 	}
+	reqData.owner = ownerID(r)
+	if viaKey && !key.Allows(reqData.Action) {
+		h.writeError(w, fmt.Sprintf("This API key is not allowed to run %q", reqData.Action), http.StatusForbidden)
+		return
+	}
 	if reqData.Action != "research" && len(strings.Fields(reqData.Text)) > 200 {
 		h.writeError(w, "Input text exceeds the 200-word limit.", http.StatusBadRequest)
 		return
 	}
+	if reqData.RevisionID != "" && h.History != nil {
+		rev, err := h.History.Revision(reqData.owner, reqData.RevisionID)
+		if err != nil || rev.Action != reqData.Action {
+			h.writeError(w, "Unknown revision", http.StatusBadRequest)
+			return
+		}
+	}
 	h.StatsTracker.Increment(reqData.Action)
+	capture := &responseCapture{ResponseWriter: w}
 	switch reqData.Action {
 	case "humanize":
-		h.handleHumanize(w, reqData)
+		h.handleHumanize(capture, reqData)
 	case "detect":
-		h.handleDetect(w, reqData)
+		h.handleDetect(capture, reqData)
 	case "plagiarize":
-		h.handlePlagiarize(w, reqData)
+		h.handlePlagiarize(capture, reqData)
 	case "research":
-		h.handleResearch(w, reqData)
+		h.handleResearch(capture, reqData)
+	case "consistency":
+		h.handleConsistency(capture, reqData)
+	case "summarize":
+		h.handleSummarize(capture, reqData)
+	case "translate":
+		h.handleTranslate(capture, reqData)
+	case "proofread":
+		h.handleProofread(capture, reqData)
 	default:
 		h.writeError(w, "Invalid action specified", http.StatusBadRequest)
+		return
+	}
+	if capture.resp == nil {
+		return
+	}
+	if h.History != nil && capture.status == http.StatusOK {
+		h.recordHistory(reqData, capture.resp)
+	}
+	h.writeJSON(w, *capture.resp, capture.status)
+	// Texts that were processed successfully become the workspace's history
+	// for self-plagiarism checks, which leave out copies of the text checked.
+	if capture.status == http.StatusOK && reqData.Action != "research" && strings.TrimSpace(reqData.Text) != "" {
+		h.Submissions.Record(reqData.owner, reqData.Workspace, "", reqData.Text)
 	}
 }
 
 func (h *ProcessHandler) handleHumanize(w http.ResponseWriter, reqData APIRequest) {
-	rewrittenText, err := h.GeminiService.RephraseText(reqData.Text, reqData.Tone, reqData.Complexity, reqData.Dialect, reqData.FreezeKeywords)
+	opts := services.RephraseOptions{
+		Tone:           reqData.Tone,
+		Complexity:     reqData.Complexity,
+		Dialect:        reqData.Dialect,
+		FreezeKeywords: reqData.FreezeKeywords,
+	}
+	if reqData.StyleGuideID != "" {
+		guide, ok := h.StyleGuides.Get(reqData.StyleGuideID)
+		if !ok {
+			h.writeError(w, "Unknown style guide", http.StatusBadRequest)
+			return
+		}
+		opts.StyleGuide = guide
+	}
+	if glossary, ok := h.Glossaries.Get(reqData.owner, reqData.Workspace); ok {
+		opts.Glossary = glossary
+	}
+	if reqData.TextFormat != "" && !markup.ValidFormat(reqData.TextFormat) {
+		h.writeError(w, "Invalid text format", http.StatusBadRequest)
+		return
+	}
+	format := reqData.TextFormat
+	if format == markup.FormatAuto {
+		format = markup.Detect(reqData.Text)
+	}
+	var doc *markup.Document
+	lengthBase := reqData.Text
+	if format == markup.FormatMarkdown || format == markup.FormatHTML {
+		var err error
+		if doc, err = markup.Parse(reqData.Text, format); err != nil {
+			h.writeError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		lengthBase = doc.Prose()
+	}
+	target, err := services.ResolveLengthTarget(lengthBase, reqData.TargetWords, reqData.TargetPercent, reqData.LengthTolerance)
+	if err != nil {
+		h.writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	opts.Length = target
+	if doc != nil {
+		h.humanizeDocument(w, doc, opts)
+		return
+	}
+
+	var rewrittenText string
+	var lengthReport *services.LengthReport
+	if opts.Length != nil {
+		rewrittenText, lengthReport, err = h.GeminiService.RephraseToLength(reqData.Text, opts)
+	} else {
+		rewrittenText, err = h.GeminiService.RephraseText(reqData.Text, opts)
+	}
 	if err != nil {
 		h.writeError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	h.writeJSON(w, APIResponse{ResultType: "humanize", Text: rewrittenText}, http.StatusOK)
+	resp := APIResponse{ResultType: "humanize", LengthReport: lengthReport}
+	if opts.Glossary != nil {
+		// The prompt asks for glossary terms, but the substitution pass is what guarantees them.
+		// Substitutions and the issues the substitution could not fix are
+		// both located in the text that is returned.
+		report := &services.GlossaryReport{Workspace: opts.Glossary.Workspace}
+		rewrittenText, report.Substitutions = opts.Glossary.Apply(rewrittenText)
+		report.Issues = opts.Glossary.Check(rewrittenText)
+		resp.GlossaryReport = report
+		if lengthReport != nil {
+			lengthReport.Measure(rewrittenText)
+		}
+	}
+	resp.Text = rewrittenText
+	if opts.StyleGuide != nil {
+		resp.StyleReport = &services.StyleReport{
+			GuideID:    opts.StyleGuide.ID,
+			GuideName:  opts.StyleGuide.Name,
+			Violations: services.CheckStyle(rewrittenText, opts.StyleGuide),
+		}
+	}
+	h.writeJSON(w, resp, http.StatusOK)
+}
+
+// humanizeDocument rewrites only the prose of a Markdown or HTML document and
+// puts it back into the original structure. Glossary substitutions are made
+// segment by segment, so code, URLs and markup are never touched; any
+// non-compliant terms left there are reported as issues.
+func (h *ProcessHandler) humanizeDocument(w http.ResponseWriter, doc *markup.Document, opts services.RephraseOptions) {
+	units, lengthReport, err := h.GeminiService.RephraseDocument(doc, opts)
+	if err != nil {
+		h.writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var substitutions []services.GlossarySubstitution
+	if opts.Glossary != nil {
+		for i, u := range units {
+			var subs []services.GlossarySubstitution
+			units[i], subs = opts.Glossary.Apply(u)
+			substitutions = append(substitutions, subs...)
+		}
+	}
+	text, warnings := doc.Render(units)
+	if lengthReport != nil {
+		lengthReport.Measure(markup.Prose(units))
+	}
+	resp := APIResponse{ResultType: "humanize", Text: text, TextFormat: doc.Format, FormatWarnings: warnings, LengthReport: lengthReport}
+	if opts.Glossary != nil {
+		resp.GlossaryReport = &services.GlossaryReport{
+			Workspace:     opts.Glossary.Workspace,
+			Issues:        opts.Glossary.Check(text),
+			Substitutions: locateSubstitutions(text, substitutions),
+		}
+	}
+	if opts.StyleGuide != nil {
+		resp.StyleReport = &services.StyleReport{
+			GuideID:    opts.StyleGuide.ID,
+			GuideName:  opts.StyleGuide.Name,
+			Violations: services.CheckStyle(text, opts.StyleGuide),
+		}
+	}
+	h.writeJSON(w, resp, http.StatusOK)
 }
 
+// locateSubstitutions points substitutions made segment by segment at their
+// replacements in the rendered output, searching in document order. One that
+// cannot be found, e.g. in a segment that was kept unchanged, gets offsets
+// of -1.
+func locateSubstitutions(text string, subs []services.GlossarySubstitution) []services.GlossarySubstitution {
+	located := []services.GlossarySubstitution{}
+	pos := 0
+	for _, sub := range subs {
+		sub.Start, sub.End = -1, -1
+		if i := strings.Index(text[pos:], sub.Replacement); i >= 0 {
+			start := pos + i
+			pos = start + len(sub.Replacement)
+			sub.Start = textutil.CharOffset(text, start)
+			sub.End = textutil.CharOffset(text, pos)
+		}
+		located = append(located, sub)
+	}
+	return located
+}
+
+func (h *ProcessHandler) handleConsistency(w http.ResponseWriter, reqData APIRequest) {
+	glossary, ok := h.Glossaries.Get(reqData.owner, reqData.Workspace)
+	if !ok {
+		h.writeError(w, "This workspace has no glossary entries yet", http.StatusBadRequest)
+		return
+	}
+	report := &services.GlossaryReport{Workspace: glossary.Workspace, Issues: glossary.Check(reqData.Text)}
+	var corrected string
+	corrected, report.Substitutions = glossary.Apply(reqData.Text)
+	h.writeJSON(w, APIResponse{ResultType: "consistency", Text: corrected, GlossaryReport: report}, http.StatusOK)
+}
+
+func (h *ProcessHandler) handleSummarize(w http.ResponseWriter, reqData APIRequest) {
+	if reqData.SummaryFormat != "" && !services.ValidSummaryFormat(reqData.SummaryFormat) {
+		h.writeError(w, "Invalid summary format", http.StatusBadRequest)
+		return
+	}
+	length, err := services.ResolveLengthTarget(reqData.Text, reqData.TargetWords, reqData.TargetPercent, reqData.LengthTolerance)
+	if err != nil {
+		h.writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	result, err := h.GeminiService.SummarizeText(reqData.Text, reqData.SummaryFormat, length)
+	if err != nil {
+		h.writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.writeJSON(w, APIResponse{ResultType: "summarize", SummaryResult: result}, http.StatusOK)
+}
+
+func (h *ProcessHandler) handleTranslate(w http.ResponseWriter, reqData APIRequest) {
+	if strings.TrimSpace(reqData.TargetLanguage) == "" {
+		h.writeError(w, "Target language is required", http.StatusBadRequest)
+		return
+	}
+	opts := services.TranslateOptions{
+		SourceLanguage: reqData.SourceLanguage,
+		TargetLanguage: reqData.TargetLanguage,
+		Tone:           reqData.Tone,
+		FreezeKeywords: reqData.FreezeKeywords,
+	}
+	if glossary, ok := h.Glossaries.Get(reqData.owner, reqData.Workspace); ok {
+		opts.Glossary = glossary
+	}
+	result, err := h.GeminiService.TranslateText(reqData.Text, opts)
+	if err != nil {
+		h.writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.writeJSON(w, APIResponse{ResultType: "translate", TranslationResult: result}, http.StatusOK)
+}
+
+func (h *ProcessHandler) handleProofread(w http.ResponseWriter, reqData APIRequest) {
+	result, err := h.GeminiService.ProofreadText(reqData.Text, reqData.Dialect)
+	if err != nil {
+		h.writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.writeJSON(w, APIResponse{ResultType: "proofread", ProofreadResult: result}, http.StatusOK)
+}
+
+// handleDetect runs the detector ensemble. detection_mode "local" skips the
+// LLM so the result is reproducible and works offline.
 func (h *ProcessHandler) handleDetect(w http.ResponseWriter, reqData APIRequest) {
-	result, err := h.GeminiService.DetectAI(reqData.Text)
+	var exclude []string
+	switch reqData.DetectionMode {
+	case "", "combined":
+	case "local":
+		exclude = append(exclude, "llm")
+	default:
+		h.writeError(w, "Invalid detection mode", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.Detectors.Detect(reqData.Text, exclude...)
 	if err != nil {
 		h.writeError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	h.writeJSON(w, APIResponse{ResultType: "detect", DetectionResult: result}, http.StatusOK)
+	h.writeJSON(w, APIResponse{ResultType: "detect", DetectionResult: services.NewAIDetectionResult(result)}, http.StatusOK)
 }
+
 func (h *ProcessHandler) handlePlagiarize(w http.ResponseWriter, reqData APIRequest) {
-	report, err := h.GeminiService.CheckPlagiarism(reqData.Text)
+	switch reqData.PlagiarismMode {
+	case "", "reference":
+	case "self":
+		h.handleSelfPlagiarism(w, reqData)
+		return
+	default:
+		h.writeError(w, "Unknown plagiarism mode", http.StatusBadRequest)
+		return
+	}
+	result, err := h.Plagiarism.Check(reqData.Text)
+	if err != nil {
+		h.writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	report := services.NewPlagiarismResult(result, h.Corpus.Len())
+	h.writeJSON(w, APIResponse{ResultType: "plagiarize", PlagiarismResult: report}, http.StatusOK)
+}
+
+// handleSelfPlagiarism compares the text with the workspace's own earlier
+// submissions. Matches link to the originating text.
+func (h *ProcessHandler) handleSelfPlagiarism(w http.ResponseWriter, reqData APIRequest) {
+	history, searched := h.Submissions.Source(reqData.owner, reqData.Workspace, reqData.Text)
+	result, err := plagiarism.NewChecker(history).Check(reqData.Text)
 	if err != nil {
 		h.writeError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	for i := range result.Matches {
+		m := &result.Matches[i]
+		m.URL = "/api/submissions?" + url.Values{"workspace": {reqData.Workspace}, "id": {m.DocumentID}}.Encode()
+	}
+	report := services.NewPlagiarismResult(result, searched)
 	h.writeJSON(w, APIResponse{ResultType: "plagiarize", PlagiarismResult: report}, http.StatusOK)
 }
 func (h *ProcessHandler) handleResearch(w http.ResponseWriter, reqData APIRequest) {
@@ -97,15 +448,74 @@ func (h *ProcessHandler) handleResearch(w http.ResponseWriter, reqData APIReques
 		h.writeError(w, "Research topic cannot be empty", http.StatusBadRequest)
 		return
 	}
-	result, err := h.GeminiService.ResearchTopic(reqData.Text)
+	if reqData.ResearchDepth != "" && !services.ValidResearchDepth(reqData.ResearchDepth) {
+		h.writeError(w, "Research depth must be quick, standard, or deep", http.StatusBadRequest)
+		return
+	}
+	// With a collection, the briefing answers from its best-matching
+	// passages and cites them claim by claim.
+	var retrieve services.Retriever
+	if reqData.CollectionID != "" {
+		if _, ok := h.Library.Get(reqData.owner, reqData.CollectionID); !ok {
+			h.writeError(w, "Collection not found", http.StatusNotFound)
+			return
+		}
+		retrieve = collectionRetriever(h.Library, reqData.owner, reqData.CollectionID)
+	}
+	opts := services.ResearchOptions{Depth: reqData.ResearchDepth, Sections: reqData.Sections, Decompose: reqData.Decompose}
+	result, err := h.GeminiService.ResearchTopic(reqData.Text, opts, retrieve)
 	if err != nil {
-		h.writeError(w, err.Error(), http.StatusInternalServerError)
+		message, status := researchError(err)
+		h.writeError(w, message, status)
 		return
 	}
+	result.CollectionID = reqData.CollectionID
+	h.Sessions.Create(reqData.owner, result)
 	h.writeJSON(w, APIResponse{ResultType: "research", ResearchResult: result}, http.StatusOK)
 }
 
+// researchError maps a research failure to the message and status to report:
+// problems with the request are the client's, and so is asking a collection
+// about something it does not cover.
+func researchError(err error) (string, int) {
+	switch {
+	case errors.Is(err, services.ErrInvalidResearch):
+		return err.Error(), http.StatusBadRequest
+	case errors.Is(err, services.ErrNoRelevantPassages):
+		return "No passages in the collection are relevant to this topic", http.StatusUnprocessableEntity
+	default:
+		return err.Error(), http.StatusInternalServerError
+	}
+}
+
+// recordKeyUsage counts a request made with an API key against its action.
+// Anything that is not a known action is counted as "invalid", so clients
+// cannot fill the usage table with arbitrary names.
+func (h *ProcessHandler) recordKeyUsage(key *auth.APIKey, action string, status int) {
+	if !isProcessAction(action) {
+		action = "invalid"
+	}
+	if err := h.APIKeys.RecordKeyUsage(key.ID, action, status < http.StatusBadRequest); err != nil {
+		log.Printf("Failed to record API key usage: %v", err)
+	}
+}
+
+// statusRecorder remembers the status code written through it.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
 func (h *ProcessHandler) writeJSON(w http.ResponseWriter, data APIResponse, statusCode int) {
+	if capture, ok := w.(*responseCapture); ok {
+		capture.resp, capture.status = &data, statusCode
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
 	json.NewEncoder(w).Encode(data)
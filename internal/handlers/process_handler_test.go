@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/victor-butita/rephrase/internal/services"
+)
+
+// geminiStub answers every Gemini request with the same text.
+type geminiStub string
+
+func (g geminiStub) RoundTrip(*http.Request) (*http.Response, error) {
+	body, _ := json.Marshal(services.GeminiResponse{Candidates: []struct {
+		Content      services.GeminiContent `json:"content"`
+		FinishReason string                 `json:"finishReason"`
+	}{{Content: services.GeminiContent{Parts: []services.GeminiPart{{Text: string(g)}}}, FinishReason: "STOP"}}})
+	return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(string(body)))}, nil
+}
+
+// newTestProcessHandler returns a handler whose model always replies with
+// reply.
+func newTestProcessHandler(t *testing.T, reply string) *ProcessHandler {
+	t.Helper()
+	hub := NewHub()
+	go hub.Run()
+	gs := services.NewGeminiService("test")
+	gs.HTTPClient = &http.Client{Transport: geminiStub(reply)}
+	submissions, err := services.NewSubmissionStore(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewProcessHandler(gs, NewStatsTracker(hub), services.NewStyleGuideStore(), services.NewGlossaryStore(), nil, nil, nil, submissions, nil, services.NewResearchSessionStore(), nil, nil)
+}
+
+func process(t *testing.T, h *ProcessHandler, req APIRequest) APIResponse {
+	t.Helper()
+	body, _ := json.Marshal(req)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/process", strings.NewReader(string(body))))
+	var resp APIResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, resp.Error)
+	}
+	return resp
+}
+
+func TestGlossarySubstitutionOffsets(t *testing.T) {
+	const reply = "Our naïve café app lets you log in. Naïve users log in twice."
+	tests := []struct {
+		name string
+		req  APIRequest
+	}{
+		{"humanize", APIRequest{Action: "humanize", Text: "Users log in to the app.", Workspace: "docs"}},
+		{"consistency", APIRequest{Action: "consistency", Text: reply, Workspace: "docs"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestProcessHandler(t, reply)
+			_, err := h.Glossaries.Replace("", "docs", []services.GlossaryEntry{
+				{Term: "naïve", Preferred: "simple"},
+				{Term: "log in", Preferred: "sign in"},
+				{Term: "café", Preferred: "coffee shop"},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp := process(t, h, tt.req)
+			want := "Our simple coffee shop app lets you sign in. Simple users sign in twice."
+			if resp.Text != want {
+				t.Fatalf("text = %q, want %q", resp.Text, want)
+			}
+			subs := resp.GlossaryReport.Substitutions
+			if len(subs) != 5 {
+				t.Fatalf("got %d substitutions, want 5", len(subs))
+			}
+			runes := []rune(resp.Text)
+			for _, s := range subs {
+				if s.Start < 0 || s.End > len(runes) || string(runes[s.Start:s.End]) != s.Replacement {
+					t.Errorf("substitution %q -> %q at %d-%d does not point at its replacement in the returned text", s.Original, s.Replacement, s.Start, s.End)
+				}
+			}
+		})
+	}
+}
+
+func TestLocateSubstitutions(t *testing.T) {
+	// Rendered from three segments: a heading, a list item and a paragraph,
+	// each rewritten with the glossary on its own.
+	const text = "# Sign in to the coffee shop\n\n- Simple users sign in\n\nThe [coffee shop](https://example.com/café) says: sign in, simple.\n"
+	subs := []services.GlossarySubstitution{
+		{Original: "Log in", Replacement: "Sign in"},
+		{Original: "café", Replacement: "coffee shop"},
+		{Original: "Naïve", Replacement: "Simple"},
+		{Original: "log in", Replacement: "sign in"},
+		{Original: "café", Replacement: "coffee shop"},
+		// Made in a segment that was then kept unchanged.
+		{Original: "utilise", Replacement: "use"},
+		{Original: "log in", Replacement: "sign in"},
+		{Original: "naïve", Replacement: "simple"},
+	}
+	got := locateSubstitutions(text, subs)
+	if len(got) != len(subs) {
+		t.Fatalf("got %d substitutions, want %d", len(got), len(subs))
+	}
+	runes := []rune(text)
+	last := 0
+	for i, s := range got {
+		if s.Replacement == "use" {
+			if s.Start != -1 || s.End != -1 {
+				t.Errorf("substitution %d: missing replacement located at %d-%d", i, s.Start, s.End)
+			}
+			continue
+		}
+		if s.Start < last || s.End > len(runes) || string(runes[s.Start:s.End]) != s.Replacement {
+			t.Fatalf("substitution %d %q at %d-%d does not point at its replacement after %d", i, s.Replacement, s.Start, s.End, last)
+		}
+		last = s.End
+	}
+	// Matches run in document order, so the second "coffee shop" is the
+	// link text in the paragraph and not the heading's.
+	if want := utf8.RuneCountInString(text[:strings.Index(text, "[coffee shop]")]) + 1; got[4].Start != want {
+		t.Errorf("second coffee shop at %d, want %d", got[4].Start, want)
+	}
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/victor-butita/rephrase/internal/research"
+	"github.com/victor-butita/rephrase/internal/services"
+)
+
+// ResearchSessionHandler continues the signed-in user's research briefings
+// as conversations:
+//
+//	GET    /api/research/sessions?id=...  fetch the briefing and its follow-ups
+//	POST   /api/research/sessions?id=...  ask a follow-up: {"question": "..."}
+//	DELETE /api/research/sessions?id=...  end the session
+type ResearchSessionHandler struct {
+	GeminiService *services.GeminiService
+	Sessions      *services.ResearchSessionStore
+	Library       *research.Library
+}
+
+func NewResearchSessionHandler(gs *services.GeminiService, rss *services.ResearchSessionStore, lib *research.Library) *ResearchSessionHandler {
+	return &ResearchSessionHandler{GeminiService: gs, Sessions: rss, Library: lib}
+}
+
+func (h *ResearchSessionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	owner := ownerID(r)
+	switch r.Method {
+	case http.MethodGet:
+		session, ok := h.Sessions.Get(owner, id)
+		if !ok {
+			respondError(w, "Research session not found", http.StatusNotFound)
+			return
+		}
+		respondJSON(w, session.Snapshot(), http.StatusOK)
+	case http.MethodPost:
+		session, ok := h.Sessions.Get(owner, id)
+		if !ok {
+			respondError(w, "Research session not found", http.StatusNotFound)
+			return
+		}
+		var payload struct {
+			Question string `json:"question"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			respondError(w, "Invalid JSON payload", http.StatusBadRequest)
+			return
+		}
+		var retrieve services.Retriever
+		if session.CollectionID != "" {
+			if _, ok := h.Library.Get(owner, session.CollectionID); !ok {
+				respondError(w, "The session's collection has been deleted", http.StatusGone)
+				return
+			}
+			retrieve = collectionRetriever(h.Library, owner, session.CollectionID)
+		}
+		turn, err := h.GeminiService.AskFollowUp(session, payload.Question, retrieve)
+		if err != nil {
+			message, status := researchError(err)
+			respondError(w, message, status)
+			return
+		}
+		respondJSON(w, turn, http.StatusOK)
+	case http.MethodDelete:
+		if !h.Sessions.Delete(owner, id) {
+			respondError(w, "Research session not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		respondError(w, "Invalid request method", http.StatusMethodNotAllowed)
+	}
+}
+
+func collectionRetriever(lib *research.Library, owner, collectionID string) services.Retriever {
+	return func(query string, k int) ([]research.Hit, error) {
+		return lib.Search(owner, collectionID, query, k)
+	}
+}
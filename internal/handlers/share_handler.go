@@ -0,0 +1,299 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/victor-butita/rephrase/internal/export"
+	"github.com/victor-butita/rephrase/internal/history"
+	"github.com/victor-butita/rephrase/internal/services"
+)
+
+const maxShareHours = 365 * 24
+
+// sharedSubmissionTitle stands in for the title of an earlier submission in
+// a shared plagiarism report.
+const sharedSubmissionTitle = "An earlier submission"
+
+// sharedResultCSP allows the page's own script and stylesheet, the Markdown
+// renderer, sanitizer and font it loads from their CDNs, and calls back to
+// this server, and nothing else: no inline scripts, plugins, framing or form
+// posts.
+const sharedResultCSP = "default-src 'none'; " +
+	"script-src 'self' https://cdn.jsdelivr.net; " +
+	"style-src 'self' 'unsafe-inline' https://fonts.googleapis.com; " +
+	"font-src https://fonts.gstatic.com; " +
+	"img-src 'self' data:; " +
+	"connect-src 'self'; " +
+	"base-uri 'self'; form-action 'none'; frame-ancestors 'none'"
+
+// ShareHandler publishes results from the history as read-only links and
+// manages them:
+//
+//	POST   /api/shares                {"revision_id": "...", "title": "...", "expires_in_hours": 0}
+//	GET    /api/shares?workspace=...  list a workspace's links with their access counts
+//	DELETE /api/shares?id=...         revoke a link
+//
+// A link publishes the stored result of a revision, or of a document's head
+// revision when document_id is given instead; results are never taken from
+// the client. A link never expires when expires_in_hours is 0.
+type ShareHandler struct {
+	Store *history.Store
+}
+
+func NewShareHandler(store *history.Store) *ShareHandler {
+	return &ShareHandler{Store: store}
+}
+
+// shareView is a share as the API reports it, with the link to publish.
+type shareView struct {
+	*history.Share
+	URL    string `json:"url"`
+	Active bool   `json:"active"`
+}
+
+func newShareView(sh *history.Share) shareView {
+	return shareView{Share: sh, URL: "/s/" + sh.Token, Active: sh.Active(time.Now())}
+}
+
+func (h *ShareHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	switch r.Method {
+	case http.MethodPost:
+		h.create(w, r)
+	case http.MethodGet:
+		shares, err := h.Store.Shares(ownerID(r), workspaceOrDefault(query.Get("workspace")))
+		if err != nil {
+			respondError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		views := make([]shareView, len(shares))
+		for i := range shares {
+			views[i] = newShareView(&shares[i])
+		}
+		respondJSON(w, views, http.StatusOK)
+	case http.MethodDelete:
+		sh, err := h.Store.RevokeShare(ownerID(r), query.Get("id"))
+		if err != nil {
+			respondShareError(w, err)
+			return
+		}
+		respondJSON(w, newShareView(sh), http.StatusOK)
+	default:
+		respondError(w, "Invalid request method", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *ShareHandler) create(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		DocumentID     string `json:"document_id"`
+		RevisionID     string `json:"revision_id"`
+		Title          string `json:"title"`
+		ExpiresInHours int    `json:"expires_in_hours"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		respondError(w, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+	if payload.ExpiresInHours < 0 || payload.ExpiresInHours > maxShareHours {
+		respondError(w, "expires_in_hours must be between 0 and 8760", http.StatusBadRequest)
+		return
+	}
+	if payload.RevisionID == "" && payload.DocumentID == "" {
+		respondError(w, "revision_id or document_id is required", http.StatusBadRequest)
+		return
+	}
+	owner := ownerID(r)
+	revisionID := payload.RevisionID
+	if revisionID == "" {
+		doc, err := h.Store.Get(owner, payload.DocumentID)
+		if err != nil {
+			respondHistoryError(w, err)
+			return
+		}
+		revisionID = doc.HeadRevisionID
+	}
+	rev, err := h.Store.Revision(owner, revisionID)
+	if err == nil && payload.DocumentID != "" && rev.DocumentID != payload.DocumentID {
+		err = history.ErrRevisionNotFound
+	}
+	if err != nil {
+		respondHistoryError(w, err)
+		return
+	}
+	doc, err := h.Store.Get(owner, rev.DocumentID)
+	if err != nil {
+		respondHistoryError(w, err)
+		return
+	}
+
+	var res APIResponse
+	if err := json.Unmarshal(rev.Result, &res); err != nil || res.ResultType == "" || res.Error != "" {
+		respondError(w, "Only successful results can be shared", http.StatusBadRequest)
+		return
+	}
+	redactShared(&res)
+	resultJSON, err := json.Marshal(res)
+	if err != nil {
+		respondError(w, "Failed to encode result", http.StatusInternalServerError)
+		return
+	}
+
+	title := strings.TrimSpace(payload.Title)
+	if title == "" {
+		if built, err := export.Build(res.exportResult("")); err == nil {
+			title = built.Title
+		}
+	}
+	if title == "" {
+		title = doc.Title
+	}
+	sh := &history.Share{
+		UserID:     owner,
+		Workspace:  doc.Workspace,
+		Title:      title,
+		ResultType: res.ResultType,
+		Result:     resultJSON,
+		Text:       rev.Input,
+	}
+	if err := h.Store.CreateShare(sh, time.Duration(payload.ExpiresInHours)*time.Hour); err != nil {
+		respondError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sh.Result, sh.Text = nil, ""
+	respondJSON(w, newShareView(sh), http.StatusCreated)
+}
+
+// redactShared strips a result of what a read-only link must not pass on:
+// handles into the history, a research session or a document collection
+// that the viewer could use to reach further, and the links to and titles of
+// the owner's earlier submissions, which quote their private texts.
+func redactShared(res *APIResponse) {
+	res.DocumentID, res.RevisionID = "", ""
+	if res.ResearchResult != nil {
+		research := *res.ResearchResult
+		research.SessionID, research.CollectionID = "", ""
+		res.ResearchResult = &research
+	}
+	if res.PlagiarismResult != nil {
+		report := *res.PlagiarismResult
+		report.Matches = make([]services.PlagiarismMatch, len(res.PlagiarismResult.Matches))
+		for i, m := range res.PlagiarismResult.Matches {
+			if m.Source == services.HistoryProvider {
+				m.PotentialSource = sharedSubmissionTitle
+				if m.Location != nil {
+					loc := *m.Location
+					loc.DocumentID, loc.URL = "", ""
+					m.Location = &loc
+				}
+			}
+			report.Matches[i] = m
+		}
+		res.PlagiarismResult = &report
+	}
+}
+
+func respondShareError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, history.ErrShareNotFound):
+		respondError(w, "Share not found", http.StatusNotFound)
+	case errors.Is(err, history.ErrShareGone):
+		respondError(w, "This link has been revoked or has expired", http.StatusGone)
+	default:
+		respondError(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// SharedResultHandler serves a published result read-only:
+//
+//	GET /s/<token>
+//
+// It sends the app's own page with the result embedded; the page sees the
+// embedded result, drops the editor and renders it the way the app renders
+// its own results.
+type SharedResultHandler struct {
+	Store *history.Store
+	// Page is the path of the app's index.html.
+	Page string
+}
+
+func NewSharedResultHandler(store *history.Store, page string) *SharedResultHandler {
+	return &SharedResultHandler{Store: store, Page: page}
+}
+
+func (h *SharedResultHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	// The token is the only credential, so keep it out of caches, search
+	// engines and the Referer header of outgoing links.
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("X-Robots-Tag", "noindex")
+	// Shared results are other people's data; should any of it slip past
+	// the page's escaping, it still cannot load or run anything the app
+	// itself does not.
+	w.Header().Set("Content-Security-Policy", sharedResultCSP)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	token := strings.TrimPrefix(r.URL.Path, "/s/")
+	if token == "" || strings.Contains(token, "/") {
+		http.Error(w, "This link does not exist.", http.StatusNotFound)
+		return
+	}
+	sh, err := h.Store.OpenShare(token)
+	switch {
+	case errors.Is(err, history.ErrShareNotFound):
+		http.Error(w, "This link does not exist.", http.StatusNotFound)
+		return
+	case errors.Is(err, history.ErrShareGone):
+		http.Error(w, "This link has been revoked or has expired.", http.StatusGone)
+		return
+	case err != nil:
+		log.Printf("Failed to open share: %v", err)
+		http.Error(w, "Could not load this link.", http.StatusInternalServerError)
+		return
+	}
+
+	page, err := os.ReadFile(h.Page)
+	if err != nil {
+		log.Printf("Failed to read page: %v", err)
+		http.Error(w, "Could not load this link.", http.StatusInternalServerError)
+		return
+	}
+	// json.Marshal escapes <, > and &, so the data cannot close the script
+	// element it is embedded in.
+	data, err := json.Marshal(struct {
+		Title     string          `json:"title"`
+		Result    json.RawMessage `json:"result"`
+		Text      string          `json:"text,omitempty"`
+		CreatedAt time.Time       `json:"created_at"`
+		ExpiresAt *time.Time      `json:"expires_at,omitempty"`
+	}{sh.Title, sh.Result, sh.Text, sh.CreatedAt, sh.ExpiresAt})
+	if err != nil {
+		http.Error(w, "Could not load this link.", http.StatusInternalServerError)
+		return
+	}
+	// The page links its assets relatively, so they are resolved from the
+	// site root rather than from /s/.
+	page = bytes.Replace(page, []byte("<head>"), []byte(`<head>
+    <base href="/">
+    <meta name="robots" content="noindex">`), 1)
+	page = bytes.Replace(page, []byte("</head>"), append(append([]byte(`    <script id="shared-result" type="application/json">`), data...), "</script>\n</head>"...), 1)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(page)
+}
+
+func workspaceOrDefault(workspace string) string {
+	if workspace = strings.TrimSpace(workspace); workspace != "" {
+		return workspace
+	}
+	return services.DefaultWorkspace
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/victor-butita/rephrase/internal/plagiarism"
+	"github.com/victor-butita/rephrase/internal/services"
+)
+
+func TestRedactShared(t *testing.T) {
+	res := APIResponse{
+		ResultType: "plagiarize",
+		DocumentID: "doc-1",
+		RevisionID: "rev-1",
+		ResearchResult: &services.ResearchResult{
+			Topic:        "Tides",
+			SessionID:    "session-1",
+			CollectionID: "collection-1",
+		},
+		PlagiarismResult: &services.PlagiarismResult{Matches: []services.PlagiarismMatch{
+			{
+				MatchingText:    "the passage",
+				PotentialSource: "My private draft about…",
+				Source:          services.HistoryProvider,
+				Location:        &services.PlagiarismLocation{DocumentID: "sub-1", URL: "/api/submissions?id=sub-1&workspace=w", SourceText: "the passage"},
+			},
+			{
+				MatchingText:    "another passage",
+				PotentialSource: "Reference book",
+				Source:          plagiarism.LocalCorpusProvider,
+				Location:        &services.PlagiarismLocation{DocumentID: "corpus-1", SourceText: "another passage"},
+			},
+		}},
+	}
+	before, _ := json.Marshal(res)
+
+	shared := res
+	redactShared(&shared)
+	out, _ := json.Marshal(shared)
+	for _, leak := range []string{"doc-1", "rev-1", "session-1", "collection-1", "sub-1", "/api/submissions", "My private draft"} {
+		if strings.Contains(string(out), leak) {
+			t.Errorf("shared result still contains %q: %s", leak, out)
+		}
+	}
+	history, corpus := shared.PlagiarismResult.Matches[0], shared.PlagiarismResult.Matches[1]
+	if history.PotentialSource != sharedSubmissionTitle || history.Location.SourceText != "the passage" {
+		t.Errorf("shared submission match = %+v, want it titled %q with its passage", history, sharedSubmissionTitle)
+	}
+	if corpus.PotentialSource != "Reference book" || corpus.Location.DocumentID != "corpus-1" {
+		t.Errorf("shared corpus match = %+v, want it unchanged", corpus)
+	}
+
+	// The stored result is left alone.
+	if after, _ := json.Marshal(res); string(after) != string(before) {
+		t.Errorf("redacting changed the original result:\n%s\nwant\n%s", after, before)
+	}
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/victor-butita/rephrase/internal/services"
+)
+
+// StyleGuideHandler manages uploaded style guides:
+//
+//	GET    /api/style-guides         list all guides
+//	GET    /api/style-guides?id=...  fetch one guide
+//	POST   /api/style-guides         upload (or replace) a guide
+//	DELETE /api/style-guides?id=...  remove a guide
+//
+// Guides are shared: everyone can list and use them, but only the user who
+// uploaded a guide or an admin can replace or remove it.
+type StyleGuideHandler struct {
+	Store *services.StyleGuideStore
+}
+
+func NewStyleGuideHandler(store *services.StyleGuideStore) *StyleGuideHandler {
+	return &StyleGuideHandler{Store: store}
+}
+
+func (h *StyleGuideHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	switch r.Method {
+	case http.MethodGet:
+		if id == "" {
+			respondJSON(w, h.Store.List(), http.StatusOK)
+			return
+		}
+		guide, ok := h.Store.Get(id)
+		if !ok {
+			respondError(w, "Style guide not found", http.StatusNotFound)
+			return
+		}
+		respondJSON(w, guide, http.StatusOK)
+	case http.MethodPost:
+		var guide services.StyleGuide
+		if err := json.NewDecoder(r.Body).Decode(&guide); err != nil {
+			respondError(w, "Invalid JSON payload", http.StatusBadRequest)
+			return
+		}
+		guide.Owner = ownerID(r)
+		if existing, ok := h.Store.Get(guide.ID); ok && guide.ID != "" {
+			if !mayChange(r, existing.Owner) {
+				respondError(w, "Only the user who uploaded this style guide can replace it", http.StatusForbidden)
+				return
+			}
+			guide.Owner = existing.Owner
+		}
+		saved, err := h.Store.Save(&guide)
+		if err != nil {
+			respondError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		respondJSON(w, saved, http.StatusCreated)
+	case http.MethodDelete:
+		guide, ok := h.Store.Get(id)
+		if !ok {
+			respondError(w, "Style guide not found", http.StatusNotFound)
+			return
+		}
+		if !mayChange(r, guide.Owner) {
+			respondError(w, "Only the user who uploaded this style guide can remove it", http.StatusForbidden)
+			return
+		}
+		h.Store.Delete(id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		respondError(w, "Invalid request method", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/victor-butita/rephrase/internal/services"
+)
+
+// SubmissionHandler exposes the own text history of one of the signed-in
+// user's workspaces, which the self-plagiarism check compares against:
+//
+//	GET    /api/submissions?workspace=...          list texts (without text)
+//	GET    /api/submissions?workspace=...&id=...   fetch one text
+//	POST   /api/submissions                        save a text: {"workspace", "title", "text"}
+//	DELETE /api/submissions?workspace=...&id=...   remove a text
+type SubmissionHandler struct {
+	Store *services.SubmissionStore
+}
+
+func NewSubmissionHandler(store *services.SubmissionStore) *SubmissionHandler {
+	return &SubmissionHandler{Store: store}
+}
+
+func (h *SubmissionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	workspace := r.URL.Query().Get("workspace")
+	id := r.URL.Query().Get("id")
+	owner := ownerID(r)
+	switch r.Method {
+	case http.MethodGet:
+		index := h.Store.Index(owner, workspace)
+		if id == "" {
+			respondJSON(w, index.List(), http.StatusOK)
+			return
+		}
+		doc, ok := index.Get(id)
+		if !ok {
+			respondError(w, "Document not found", http.StatusNotFound)
+			return
+		}
+		respondJSON(w, doc, http.StatusOK)
+	case http.MethodPost:
+		var payload struct {
+			Workspace string `json:"workspace"`
+			Title     string `json:"title"`
+			Text      string `json:"text"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			respondError(w, "Invalid JSON payload", http.StatusBadRequest)
+			return
+		}
+		doc, err := h.Store.Record(owner, payload.Workspace, payload.Title, payload.Text)
+		if err != nil {
+			respondError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		respondJSON(w, doc, http.StatusCreated)
+	case http.MethodDelete:
+		ok, err := h.Store.Delete(owner, workspace, id)
+		if err != nil {
+			respondError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			respondError(w, "Document not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		respondError(w, "Invalid request method", http.StatusMethodNotAllowed)
+	}
+}
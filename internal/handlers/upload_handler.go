@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/victor-butita/rephrase/internal/extract"
+	"github.com/victor-butita/rephrase/internal/textutil"
+)
+
+const maxUploadBytes = 20 << 20
+
+// UploadHandler extracts text from an uploaded document so it can be fed
+// into any action:
+//
+//	POST /api/upload  multipart/form-data with a "file" part
+//
+// The response carries the text with paragraphs separated by blank lines,
+// the paragraphs themselves, and warnings about anything that could not be
+// extracted faithfully.
+type UploadHandler struct{}
+
+func NewUploadHandler() *UploadHandler {
+	return &UploadHandler{}
+}
+
+type UploadResponse struct {
+	*extract.Document
+	Text  string `json:"text"`
+	Words int    `json:"words"`
+}
+
+func (h *UploadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	files, ok := parseUploads(w, r)
+	if !ok {
+		return
+	}
+	if len(files) != 1 {
+		respondError(w, "Upload exactly one file", http.StatusBadRequest)
+		return
+	}
+	doc, status, err := extractUpload(files[0])
+	if err != nil {
+		respondError(w, err.Error(), status)
+		return
+	}
+	text := doc.Text()
+	respondJSON(w, UploadResponse{Document: doc, Text: text, Words: textutil.WordCount(text)}, http.StatusOK)
+}
+
+// parseUploads reads a multipart form and returns its "file" parts,
+// responding with an error itself when there are none.
+func parseUploads(w http.ResponseWriter, r *http.Request) ([]*multipart.FileHeader, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadBytes)
+	if err := r.ParseMultipartForm(maxUploadBytes); err != nil {
+		respondError(w, "Upload must be multipart/form-data under 20 MB", http.StatusBadRequest)
+		return nil, false
+	}
+	files := r.MultipartForm.File["file"]
+	if len(files) == 0 {
+		respondError(w, "No file uploaded", http.StatusBadRequest)
+		return nil, false
+	}
+	return files, true
+}
+
+// extractUpload reads one uploaded file and extracts its text, returning
+// the HTTP status to use if it fails.
+func extractUpload(fh *multipart.FileHeader) (*extract.Document, int, error) {
+	if !extract.Supported(fh.Filename) {
+		exts := extract.Extensions()
+		sort.Strings(exts)
+		return nil, http.StatusBadRequest, fmt.Errorf("%s: unsupported file type (supported: %s)", fh.Filename, strings.Join(exts, ", "))
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("failed to read upload")
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("failed to read upload")
+	}
+	doc, err := extract.Extract(fh.Filename, data)
+	if err != nil {
+		return nil, http.StatusUnprocessableEntity, err
+	}
+	return doc, http.StatusOK, nil
+}
@@ -147,6 +147,9 @@ func NewStatsTracker(hub *Hub) *StatsTracker {
 			"detect":     0,
 			"plagiarize": 0,
 			"research":   0,
+			"summarize":  0,
+			"translate":  0,
+			"proofread":  0,
 		},
 	}
 }
@@ -169,6 +172,9 @@ func (st *StatsTracker) BroadcastStats() {
 		"detect_count":     st.counts["detect"],
 		"plagiarize_count": st.counts["plagiarize"],
 		"research_count":   st.counts["research"],
+		"summarize_count":  st.counts["summarize"],
+		"translate_count":  st.counts["translate"],
+		"proofread_count":  st.counts["proofread"],
 	}
 	st.mu.RUnlock()
 
@@ -0,0 +1,168 @@
+package history
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Above this many token pairs the word diff falls back to whole lines, which
+// keeps the quadratic table small for long research briefings. Texts that
+// still differ in too many lines are shown as one replacement of everything
+// between their common start and end.
+const maxDiffCells = 4_000_000
+
+const (
+	DiffEqual  = "equal"
+	DiffInsert = "insert"
+	DiffDelete = "delete"
+)
+
+type DiffOp struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// Diff compares the text of two revisions word by word.
+type Diff struct {
+	From       *Revision `json:"from"`
+	To         *Revision `json:"to"`
+	Ops        []DiffOp  `json:"ops"`
+	Insertions int       `json:"insertions"`
+	Deletions  int       `json:"deletions"`
+}
+
+// DiffText returns the edits turning a into b, as runs of equal, deleted and
+// inserted text, along with the number of words inserted and deleted.
+func DiffText(a, b string) ([]DiffOp, int, int) {
+	x, y := diffTokens(a, splitWords), diffTokens(b, splitWords)
+	if len(x)*len(y) > maxDiffCells {
+		x, y = diffTokens(a, splitLines), diffTokens(b, splitLines)
+	}
+
+	var ops []DiffOp
+	insertions, deletions := 0, 0
+	emit := func(op, text string) {
+		if op == DiffInsert {
+			insertions += wordCount(text)
+		} else if op == DiffDelete {
+			deletions += wordCount(text)
+		}
+		if n := len(ops); n > 0 && ops[n-1].Op == op {
+			ops[n-1].Text += text
+			return
+		}
+		ops = append(ops, DiffOp{Op: op, Text: text})
+	}
+
+	// The common start and end need no table.
+	prefix := 0
+	for prefix < len(x) && prefix < len(y) && x[prefix] == y[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(x)-prefix && suffix < len(y)-prefix && x[len(x)-1-suffix] == y[len(y)-1-suffix] {
+		suffix++
+	}
+	for _, t := range x[:prefix] {
+		emit(DiffEqual, t)
+	}
+	tail := y[len(y)-suffix:]
+	x, y = x[prefix:len(x)-suffix], y[prefix:len(y)-suffix]
+	if len(x)*len(y) > maxDiffCells {
+		emit(DiffDelete, strings.Join(x, ""))
+		emit(DiffInsert, strings.Join(y, ""))
+	} else {
+		diffMiddle(x, y, emit)
+	}
+	for _, t := range tail {
+		emit(DiffEqual, t)
+	}
+
+	if ops == nil {
+		ops = []DiffOp{}
+	}
+	return ops, insertions, deletions
+}
+
+// diffMiddle emits the edits turning x into y along a longest common
+// subsequence.
+func diffMiddle(x, y []string, emit func(op, text string)) {
+	// lcs[i][j] is the length of the longest common subsequence of x[i:]
+	// and y[j:].
+	lcs := make([][]int32, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		switch {
+		case x[i] == y[j]:
+			emit(DiffEqual, x[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			emit(DiffDelete, x[i])
+			i++
+		default:
+			emit(DiffInsert, y[j])
+			j++
+		}
+	}
+	for ; i < len(x); i++ {
+		emit(DiffDelete, x[i])
+	}
+	for ; j < len(y); j++ {
+		emit(DiffInsert, y[j])
+	}
+}
+
+// diffTokens splits text into tokens that concatenate back to it.
+func diffTokens(text string, split func(string) int) []string {
+	var tokens []string
+	for text != "" {
+		n := split(text)
+		tokens = append(tokens, text[:n])
+		text = text[n:]
+	}
+	return tokens
+}
+
+// splitWords returns the length of the leading word or run of whitespace.
+func splitWords(text string) int {
+	r, size := utf8.DecodeRuneInString(text)
+	space := unicode.IsSpace(r)
+	n := size
+	for n < len(text) {
+		r, size = utf8.DecodeRuneInString(text[n:])
+		if unicode.IsSpace(r) != space {
+			break
+		}
+		n += size
+	}
+	return n
+}
+
+// splitLines returns the length of the leading line, including its newline.
+func splitLines(text string) int {
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		return i + 1
+	}
+	return len(text)
+}
+
+func wordCount(text string) int {
+	return len(strings.Fields(text))
+}
@@ -0,0 +1,71 @@
+package history
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// applyDiff rebuilds one side of a diff: the original from its equal and
+// deleted runs, or the new text from its equal and inserted runs.
+func applyDiff(ops []DiffOp, keep string) string {
+	var b strings.Builder
+	for _, op := range ops {
+		if op.Op == DiffEqual || op.Op == keep {
+			b.WriteString(op.Text)
+		}
+	}
+	return b.String()
+}
+
+func TestDiffText(t *testing.T) {
+	manyLines := func(n int, word string) string {
+		var b strings.Builder
+		for i := 0; i < n; i++ {
+			fmt.Fprintf(&b, "%s %d\n", word, i)
+		}
+		return b.String()
+	}
+	tests := []struct {
+		name                  string
+		a, b                  string
+		insertions, deletions int
+	}{
+		{"equal", "The cat sat.", "The cat sat.", 0, 0},
+		{"both empty", "", "", 0, 0},
+		{"from empty", "", "A new draft.", 3, 0},
+		{"to empty", "An old draft.", "", 0, 3},
+		{"one word replaced", "The cat sat on the mat.", "The dog sat on the mat.", 1, 1},
+		{"words added and removed", "We really need to ship this soon.", "We need to ship this today, carefully.", 2, 2},
+		{"whitespace only", "one two", "one  two", 0, 0},
+		{"multibyte", "Der Bär schläft.", "Der Bär träumt.", 1, 1},
+		// Too many words for a word diff: compared line by line, which still
+		// finds the one line that changed.
+		{"line fallback", manyLines(2500, "line"), strings.Replace(manyLines(2500, "line"), "line 1200\n", "changed 1200\n", 1), 2, 2},
+		// Too many differing lines for a line diff as well: replaced as a
+		// whole between the common start and end.
+		{"over the cap", "start\n" + manyLines(3000, "old") + "end\n", "start\n" + manyLines(3000, "new") + "end\n", 6000, 6000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ops, insertions, deletions := DiffText(tt.a, tt.b)
+			if got := applyDiff(ops, DiffDelete); got != tt.a {
+				t.Errorf("equal and deleted runs give %q, want %q", got, tt.a)
+			}
+			if got := applyDiff(ops, DiffInsert); got != tt.b {
+				t.Errorf("equal and inserted runs give %q, want %q", got, tt.b)
+			}
+			if insertions != tt.insertions || deletions != tt.deletions {
+				t.Errorf("counted %d insertions and %d deletions, want %d and %d", insertions, deletions, tt.insertions, tt.deletions)
+			}
+			for i, op := range ops {
+				if op.Text == "" {
+					t.Errorf("op %d is empty", i)
+				}
+				if i > 0 && ops[i-1].Op == op.Op {
+					t.Errorf("ops %d and %d are both %s runs", i-1, i, op.Op)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package history
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/victor-butita/rephrase/internal/storeutil"
+)
+
+var (
+	ErrShareNotFound = errors.New("share not found")
+	// ErrShareGone is returned for links that were revoked or have expired.
+	ErrShareGone = errors.New("share is no longer available")
+)
+
+// Share is a result published read-only under an unguessable token. Result
+// holds the API response as JSON and Text the submitted text, if any.
+// Revoked shares are kept so their access counts stay visible. Like
+// documents, shares belong to the user who created them.
+type Share struct {
+	ID             string          `json:"id"`
+	UserID         string          `json:"-"`
+	Token          string          `json:"token"`
+	Workspace      string          `json:"workspace"`
+	Title          string          `json:"title"`
+	ResultType     string          `json:"result_type"`
+	Result         json.RawMessage `json:"result,omitempty"`
+	Text           string          `json:"text,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	ExpiresAt      *time.Time      `json:"expires_at,omitempty"`
+	RevokedAt      *time.Time      `json:"revoked_at,omitempty"`
+	AccessCount    int             `json:"access_count"`
+	LastAccessedAt *time.Time      `json:"last_accessed_at,omitempty"`
+}
+
+// Active reports whether the share can still be viewed at now.
+func (sh *Share) Active(now time.Time) bool {
+	return sh.RevokedAt == nil && (sh.ExpiresAt == nil || now.Before(*sh.ExpiresAt))
+}
+
+// CreateShare publishes sh under a new token. A zero ttl never expires.
+func (s *Store) CreateShare(sh *Share, ttl time.Duration) error {
+	now := time.Now().UTC()
+	sh.ID, sh.Token, sh.CreatedAt = storeutil.NewID(), newToken(), now
+	sh.ExpiresAt, sh.RevokedAt, sh.LastAccessedAt, sh.AccessCount = nil, nil, nil, 0
+	if ttl > 0 {
+		expires := now.Add(ttl)
+		sh.ExpiresAt = &expires
+	}
+	result := string(sh.Result)
+	if result == "" {
+		result = "{}"
+	}
+	_, err := s.db.Exec(`INSERT INTO shares (id, token, user_id, workspace, title, result_type, result, text, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		sh.ID, sh.Token, sh.UserID, sh.Workspace, sh.Title, sh.ResultType, result, sh.Text, now.UnixMilli(), storeutil.ToMillis(sh.ExpiresAt))
+	if err != nil {
+		return fmt.Errorf("failed to save share: %w", err)
+	}
+	return nil
+}
+
+// Shares lists the shares of owner's workspace, newest first, without their
+// results.
+func (s *Store) Shares(owner, workspace string) ([]Share, error) {
+	rows, err := s.db.Query(`SELECT `+shareColumns+`, '', '' FROM shares WHERE user_id = ? AND workspace = ? ORDER BY created_at DESC, rowid DESC`, owner, workspace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list shares: %w", err)
+	}
+	defer rows.Close()
+	shares := []Share{}
+	for rows.Next() {
+		sh, err := scanShare(rows)
+		if err != nil {
+			return nil, err
+		}
+		sh.Result = nil
+		shares = append(shares, *sh)
+	}
+	return shares, rows.Err()
+}
+
+// OpenShare returns the share behind token and counts the access. Revoked
+// and expired shares are reported as ErrShareGone and not counted.
+func (s *Store) OpenShare(token string) (*Share, error) {
+	now := time.Now().UTC()
+	sh, err := scanShare(s.db.QueryRow(`SELECT `+shareColumns+`, result, text FROM shares WHERE token = ?`, token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrShareNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load share: %w", err)
+	}
+	if !sh.Active(now) {
+		return nil, ErrShareGone
+	}
+	if _, err := s.db.Exec(`UPDATE shares SET access_count = access_count + 1, last_accessed_at = ? WHERE id = ?`, now.UnixMilli(), sh.ID); err != nil {
+		return nil, fmt.Errorf("failed to count share access: %w", err)
+	}
+	sh.AccessCount++
+	sh.LastAccessedAt = &now
+	return sh, nil
+}
+
+// RevokeShare stops one of owner's shares from being viewed. Revoking twice
+// is not an error.
+func (s *Store) RevokeShare(owner, id string) (*Share, error) {
+	now := time.Now().UTC()
+	if _, err := s.db.Exec(`UPDATE shares SET revoked_at = ? WHERE id = ? AND user_id = ? AND revoked_at = 0`, now.UnixMilli(), id, owner); err != nil {
+		return nil, fmt.Errorf("failed to revoke share: %w", err)
+	}
+	sh, err := scanShare(s.db.QueryRow(`SELECT `+shareColumns+`, '', '' FROM shares WHERE id = ? AND user_id = ?`, id, owner))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrShareNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load share: %w", err)
+	}
+	sh.Result = nil
+	return sh, nil
+}
+
+const shareColumns = "id, token, user_id, workspace, title, result_type, created_at, expires_at, revoked_at, access_count, last_accessed_at"
+
+func scanShare(row storeutil.RowScanner) (*Share, error) {
+	var sh Share
+	var result string
+	var created, expires, revoked, accessed int64
+	if err := row.Scan(&sh.ID, &sh.Token, &sh.UserID, &sh.Workspace, &sh.Title, &sh.ResultType, &created, &expires, &revoked, &sh.AccessCount, &accessed, &result, &sh.Text); err != nil {
+		return nil, err
+	}
+	sh.Result = json.RawMessage(result)
+	sh.CreatedAt = storeutil.FromMillis(created)
+	sh.ExpiresAt, sh.RevokedAt, sh.LastAccessedAt = storeutil.OptionalMillis(expires), storeutil.OptionalMillis(revoked), storeutil.OptionalMillis(accessed)
+	return &sh, nil
+}
+
+// newToken returns a URL-safe token with 192 bits of randomness, enough that
+// share links cannot be guessed.
+func newToken() string {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// RevokeUnownedShares revokes every active share that belongs to no user, which no
+// one could otherwise revoke once accounts are enabled, and returns how many
+// it revoked.
+func (s *Store) RevokeUnownedShares() (int, error) {
+	res, err := s.db.Exec(`UPDATE shares SET revoked_at = ? WHERE user_id = '' AND revoked_at = 0`, time.Now().UnixMilli())
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke shares: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
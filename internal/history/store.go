@@ -0,0 +1,534 @@
+// Package history persists every processed request as a document revision,
+// so work survives restarts and can be listed, searched and revisited.
+package history
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/victor-butita/rephrase/internal/storeutil"
+	_ "modernc.org/sqlite"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 200
+	snippetChars     = 160
+)
+
+var (
+	ErrNotFound         = errors.New("document not found")
+	ErrRevisionNotFound = errors.New("revision not found")
+)
+
+// migrations are applied in order; the database's user_version records how
+// many have run.
+var migrations = []string{
+	`CREATE TABLE documents (
+		id         TEXT PRIMARY KEY,
+		workspace  TEXT NOT NULL,
+		title      TEXT NOT NULL,
+		action     TEXT NOT NULL,
+		created_at INTEGER NOT NULL,
+		updated_at INTEGER NOT NULL
+	);
+	CREATE INDEX documents_workspace ON documents (workspace, updated_at DESC);
+	CREATE TABLE revisions (
+		id          TEXT PRIMARY KEY,
+		document_id TEXT NOT NULL,
+		action      TEXT NOT NULL,
+		input       TEXT NOT NULL,
+		options     TEXT NOT NULL,
+		result      TEXT NOT NULL,
+		output      TEXT NOT NULL,
+		created_at  INTEGER NOT NULL
+	);
+	CREATE INDEX revisions_document ON revisions (document_id, created_at);`,
+	`ALTER TABLE revisions ADD COLUMN parent_id TEXT NOT NULL DEFAULT '';
+	ALTER TABLE revisions ADD COLUMN text TEXT NOT NULL DEFAULT '';
+	ALTER TABLE documents ADD COLUMN head_revision_id TEXT NOT NULL DEFAULT '';
+	UPDATE revisions SET text = COALESCE(json_extract(result, '$.text'), '') WHERE json_valid(result);
+	UPDATE documents SET head_revision_id = COALESCE((SELECT r.id FROM revisions r WHERE r.document_id = documents.id ORDER BY r.created_at DESC, r.rowid DESC LIMIT 1), '');`,
+	`CREATE TABLE shares (
+		id               TEXT PRIMARY KEY,
+		token            TEXT NOT NULL UNIQUE,
+		workspace        TEXT NOT NULL,
+		title            TEXT NOT NULL,
+		result_type      TEXT NOT NULL,
+		result           TEXT NOT NULL,
+		text             TEXT NOT NULL,
+		created_at       INTEGER NOT NULL,
+		expires_at       INTEGER NOT NULL DEFAULT 0,
+		revoked_at       INTEGER NOT NULL DEFAULT 0,
+		access_count     INTEGER NOT NULL DEFAULT 0,
+		last_accessed_at INTEGER NOT NULL DEFAULT 0
+	);
+	CREATE INDEX shares_workspace ON shares (workspace, created_at DESC);`,
+	`CREATE TABLE submissions (
+		id         TEXT PRIMARY KEY,
+		workspace  TEXT NOT NULL,
+		title      TEXT NOT NULL,
+		text       TEXT NOT NULL,
+		created_at INTEGER NOT NULL
+	);
+	CREATE INDEX submissions_workspace ON submissions (workspace, created_at);`,
+	`ALTER TABLE documents ADD COLUMN user_id TEXT NOT NULL DEFAULT '';
+	ALTER TABLE shares ADD COLUMN user_id TEXT NOT NULL DEFAULT '';
+	ALTER TABLE submissions ADD COLUMN user_id TEXT NOT NULL DEFAULT '';
+	DROP INDEX documents_workspace;
+	DROP INDEX shares_workspace;
+	DROP INDEX submissions_workspace;
+	CREATE INDEX documents_user ON documents (user_id, workspace, updated_at DESC);
+	CREATE INDEX shares_user ON shares (user_id, workspace, created_at DESC);
+	CREATE INDEX submissions_user ON submissions (user_id, workspace, created_at);`,
+}
+
+// Document groups the revisions of one piece of work. Revisions form a tree
+// through their parents; the head is the revision the document currently
+// stands at, normally the newest one unless an older one was restored.
+//
+// Every document belongs to the user whose request created it, and the
+// store's methods take that user's ID as owner: documents, their revisions
+// and their workspaces are invisible to everyone else. The owner is "" when
+// accounts are disabled.
+type Document struct {
+	ID             string     `json:"id"`
+	UserID         string     `json:"-"`
+	Workspace      string     `json:"workspace"`
+	Title          string     `json:"title"`
+	Action         string     `json:"action"`
+	HeadRevisionID string     `json:"head_revision_id"`
+	RevisionCount  int        `json:"revision_count"`
+	Snippet        string     `json:"snippet,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	Revisions      []Revision `json:"revisions,omitempty"`
+}
+
+// Revision is one request and its result. Options and Result hold the
+// request options and API response as JSON; Output is the result as plain
+// text, which search runs over. Text is the rewritten text itself, for
+// actions that produce one, and is what revisions are diffed and continued
+// from. ParentID is empty for the first revision of a document.
+type Revision struct {
+	ID         string          `json:"id"`
+	DocumentID string          `json:"document_id"`
+	ParentID   string          `json:"parent_id,omitempty"`
+	Action     string          `json:"action"`
+	Input      string          `json:"input"`
+	Text       string          `json:"text,omitempty"`
+	Options    json.RawMessage `json:"options"`
+	Result     json.RawMessage `json:"result"`
+	Output     string          `json:"output"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// Query filters a history listing. Search matches titles, inputs and
+// outputs case-insensitively. Only Owner's documents are ever listed.
+type Query struct {
+	Owner     string
+	Workspace string
+	Action    string
+	Search    string
+	Limit     int
+	Offset    int
+}
+
+type Workspace struct {
+	Name      string    `json:"name"`
+	Documents int       `json:"documents"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type Store struct {
+	db *sql.DB
+}
+
+// Open opens or creates the SQLite database at path and brings its schema up
+// to date.
+func Open(path string) (*Store, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history database: %w", err)
+	}
+	// SQLite allows one writer at a time; a single connection avoids
+	// "database is locked" errors under concurrent requests.
+	db.SetMaxOpenConns(1)
+	s := &Store{db: db}
+	if err := storeutil.Migrate(db, "history", migrations); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Record stores rev as the first revision of a new document of owner's and
+// returns the document.
+func (s *Store) Record(owner, workspace, title string, rev *Revision) (*Document, error) {
+	now := time.Now().UTC()
+	doc := &Document{
+		ID:            storeutil.NewID(),
+		UserID:        owner,
+		Workspace:     workspace,
+		Title:         title,
+		Action:        rev.Action,
+		RevisionCount: 1,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	rev.ID, rev.DocumentID, rev.ParentID, rev.CreatedAt = storeutil.NewID(), doc.ID, "", now
+	doc.HeadRevisionID = rev.ID
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`INSERT INTO documents (id, user_id, workspace, title, action, head_revision_id, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		doc.ID, doc.UserID, doc.Workspace, doc.Title, doc.Action, doc.HeadRevisionID, now.UnixMilli(), now.UnixMilli()); err != nil {
+		return nil, fmt.Errorf("failed to save document: %w", err)
+	}
+	if err := insertRevision(tx, rev); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+func insertRevision(tx *sql.Tx, rev *Revision) error {
+	options, result := string(rev.Options), string(rev.Result)
+	if options == "" {
+		options = "{}"
+	}
+	if result == "" {
+		result = "{}"
+	}
+	_, err := tx.Exec(`INSERT INTO revisions (id, document_id, parent_id, action, input, text, options, result, output, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		rev.ID, rev.DocumentID, rev.ParentID, rev.Action, rev.Input, rev.Text, options, result, rev.Output, rev.CreatedAt.UnixMilli())
+	if err != nil {
+		return fmt.Errorf("failed to save revision: %w", err)
+	}
+	return nil
+}
+
+// AddRevision stores rev in an existing document as a child of rev.ParentID,
+// or as another draft of the original input when ParentID is empty, and makes
+// it the document's head. Adding to a revision that already has children
+// starts a branch; earlier drafts are never overwritten.
+func (s *Store) AddRevision(owner, documentID string, rev *Revision) (*Document, error) {
+	if _, err := s.document(owner, documentID); err != nil {
+		return nil, err
+	}
+	if rev.ParentID != "" {
+		parent, err := s.Revision(owner, rev.ParentID)
+		if err != nil {
+			return nil, err
+		}
+		if parent.DocumentID != documentID {
+			return nil, fmt.Errorf("revision %s belongs to another document", parent.ID)
+		}
+	}
+	now := time.Now().UTC()
+	rev.ID, rev.DocumentID, rev.CreatedAt = storeutil.NewID(), documentID, now
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	if err := insertRevision(tx, rev); err != nil {
+		return nil, err
+	}
+	if err := setHead(tx, rev.DocumentID, rev.ID, now); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.document(owner, rev.DocumentID)
+}
+
+// Restore makes the revision id the head of its document again. Later
+// revisions are kept, and the next rewrite branches from the restored one.
+func (s *Store) Restore(owner, id string) (*Document, error) {
+	rev, err := s.Revision(owner, id)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	if err := setHead(tx, rev.DocumentID, rev.ID, time.Now().UTC()); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.Get(owner, rev.DocumentID)
+}
+
+func setHead(tx *sql.Tx, documentID, revisionID string, at time.Time) error {
+	res, err := tx.Exec(`UPDATE documents SET head_revision_id = ?, updated_at = ? WHERE id = ?`, revisionID, at.UnixMilli(), documentID)
+	if err != nil {
+		return fmt.Errorf("failed to update document: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Revision returns a single revision of one of owner's documents.
+func (s *Store) Revision(owner, id string) (*Revision, error) {
+	rev, err := scanRevision(s.db.QueryRow(`SELECT `+storeutil.PrefixColumns("r.", revisionColumns)+` FROM revisions r JOIN documents d ON d.id = r.document_id
+		WHERE r.id = ? AND d.user_id = ?`, id, owner))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRevisionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load revision: %w", err)
+	}
+	return rev, nil
+}
+
+// FindRevision returns the newest revision of an action in owner's workspace
+// whose rewritten text or input is text, ignoring surrounding whitespace. It
+// reports whether the match was on the rewritten text, meaning text
+// continues from that revision rather than repeating it.
+func (s *Store) FindRevision(owner, workspace, action, text string) (*Revision, bool, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, false, ErrRevisionNotFound
+	}
+	rev, err := scanRevision(s.db.QueryRow(`SELECT `+storeutil.PrefixColumns("r.", revisionColumns)+` FROM revisions r JOIN documents d ON d.id = r.document_id
+		WHERE d.user_id = ? AND d.workspace = ? AND r.action = ? AND (trim(r.text) = ? OR trim(r.input) = ?)
+		ORDER BY r.created_at DESC, r.rowid DESC LIMIT 1`, owner, workspace, action, text, text))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, ErrRevisionNotFound
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to look up revision: %w", err)
+	}
+	return rev, strings.TrimSpace(rev.Text) == text, nil
+}
+
+// Diff compares the rewritten text of two revisions, falling back to their
+// input for actions that do not rewrite.
+func (s *Store) Diff(owner, fromID, toID string) (*Diff, error) {
+	from, err := s.Revision(owner, fromID)
+	if err != nil {
+		return nil, err
+	}
+	to, err := s.Revision(owner, toID)
+	if err != nil {
+		return nil, err
+	}
+	d := &Diff{From: from, To: to}
+	d.Ops, d.Insertions, d.Deletions = DiffText(from.content(), to.content())
+	return d, nil
+}
+
+func (r *Revision) content() string {
+	if r.Text != "" {
+		return r.Text
+	}
+	return r.Input
+}
+
+const revisionColumns = "id, document_id, parent_id, action, input, text, options, result, output, created_at"
+
+func scanRevision(row storeutil.RowScanner) (*Revision, error) {
+	var r Revision
+	var options, result string
+	var at int64
+	if err := row.Scan(&r.ID, &r.DocumentID, &r.ParentID, &r.Action, &r.Input, &r.Text, &options, &result, &r.Output, &at); err != nil {
+		return nil, err
+	}
+	r.Options, r.Result, r.CreatedAt = json.RawMessage(options), json.RawMessage(result), storeutil.FromMillis(at)
+	return &r, nil
+}
+
+// List returns the documents matching q, most recently updated first, and
+// the total number of matches.
+func (s *Store) List(q Query) ([]Document, int, error) {
+	where := []string{"d.user_id = ?"}
+	args := []interface{}{q.Owner}
+	if q.Workspace != "" {
+		where = append(where, "d.workspace = ?")
+		args = append(args, q.Workspace)
+	}
+	if q.Action != "" {
+		where = append(where, "d.action = ?")
+		args = append(args, q.Action)
+	}
+	if search := strings.TrimSpace(q.Search); search != "" {
+		pattern := "%" + likeEscaper.Replace(search) + "%"
+		where = append(where, `(d.title LIKE ? ESCAPE '\' OR EXISTS (SELECT 1 FROM revisions r WHERE r.document_id = d.id AND (r.input LIKE ? ESCAPE '\' OR r.output LIKE ? ESCAPE '\')))`)
+		args = append(args, pattern, pattern, pattern)
+	}
+	clause := " WHERE " + strings.Join(where, " AND ")
+
+	var total int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM documents d"+clause, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count history: %w", err)
+	}
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	if limit > maxListLimit {
+		limit = maxListLimit
+	}
+	rows, err := s.db.Query(`SELECT d.id, d.workspace, d.title, d.action, d.head_revision_id, d.created_at, d.updated_at,
+		(SELECT COUNT(*) FROM revisions r WHERE r.document_id = d.id),
+		COALESCE((SELECT CASE WHEN r.output <> '' THEN r.output ELSE r.input END FROM revisions r WHERE r.id = d.head_revision_id), '')
+		FROM documents d`+clause+` ORDER BY d.updated_at DESC, d.rowid DESC LIMIT ? OFFSET ?`,
+		append(args, limit, q.Offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list history: %w", err)
+	}
+	defer rows.Close()
+	docs := []Document{}
+	for rows.Next() {
+		var d Document
+		var created, updated int64
+		if err := rows.Scan(&d.ID, &d.Workspace, &d.Title, &d.Action, &d.HeadRevisionID, &created, &updated, &d.RevisionCount, &d.Snippet); err != nil {
+			return nil, 0, err
+		}
+		d.CreatedAt, d.UpdatedAt = storeutil.FromMillis(created), storeutil.FromMillis(updated)
+		d.Snippet = snippet(d.Snippet)
+		docs = append(docs, d)
+	}
+	return docs, total, rows.Err()
+}
+
+// Get returns one of owner's documents with all of its revisions, oldest
+// first.
+func (s *Store) Get(owner, id string) (*Document, error) {
+	d, err := s.document(owner, id)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db.Query(`SELECT `+revisionColumns+` FROM revisions WHERE document_id = ? ORDER BY created_at, rowid`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load revisions: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		r, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		d.Revisions = append(d.Revisions, *r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	d.RevisionCount = len(d.Revisions)
+	return d, nil
+}
+
+// document returns one of owner's documents without its revisions.
+func (s *Store) document(owner, id string) (*Document, error) {
+	var d Document
+	var created, updated int64
+	err := s.db.QueryRow(`SELECT id, user_id, workspace, title, action, head_revision_id, created_at, updated_at,
+		(SELECT COUNT(*) FROM revisions r WHERE r.document_id = documents.id)
+		FROM documents WHERE id = ? AND user_id = ?`, id, owner).
+		Scan(&d.ID, &d.UserID, &d.Workspace, &d.Title, &d.Action, &d.HeadRevisionID, &created, &updated, &d.RevisionCount)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load document: %w", err)
+	}
+	d.CreatedAt, d.UpdatedAt = storeutil.FromMillis(created), storeutil.FromMillis(updated)
+	return &d, nil
+}
+
+// Delete removes one of owner's documents and its revisions.
+func (s *Store) Delete(owner, id string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	res, err := tx.Exec(`DELETE FROM documents WHERE id = ? AND user_id = ?`, id, owner)
+	if err != nil {
+		return fmt.Errorf("failed to delete document: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	if _, err := tx.Exec(`DELETE FROM revisions WHERE document_id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete revisions: %w", err)
+	}
+	return tx.Commit()
+}
+
+// Workspaces lists owner's workspaces with stored documents, most recently
+// used first.
+func (s *Store) Workspaces(owner string) ([]Workspace, error) {
+	rows, err := s.db.Query(`SELECT workspace, COUNT(*), MAX(updated_at) FROM documents WHERE user_id = ? GROUP BY workspace ORDER BY MAX(updated_at) DESC`, owner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workspaces: %w", err)
+	}
+	defer rows.Close()
+	workspaces := []Workspace{}
+	for rows.Next() {
+		var ws Workspace
+		var updated int64
+		if err := rows.Scan(&ws.Name, &ws.Documents, &updated); err != nil {
+			return nil, err
+		}
+		ws.UpdatedAt = storeutil.FromMillis(updated)
+		workspaces = append(workspaces, ws)
+	}
+	return workspaces, rows.Err()
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func snippet(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	runes := []rune(text)
+	if len(runes) <= snippetChars {
+		return text
+	}
+	return strings.TrimSpace(string(runes[:snippetChars])) + "…"
+}
+
+// ClaimUnowned gives owner the documents, shares and submissions that belong
+// to no user because they were saved while accounts were disabled, and
+// returns how many documents it moved.
+func (s *Store) ClaimUnowned(owner string) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	res, err := tx.Exec(`UPDATE documents SET user_id = ? WHERE user_id = ''`, owner)
+	if err != nil {
+		return 0, fmt.Errorf("failed to claim documents: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	for _, table := range []string{"shares", "submissions"} {
+		if _, err := tx.Exec(`UPDATE `+table+` SET user_id = ? WHERE user_id = ''`, owner); err != nil {
+			return 0, fmt.Errorf("failed to claim %s: %w", table, err)
+		}
+	}
+	return int(n), tx.Commit()
+}
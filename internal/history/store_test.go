@@ -0,0 +1,103 @@
+package history
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	s, err := Open(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestOwnerScoping(t *testing.T) {
+	s := newTestStore(t)
+	rev := &Revision{Action: "humanize", Input: "Some text.", Text: "Other text."}
+	doc, err := s.Record("alice", "default", "Some text.", rev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get("alice", doc.ID); err != nil {
+		t.Fatalf("owner cannot get their document: %v", err)
+	}
+	if _, err := s.Get("bob", doc.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get by another user = %v, want ErrNotFound", err)
+	}
+	if _, err := s.Revision("bob", rev.ID); !errors.Is(err, ErrRevisionNotFound) {
+		t.Errorf("Revision by another user = %v, want ErrRevisionNotFound", err)
+	}
+	if _, _, err := s.FindRevision("bob", "default", "humanize", "Other text."); !errors.Is(err, ErrRevisionNotFound) {
+		t.Errorf("FindRevision by another user = %v, want ErrRevisionNotFound", err)
+	}
+	if _, err := s.AddRevision("bob", doc.ID, &Revision{Action: "humanize", Input: "x", ParentID: rev.ID}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("AddRevision by another user = %v, want ErrNotFound", err)
+	}
+	if docs, total, err := s.List(Query{Owner: "bob"}); err != nil || total != 0 || len(docs) != 0 {
+		t.Errorf("List by another user = %d documents, %v", total, err)
+	}
+	if ws, err := s.Workspaces("bob"); err != nil || len(ws) != 0 {
+		t.Errorf("Workspaces by another user = %v, %v", ws, err)
+	}
+	if err := s.Delete("bob", doc.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete by another user = %v, want ErrNotFound", err)
+	}
+}
+
+func TestClaimUnowned(t *testing.T) {
+	s := newTestStore(t)
+	doc, err := s.Record("", "default", "Saved without accounts.", &Revision{Action: "detect", Input: "Saved without accounts."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sh := &Share{Workspace: "default", Title: "Shared", ResultType: "detect"}
+	if err := s.CreateShare(sh, 0); err != nil {
+		t.Fatal(err)
+	}
+	n, err := s.ClaimUnowned("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("claimed %d documents, want 1", n)
+	}
+	if _, err := s.Get("admin", doc.ID); err != nil {
+		t.Errorf("new owner cannot get the document: %v", err)
+	}
+	if _, err := s.Get("", doc.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("document is still unowned: %v", err)
+	}
+	if _, err := s.RevokeShare("admin", sh.ID); err != nil {
+		t.Errorf("new owner cannot revoke the share: %v", err)
+	}
+}
+
+func TestRevokeUnownedShares(t *testing.T) {
+	s := newTestStore(t)
+	unowned := &Share{Workspace: "default", Title: "Unowned", ResultType: "detect"}
+	owned := &Share{UserID: "alice", Workspace: "default", Title: "Owned", ResultType: "detect"}
+	for _, sh := range []*Share{unowned, owned} {
+		if err := s.CreateShare(sh, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n, err := s.RevokeUnownedShares(); err != nil || n != 1 {
+		t.Fatalf("RevokeUnownedShares = %d, %v, want 1", n, err)
+	}
+	if _, err := s.OpenShare(unowned.Token); !errors.Is(err, ErrShareGone) {
+		t.Errorf("unowned share still opens: %v", err)
+	}
+	got, err := s.OpenShare(owned.Token)
+	if err != nil {
+		t.Fatalf("owned share no longer opens: %v", err)
+	}
+	if !got.Active(time.Now()) {
+		t.Error("owned share was revoked")
+	}
+}
@@ -0,0 +1,58 @@
+package history
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/victor-butita/rephrase/internal/storeutil"
+)
+
+// Submission is a text a workspace submitted or saved, which self-plagiarism
+// checks compare new drafts against. The plagiarism index of submissions is
+// kept in memory and rebuilt from these rows at startup. Workspaces are
+// per user, so submissions belong to a user as well.
+type Submission struct {
+	ID        string
+	UserID    string
+	Workspace string
+	Title     string
+	Text      string
+	CreatedAt time.Time
+}
+
+// AddSubmission stores sub under the ID and time it was indexed with.
+func (s *Store) AddSubmission(sub *Submission) error {
+	_, err := s.db.Exec(`INSERT INTO submissions (id, user_id, workspace, title, text, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		sub.ID, sub.UserID, sub.Workspace, sub.Title, sub.Text, sub.CreatedAt.UnixMilli())
+	if err != nil {
+		return fmt.Errorf("failed to save submission: %w", err)
+	}
+	return nil
+}
+
+// Submissions returns every workspace's submissions, oldest first.
+func (s *Store) Submissions() ([]Submission, error) {
+	rows, err := s.db.Query(`SELECT id, user_id, workspace, title, text, created_at FROM submissions ORDER BY created_at, rowid`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load submissions: %w", err)
+	}
+	defer rows.Close()
+	var subs []Submission
+	for rows.Next() {
+		var sub Submission
+		var created int64
+		if err := rows.Scan(&sub.ID, &sub.UserID, &sub.Workspace, &sub.Title, &sub.Text, &created); err != nil {
+			return nil, err
+		}
+		sub.CreatedAt = storeutil.FromMillis(created)
+		subs = append(subs, sub)
+	}
+	return subs, rows.Err()
+}
+
+func (s *Store) DeleteSubmission(owner, id string) error {
+	if _, err := s.db.Exec(`DELETE FROM submissions WHERE id = ? AND user_id = ?`, id, owner); err != nil {
+		return fmt.Errorf("failed to delete submission: %w", err)
+	}
+	return nil
+}
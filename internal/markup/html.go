@@ -0,0 +1,261 @@
+package markup
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var htmlDocument = regexp.MustCompile(`(?i)<(!doctype|html|head|body)[\s>]`)
+
+// htmlInline elements wrap text that is rewritten along with its
+// surroundings; they become paired placeholders.
+var htmlInline = map[atom.Atom]bool{
+	atom.A: true, atom.Abbr: true, atom.B: true, atom.Bdi: true, atom.Bdo: true,
+	atom.Cite: true, atom.Data: true, atom.Del: true, atom.Dfn: true, atom.Em: true,
+	atom.Font: true, atom.I: true, atom.Ins: true, atom.Label: true, atom.Mark: true,
+	atom.Q: true, atom.S: true, atom.Small: true, atom.Span: true, atom.Strong: true,
+	atom.Sub: true, atom.Sup: true, atom.Time: true, atom.U: true,
+}
+
+// htmlAtomic elements sit inside prose but are kept whole, as a single
+// placeholder.
+var htmlAtomic = map[atom.Atom]bool{
+	atom.Br: true, atom.Wbr: true, atom.Img: true, atom.Input: true, atom.Button: true,
+	atom.Select: true, atom.Textarea: true, atom.Code: true, atom.Kbd: true,
+	atom.Samp: true, atom.Var: true, atom.Svg: true, atom.Math: true,
+	atom.Script: true, atom.Style: true, atom.Iframe: true, atom.Object: true,
+	atom.Embed: true, atom.Video: true, atom.Audio: true, atom.Canvas: true,
+	atom.Picture: true, atom.Noscript: true, atom.Template: true,
+}
+
+// htmlSkipped elements are never descended into.
+var htmlSkipped = map[atom.Atom]bool{
+	atom.Head: true, atom.Pre: true, atom.Code: true, atom.Script: true,
+	atom.Style: true, atom.Textarea: true, atom.Template: true, atom.Noscript: true,
+	atom.Svg: true, atom.Math: true, atom.Iframe: true, atom.Object: true,
+	atom.Select: true,
+}
+
+// htmlRun is a sequence of sibling inline nodes holding one unit of prose.
+type htmlRun struct {
+	parent *html.Node
+	nodes  []*html.Node
+	// lead and trail are whitespace kept outside the unit.
+	lead, trail string
+	// markers holds the node behind each placeholder.
+	markers []*html.Node
+}
+
+type htmlTree struct {
+	root  *html.Node
+	full  bool
+	runs  []*htmlRun
+	units []*unit
+}
+
+// parseHTML splits a full HTML document or a fragment. Text inside pre,
+// code, script, style and form controls is left alone.
+func parseHTML(text string) (*Document, error) {
+	tree, err := buildHTMLTree(text)
+	if err != nil {
+		return nil, err
+	}
+	return &Document{
+		Format: FormatHTML,
+		units:  tree.units,
+		assemble: func(tokens [][]token) (string, error) {
+			// Rendering rewires nodes, so it works on a fresh parse; the
+			// tree is built the same way every time.
+			tree, err := buildHTMLTree(text)
+			if err != nil {
+				return "", err
+			}
+			if len(tree.runs) != len(tokens) {
+				return "", fmt.Errorf("html structure changed between parses")
+			}
+			for i, run := range tree.runs {
+				run.replace(tokens[i])
+			}
+			var b strings.Builder
+			if tree.full {
+				err = html.Render(&b, tree.root)
+			} else {
+				for c := tree.root.FirstChild; c != nil && err == nil; c = c.NextSibling {
+					err = html.Render(&b, c)
+				}
+			}
+			if err != nil {
+				return "", fmt.Errorf("failed to render html: %w", err)
+			}
+			return b.String(), nil
+		},
+	}, nil
+}
+
+func buildHTMLTree(text string) (*htmlTree, error) {
+	tree := &htmlTree{full: htmlDocument.MatchString(text)}
+	if tree.full {
+		root, err := html.Parse(strings.NewReader(text))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse html: %w", err)
+		}
+		tree.root = root
+	} else {
+		body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+		nodes, err := html.ParseFragment(strings.NewReader(text), body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse html: %w", err)
+		}
+		tree.root = &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div}
+		for _, n := range nodes {
+			tree.root.AppendChild(n)
+		}
+	}
+	tree.walk(tree.root)
+	return tree, nil
+}
+
+// walk groups the children of n into runs of inline content, descending into
+// block elements.
+func (t *htmlTree) walk(n *html.Node) {
+	var run []*html.Node
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if isInlineNode(c) {
+			run = append(run, c)
+			continue
+		}
+		t.addRun(n, run)
+		run = nil
+		if c.Type == html.ElementNode && !htmlSkipped[c.DataAtom] {
+			t.walk(c)
+		}
+	}
+	t.addRun(n, run)
+}
+
+func isInlineNode(n *html.Node) bool {
+	switch n.Type {
+	case html.TextNode:
+		return true
+	case html.ElementNode:
+		if htmlAtomic[n.DataAtom] {
+			return true
+		}
+		if !htmlInline[n.DataAtom] {
+			return false
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if !isInlineNode(c) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+var htmlSpace = regexp.MustCompile(`\s+`)
+
+func (t *htmlTree) addRun(parent *html.Node, nodes []*html.Node) {
+	if len(nodes) == 0 {
+		return
+	}
+	run := &htmlRun{parent: parent, nodes: nodes}
+	u := &unit{}
+	var b strings.Builder
+	var write func(n *html.Node)
+	write = func(n *html.Node) {
+		switch {
+		case n.Type == html.TextNode:
+			text := htmlSpace.ReplaceAllString(n.Data, " ")
+			for {
+				i := strings.Index(text, "{{")
+				if i < 0 {
+					break
+				}
+				b.WriteString(text[:i])
+				run.markers = append(run.markers, &html.Node{Type: html.TextNode, Data: "{{"})
+				fmt.Fprintf(&b, "{{%d}}", u.single())
+				text = text[i+2:]
+			}
+			b.WriteString(text)
+		case htmlAtomic[n.DataAtom]:
+			run.markers = append(run.markers, n)
+			fmt.Fprintf(&b, "{{%d}}", u.single())
+		default:
+			run.markers = append(run.markers, n)
+			k := u.pair()
+			fmt.Fprintf(&b, "{{%d}}", k)
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				write(c)
+			}
+			fmt.Fprintf(&b, "{{/%d}}", k)
+		}
+	}
+	for _, n := range nodes {
+		write(n)
+	}
+	u.text = strings.TrimSpace(b.String())
+	if !HasProse(u.text) {
+		return
+	}
+	if first := nodes[0]; first.Type == html.TextNode {
+		run.lead = first.Data[:len(first.Data)-len(strings.TrimLeft(first.Data, " \t\r\n\f"))]
+	}
+	if last := nodes[len(nodes)-1]; last.Type == html.TextNode {
+		run.trail = last.Data[len(strings.TrimRight(last.Data, " \t\r\n\f")):]
+	}
+	t.runs = append(t.runs, run)
+	t.units = append(t.units, u)
+}
+
+// replace swaps the run's nodes for ones built from tokens, reusing the
+// original elements for placeholders.
+func (run *htmlRun) replace(tokens []token) {
+	next := run.nodes[len(run.nodes)-1].NextSibling
+	for _, n := range run.nodes {
+		run.parent.RemoveChild(n)
+	}
+	holder := &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div}
+	if run.lead != "" {
+		holder.AppendChild(&html.Node{Type: html.TextNode, Data: run.lead})
+	}
+	stack := []*html.Node{holder}
+	for _, t := range tokens {
+		top := stack[len(stack)-1]
+		switch t.kind {
+		case tokenText:
+			top.AppendChild(&html.Node{Type: html.TextNode, Data: t.text})
+		case tokenSingle:
+			n := run.markers[t.n-1]
+			if n.Parent != nil {
+				n.Parent.RemoveChild(n)
+			}
+			top.AppendChild(n)
+		case tokenOpen:
+			orig := run.markers[t.n-1]
+			el := &html.Node{
+				Type:      html.ElementNode,
+				DataAtom:  orig.DataAtom,
+				Data:      orig.Data,
+				Namespace: orig.Namespace,
+				Attr:      append([]html.Attribute(nil), orig.Attr...),
+			}
+			top.AppendChild(el)
+			stack = append(stack, el)
+		case tokenClose:
+			stack = stack[:len(stack)-1]
+		}
+	}
+	if run.trail != "" {
+		holder.AppendChild(&html.Node{Type: html.TextNode, Data: run.trail})
+	}
+	for c := holder.FirstChild; c != nil; c = holder.FirstChild {
+		holder.RemoveChild(c)
+		run.parent.InsertBefore(c, next)
+	}
+}
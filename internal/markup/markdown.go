@@ -0,0 +1,505 @@
+package markup
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	mdFence       = regexp.MustCompile("^\\s*(`{3,}|~{3,})")
+	mdHeading     = regexp.MustCompile(`^\s{0,3}#{1,6}(\s+|$)`)
+	mdClosingHash = regexp.MustCompile(`\s+#+\s*$`)
+	mdRule        = regexp.MustCompile(`^\s{0,3}([-*_])(\s*([-*_])){2,}\s*$`)
+	mdSetext      = regexp.MustCompile(`^\s{0,3}(=+|-+)\s*$`)
+	mdListItem    = regexp.MustCompile(`^\s*([-*+]|\d{1,9}[.)])(\s+|$)(\[[ xX]\]\s+)?`)
+	mdQuote       = regexp.MustCompile(`^\s{0,3}>\s?`)
+	mdTableRule   = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+	mdHTMLBlock   = regexp.MustCompile(`^\s{0,3}<(/?[A-Za-z][\w-]*(\s|/?>|$)|!--|\?|![A-Za-z])`)
+	mdLinkDef     = regexp.MustCompile(`^\s{0,3}\[[^\]]+\]:\s*\S`)
+	mdAutolink    = regexp.MustCompile(`^<([A-Za-z][A-Za-z0-9+.-]{1,31}:[^\s<>]*|[^\s@<>]+@[^\s@<>]+)>`)
+	mdInlineHTML  = regexp.MustCompile(`^<(/?[A-Za-z][\w-]*(\s[^<>]*)?/?|!--[\s\S]*?--)>`)
+	mdEntity      = regexp.MustCompile(`^&(#[0-9]{1,7}|#[xX][0-9a-fA-F]{1,6}|[A-Za-z][A-Za-z0-9]{1,31});`)
+	mdBareURL     = regexp.MustCompile(`^(https?://|www\.)[^\s<]+`)
+)
+
+type mdPart struct {
+	raw  string
+	unit int
+}
+
+type markdownParser struct {
+	parts []mdPart
+	units []*unit
+	// lits holds, per unit and placeholder, the source text it stands for:
+	// the opening and closing markup of a pair, or the whole of a single.
+	lits [][][2]string
+	para *mdParagraph
+}
+
+// mdParagraph collects the lines of a paragraph, list item or heading. The
+// lines are joined into one unit, so a rewritten paragraph comes back as a
+// single line after the first line's prefix; hard line breaks are kept.
+type mdParagraph struct {
+	prefixes []string
+	lines    []string
+	suffix   string
+}
+
+// parseMarkdown splits Markdown line by line. Code blocks, front matter,
+// tables, HTML blocks, rules and link definitions are kept verbatim; the
+// text of headings, paragraphs, list items and block quotes becomes units.
+func parseMarkdown(text string) *Document {
+	p := &markdownParser{}
+	trailingNewline := strings.HasSuffix(text, "\n")
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+
+	var fence string
+	inTable, inHTML, inList, lastBlank := false, false, false, false
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if i == 0 && strings.TrimSpace(line) == "---" {
+			if end := frontMatterEnd(lines); end > 0 {
+				for ; i <= end; i++ {
+					p.raw(lines[i])
+				}
+				i--
+				continue
+			}
+		}
+
+		quote, rest := splitQuote(line)
+		if fence != "" {
+			p.raw(line)
+			if t := strings.TrimSpace(rest); strings.HasPrefix(t, fence) && strings.Trim(t, fence[:1]) == "" {
+				fence = ""
+			}
+			continue
+		}
+		if m := mdFence.FindStringSubmatch(rest); m != nil {
+			p.flush()
+			p.raw(line)
+			fence, inList = m[1], false
+			continue
+		}
+		if strings.TrimSpace(rest) == "" {
+			p.flush()
+			p.raw(line)
+			inTable, inHTML, lastBlank = false, false, true
+			continue
+		}
+		blankBefore := lastBlank
+		lastBlank = false
+		if inTable || inHTML {
+			p.raw(line)
+			continue
+		}
+
+		indent := indentWidth(rest)
+		switch {
+		case indent >= 4 && p.para == nil && !inList:
+			p.raw(line) // indented code block
+		case p.para != nil && mdSetext.MatchString(rest) && len(p.para.lines) > 0:
+			p.flush()
+			p.raw(line)
+		case mdRule.MatchString(rest):
+			p.flush()
+			p.raw(line)
+			inList = false
+		case mdHeading.MatchString(rest):
+			p.flush()
+			inList = false
+			m := mdHeading.FindString(rest)
+			content := rest[len(m):]
+			suffix := mdClosingHash.FindString(content)
+			content = strings.TrimSuffix(content, suffix)
+			if strings.TrimSpace(content) == "" {
+				p.raw(line)
+				continue
+			}
+			p.para = &mdParagraph{prefixes: []string{quote + m}, lines: []string{content}, suffix: suffix}
+			p.flush()
+		case strings.Contains(rest, "|") && i+1 < len(lines) && strings.Contains(lines[i+1], "|") && mdTableRule.MatchString(stripQuote(lines[i+1])):
+			p.flush()
+			p.raw(line)
+			inTable, inList = true, false
+		case p.para == nil && mdHTMLBlock.MatchString(rest):
+			p.raw(line)
+			inHTML, inList = true, false
+		case p.para == nil && mdLinkDef.MatchString(rest):
+			p.raw(line)
+		case mdListItem.MatchString(rest):
+			p.flush()
+			m := mdListItem.FindString(rest)
+			inList = true
+			if strings.TrimSpace(rest[len(m):]) == "" {
+				p.raw(line)
+				continue
+			}
+			p.para = &mdParagraph{prefixes: []string{quote + m}, lines: []string{rest[len(m):]}}
+		default:
+			if blankBefore && indent == 0 {
+				inList = false
+			}
+			lead := rest[:len(rest)-len(strings.TrimLeft(rest, " \t"))]
+			if p.para == nil {
+				p.para = &mdParagraph{}
+			}
+			p.para.prefixes = append(p.para.prefixes, quote+lead)
+			p.para.lines = append(p.para.lines, rest[len(lead):])
+		}
+	}
+	p.flush()
+
+	return &Document{
+		Format: FormatMarkdown,
+		units:  p.units,
+		assemble: func(tokens [][]token) (string, error) {
+			var b strings.Builder
+			for _, part := range p.parts {
+				if part.unit < 0 {
+					b.WriteString(part.raw)
+					continue
+				}
+				for _, t := range tokens[part.unit] {
+					switch t.kind {
+					case tokenText:
+						b.WriteString(t.text)
+					case tokenOpen, tokenSingle:
+						b.WriteString(p.lits[part.unit][t.n-1][0])
+					case tokenClose:
+						b.WriteString(p.lits[part.unit][t.n-1][1])
+					}
+				}
+			}
+			out := strings.TrimSuffix(b.String(), "\n")
+			if trailingNewline {
+				out += "\n"
+			}
+			return out, nil
+		},
+	}
+}
+
+func (p *markdownParser) raw(line string) {
+	p.parts = append(p.parts, mdPart{raw: line + "\n", unit: -1})
+}
+
+// flush turns the open paragraph into a unit, or into raw lines when it has
+// nothing to rewrite.
+func (p *markdownParser) flush() {
+	para := p.para
+	if para == nil {
+		return
+	}
+	p.para = nil
+	u := &unit{}
+	s := &mdInline{u: u, prefixes: para.prefixes}
+	u.text = strings.TrimSpace(s.scan(strings.Join(para.lines, "\n")))
+	if !HasProse(u.text) {
+		for i, line := range para.lines {
+			p.raw(para.prefixes[i] + line + para.suffix)
+		}
+		return
+	}
+	p.parts = append(p.parts, mdPart{raw: para.prefixes[0], unit: -1}, mdPart{unit: len(p.units)}, mdPart{raw: para.suffix + "\n", unit: -1})
+	p.units = append(p.units, u)
+	p.lits = append(p.lits, s.lits)
+}
+
+func frontMatterEnd(lines []string) int {
+	for i := 1; i < len(lines); i++ {
+		if t := strings.TrimSpace(lines[i]); t == "---" || t == "..." {
+			return i
+		}
+	}
+	return -1
+}
+
+// splitQuote separates block quote markers from the rest of the line.
+func splitQuote(line string) (string, string) {
+	quote := ""
+	for {
+		m := mdQuote.FindString(line)
+		if m == "" {
+			return quote, line
+		}
+		quote += m
+		line = line[len(m):]
+	}
+}
+
+func stripQuote(line string) string {
+	_, rest := splitQuote(line)
+	return rest
+}
+
+func indentWidth(line string) int {
+	width := 0
+	for _, r := range line {
+		switch r {
+		case ' ':
+			width++
+		case '\t':
+			width += 4 - width%4
+		default:
+			return width
+		}
+	}
+	return width
+}
+
+// mdInline replaces inline markup in a paragraph with placeholders.
+type mdInline struct {
+	u        *unit
+	lits     [][2]string
+	prefixes []string
+	line     int
+}
+
+func (s *mdInline) single(lit string) string {
+	s.lits = append(s.lits, [2]string{s.literal(lit)})
+	return fmt.Sprintf("{{%d}}", s.u.single())
+}
+
+func (s *mdInline) pair(open, close string) (string, string) {
+	s.lits = append(s.lits, [2]string{s.literal(open), s.literal(close)})
+	n := s.u.pair()
+	return fmt.Sprintf("{{%d}}", n), fmt.Sprintf("{{/%d}}", n)
+}
+
+// literal restores the line prefixes of markup that spans lines.
+func (s *mdInline) literal(lit string) string {
+	if !strings.Contains(lit, "\n") {
+		return lit
+	}
+	var b strings.Builder
+	for i, part := range strings.Split(lit, "\n") {
+		if i > 0 {
+			s.line++
+			b.WriteString("\n")
+			if s.line < len(s.prefixes) {
+				b.WriteString(s.prefixes[s.line])
+			}
+		}
+		b.WriteString(part)
+	}
+	return b.String()
+}
+
+func (s *mdInline) scan(text string) string {
+	var out []byte
+	for i := 0; i < len(text); {
+		c := text[i]
+		rest := text[i:]
+		switch {
+		case c == '\n':
+			// Soft breaks become spaces; hard breaks (two trailing spaces or
+			// a backslash) are kept along with the next line's prefix.
+			trimmed := strings.TrimRight(string(out), " ")
+			hard := ""
+			if len(out)-len(trimmed) >= 2 {
+				hard = string(out[len(trimmed):])
+			} else if strings.HasSuffix(trimmed, "\\") {
+				trimmed, hard = trimmed[:len(trimmed)-1], "\\"
+			}
+			out = []byte(trimmed)
+			if hard != "" {
+				out = append(out, s.single(hard+"\n")...)
+			} else {
+				s.line++
+				out = append(out, ' ')
+			}
+			i++
+			continue
+		case c == '\\' && i+1 < len(text) && isASCIIPunct(text[i+1]):
+			out = append(out, s.single(text[i:i+2])...)
+			i += 2
+			continue
+		case c == '`':
+			n := runLength(text, i, '`')
+			if end := closingRun(text, i+n, '`', n); end >= 0 {
+				out = append(out, s.single(text[i:end+n])...)
+				i = end + n
+			} else {
+				out = append(out, s.single(text[i:i+n])...)
+				i += n
+			}
+			continue
+		case c == '{' && strings.HasPrefix(rest, "{{"):
+			out = append(out, s.single("{{")...)
+			i += 2
+			continue
+		case c == '!' && strings.HasPrefix(rest, "!["):
+			if _, end := linkSpan(text, i+1); end > 0 {
+				out = append(out, s.single(text[i:end])...)
+				i = end
+				continue
+			}
+		case c == '[' && strings.HasPrefix(rest, "[^"):
+			if end := strings.IndexByte(rest, ']'); end > 0 {
+				out = append(out, s.single(rest[:end+1])...)
+				i += end + 1
+				continue
+			}
+		case c == '[':
+			if textEnd, end := linkSpan(text, i); end > 0 {
+				open, close := s.pair("[", text[textEnd:end])
+				out = append(out, open...)
+				out = append(out, s.scan(text[i+1:textEnd])...)
+				out = append(out, close...)
+				i = end
+				continue
+			}
+		case c == '<':
+			if m := mdAutolink.FindString(rest); m != "" {
+				out = append(out, s.single(m)...)
+				i += len(m)
+				continue
+			}
+			if m := mdInlineHTML.FindString(rest); m != "" {
+				out = append(out, s.single(m)...)
+				i += len(m)
+				continue
+			}
+		case c == '&':
+			if m := mdEntity.FindString(rest); m != "" {
+				out = append(out, s.single(m)...)
+				i += len(m)
+				continue
+			}
+		case (c == 'h' || c == 'w') && (i == 0 || !isWordByte(text[i-1])):
+			if m := mdBareURL.FindString(rest); m != "" {
+				m = strings.TrimRight(m, ".,;:!?)'\"")
+				out = append(out, s.single(m)...)
+				i += len(m)
+				continue
+			}
+		case c == '*' || c == '_' || c == '~':
+			n := runLength(text, i, c)
+			if end := emphasisEnd(text, i, n); end >= 0 {
+				delim := text[i : i+n]
+				open, close := s.pair(delim, delim)
+				out = append(out, open...)
+				out = append(out, s.scan(text[i+n:end])...)
+				out = append(out, close...)
+				i = end + n
+				continue
+			}
+			out = append(out, text[i:i+n]...)
+			i += n
+			continue
+		}
+		out = append(out, c)
+		i++
+	}
+	return string(out)
+}
+
+// linkSpan matches [text](destination) or [text][label] starting at the
+// bracket at i. It returns the offset of the closing bracket of the text and
+// the end of the whole link, or -1s.
+func linkSpan(text string, i int) (int, int) {
+	textEnd := matchBracket(text, i, '[', ']')
+	if textEnd < 0 || textEnd+1 >= len(text) {
+		return -1, -1
+	}
+	switch text[textEnd+1] {
+	case '(':
+		if end := matchBracket(text, textEnd+1, '(', ')'); end >= 0 {
+			return textEnd, end + 1
+		}
+	case '[':
+		if end := strings.IndexByte(text[textEnd+1:], ']'); end >= 0 {
+			return textEnd, textEnd + 1 + end + 1
+		}
+	}
+	return -1, -1
+}
+
+func matchBracket(text string, i int, open, close byte) int {
+	depth := 0
+	for j := i; j < len(text); j++ {
+		switch text[j] {
+		case '\\':
+			j++
+		case open:
+			depth++
+		case close:
+			depth--
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+	return -1
+}
+
+func runLength(text string, i int, c byte) int {
+	n := 0
+	for i+n < len(text) && text[i+n] == c {
+		n++
+	}
+	return n
+}
+
+// closingRun finds the next run of exactly n c's at or after i.
+func closingRun(text string, i int, c byte, n int) int {
+	for j := i; j < len(text); {
+		if text[j] != c {
+			j++
+			continue
+		}
+		run := runLength(text, j, c)
+		if run == n {
+			return j
+		}
+		j += run
+	}
+	return -1
+}
+
+// emphasisEnd finds the delimiter run closing the emphasis that opens with n
+// delimiters at i, or -1 when the run at i does not open emphasis.
+func emphasisEnd(text string, i, n int) int {
+	c := text[i]
+	if i+n >= len(text) || isSpaceByte(text[i+n]) || (c == '~' && n != 2) || n > 3 {
+		return -1
+	}
+	if c == '_' && i > 0 && isWordByte(text[i-1]) {
+		return -1
+	}
+	for j := i + n; j < len(text); {
+		if text[j] == '`' {
+			run := runLength(text, j, '`')
+			if end := closingRun(text, j+run, '`', run); end >= 0 {
+				j = end + run
+				continue
+			}
+		}
+		if text[j] != c {
+			j++
+			continue
+		}
+		run := runLength(text, j, c)
+		if run == n && !isSpaceByte(text[j-1]) && !(c == '_' && j+n < len(text) && isWordByte(text[j+n])) {
+			return j
+		}
+		j += run
+	}
+	return -1
+}
+
+func isASCIIPunct(c byte) bool {
+	return c < utf8.RuneSelf && unicode.IsPunct(rune(c)) || strings.IndexByte("$+<=>^`|~", c) >= 0
+}
+
+func isWordByte(c byte) bool {
+	return c >= utf8.RuneSelf || c == '_' || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c))
+}
+
+func isSpaceByte(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n'
+}
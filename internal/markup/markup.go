@@ -0,0 +1,243 @@
+// Package markup splits Markdown and HTML into the prose that may be
+// rewritten and the structure that must survive untouched. Each run of prose
+// becomes a unit of text in which links, emphasis and other inline markup are
+// replaced by numbered placeholders: {{n}}…{{/n}} around text that stays
+// wrapped, and a lone {{n}} for content such as code spans or URLs that must
+// be kept verbatim. Rewritten units are put back into the original structure
+// by Render.
+package markup
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	FormatPlain    = "plain"
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+	FormatAuto     = "auto"
+)
+
+// ValidFormat reports whether format is accepted by Parse.
+func ValidFormat(format string) bool {
+	switch format {
+	case FormatPlain, FormatMarkdown, FormatHTML, FormatAuto:
+		return true
+	}
+	return false
+}
+
+var (
+	htmlStart       = regexp.MustCompile(`(?i)^\s*<(!doctype|!--|[a-z][a-z0-9]*[\s/>])`)
+	markdownSignals = []*regexp.Regexp{
+		regexp.MustCompile(`(?m)^\s{0,3}#{1,6}\s+\S`),
+		regexp.MustCompile(`(?m)^\s{0,3}(` + "```" + `|~~~)`),
+		regexp.MustCompile(`(?m)^\s{0,3}>\s?\S`),
+		regexp.MustCompile(`(?m)^\s*([-*+]|\d{1,9}[.)])\s+\S`),
+		regexp.MustCompile(`!?\[[^\]\n]+\]\([^)\s]+[^)]*\)`),
+		regexp.MustCompile("(\\*\\*|__)\\S[^\\n]*?\\S(\\*\\*|__)|`[^`\\n]+`"),
+	}
+)
+
+// Detect guesses whether text is HTML, Markdown or plain text.
+func Detect(text string) string {
+	if htmlStart.MatchString(text) && strings.Contains(text, "</") {
+		return FormatHTML
+	}
+	for _, re := range markdownSignals {
+		if re.MatchString(text) {
+			return FormatMarkdown
+		}
+	}
+	return FormatPlain
+}
+
+// Document is a parsed text ready for unit-by-unit rewriting.
+type Document struct {
+	Format string
+
+	// source is the text as given to Parse.
+	source string
+	units  []*unit
+	// assemble rebuilds the text from the tokens of every unit.
+	assemble func(units [][]token) (string, error)
+}
+
+// Parse splits Markdown or HTML text. Plain text has no structure to keep
+// and is rejected; callers rewrite it directly.
+func Parse(text, format string) (*Document, error) {
+	source := text
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	var doc *Document
+	var err error
+	switch format {
+	case FormatMarkdown:
+		doc = parseMarkdown(text)
+	case FormatHTML:
+		doc, err = parseHTML(text)
+	default:
+		return nil, fmt.Errorf("unsupported text format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	doc.source = source
+	return doc, nil
+}
+
+// Units returns the text of each unit with its placeholders.
+func (d *Document) Units() []string {
+	texts := make([]string, len(d.units))
+	for i, u := range d.units {
+		texts[i] = u.text
+	}
+	return texts
+}
+
+// Prose joins the units with their placeholders removed, which is the text a
+// reader would see.
+func (d *Document) Prose() string {
+	return Prose(d.Units())
+}
+
+// Render rebuilds the document with rewritten in place of each unit's text.
+// A rewritten unit whose placeholders are missing, duplicated or wrongly
+// nested is replaced by the original, in rewritten as well as in the output,
+// and a warning says so. When no unit changes, the text is returned exactly
+// as it was given to Parse; otherwise HTML is re-serialized, which
+// normalizes details such as attribute quoting.
+func (d *Document) Render(rewritten []string) (string, []string) {
+	var warnings []string
+	changed := false
+	tokens := make([][]token, len(d.units))
+	for i, u := range d.units {
+		var err error
+		if i < len(rewritten) {
+			tokens[i], err = u.tokens(rewritten[i])
+		} else {
+			err = fmt.Errorf("no rewrite returned")
+		}
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("Segment %d was left unchanged: %v.", i+1, err))
+			tokens[i], _ = u.tokens(u.text)
+			if i < len(rewritten) {
+				rewritten[i] = u.text
+			}
+		} else if rewritten[i] != u.text {
+			changed = true
+		}
+	}
+	if !changed {
+		return d.source, warnings
+	}
+	out, err := d.assemble(tokens)
+	if err != nil {
+		// Units parse deterministically, so this only happens if the source
+		// itself cannot be parsed a second time.
+		return "", append(warnings, err.Error())
+	}
+	return out, warnings
+}
+
+// Prose joins unit texts with their placeholders removed.
+func Prose(units []string) string {
+	parts := make([]string, 0, len(units))
+	for _, u := range units {
+		parts = append(parts, strings.TrimSpace(placeholder.ReplaceAllString(u, "")))
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// HasProse reports whether unit contains any letters or digits outside its
+// placeholders, i.e. whether there is anything to rewrite.
+func HasProse(unit string) bool {
+	return strings.IndexFunc(placeholder.ReplaceAllString(unit, ""), func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r)
+	}) >= 0
+}
+
+var placeholder = regexp.MustCompile(`\{\{(/?)(\d+)\}\}`)
+
+type tokenKind int
+
+const (
+	tokenText tokenKind = iota
+	tokenOpen
+	tokenClose
+	tokenSingle
+)
+
+type token struct {
+	kind tokenKind
+	n    int
+	text string
+}
+
+// unit is one run of prose. Placeholders are numbered from 1 in the order
+// they were created; paired[n-1] marks those that wrap text.
+type unit struct {
+	text   string
+	paired []bool
+}
+
+func (u *unit) single() int {
+	u.paired = append(u.paired, false)
+	return len(u.paired)
+}
+
+func (u *unit) pair() int {
+	u.paired = append(u.paired, true)
+	return len(u.paired)
+}
+
+// tokens splits s at its placeholders, checking that every placeholder of
+// the unit appears exactly once and that pairs nest properly. The model may
+// move placeholders around, but not drop or invent them.
+func (u *unit) tokens(s string) ([]token, error) {
+	var tokens []token
+	opened := make([]bool, len(u.paired))
+	closed := make([]bool, len(u.paired))
+	var stack []int
+	last := 0
+	for _, m := range placeholder.FindAllStringSubmatchIndex(s, -1) {
+		if m[0] > last {
+			tokens = append(tokens, token{kind: tokenText, text: s[last:m[0]]})
+		}
+		last = m[1]
+		n, _ := strconv.Atoi(s[m[4]:m[5]])
+		if n < 1 || n > len(u.paired) {
+			return nil, fmt.Errorf("unknown placeholder {{%d}}", n)
+		}
+		switch {
+		case m[3] > m[2]:
+			if !u.paired[n-1] || !opened[n-1] || closed[n-1] || len(stack) == 0 || stack[len(stack)-1] != n {
+				return nil, fmt.Errorf("misplaced placeholder {{/%d}}", n)
+			}
+			closed[n-1] = true
+			stack = stack[:len(stack)-1]
+			tokens = append(tokens, token{kind: tokenClose, n: n})
+		case opened[n-1]:
+			return nil, fmt.Errorf("placeholder {{%d}} repeated", n)
+		case u.paired[n-1]:
+			opened[n-1] = true
+			stack = append(stack, n)
+			tokens = append(tokens, token{kind: tokenOpen, n: n})
+		default:
+			opened[n-1] = true
+			tokens = append(tokens, token{kind: tokenSingle, n: n})
+		}
+	}
+	if last < len(s) {
+		tokens = append(tokens, token{kind: tokenText, text: s[last:]})
+	}
+	for i := range u.paired {
+		if !opened[i] || (u.paired[i] && !closed[i]) {
+			return nil, fmt.Errorf("placeholder {{%d}} missing", i+1)
+		}
+	}
+	return tokens, nil
+}
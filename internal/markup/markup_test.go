@@ -0,0 +1,143 @@
+package markup
+
+import (
+	"strings"
+	"testing"
+)
+
+var roundTripTexts = []struct {
+	name   string
+	format string
+	text   string
+}{
+	{
+		name:   "markdown code fences",
+		format: FormatMarkdown,
+		text:   "Intro text here.\n\n```go\n// Not prose. [x](y)\nfunc main() { fmt.Println(\"*hi*\") }\n```\n\n~~~\nplain fence\n~~~\n\n    indented code\n\nAfter the code.\n",
+	},
+	{
+		name:   "markdown links",
+		format: FormatMarkdown,
+		text:   "See [the docs](https://example.com/a_b?c=1 \"Docs\"), ![logo](img.png), <https://example.com> and [a reference][ref].\n\n[ref]: https://example.com/ref\n",
+	},
+	{
+		name:   "markdown inline html",
+		format: FormatMarkdown,
+		text:   "Some <span class='note'>inline *html*</span> and <br/> a break.\n\n<div align=\"center\">\n  <b>block</b>\n</div>\n",
+	},
+	{
+		name:   "markdown tables",
+		format: FormatMarkdown,
+		text:   "Tables are kept verbatim.\n\n| Name | Notes |\n|:-----|------:|\n| one  | *emphasis* and `code` |\n| two  | a [link](x) |\n\nText after.\n",
+	},
+	{
+		name:   "markdown nested lists",
+		format: FormatMarkdown,
+		text:   "- first item\n  - nested **bold** item\n    1. deeper still\n    2) and again\n- second item\n\n> quoted\n> - list in a quote\n",
+	},
+	{
+		name:   "markdown front matter and crlf",
+		format: FormatMarkdown,
+		text:   "---\ntitle: Notes\n---\r\n# Heading\r\n\r\nCafé — naïve text with 🚀.\r\n",
+	},
+	{
+		name:   "html links",
+		format: FormatHTML,
+		text:   "<p>Read <a href='/x?a=1&amp;b=2' target=_blank>the guide</a>, it&#39;s short.</p>",
+	},
+	{
+		name:   "html inline elements",
+		format: FormatHTML,
+		text:   "<p>Some <b>bold</b><br/>and <code>x&lt;y</code> with&nbsp;spaces.</p>\n<pre>kept   as is</pre>",
+	},
+	{
+		name:   "html tables",
+		format: FormatHTML,
+		text:   "<table><tr><td>cell one</td><td>cell <em>two</em></td></tr></table>",
+	},
+	{
+		name:   "html nested lists",
+		format: FormatHTML,
+		text:   "<ul><li>One<ul><li>Two <i>nested</i></li></ul></li><li>Three</ul>",
+	},
+	{
+		name:   "html document",
+		format: FormatHTML,
+		text:   "<!DOCTYPE html>\n<html><head><title>T</title></head><body><p>Hi <em>there</em>.</p></body></html>\n",
+	},
+}
+
+func TestRenderIdentity(t *testing.T) {
+	for _, tt := range roundTripTexts {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := Parse(tt.text, tt.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(doc.Units()) == 0 {
+				t.Fatal("no units")
+			}
+			out, warnings := doc.Render(doc.Units())
+			if out != tt.text || len(warnings) > 0 {
+				t.Errorf("Render(Units()) = %q (warnings %v), want %q", out, warnings, tt.text)
+			}
+		})
+	}
+}
+
+// Rewriting the prose leaves everything else in place.
+func TestRenderKeepsStructure(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		text   string
+		want   string
+	}{
+		{
+			name:   "markdown",
+			format: FormatMarkdown,
+			text:   "# Old title\n\nOld [link](http://x.y/old) text.\n\n```\nold code\n```\n\n- old item\n  - old nested\n",
+			want:   "# New title\n\nNew [link](http://x.y/old) text.\n\n```\nold code\n```\n\n- new item\n  - new nested\n",
+		},
+		{
+			name:   "html",
+			format: FormatHTML,
+			text:   "<h1>Old title</h1><p>Old <a href=\"/old\">link</a> text.</p><pre>old code</pre>",
+			want:   "<h1>New title</h1><p>New <a href=\"/old\">link</a> text.</p><pre>old code</pre>",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := Parse(tt.text, tt.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			units := doc.Units()
+			for i, u := range units {
+				units[i] = strings.ReplaceAll(strings.ReplaceAll(u, "Old", "New"), "old", "new")
+			}
+			out, warnings := doc.Render(units)
+			if out != tt.want || len(warnings) > 0 {
+				t.Errorf("Render = %q (warnings %v), want %q", out, warnings, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderRejectsBrokenPlaceholders(t *testing.T) {
+	doc, err := Parse("First [link](a) here.\n\nSecond paragraph.\n", FormatMarkdown)
+	if err != nil {
+		t.Fatal(err)
+	}
+	units := []string{"First link here, placeholders dropped.", "Second rewritten paragraph."}
+	out, warnings := doc.Render(units)
+	if want := "First [link](a) here.\n\nSecond rewritten paragraph.\n"; out != want {
+		t.Errorf("Render = %q, want %q", out, want)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "Segment 1") {
+		t.Errorf("warnings = %v, want one for segment 1", warnings)
+	}
+	if units[0] != doc.Units()[0] {
+		t.Errorf("broken unit = %q, want the original %q", units[0], doc.Units()[0])
+	}
+}
@@ -0,0 +1,192 @@
+package plagiarism
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// SourceProvider is one backend that can find passages of a text in its
+// reference material.
+type SourceProvider interface {
+	Name() string
+	Find(text string) ([]Match, error)
+}
+
+type SourceStatus struct {
+	Name    string `json:"name"`
+	Matches int    `json:"matches"`
+	Error   string `json:"error,omitempty"`
+}
+
+type Report struct {
+	Matches      []Match        `json:"matches"`
+	Sources      []SourceStatus `json:"sources"`
+	MatchedWords int            `json:"matched_words"`
+	TotalWords   int            `json:"total_words"`
+}
+
+// Checker runs providers concurrently and merges their matches. Like the
+// detector ensemble, a failing provider is reported rather than failing the
+// whole check.
+type Checker struct {
+	providers []SourceProvider
+}
+
+func NewChecker(providers ...SourceProvider) *Checker {
+	return &Checker{providers: providers}
+}
+
+func (c *Checker) Names() []string {
+	names := make([]string, len(c.providers))
+	for i, p := range c.providers {
+		names[i] = p.Name()
+	}
+	return names
+}
+
+// Check queries every provider not listed in exclude.
+func (c *Checker) Check(text string, exclude ...string) (*Report, error) {
+	skip := make(map[string]bool)
+	for _, name := range exclude {
+		skip[name] = true
+	}
+	var providers []SourceProvider
+	for _, p := range c.providers {
+		if !skip[p.Name()] {
+			providers = append(providers, p)
+		}
+	}
+	if len(providers) == 0 {
+		return nil, fmt.Errorf("no plagiarism sources are enabled")
+	}
+
+	found := make([][]Match, len(providers))
+	errs := make([]error, len(providers))
+	var wg sync.WaitGroup
+	for i, p := range providers {
+		wg.Add(1)
+		go func(i int, p SourceProvider) {
+			defer wg.Done()
+			found[i], errs[i] = p.Find(text)
+		}(i, p)
+	}
+	wg.Wait()
+
+	report := &Report{TotalWords: len(tokenize(text))}
+	var all []Match
+	var failures []string
+	for i, p := range providers {
+		status := SourceStatus{Name: p.Name(), Matches: len(found[i])}
+		if errs[i] != nil {
+			status.Error = errs[i].Error()
+			failures = append(failures, fmt.Sprintf("%s: %v", p.Name(), errs[i]))
+		}
+		report.Sources = append(report.Sources, status)
+		for _, m := range found[i] {
+			if m.Provider == "" {
+				m.Provider = p.Name()
+			}
+			if m.Kind == "" {
+				m.Kind = KindReported
+				if m.SourceText != "" {
+					m.Kind = KindExact
+				}
+			}
+			all = append(all, m)
+		}
+	}
+	if len(failures) == len(providers) {
+		return nil, fmt.Errorf("all plagiarism sources failed: %s", strings.Join(failures, "; "))
+	}
+
+	report.Matches = Merge(all)
+	report.MatchedWords = matchedWords(text, report.Matches)
+	return report, nil
+}
+
+// Merge de-duplicates matches reported by several providers. Two matches
+// are the same finding when they quote the same passage of the checked text
+// (ignoring case and punctuation) and, when both quote their source, the
+// same source passage. A provider reporting one passage in two of its own
+// documents yields two findings. The most confident match is kept and the
+// other providers are listed in CorroboratedBy. Paraphrased matches that
+// mostly overlap an exact match add nothing and are dropped.
+func Merge(matches []Match) []Match {
+	sorted := append([]Match(nil), matches...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Confidence > sorted[j].Confidence })
+
+	merged := []Match{}
+	for _, m := range sorted {
+		dup := -1
+		for i, kept := range merged {
+			if sameFinding(kept, m) {
+				dup = i
+				break
+			}
+		}
+		if dup < 0 {
+			merged = append(merged, m)
+			continue
+		}
+		kept := &merged[dup]
+		if m.Provider != kept.Provider && !contains(kept.CorroboratedBy, m.Provider) {
+			kept.CorroboratedBy = append(kept.CorroboratedBy, m.Provider)
+		}
+	}
+
+	out := merged[:0]
+	for _, m := range merged {
+		if m.Kind != KindParaphrased || !coveredByExact(m, merged) {
+			out = append(out, m)
+		}
+	}
+	sortMatches(out)
+	return out
+}
+
+func coveredByExact(m Match, matches []Match) bool {
+	span := m.End - m.Start
+	for _, e := range matches {
+		if e.Kind != KindExact {
+			continue
+		}
+		overlap := min(m.End, e.End) - max(m.Start, e.Start)
+		if span > 0 && 2*overlap >= span {
+			return true
+		}
+	}
+	return false
+}
+
+func sameFinding(a, b Match) bool {
+	if normalizePassage(a.Text) != normalizePassage(b.Text) {
+		return false
+	}
+	if a.Provider == b.Provider {
+		return a.DocumentID == b.DocumentID
+	}
+	if a.SourceText != "" && b.SourceText != "" {
+		return normalizePassage(a.SourceText) == normalizePassage(b.SourceText)
+	}
+	return true
+}
+
+func normalizePassage(text string) string {
+	tokens := tokenize(text)
+	words := make([]string, len(tokens))
+	for i, t := range tokens {
+		words[i] = t.word
+	}
+	return strings.Join(words, " ")
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,326 @@
+// Package plagiarism finds passages of a text that also occur in reference
+// material. Matches come from pluggable SourceProviders: the built-in local
+// corpus, a self-hosted search engine, or any other backend; a Checker runs
+// them together and merges their findings.
+//
+// The local corpus retrieves candidate documents with MinHash/LSH over word
+// shingles, and every match it reports is an exact, aligned word span, so
+// results can be verified against the source document.
+package plagiarism
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/victor-butita/rephrase/internal/storeutil"
+	"github.com/victor-butita/rephrase/internal/textutil"
+)
+
+// MinMatchWords is the shortest word run reported as a match. Shorter runs
+// are mostly stock phrases.
+const MinMatchWords = 8
+
+// Document is an indexed text. Owner is the ID of the user who added it, or
+// "" when accounts are disabled.
+type Document struct {
+	ID      string    `json:"id"`
+	Owner   string    `json:"-"`
+	Title   string    `json:"title"`
+	Text    string    `json:"text,omitempty"`
+	Words   int       `json:"words"`
+	AddedAt time.Time `json:"added_at"`
+}
+
+// Match is a passage of the checked text that a provider found in some
+// source, either verbatim or, for KindParaphrased, reworded. Start and End are character offsets in the checked text, or -1
+// when the provider could not locate the passage. For exact matches the
+// Source* fields locate the same passage in the source document.
+type Match struct {
+	Kind           string    `json:"kind"`
+	Provider       string    `json:"provider"`
+	CorroboratedBy []string  `json:"corroborated_by,omitempty"`
+	DocumentID     string    `json:"document_id,omitempty"`
+	DocumentTitle  string    `json:"document_title"`
+	DocumentDate   time.Time `json:"document_date,omitzero"`
+	URL            string    `json:"url,omitempty"`
+	Text           string    `json:"text"`
+	Start          int       `json:"start"`
+	End            int       `json:"end"`
+	SourceText     string    `json:"source_text,omitempty"`
+	SourceStart    int       `json:"source_start"`
+	SourceEnd      int       `json:"source_end"`
+	Words          int       `json:"words"`
+	// Similarity is the cosine similarity of paraphrased matches.
+	Similarity float64 `json:"similarity,omitempty"`
+	Confidence float64 `json:"confidence"`
+}
+
+type Result struct {
+	Matches           []Match `json:"matches"`
+	MatchedWords      int     `json:"matched_words"`
+	TotalWords        int     `json:"total_words"`
+	DocumentsSearched int     `json:"documents_searched"`
+}
+
+// Coverage is the share of the checked text's words covered by a match.
+func (r *Result) Coverage() float64 {
+	if r.TotalWords == 0 {
+		return 0
+	}
+	return float64(r.MatchedWords) / float64(r.TotalWords)
+}
+
+type indexedDocument struct {
+	Document
+	tokens    []token
+	positions map[uint64][]int
+	buckets   []uint64
+}
+
+// Index is an in-memory reference corpus.
+type Index struct {
+	name    string
+	mu      sync.RWMutex
+	docs    map[string]*indexedDocument
+	buckets map[uint64][]string
+}
+
+func NewIndex() *Index {
+	return NewNamedIndex(LocalCorpusProvider)
+}
+
+// NewNamedIndex creates an index that reports its matches under name.
+func NewNamedIndex(name string) *Index {
+	return &Index{name: name, docs: make(map[string]*indexedDocument), buckets: make(map[uint64][]string)}
+}
+
+func newIndexedDocument(d Document) (*indexedDocument, []uint64) {
+	tokens := tokenize(d.Text)
+	hashes := shingles(tokens)
+	d.Words = len(tokens)
+	doc := &indexedDocument{Document: d, tokens: tokens, positions: make(map[uint64][]int, len(hashes))}
+	for i, h := range hashes {
+		doc.positions[h] = append(doc.positions[h], i)
+	}
+	return doc, hashes
+}
+
+// Add indexes a document added by owner and returns it without its text.
+func (ix *Index) Add(owner, title, text string) (*Document, error) {
+	title = strings.TrimSpace(title)
+	if strings.TrimSpace(text) == "" {
+		return nil, fmt.Errorf("document text cannot be empty")
+	}
+	if title == "" {
+		return nil, fmt.Errorf("document title is required")
+	}
+
+	return ix.Put(Document{ID: storeutil.NewID(), Owner: owner, Title: title, Text: text, AddedAt: time.Now().UTC()}), nil
+}
+
+// Put indexes d under its own ID and time, replacing any document with the
+// same ID; it is how stored documents are reloaded. It returns d without its
+// text.
+func (ix *Index) Put(d Document) *Document {
+	ix.Delete(d.ID)
+	doc, hashes := newIndexedDocument(d)
+	seen := make(map[uint64]bool)
+	for _, c := range chunks(hashes) {
+		for _, key := range bandKeys(minhash(c)) {
+			if !seen[key] {
+				seen[key] = true
+				doc.buckets = append(doc.buckets, key)
+			}
+		}
+	}
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.docs[doc.ID] = doc
+	for _, key := range doc.buckets {
+		ix.buckets[key] = append(ix.buckets[key], doc.ID)
+	}
+	summary := doc.Document
+	summary.Text = ""
+	return &summary
+}
+
+func (ix *Index) Get(id string) (*Document, bool) {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	doc, ok := ix.docs[id]
+	if !ok {
+		return nil, false
+	}
+	d := doc.Document
+	return &d, true
+}
+
+// List returns all documents, newest first, without their text.
+func (ix *Index) List() []Document {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	docs := make([]Document, 0, len(ix.docs))
+	for _, d := range ix.docs {
+		summary := d.Document
+		summary.Text = ""
+		docs = append(docs, summary)
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].AddedAt.After(docs[j].AddedAt) })
+	return docs
+}
+
+func (ix *Index) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return len(ix.docs)
+}
+
+func (ix *Index) Delete(id string) bool {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	doc, ok := ix.docs[id]
+	if !ok {
+		return false
+	}
+	for _, key := range doc.buckets {
+		ids := ix.buckets[key]
+		for i, other := range ids {
+			if other == id {
+				ids = append(ids[:i], ids[i+1:]...)
+				break
+			}
+		}
+		if len(ids) == 0 {
+			delete(ix.buckets, key)
+		} else {
+			ix.buckets[key] = ids
+		}
+	}
+	delete(ix.docs, id)
+	return true
+}
+
+// LocalCorpusProvider is the name the reference corpus reports matches under.
+const LocalCorpusProvider = "local_corpus"
+
+func (ix *Index) Name() string { return ix.name }
+
+func (ix *Index) Find(text string) ([]Match, error) {
+	return ix.Search(text).Matches, nil
+}
+
+// Search finds every passage of text that occurs in a corpus document.
+func (ix *Index) Search(text string) *Result {
+	tokens := tokenize(text)
+	hashes := shingles(tokens)
+
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	result := &Result{Matches: []Match{}, TotalWords: len(tokens), DocumentsSearched: len(ix.docs)}
+
+	candidates := make(map[string]bool)
+	for _, c := range chunks(hashes) {
+		for _, key := range bandKeys(minhash(c)) {
+			for _, id := range ix.buckets[key] {
+				candidates[id] = true
+			}
+		}
+	}
+
+	for id := range candidates {
+		result.Matches = append(result.Matches, alignDocument(text, tokens, hashes, ix.docs[id], ix.name)...)
+	}
+	sortMatches(result.Matches)
+	result.MatchedWords = matchedWords(text, result.Matches)
+	return result
+}
+
+// alignDocument reports every exact passage text shares with doc.
+func alignDocument(text string, tokens []token, hashes []uint64, doc *indexedDocument, provider string) []Match {
+	var matches []Match
+	for _, r := range align(tokens, hashes, doc) {
+		m := newMatch(text, tokens, doc, r)
+		m.Provider = provider
+		matches = append(matches, m)
+	}
+	return matches
+}
+
+func sortMatches(matches []Match) {
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if a.Start != b.Start {
+			return a.Start < b.Start
+		}
+		return a.DocumentTitle < b.DocumentTitle
+	})
+}
+
+// matchedWords counts the words of text covered by at least one located
+// match.
+func matchedWords(text string, matches []Match) int {
+	var count int
+	for _, t := range tokenize(text) {
+		start, end := textutil.CharOffset(text, t.start), textutil.CharOffset(text, t.end)
+		for _, m := range matches {
+			if m.Start >= 0 && m.Start <= start && end <= m.End {
+				count++
+				break
+			}
+		}
+	}
+	return count
+}
+
+type run struct {
+	query, source, length int
+}
+
+// align walks the query left to right. At each shingle that also occurs in
+// the document it extends the longest exact word run, records it if it is at
+// least MinMatchWords long, and continues after it.
+func align(query []token, hashes []uint64, doc *indexedDocument) []run {
+	var runs []run
+	for i := 0; i < len(hashes); {
+		best := run{query: i}
+		for _, p := range doc.positions[hashes[i]] {
+			n := 0
+			for i+n < len(query) && p+n < len(doc.tokens) && query[i+n].word == doc.tokens[p+n].word {
+				n++
+			}
+			if n > best.length {
+				best.source, best.length = p, n
+			}
+		}
+		if best.length >= MinMatchWords {
+			runs = append(runs, best)
+			i += best.length
+			continue
+		}
+		i++
+	}
+	return runs
+}
+
+func newMatch(text string, query []token, doc *indexedDocument, r run) Match {
+	qStart, qEnd := query[r.query].start, query[r.query+r.length-1].end
+	sStart, sEnd := doc.tokens[r.source].start, doc.tokens[r.source+r.length-1].end
+	return Match{
+		DocumentID:    doc.ID,
+		DocumentTitle: doc.Title,
+		DocumentDate:  doc.AddedAt,
+		Text:          text[qStart:qEnd],
+		Start:         textutil.CharOffset(text, qStart),
+		End:           textutil.CharOffset(text, qEnd),
+		SourceText:    doc.Text[sStart:sEnd],
+		SourceStart:   textutil.CharOffset(doc.Text, sStart),
+		SourceEnd:     textutil.CharOffset(doc.Text, sEnd),
+		Kind:          KindExact,
+		Words:         r.length,
+		Confidence:    1,
+	}
+}
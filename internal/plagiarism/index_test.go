@@ -0,0 +1,117 @@
+package plagiarism
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// copiedPassage is a paragraph lifted from a source, with multi-byte
+// characters so that byte and character offsets differ.
+const copiedPassage = "Über den Wolken, wrote the café's owner, the naïve traveller learns that every " +
+	"map is a guess 🚀 and every guess is a small act of courage. She kept a notebook of " +
+	"wrong turns, annotated in pencil, and years later it read like a field guide to a " +
+	"country that never existed."
+
+// copiedWords is the span a match covers, from the passage's first word to
+// its last.
+var copiedWords = strings.TrimSuffix(copiedPassage, ".")
+
+// filler returns n distinct words that share nothing with other fillers.
+func filler(prefix string, n int) string {
+	words := make([]string, n)
+	for i := range words {
+		words[i] = fmt.Sprintf("%s%d", prefix, i)
+	}
+	return strings.Join(words, " ")
+}
+
+func TestIndexFindsCopiedPassage(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		text   string
+		// want is the passage as it appears in text; wantSource as it
+		// appears in the source.
+		want, wantSource string
+	}{
+		{
+			name:   "verbatim",
+			source: "Prólogo — " + filler("src", 250) + ". " + copiedPassage + " " + filler("end", 250),
+			text:   "Ça commence ici — " + filler("mine", 30) + ". " + copiedPassage + " " + filler("more", 30),
+			want:   copiedWords, wantSource: copiedWords,
+		},
+		{
+			name:   "whole text",
+			source: copiedPassage,
+			text:   copiedPassage,
+			want:   copiedWords, wantSource: copiedWords,
+		},
+		{
+			// Case, punctuation and spacing do not hide a copy; the match
+			// covers the passage as written on each side.
+			name:       "reformatted",
+			source:     filler("src", 100) + " " + copiedPassage + " " + filler("end", 100),
+			text:       "Intro. " + strings.ToUpper(strings.ReplaceAll(copiedPassage, ", ", " —\n")),
+			want:       strings.ToUpper(strings.ReplaceAll(copiedWords, ", ", " —\n")),
+			wantSource: copiedWords,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ix := NewIndex()
+			ix.Add("", "Unrelated", filler("other", 200))
+			doc, err := ix.Add("", "Travel notes", tt.source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result := ix.Search(tt.text)
+			if len(result.Matches) != 1 {
+				t.Fatalf("got %d matches, want 1: %+v", len(result.Matches), result.Matches)
+			}
+			m := result.Matches[0]
+			if m.DocumentID != doc.ID || m.DocumentTitle != "Travel notes" || m.Kind != KindExact || m.Provider != LocalCorpusProvider {
+				t.Errorf("match from %s %q (%s, %s), want %s", m.DocumentID, m.DocumentTitle, m.Kind, m.Provider, doc.ID)
+			}
+
+			text, source := []rune(tt.text), []rune(tt.source)
+			if m.Text != tt.want || string(text[m.Start:m.End]) != tt.want {
+				t.Errorf("match text[%d:%d] = %q (reported %q), want %q", m.Start, m.End, string(text[m.Start:m.End]), m.Text, tt.want)
+			}
+			if m.SourceText != tt.wantSource || string(source[m.SourceStart:m.SourceEnd]) != tt.wantSource {
+				t.Errorf("match source[%d:%d] = %q (reported %q), want %q", m.SourceStart, m.SourceEnd, string(source[m.SourceStart:m.SourceEnd]), m.SourceText, tt.wantSource)
+			}
+			if words := len(tokenize(copiedPassage)); m.Words != words || result.MatchedWords != words {
+				t.Errorf("match covers %d words (%d matched), want %d", m.Words, result.MatchedWords, words)
+			}
+		})
+	}
+}
+
+func TestIndexIgnoresUnrelatedText(t *testing.T) {
+	ix := NewIndex()
+	doc, err := ix.Add("", "Travel notes", filler("src", 100)+" "+copiedPassage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Seven words in a row are too few to report, however many such runs
+	// there are.
+	short := strings.Fields(copiedPassage)
+	tests := map[string]string{
+		"unrelated":  "The quarterly budget review covers staffing, rent and the new coffee machine in some detail.",
+		"stock runs": strings.Join(short[:MinMatchWords-1], " ") + " and then " + strings.Join(short[20:20+MinMatchWords-1], " "),
+		"empty":      "",
+	}
+	for name, text := range tests {
+		if result := ix.Search(text); len(result.Matches) != 0 || result.Coverage() != 0 {
+			t.Errorf("%s: got matches %+v", name, result.Matches)
+		}
+	}
+
+	if !ix.Delete(doc.ID) {
+		t.Fatal("delete failed")
+	}
+	if result := ix.Search(copiedPassage); len(result.Matches) != 0 {
+		t.Errorf("deleted document still matches: %+v", result.Matches)
+	}
+}
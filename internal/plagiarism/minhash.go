@@ -0,0 +1,135 @@
+package plagiarism
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/victor-butita/rephrase/internal/textutil"
+)
+
+const (
+	// shingleSize is the number of words per shingle.
+	shingleSize = 5
+	// Documents are signed in overlapping chunks so that a short passage
+	// copied from a long document still looks similar to one of its chunks.
+	chunkShingles = 40
+	chunkStep     = 20
+	// 40 bands of 3 rows put the LSH threshold near a Jaccard similarity of
+	// 0.3, which a copied chunk clears comfortably.
+	lshBands   = 40
+	lshRows    = 3
+	signatures = lshBands * lshRows
+)
+
+// token is a normalized word with its byte span in the original text.
+type token struct {
+	word       string
+	start, end int
+}
+
+func tokenize(text string) []token {
+	var tokens []token
+	for _, w := range textutil.Words(text) {
+		word := strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				return unicode.ToLower(r)
+			}
+			return -1
+		}, w.Text)
+		if word != "" {
+			tokens = append(tokens, token{word: word, start: w.Start, end: w.End})
+		}
+	}
+	return tokens
+}
+
+// shingles hashes every run of shingleSize consecutive words. Element i is
+// the shingle starting at token i.
+func shingles(tokens []token) []uint64 {
+	if len(tokens) < shingleSize {
+		return nil
+	}
+	out := make([]uint64, len(tokens)-shingleSize+1)
+	for i := range out {
+		h := fnv.New64a()
+		for _, t := range tokens[i : i+shingleSize] {
+			h.Write([]byte(t.word))
+			h.Write([]byte{0})
+		}
+		out[i] = h.Sum64()
+	}
+	return out
+}
+
+// chunks splits a shingle list into overlapping windows. Texts shorter than
+// one window form a single chunk.
+func chunks(hashes []uint64) [][]uint64 {
+	if len(hashes) == 0 {
+		return nil
+	}
+	var out [][]uint64
+	for start := 0; ; start += chunkStep {
+		end := start + chunkShingles
+		if end >= len(hashes) {
+			return append(out, hashes[start:])
+		}
+		out = append(out, hashes[start:end])
+	}
+}
+
+// minhash computes the signature of a shingle set. Each of the hash
+// functions is the shingle hash mixed with a fixed per-function seed, so
+// signatures are stable across restarts.
+func minhash(hashes []uint64) [signatures]uint64 {
+	var sig [signatures]uint64
+	for i := range sig {
+		sig[i] = math.MaxUint64
+	}
+	for _, h := range hashes {
+		for i := range sig {
+			if v := mix(h ^ seeds[i]); v < sig[i] {
+				sig[i] = v
+			}
+		}
+	}
+	return sig
+}
+
+// bandKeys hashes each LSH band of a signature, tagged with its band index.
+func bandKeys(sig [signatures]uint64) []uint64 {
+	keys := make([]uint64, lshBands)
+	buf := make([]byte, 8)
+	for b := range keys {
+		h := fnv.New64a()
+		binary.LittleEndian.PutUint64(buf, uint64(b))
+		h.Write(buf)
+		for _, v := range sig[b*lshRows : (b+1)*lshRows] {
+			binary.LittleEndian.PutUint64(buf, v)
+			h.Write(buf)
+		}
+		keys[b] = h.Sum64()
+	}
+	return keys
+}
+
+var seeds = func() [signatures]uint64 {
+	var s [signatures]uint64
+	state := uint64(0x5eed)
+	for i := range s {
+		state += 0x9e3779b97f4a7c15
+		s[i] = mix(state)
+	}
+	return s
+}()
+
+// mix is the splitmix64 finalizer.
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	return x ^ x>>31
+}
@@ -0,0 +1,302 @@
+package plagiarism
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/victor-butita/rephrase/internal/textutil"
+)
+
+const (
+	ParaphraseProvider = "paraphrase"
+
+	KindExact       = "exact"
+	KindParaphrased = "paraphrased"
+	// KindReported marks matches a provider asserts without quoting a source.
+	KindReported = "reported"
+
+	DefaultParaphraseThreshold = 0.85
+	// Sentences shorter than this carry too little meaning to compare.
+	minParaphraseWords = 6
+	// A corpus document that could not be embedded is tried again after
+	// this long.
+	paraphraseRetryAfter = time.Minute
+)
+
+// Embedder turns texts into vectors; texts and vectors are aligned.
+type Embedder interface {
+	Embed(texts []string) ([][]float32, error)
+}
+
+// HTTPEmbedder calls an OpenAI-compatible /v1/embeddings endpoint, as served
+// by Ollama, llama.cpp, LM Studio or text-embeddings-inference.
+type HTTPEmbedder struct {
+	URL        string
+	Model      string
+	HTTPClient *http.Client
+}
+
+func NewHTTPEmbedder(url, model string) *HTTPEmbedder {
+	return &HTTPEmbedder{URL: url, Model: model, HTTPClient: &http.Client{Timeout: 60 * time.Second}}
+}
+
+func (e *HTTPEmbedder) Embed(texts []string) ([][]float32, error) {
+	body, err := json.Marshal(map[string]interface{}{"input": texts, "model": e.Model})
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling request body: %w", err)
+	}
+	resp, err := e.HTTPClient.Post(e.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("embedding request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("embedding endpoint error (status %d): %s", resp.StatusCode, string(msg))
+	}
+	var out struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("could not parse embedding response: %w", err)
+	}
+	if len(out.Data) != len(texts) {
+		return nil, fmt.Errorf("embedding endpoint returned %d vectors for %d texts", len(out.Data), len(texts))
+	}
+	sort.Slice(out.Data, func(i, j int) bool { return out.Data[i].Index < out.Data[j].Index })
+	vectors := make([][]float32, len(out.Data))
+	for i, d := range out.Data {
+		vectors[i] = d.Embedding
+	}
+	return vectors, nil
+}
+
+type sentenceVector struct {
+	documentID string
+	title      string
+	text       string
+	start, end int
+	vector     []float32
+}
+
+// ParaphraseSource finds reworded copying. It keeps a vector index of every
+// sentence in the corpus, embedding new documents on the next search and
+// dropping deleted ones, and reports input sentences whose nearest corpus
+// sentence is at least Threshold similar by cosine similarity.
+type ParaphraseSource struct {
+	Corpus    *Index
+	Embedder  Embedder
+	Threshold float64
+
+	mu      sync.Mutex
+	vectors map[string][]sentenceVector
+	// embedding holds the documents a search is embedding right now, and
+	// failed when each document that could not be embedded last failed.
+	embedding map[string]bool
+	failed    map[string]time.Time
+}
+
+func NewParaphraseSource(corpus *Index, embedder Embedder, threshold float64) *ParaphraseSource {
+	if threshold <= 0 {
+		threshold = DefaultParaphraseThreshold
+	}
+	return &ParaphraseSource{Corpus: corpus, Embedder: embedder, Threshold: threshold, vectors: make(map[string][]sentenceVector), embedding: make(map[string]bool), failed: make(map[string]time.Time)}
+}
+
+func (p *ParaphraseSource) Name() string { return ParaphraseProvider }
+
+func (p *ParaphraseSource) Find(text string) ([]Match, error) {
+	index := p.sync()
+	if len(index) == 0 {
+		return nil, nil
+	}
+
+	sentences := comparableSentences(text)
+	if len(sentences) == 0 {
+		return nil, nil
+	}
+	texts := make([]string, len(sentences))
+	for i, s := range sentences {
+		texts[i] = s.Text
+	}
+	vectors, err := p.Embedder.Embed(texts)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []Match
+	for i, s := range sentences {
+		normalize(vectors[i])
+		best, bestSim := -1, 0.0
+		for j := range index {
+			if sim := dot(vectors[i], index[j].vector); sim > bestSim {
+				best, bestSim = j, sim
+			}
+		}
+		if best < 0 || bestSim < p.Threshold {
+			continue
+		}
+		src := index[best]
+		sim := math.Round(bestSim*1000) / 1000
+		matches = append(matches, Match{
+			Kind:          KindParaphrased,
+			DocumentID:    src.documentID,
+			DocumentTitle: src.title,
+			Text:          s.Text,
+			Start:         textutil.CharOffset(text, s.Start),
+			End:           textutil.CharOffset(text, s.End),
+			SourceText:    src.text,
+			SourceStart:   src.start,
+			SourceEnd:     src.end,
+			Words:         len(tokenize(s.Text)),
+			Similarity:    sim,
+			Confidence:    sim,
+		})
+	}
+	return matches, nil
+}
+
+// sync brings the vector index in line with the corpus and returns a
+// snapshot of it. New documents are embedded without holding the lock, so
+// concurrent searches only wait for each other while the index is updated.
+// A document that cannot be embedded is left out of the index and logged,
+// and tried again once paraphraseRetryAfter has passed.
+func (p *ParaphraseSource) sync() []sentenceVector {
+	p.mu.Lock()
+	var pending []*Document
+	now := time.Now()
+	for _, d := range p.Corpus.List() {
+		if _, ok := p.vectors[d.ID]; ok || p.embedding[d.ID] {
+			continue
+		}
+		if failed, ok := p.failed[d.ID]; ok && now.Sub(failed) < paraphraseRetryAfter {
+			continue
+		}
+		if doc, ok := p.Corpus.Get(d.ID); ok {
+			p.embedding[d.ID] = true
+			pending = append(pending, doc)
+		}
+	}
+	p.mu.Unlock()
+
+	embedded := make(map[string][]sentenceVector)
+	failed := make(map[string]time.Time)
+	for _, doc := range pending {
+		entries, err := p.embedDocument(doc)
+		if err != nil {
+			log.Printf("Could not embed %q for paraphrase search: %v", doc.Title, err)
+			failed[doc.ID] = time.Now()
+			continue
+		}
+		embedded[doc.ID] = entries
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, doc := range pending {
+		delete(p.embedding, doc.ID)
+	}
+	for id, entries := range embedded {
+		p.vectors[id] = entries
+		delete(p.failed, id)
+	}
+	for id, at := range failed {
+		p.failed[id] = at
+	}
+	current := make(map[string]bool)
+	for _, d := range p.Corpus.List() {
+		current[d.ID] = true
+	}
+	for id := range p.failed {
+		if !current[id] {
+			delete(p.failed, id)
+		}
+	}
+	var index []sentenceVector
+	for id, entries := range p.vectors {
+		if !current[id] {
+			delete(p.vectors, id)
+			continue
+		}
+		index = append(index, entries...)
+	}
+	return index
+}
+
+// embedDocument embeds the comparable sentences of doc.
+func (p *ParaphraseSource) embedDocument(doc *Document) ([]sentenceVector, error) {
+	sentences := comparableSentences(doc.Text)
+	entries := []sentenceVector{}
+	if len(sentences) == 0 {
+		return entries, nil
+	}
+	texts := make([]string, len(sentences))
+	for i, s := range sentences {
+		texts[i] = s.Text
+	}
+	vectors, err := p.Embedder.Embed(texts)
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) != len(sentences) {
+		return nil, fmt.Errorf("got %d vectors for %d sentences", len(vectors), len(sentences))
+	}
+	for i, s := range sentences {
+		normalize(vectors[i])
+		entries = append(entries, sentenceVector{
+			documentID: doc.ID,
+			title:      doc.Title,
+			text:       s.Text,
+			start:      textutil.CharOffset(doc.Text, s.Start),
+			end:        textutil.CharOffset(doc.Text, s.End),
+			vector:     vectors[i],
+		})
+	}
+	return entries, nil
+}
+
+func comparableSentences(text string) []textutil.Span {
+	var out []textutil.Span
+	for _, s := range textutil.Sentences(text) {
+		if len(tokenize(s.Text)) >= minParaphraseWords {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func normalize(v []float32) {
+	var norm float64
+	for _, x := range v {
+		norm += float64(x) * float64(x)
+	}
+	if norm == 0 {
+		return
+	}
+	norm = math.Sqrt(norm)
+	for i := range v {
+		v[i] = float32(float64(v[i]) / norm)
+	}
+}
+
+func dot(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
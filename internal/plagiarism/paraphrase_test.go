@@ -0,0 +1,96 @@
+package plagiarism
+
+import (
+	"errors"
+	"hash/fnv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// bagEmbedder embeds a sentence as its bag of words, so sentences with the
+// same words in any order are identical and unrelated ones are not alike.
+// It refuses texts containing "unembeddable".
+type bagEmbedder struct {
+	mu    sync.Mutex
+	texts int
+}
+
+func (e *bagEmbedder) Embed(texts []string) ([][]float32, error) {
+	e.mu.Lock()
+	e.texts += len(texts)
+	e.mu.Unlock()
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		if strings.Contains(text, "unembeddable") {
+			return nil, errors.New("embedding failed")
+		}
+		v := make([]float32, 512)
+		for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return r == ' ' || r == '.' || r == ',' }) {
+			h := fnv.New32a()
+			h.Write([]byte(w))
+			v[h.Sum32()%512]++
+		}
+		vectors[i] = v
+	}
+	return vectors, nil
+}
+
+func TestParaphraseSource(t *testing.T) {
+	corpus := NewIndex()
+	const sourceText = "Über die Straße läuft heute niemand mehr. The quick brown fox jumps over the lazy sleeping dog today."
+	source, err := corpus.Add("", "Fables", sourceText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := corpus.Add("", "Broken", "This document is unembeddable and should simply be skipped."); err != nil {
+		t.Fatal(err)
+	}
+	embedder := &bagEmbedder{}
+	p := NewParaphraseSource(corpus, embedder, 0)
+
+	// The second sentence rewords the source sentence; the third shares
+	// only some of its words, which is below the default threshold.
+	input := "Das Café öffnet erst am späten Nachmittag wieder. Today over the lazy sleeping dog the quick brown fox jumps. The quick brown fox eats cheese in the garden now."
+	matches, err := p.Find(input)
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	if len(matches) != 1 {
+		t.Fatalf("got %d matches, want 1: %+v", len(matches), matches)
+	}
+	m := matches[0]
+	if m.Kind != KindParaphrased || m.DocumentID != source.ID || m.Similarity < 0.999 {
+		t.Errorf("match = %+v, want a paraphrase of %s with similarity 1", m, source.ID)
+	}
+	if got := string([]rune(input)[m.Start:m.End]); got != m.Text || !strings.HasPrefix(got, "Today over") {
+		t.Errorf("input offsets %d-%d give %q, want the reworded sentence %q", m.Start, m.End, got, m.Text)
+	}
+	if got := string([]rune(sourceText)[m.SourceStart:m.SourceEnd]); got != m.SourceText || !strings.HasPrefix(got, "The quick") {
+		t.Errorf("source offsets %d-%d give %q, want %q", m.SourceStart, m.SourceEnd, got, m.SourceText)
+	}
+
+	// A lower threshold also takes in the partial overlap.
+	loose := NewParaphraseSource(corpus, embedder, 0.5)
+	if matches, err := loose.Find(input); err != nil || len(matches) != 2 {
+		t.Errorf("with threshold 0.5: %d matches, %v, want 2", len(matches), err)
+	}
+
+	// Corpus documents are embedded once, not on every search.
+	embedder.texts = 0
+	if _, err := p.Find(input); err != nil {
+		t.Fatal(err)
+	}
+	if embedder.texts != 3 {
+		t.Errorf("second search embedded %d texts, want only the 3 input sentences", embedder.texts)
+	}
+
+	corpus.Delete(source.ID)
+	matches, err = p.Find(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 0 {
+		t.Errorf("deleted document still matches: %+v", matches)
+	}
+}
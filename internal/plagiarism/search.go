@@ -0,0 +1,226 @@
+package plagiarism
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/victor-butita/rephrase/internal/textutil"
+)
+
+const (
+	// maxSearchQueries bounds how many passages of one text are sent to a
+	// search engine.
+	maxSearchQueries   = 20
+	searchHitsPerQuery = 3
+)
+
+// SearchHit is a document returned by a search engine.
+type SearchHit struct {
+	ID    string
+	Title string
+	URL   string
+	Text  string
+}
+
+// SearchEngine queries a full-text index with one passage at a time.
+type SearchEngine interface {
+	Search(query string, limit int) ([]SearchHit, error)
+}
+
+// SearchProvider finds matches through a self-hosted search engine. Each
+// sentence long enough to form a match is used as a query, and every hit is
+// then aligned word by word against the checked text, so only exact passages
+// are reported, just as for the local corpus.
+type SearchProvider struct {
+	Engine SearchEngine
+	name   string
+}
+
+func NewSearchProvider(name string, engine SearchEngine) *SearchProvider {
+	return &SearchProvider{Engine: engine, name: name}
+}
+
+func (p *SearchProvider) Name() string { return p.name }
+
+func (p *SearchProvider) Find(text string) ([]Match, error) {
+	hits := make(map[string]SearchHit)
+	var order []string
+	queries := 0
+	for _, s := range textutil.Sentences(text) {
+		if len(tokenize(s.Text)) < MinMatchWords {
+			continue
+		}
+		if queries == maxSearchQueries {
+			break
+		}
+		queries++
+		results, err := p.Engine.Search(s.Text, searchHitsPerQuery)
+		if err != nil {
+			return nil, err
+		}
+		for _, h := range results {
+			if _, ok := hits[h.ID]; !ok {
+				hits[h.ID] = h
+				order = append(order, h.ID)
+			}
+		}
+	}
+
+	tokens := tokenize(text)
+	hashes := shingles(tokens)
+	var matches []Match
+	for _, id := range order {
+		h := hits[id]
+		doc, _ := newIndexedDocument(Document{ID: h.ID, Title: h.Title, Text: h.Text})
+		for _, m := range alignDocument(text, tokens, hashes, doc, p.name) {
+			m.URL = h.URL
+			matches = append(matches, m)
+		}
+	}
+	return matches, nil
+}
+
+// SearchConfig describes a search engine index. TextField holds the document
+// body; TitleField and URLField are optional.
+type SearchConfig struct {
+	URL        string
+	Index      string
+	APIKey     string
+	TextField  string
+	TitleField string
+	URLField   string
+}
+
+func (c *SearchConfig) withDefaults() SearchConfig {
+	out := *c
+	out.URL = strings.TrimRight(out.URL, "/")
+	if out.TextField == "" {
+		out.TextField = "text"
+	}
+	if out.TitleField == "" {
+		out.TitleField = "title"
+	}
+	return out
+}
+
+// ElasticsearchEngine queries Elasticsearch or OpenSearch, which share the
+// _search API.
+type ElasticsearchEngine struct {
+	Config     SearchConfig
+	HTTPClient *http.Client
+}
+
+func NewElasticsearchEngine(cfg SearchConfig) *ElasticsearchEngine {
+	return &ElasticsearchEngine{Config: cfg.withDefaults(), HTTPClient: &http.Client{Timeout: 15 * time.Second}}
+}
+
+func (e *ElasticsearchEngine) Search(query string, limit int) ([]SearchHit, error) {
+	body := map[string]interface{}{
+		"size":  limit,
+		"query": map[string]interface{}{"match": map[string]interface{}{e.Config.TextField: query}},
+	}
+	var out struct {
+		Hits struct {
+			Hits []struct {
+				ID     string                 `json:"_id"`
+				Source map[string]interface{} `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	endpoint := fmt.Sprintf("%s/%s/_search", e.Config.URL, url.PathEscape(e.Config.Index))
+	auth := ""
+	if e.Config.APIKey != "" {
+		auth = "ApiKey " + e.Config.APIKey
+	}
+	if err := postJSON(e.HTTPClient, endpoint, auth, body, &out); err != nil {
+		return nil, err
+	}
+	hits := make([]SearchHit, 0, len(out.Hits.Hits))
+	for _, h := range out.Hits.Hits {
+		hits = append(hits, e.Config.hit(h.ID, h.Source))
+	}
+	return hits, nil
+}
+
+// MeilisearchEngine queries a Meilisearch index.
+type MeilisearchEngine struct {
+	Config     SearchConfig
+	HTTPClient *http.Client
+	// IDField is the index's primary key.
+	IDField string
+}
+
+func NewMeilisearchEngine(cfg SearchConfig) *MeilisearchEngine {
+	return &MeilisearchEngine{Config: cfg.withDefaults(), HTTPClient: &http.Client{Timeout: 15 * time.Second}, IDField: "id"}
+}
+
+func (e *MeilisearchEngine) Search(query string, limit int) ([]SearchHit, error) {
+	body := map[string]interface{}{"q": query, "limit": limit}
+	var out struct {
+		Hits []map[string]interface{} `json:"hits"`
+	}
+	endpoint := fmt.Sprintf("%s/indexes/%s/search", e.Config.URL, url.PathEscape(e.Config.Index))
+	auth := ""
+	if e.Config.APIKey != "" {
+		auth = "Bearer " + e.Config.APIKey
+	}
+	if err := postJSON(e.HTTPClient, endpoint, auth, body, &out); err != nil {
+		return nil, err
+	}
+	hits := make([]SearchHit, 0, len(out.Hits))
+	for _, h := range out.Hits {
+		hits = append(hits, e.Config.hit(fmt.Sprint(h[e.IDField]), h))
+	}
+	return hits, nil
+}
+
+func (c *SearchConfig) hit(id string, fields map[string]interface{}) SearchHit {
+	str := func(name string) string {
+		if name == "" {
+			return ""
+		}
+		if v, ok := fields[name].(string); ok {
+			return v
+		}
+		return ""
+	}
+	hit := SearchHit{ID: id, Title: str(c.TitleField), URL: str(c.URLField), Text: str(c.TextField)}
+	if hit.Title == "" {
+		hit.Title = id
+	}
+	return hit
+}
+
+func postJSON(client *http.Client, endpoint, auth string, body, target interface{}) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("error marshalling request body: %w", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("error creating search request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("search request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("search engine error (status %d): %s", resp.StatusCode, string(msg))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(target); err != nil {
+		return fmt.Errorf("could not parse search response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,333 @@
+// Package research stores document collections for grounded research and
+// retrieves the passages most relevant to a query with BM25. Everything is
+// indexed and searched locally.
+package research
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/victor-butita/rephrase/internal/extract"
+	"github.com/victor-butita/rephrase/internal/storeutil"
+	"github.com/victor-butita/rephrase/internal/textutil"
+)
+
+const (
+	// Passages are built from whole paragraphs up to about this many words;
+	// longer paragraphs are split at sentence boundaries.
+	passageWords = 150
+
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+type Passage struct {
+	ID         string `json:"id"`
+	DocumentID string `json:"document_id"`
+	Index      int    `json:"index"`
+	Text       string `json:"text"`
+	terms      map[string]int
+	length     int
+}
+
+type Document struct {
+	ID       string    `json:"id"`
+	Title    string    `json:"title"`
+	Filename string    `json:"filename"`
+	Format   string    `json:"format"`
+	Words    int       `json:"words"`
+	Passages int       `json:"passages"`
+	Warnings []string  `json:"warnings,omitempty"`
+	AddedAt  time.Time `json:"added_at"`
+}
+
+// Collection is a set of documents to research. It belongs to the user who
+// created it, its Owner, and the library's methods only find it for them.
+type Collection struct {
+	ID        string     `json:"id"`
+	Owner     string     `json:"-"`
+	Name      string     `json:"name"`
+	Documents []Document `json:"documents"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type collection struct {
+	Collection
+	passages []*Passage
+}
+
+// Hit is a retrieved passage with its document's title.
+type Hit struct {
+	Passage       Passage `json:"passage"`
+	DocumentTitle string  `json:"document_title"`
+	Score         float64 `json:"score"`
+}
+
+type Library struct {
+	mu          sync.RWMutex
+	collections map[string]*collection
+}
+
+func NewLibrary() *Library {
+	return &Library{collections: make(map[string]*collection)}
+}
+
+func (l *Library) Create(owner, name string) (*Collection, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("collection name is required")
+	}
+	c := &collection{Collection: Collection{ID: storeutil.NewID(), Owner: owner, Name: name, Documents: []Document{}, CreatedAt: time.Now().UTC()}}
+	l.mu.Lock()
+	l.collections[c.ID] = c
+	l.mu.Unlock()
+	return c.snapshot(), nil
+}
+
+// collection returns one of owner's collections. The caller holds l.mu.
+func (l *Library) collection(owner, id string) (*collection, bool) {
+	c, ok := l.collections[id]
+	if !ok || c.Owner != owner {
+		return nil, false
+	}
+	return c, true
+}
+
+func (l *Library) Get(owner, id string) (*Collection, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	c, ok := l.collection(owner, id)
+	if !ok {
+		return nil, false
+	}
+	return c.snapshot(), true
+}
+
+// List returns owner's collections by name.
+func (l *Library) List(owner string) []*Collection {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	out := []*Collection{}
+	for _, c := range l.collections {
+		if c.Owner == owner {
+			out = append(out, c.snapshot())
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+func (l *Library) Delete(owner, id string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.collection(owner, id); !ok {
+		return false
+	}
+	delete(l.collections, id)
+	return true
+}
+
+// AddDocument splits an extracted document into passages and indexes them.
+func (l *Library) AddDocument(owner, collectionID string, doc *extract.Document) (*Document, error) {
+	d := Document{
+		ID:       storeutil.NewID(),
+		Title:    doc.Title,
+		Filename: doc.Filename,
+		Format:   doc.Format,
+		Warnings: doc.Warnings,
+		AddedAt:  time.Now().UTC(),
+	}
+	var passages []*Passage
+	for i, text := range chunk(doc.Paragraphs) {
+		p := &Passage{ID: fmt.Sprintf("%s:%d", d.ID, i+1), DocumentID: d.ID, Index: i + 1, Text: text, terms: make(map[string]int)}
+		for _, t := range terms(text) {
+			p.terms[t]++
+			p.length++
+		}
+		d.Words += textutil.WordCount(text)
+		passages = append(passages, p)
+	}
+	if len(passages) == 0 {
+		return nil, fmt.Errorf("%s contains no text", doc.Filename)
+	}
+	d.Passages = len(passages)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	c, ok := l.collection(owner, collectionID)
+	if !ok {
+		return nil, fmt.Errorf("collection not found")
+	}
+	c.Documents = append(c.Documents, d)
+	c.passages = append(c.passages, passages...)
+	return &d, nil
+}
+
+func (l *Library) RemoveDocument(owner, collectionID, documentID string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	c, ok := l.collection(owner, collectionID)
+	if !ok {
+		return false
+	}
+	found := false
+	docs := c.Documents[:0]
+	for _, d := range c.Documents {
+		if d.ID == documentID {
+			found = true
+			continue
+		}
+		docs = append(docs, d)
+	}
+	c.Documents = docs
+	passages := c.passages[:0]
+	for _, p := range c.passages {
+		if p.DocumentID != documentID {
+			passages = append(passages, p)
+		}
+	}
+	c.passages = passages
+	return found
+}
+
+// Search ranks the passages of owner's collection against query with BM25
+// and returns the best k that share at least one term with it.
+func (l *Library) Search(owner, collectionID, query string, k int) ([]Hit, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	c, ok := l.collection(owner, collectionID)
+	if !ok {
+		return nil, fmt.Errorf("collection not found")
+	}
+	if len(c.passages) == 0 {
+		return nil, nil
+	}
+
+	queryTerms := make(map[string]bool)
+	for _, t := range terms(query) {
+		queryTerms[t] = true
+	}
+	df := make(map[string]int)
+	var totalLength int
+	for _, p := range c.passages {
+		totalLength += p.length
+		for t := range queryTerms {
+			if p.terms[t] > 0 {
+				df[t]++
+			}
+		}
+	}
+	n := float64(len(c.passages))
+	avgLength := float64(totalLength) / n
+	titles := make(map[string]string, len(c.Documents))
+	for _, d := range c.Documents {
+		titles[d.ID] = d.Title
+	}
+
+	var hits []Hit
+	for _, p := range c.passages {
+		var score float64
+		for t := range queryTerms {
+			tf := float64(p.terms[t])
+			if tf == 0 {
+				continue
+			}
+			idf := math.Log(1 + (n-float64(df[t])+0.5)/(float64(df[t])+0.5))
+			score += idf * tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*float64(p.length)/avgLength))
+		}
+		if score > 0 {
+			hits = append(hits, Hit{Passage: *p, DocumentTitle: titles[p.DocumentID], Score: math.Round(score*1000) / 1000})
+		}
+	}
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].Score > hits[j].Score })
+	if len(hits) > k {
+		hits = hits[:k]
+	}
+	return hits, nil
+}
+
+func (c *collection) snapshot() *Collection {
+	out := c.Collection
+	out.Documents = append([]Document{}, c.Documents...)
+	return &out
+}
+
+// chunk groups paragraphs into passages of roughly passageWords words.
+func chunk(paragraphs []string) []string {
+	var pieces []string
+	for _, p := range paragraphs {
+		if textutil.WordCount(p) <= passageWords {
+			pieces = append(pieces, p)
+			continue
+		}
+		var current []string
+		words := 0
+		for _, s := range textutil.Sentences(p) {
+			n := textutil.WordCount(s.Text)
+			if words > 0 && words+n > passageWords {
+				pieces = append(pieces, strings.Join(current, " "))
+				current, words = nil, 0
+			}
+			current = append(current, s.Text)
+			words += n
+		}
+		if len(current) > 0 {
+			pieces = append(pieces, strings.Join(current, " "))
+		}
+	}
+
+	var passages []string
+	var current []string
+	words := 0
+	for _, p := range pieces {
+		n := textutil.WordCount(p)
+		if words > 0 && words+n > passageWords {
+			passages = append(passages, strings.Join(current, "\n\n"))
+			current, words = nil, 0
+		}
+		current = append(current, p)
+		words += n
+	}
+	if len(current) > 0 {
+		passages = append(passages, strings.Join(current, "\n\n"))
+	}
+	return passages
+}
+
+// terms lowercases and drops stop words and punctuation.
+func terms(text string) []string {
+	var out []string
+	for _, w := range textutil.Words(text) {
+		t := strings.ToLower(strings.TrimFunc(w.Text, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }))
+		if t != "" && !stopWords[t] {
+			out = append(out, fold(t))
+		}
+	}
+	return out
+}
+
+// fold strips a plural "s" so "panel" matches "panels".
+func fold(t string) string {
+	if len(t) > 3 && strings.HasSuffix(t, "s") && !strings.HasSuffix(t, "ss") {
+		return t[:len(t)-1]
+	}
+	return t
+}
+
+var stopWords = func() map[string]bool {
+	m := make(map[string]bool)
+	for _, w := range strings.Fields(`a about above after again against all am an and any are as at be because been
+		before being below between both but by can could did do does doing down during each few for from further had
+		has have having he her here hers him his how i if in into is it its itself just me more most my no nor not
+		of off on once only or other our ours out over own same she should so some such than that the their theirs
+		them then there these they this those through to too under until up very was we were what when where which
+		while who whom why will with would you your yours`) {
+		m[w] = true
+	}
+	return m
+}()
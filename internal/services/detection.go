@@ -0,0 +1,112 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/victor-butita/rephrase/internal/detector"
+	"github.com/victor-butita/rephrase/internal/textutil"
+)
+
+const (
+	DefaultDetectorWeights  = "llm=0.5,heuristic=0.5"
+	defaultLocalModelWeight = 0.3
+	redFlagThreshold        = 65
+)
+
+// LLMDetector adapts DetectAI to the detector.Detector interface.
+type LLMDetector struct {
+	Gemini *GeminiService
+}
+
+func (d *LLMDetector) Name() string { return "llm" }
+
+func (d *LLMDetector) Score(text string, sentences []textutil.Span) (*detector.Verdict, error) {
+	result, err := d.Gemini.DetectAI(text, sentences)
+	if err != nil {
+		return nil, err
+	}
+	scores := result.SentenceScores
+	if len(scores) != len(sentences) {
+		scores = nil
+	}
+	var details interface{}
+	if len(result.RedFlags) > 0 {
+		details = map[string][]string{"red_flags": result.RedFlags}
+	}
+	return &detector.Verdict{
+		Score:          float64(result.OverallScore),
+		SentenceScores: scores,
+		Analysis:       result.Analysis,
+		Details:        details,
+	}, nil
+}
+
+// NewDetectorEnsemble builds the detection pipeline from a weight spec such as
+// "llm=0.5,heuristic=0.3,local_model=0.2". The local model is only added when
+// localModelURL is set; it gets a default weight if the spec omits it. A nil
+// heuristicModel means detector.DefaultModel.
+func NewDetectorEnsemble(gs *GeminiService, weightSpec, localModelURL string, heuristicModel *detector.Model) (*detector.Ensemble, error) {
+	if weightSpec == "" {
+		weightSpec = DefaultDetectorWeights
+	}
+	weights, err := detector.ParseWeights(weightSpec)
+	if err != nil {
+		return nil, err
+	}
+	for name := range weights {
+		switch name {
+		case "llm", "heuristic", "local_model":
+		default:
+			return nil, fmt.Errorf("unknown detector %q in weights", name)
+		}
+	}
+
+	if weights["llm"] > 0 && gs == nil {
+		return nil, fmt.Errorf("the llm detector needs a Gemini API key")
+	}
+
+	ensemble := detector.NewEnsemble().
+		Add(&LLMDetector{Gemini: gs}, weights["llm"]).
+		Add(&detector.HeuristicDetector{Model: heuristicModel}, weights["heuristic"])
+	if localModelURL != "" {
+		w, ok := weights["local_model"]
+		if !ok {
+			w = defaultLocalModelWeight
+			weights["local_model"] = w
+		}
+		ensemble.Add(detector.NewModelDetector(localModelURL), w)
+	} else if weights["local_model"] > 0 {
+		log.Println("Ignoring local_model detector weight: LOCAL_DETECTOR_URL is not set")
+		delete(weights, "local_model")
+	}
+	log.Printf("AI detection ensemble weights: %s", detector.SortedWeights(weights))
+	return ensemble, nil
+}
+
+// NewAIDetectionResult shapes an ensemble result for the API. The analysis is
+// taken from the LLM when it answered, otherwise from the first detector that
+// did; sentences above the red-flag threshold are listed in RedFlags.
+func NewAIDetectionResult(er *detector.EnsembleResult) *AIDetectionResult {
+	result := &AIDetectionResult{
+		OverallScore: int(math.Round(er.Score)),
+		RedFlags:     []string{},
+		Sentences:    er.Sentences,
+		Detectors:    er.Detectors,
+	}
+	for _, d := range er.Detectors {
+		if d.Score == nil || d.Analysis == "" {
+			continue
+		}
+		if result.Analysis == "" || d.Name == "llm" {
+			result.Analysis = d.Analysis
+		}
+	}
+	for _, s := range er.Sentences {
+		if s.Score > redFlagThreshold {
+			result.RedFlags = append(result.RedFlags, s.Text)
+		}
+	}
+	return result
+}
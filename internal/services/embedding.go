@@ -0,0 +1,79 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+const (
+	geminiEmbeddingModel = "models/text-embedding-004"
+	// batchEmbedContents accepts at most 100 texts per call.
+	geminiEmbeddingBatch = 100
+)
+
+// GeminiEmbedder embeds texts with Gemini's embedding model. It implements
+// plagiarism.Embedder.
+type GeminiEmbedder struct {
+	Gemini *GeminiService
+}
+
+func (e *GeminiEmbedder) Embed(texts []string) ([][]float32, error) {
+	var vectors [][]float32
+	for start := 0; start < len(texts); start += geminiEmbeddingBatch {
+		end := min(start+geminiEmbeddingBatch, len(texts))
+		batch, err := e.Gemini.embedBatch(texts[start:end])
+		if err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, batch...)
+	}
+	return vectors, nil
+}
+
+func (s *GeminiService) embedBatch(texts []string) ([][]float32, error) {
+	type request struct {
+		Model   string        `json:"model"`
+		Content GeminiContent `json:"content"`
+	}
+	var body struct {
+		Requests []request `json:"requests"`
+	}
+	for _, t := range texts {
+		body.Requests = append(body.Requests, request{Model: geminiEmbeddingModel, Content: GeminiContent{Parts: []GeminiPart{{Text: t}}}})
+	}
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling request body: %w", err)
+	}
+
+	apiURL := "https://generativelanguage.googleapis.com/v1beta/" + geminiEmbeddingModel + ":batchEmbedContents?key=" + s.APIKey
+	resp, err := s.HTTPClient.Post(apiURL, "application/json", bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("gemini embedding request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("gemini API error (status %d): %s", resp.StatusCode, string(respBody))
+	}
+
+	var out struct {
+		Embeddings []struct {
+			Values []float32 `json:"values"`
+		} `json:"embeddings"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("could not parse gemini embedding response: %w", err)
+	}
+	if len(out.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("gemini returned %d embeddings for %d texts", len(out.Embeddings), len(texts))
+	}
+	vectors := make([][]float32, len(texts))
+	for i, e := range out.Embeddings {
+		vectors[i] = e.Values
+	}
+	return vectors, nil
+}
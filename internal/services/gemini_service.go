@@ -9,6 +9,10 @@ import (
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/victor-butita/rephrase/internal/detector"
+	"github.com/victor-butita/rephrase/internal/plagiarism"
+	"github.com/victor-butita/rephrase/internal/textutil"
 )
 
 type GeminiService struct {
@@ -56,21 +60,58 @@ type GeminiResponse struct {
 }
 
 type AIDetectionResult struct {
-	OverallScore int      `json:"overall_score"`
-	Analysis     string   `json:"analysis"`
-	RedFlags     []string `json:"red_flags"`
+	OverallScore int                         `json:"overall_score"`
+	Analysis     string                      `json:"analysis"`
+	RedFlags     []string                    `json:"red_flags"`
+	Sentences    []detector.EnsembleSentence `json:"sentences"`
+	Detectors    []detector.DetectorScore    `json:"detectors"`
+}
+
+// LLMDetection is the model's own verdict, as returned by DetectAI.
+type LLMDetection struct {
+	OverallScore   int       `json:"overall_score"`
+	Analysis       string    `json:"analysis"`
+	RedFlags       []string  `json:"red_flags"`
+	SentenceScores []float64 `json:"sentence_scores"`
 }
 
 type PlagiarismMatch struct {
 	MatchingText    string  `json:"matching_text"`
 	PotentialSource string  `json:"potential_source"`
 	Confidence      float32 `json:"confidence"`
+	// Kind is "exact", "paraphrased" (with a cosine Similarity) or
+	// "reported" for unverified model findings.
+	Kind       string  `json:"kind,omitempty"`
+	Similarity float32 `json:"similarity,omitempty"`
+	// Source is the provider that reported the match; CorroboratedBy lists
+	// other providers that found the same passage.
+	Source         string   `json:"source,omitempty"`
+	CorroboratedBy []string `json:"corroborated_by,omitempty"`
+	// Location is set for verifiable matches quoted from a source document.
+	Location *PlagiarismLocation `json:"location,omitempty"`
+}
+
+// PlagiarismLocation pins a match to character offsets in the checked text
+// and in the corpus document it was found in.
+type PlagiarismLocation struct {
+	DocumentID   string    `json:"document_id"`
+	DocumentDate time.Time `json:"document_date,omitzero"`
+	URL          string    `json:"url,omitempty"`
+	Start        int       `json:"start"`
+	End          int       `json:"end"`
+	SourceText   string    `json:"source_text"`
+	SourceStart  int       `json:"source_start"`
+	SourceEnd    int       `json:"source_end"`
+	Words        int       `json:"words"`
 }
 
 type PlagiarismResult struct {
-	IsSimilarityFound bool              `json:"is_similarity_found"`
-	OverallConfidence float32           `json:"overall_confidence"`
-	Matches           []PlagiarismMatch `json:"matches"`
+	IsSimilarityFound bool                      `json:"is_similarity_found"`
+	OverallConfidence float32                   `json:"overall_confidence"`
+	Matches           []PlagiarismMatch         `json:"matches"`
+	Coverage          float32                   `json:"coverage"`
+	DocumentsSearched int                       `json:"documents_searched"`
+	Sources           []plagiarism.SourceStatus `json:"sources,omitempty"`
 }
 
 type ResearchResult struct {
@@ -80,33 +121,113 @@ type ResearchResult struct {
 	CoreConcepts              []string `json:"core_concepts"`
 	ControversiesAndCritiques []string `json:"controversies_and_critiques"`
 	PracticalApplications     []string `json:"practical_applications"`
+	// Sections holds every requested section, built-in or custom, in order;
+	// the fields above are filled only for the default sections.
+	Depth        string                `json:"depth"`
+	Sections     []ResearchSection     `json:"sections"`
+	SubQuestions []ResearchSubQuestion `json:"sub_questions,omitempty"`
+	// SessionID identifies the conversation for follow-up questions.
+	SessionID string `json:"session_id,omitempty"`
+	// Claims and Citations are set when the briefing is grounded in an
+	// uploaded document collection.
+	CollectionID string          `json:"collection_id,omitempty"`
+	Claims       []ResearchClaim `json:"claims,omitempty"`
+	Citations    []Citation      `json:"citations,omitempty"`
+}
+
+type RephraseOptions struct {
+	Tone           string
+	Complexity     string
+	Dialect        string
+	FreezeKeywords string
+	StyleGuide     *StyleGuide
+	Glossary       *Glossary
+	Length         *LengthTarget
+
+	correction *lengthCorrection
 }
 
-func (s *GeminiService) RephraseText(text, tone, complexity, dialect, freezeKeywords string) (string, error) {
+func (s *GeminiService) RephraseText(text string, opts RephraseOptions) (string, error) {
 	promptBuilder := strings.Builder{}
 	promptBuilder.WriteString("You are a world-class senior editor and copywriter. Your task is to perform a deep rewrite of the following text based on a strict set of directives. Your goal is not a simple rephrasing, but a professional transformation of the content.\n\n# DIRECTIVES:\n")
-	promptBuilder.WriteString(fmt.Sprintf("1.  **Tone & Voice:** The final text must embody a '%s' tone. It should be consistent and professionally executed.\n", tone))
-	promptBuilder.WriteString(fmt.Sprintf("2.  **Audience Complexity:** The vocabulary, sentence structure, and concepts must be precisely calibrated for a '%s' audience.\n", complexity))
+	promptBuilder.WriteString(rephraseDirectives(opts))
+
+	promptBuilder.WriteString("\n# OUTPUT FORMAT:\n- Your response MUST be ONLY the rewritten text.\n- DO NOT include any preamble, headers, notes, or explanations (e.g., 'Here is the rewritten text:'). Your entire output will be the final, polished text and nothing else.\n\n")
+	promptBuilder.WriteString(fmt.Sprintf("# ORIGINAL TEXT TO REWRITE:\n---\n%s\n---", text))
+
+	return s.generateContent(promptBuilder.String(), 4096, 0.7) // Higher temp for creative rewrite
+}
+
+// rephraseDirectives renders the numbered rewrite directives shared by plain
+// and structured rewrites.
+func rephraseDirectives(opts RephraseOptions) string {
+	promptBuilder := strings.Builder{}
+	promptBuilder.WriteString(fmt.Sprintf("1.  **Tone & Voice:** The final text must embody a '%s' tone. It should be consistent and professionally executed.\n", opts.Tone))
+	promptBuilder.WriteString(fmt.Sprintf("2.  **Audience Complexity:** The vocabulary, sentence structure, and concepts must be precisely calibrated for a '%s' audience.\n", opts.Complexity))
 	promptBuilder.WriteString("3.  **Clarity and Flow:** Rewrite for maximum clarity. Eliminate jargon, passive voice, and redundant phrases. Ensure sentences and paragraphs transition logically.\n")
 
-	if dialect != "" && dialect != "American English (Default)" {
-		promptBuilder.WriteString(fmt.Sprintf("4.  **Dialect:** The output must strictly adhere to %s spelling, grammar, and idioms.\n", dialect))
+	if opts.Dialect != "" && opts.Dialect != "American English (Default)" {
+		promptBuilder.WriteString("4.  " + dialectRule(opts.Dialect))
 	}
 
-	if strings.TrimSpace(freezeKeywords) != "" {
-		promptBuilder.WriteString(fmt.Sprintf(
-			"5.  **Keyword Integrity (Non-negotiable):** The following keywords/phrases are mission-critical and MUST appear in the final text exactly as written, without any modification: [%s].\n",
-			freezeKeywords,
-		))
+	if rule := keywordIntegrityRule(opts.FreezeKeywords); rule != "" {
+		promptBuilder.WriteString("5.  " + rule)
 	}
 
-	promptBuilder.WriteString("\n# OUTPUT FORMAT:\n- Your response MUST be ONLY the rewritten text.\n- DO NOT include any preamble, headers, notes, or explanations (e.g., 'Here is the rewritten text:'). Your entire output will be the final, polished text and nothing else.\n\n")
-	promptBuilder.WriteString(fmt.Sprintf("# ORIGINAL TEXT TO REWRITE:\n---\n%s\n---", text))
+	if opts.StyleGuide != nil {
+		promptBuilder.WriteString(fmt.Sprintf("6.  **House Style Guide (%s):** The final text must comply with every rule below.\n", opts.StyleGuide.Name))
+		promptBuilder.WriteString(opts.StyleGuide.PromptDirectives())
+	}
 
-	return s.generateContent(promptBuilder.String(), 4096, 0.7) // Higher temp for creative rewrite
+	if opts.Glossary != nil {
+		promptBuilder.WriteString("7.  **Terminology:** Use the workspace glossary's preferred terms.\n")
+		promptBuilder.WriteString(opts.Glossary.PromptDirectives())
+	}
+
+	if opts.Length != nil {
+		promptBuilder.WriteString(opts.Length.promptDirective())
+		if opts.correction != nil {
+			promptBuilder.WriteString(opts.correction.promptSection(opts.Length))
+		}
+	}
+	return promptBuilder.String()
+}
+
+func dialectRule(dialect string) string {
+	return fmt.Sprintf("**Dialect:** %s spelling, grammar, and idioms are the strict standard for the text; the output must adhere to them.\n", strings.TrimSuffix(dialect, " (Default)"))
+}
+
+// keywordIntegrityRule is the prompt directive protecting freeze keywords, or
+// "" when there are none.
+func keywordIntegrityRule(freezeKeywords string) string {
+	if strings.TrimSpace(freezeKeywords) == "" {
+		return ""
+	}
+	return fmt.Sprintf(
+		"**Keyword Integrity (Non-negotiable):** The following keywords/phrases are mission-critical and MUST appear in the final text exactly as written, without any modification: [%s].\n",
+		freezeKeywords,
+	)
 }
 
-func (s *GeminiService) DetectAI(text string) (*AIDetectionResult, error) {
+// MissingFrozenKeywords lists the comma-separated freeze keywords that do not
+// appear verbatim in text.
+func MissingFrozenKeywords(text, freezeKeywords string) []string {
+	missing := []string{}
+	for _, keyword := range strings.Split(freezeKeywords, ",") {
+		keyword = strings.TrimSpace(keyword)
+		if keyword != "" && !strings.Contains(text, keyword) {
+			missing = append(missing, keyword)
+		}
+	}
+	return missing
+}
+
+func (s *GeminiService) DetectAI(text string, sentences []textutil.Span) (*LLMDetection, error) {
+	numbered := strings.Builder{}
+	for i, sentence := range sentences {
+		numbered.WriteString(fmt.Sprintf("[%d] %s\n", i+1, sentence.Text))
+	}
+
 	prompt := fmt.Sprintf(`
 You are a forensic linguistic analysis tool. Your sole function is to analyze text for statistical markers and patterns indicative of generative AI authorship.
 
@@ -123,7 +244,8 @@ JSON Schema:
 {
   "overall_score": <int, 0-100, your confidence score that the text is AI-generated>,
   "analysis": "<string, a brief 1-2 sentence summary of your reasoning for the score>",
-  "red_flags": [<string, a list of specific phrases or sentences from the text that most strongly support your analysis>]
+  "red_flags": [<string, a list of specific phrases or sentences from the text that most strongly support your analysis>],
+  "sentence_scores": [<int, 0-100, one score per numbered sentence below, in order; exactly %d numbers>]
 }
 
 If no strong red flags are found, return an empty array for "red_flags".
@@ -132,9 +254,12 @@ Text for Forensic Analysis:
 ---
 %s
 ---
-`, text)
 
-	var result AIDetectionResult
+The same text split into numbered sentences:
+%s
+`, len(sentences), text, numbered.String())
+
+	var result LLMDetection
 	err := s.generateStructuredContent(prompt, &result)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get or parse AI detection result: %w", err)
@@ -177,45 +302,18 @@ Text to Audit:
 	return &result, nil
 }
 
-func (s *GeminiService) ResearchTopic(topic string) (*ResearchResult, error) {
-	prompt := fmt.Sprintf(`
-You are a professional research analyst tasked with generating a comprehensive and balanced executive briefing on a given topic. The briefing must be structured, objective, and multi-faceted.
-
-You MUST respond with ONLY a valid, minified JSON object. Do not include markdown or any text outside the JSON structure.
-
-The JSON schema for the executive briefing is as follows:
-{
-  "topic": "<string, the topic provided>",
-  "executive_summary": "<string, a concise 2-3 sentence overview suitable for a busy executive, stating the topic's significance>",
-  "historical_context": "<string, a brief explanation of the origin and evolution of the topic>",
-  "core_concepts": [<string, a list of the fundamental principles, technologies, or ideas that define the topic>],
-  "controversies_and_critiques": [<string, a list of the primary debates, opposing viewpoints, or criticisms related to the topic>],
-  "practical_applications": [<string, a list of real-world examples, case studies, or uses of the topic>]
-}
-
-Generate this executive briefing for the following topic.
-
-Topic:
----
-%s
----
-`, topic)
-
-	var result ResearchResult
-	err := s.generateStructuredContent(prompt, &result)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get or parse research result: %w", err)
-	}
-	return &result, nil
-}
-
 func (s *GeminiService) generateStructuredContent(prompt string, target interface{}) error {
 	// For structured data, we use a lower temperature for more predictable, deterministic output.
 	responseText, err := s.generateContent(prompt, 8192, 0.2)
 	if err != nil {
 		return fmt.Errorf("gemini API call failed: %w", err)
 	}
+	return decodeStructured(responseText, target)
+}
 
+// decodeStructured parses a JSON reply, tolerating a Markdown code fence
+// around it.
+func decodeStructured(responseText string, target interface{}) error {
 	cleanJSON := strings.TrimSpace(responseText)
 	if strings.HasPrefix(cleanJSON, "```json") {
 		cleanJSON = strings.TrimPrefix(cleanJSON, "```json")
@@ -236,6 +334,12 @@ func (s *GeminiService) generateStructuredContent(prompt string, target interfac
 }
 
 func (s *GeminiService) generateContent(prompt string, maxTokens int, temperature float32) (string, error) {
+	return s.generateChat([]GeminiContent{{Parts: []GeminiPart{{Text: prompt}}}}, maxTokens, temperature)
+}
+
+// generateChat sends a multi-turn conversation; contents alternate between
+// the "user" and "model" roles.
+func (s *GeminiService) generateChat(contents []GeminiContent, maxTokens int, temperature float32) (string, error) {
 	config := &GenerationConfig{
 		Temperature:     temperature,
 		MaxOutputTokens: maxTokens,
@@ -248,7 +352,7 @@ func (s *GeminiService) generateContent(prompt string, maxTokens int, temperatur
 	}
 
 	reqBody := GeminiRequest{
-		Contents:         []GeminiContent{{Parts: []GeminiPart{{Text: prompt}}}},
+		Contents:         contents,
 		GenerationConfig: config,
 		SafetySettings:   safetySettings,
 	}
@@ -0,0 +1,251 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/victor-butita/rephrase/internal/textutil"
+)
+
+const DefaultWorkspace = "default"
+
+type GlossaryEntry struct {
+	Term      string `json:"term"`
+	Preferred string `json:"preferred"`
+	Note      string `json:"note,omitempty"`
+}
+
+type Glossary struct {
+	Workspace string          `json:"workspace"`
+	Entries   []GlossaryEntry `json:"entries"`
+}
+
+// GlossaryIssue is a non-compliant term found in a text. Offsets are
+// character offsets into the text that was checked.
+type GlossaryIssue struct {
+	Term      string `json:"term"`
+	Preferred string `json:"preferred"`
+	Text      string `json:"text"`
+	Start     int    `json:"start"`
+	End       int    `json:"end"`
+}
+
+// GlossarySubstitution records one replacement made by Glossary.Apply.
+// Offsets are character offsets of the replacement in the text Apply
+// returns, which is the text the humanize and consistency responses carry.
+// Markdown and HTML rewrites are substituted segment by segment, and their
+// offsets are moved into the rendered document before they are reported.
+type GlossarySubstitution struct {
+	Original    string `json:"original"`
+	Replacement string `json:"replacement"`
+	Start       int    `json:"start"`
+	End         int    `json:"end"`
+}
+
+type GlossaryReport struct {
+	Workspace     string                 `json:"workspace"`
+	Issues        []GlossaryIssue        `json:"issues"`
+	Substitutions []GlossarySubstitution `json:"substitutions"`
+}
+
+func (e GlossaryEntry) validate() error {
+	if strings.TrimSpace(e.Term) == "" || strings.TrimSpace(e.Preferred) == "" {
+		return fmt.Errorf("glossary entries need both 'term' and 'preferred'")
+	}
+	if strings.EqualFold(strings.TrimSpace(e.Term), strings.TrimSpace(e.Preferred)) {
+		return fmt.Errorf("glossary term '%s' maps to itself", e.Term)
+	}
+	return nil
+}
+
+// PromptDirectives renders the glossary as a bullet list for prompts.
+func (g *Glossary) PromptDirectives() string {
+	var b strings.Builder
+	for _, e := range g.Entries {
+		b.WriteString(fmt.Sprintf("    - Write '%s', never '%s'.\n", e.Preferred, e.Term))
+	}
+	return b.String()
+}
+
+type glossaryHit struct {
+	entry GlossaryEntry
+	span  textutil.Span
+}
+
+// findHits locates every non-compliant term. Longer terms win over shorter
+// overlapping ones, and an occurrence that already reads as the preferred
+// form (e.g. term "sign" with preferred "sign in") is not a hit.
+func (g *Glossary) findHits(text string) []glossaryHit {
+	entries := append([]GlossaryEntry(nil), g.Entries...)
+	sort.SliceStable(entries, func(i, j int) bool { return len(entries[i].Term) > len(entries[j].Term) })
+
+	var hits []glossaryHit
+	taken := func(s textutil.Span) bool {
+		for _, h := range hits {
+			if s.Start < h.span.End && h.span.Start < s.End {
+				return true
+			}
+		}
+		return false
+	}
+	for _, e := range entries {
+		for _, m := range textutil.FindWord(text, e.Term) {
+			if taken(m) || hasPrefixFold(text[m.Start:], e.Preferred) {
+				continue
+			}
+			hits = append(hits, glossaryHit{entry: e, span: m})
+		}
+	}
+	sort.Slice(hits, func(i, j int) bool { return hits[i].span.Start < hits[j].span.Start })
+	return hits
+}
+
+// Check reports every non-compliant term in text without changing it.
+func (g *Glossary) Check(text string) []GlossaryIssue {
+	issues := []GlossaryIssue{}
+	for _, h := range g.findHits(text) {
+		issues = append(issues, GlossaryIssue{
+			Term:      h.entry.Term,
+			Preferred: h.entry.Preferred,
+			Text:      h.span.Text,
+			Start:     textutil.CharOffset(text, h.span.Start),
+			End:       textutil.CharOffset(text, h.span.End),
+		})
+	}
+	return issues
+}
+
+// Apply replaces every non-compliant term with its preferred form, keeping a
+// leading capital when the original occurrence had one. The substitutions
+// are located in the returned text.
+func (g *Glossary) Apply(text string) (string, []GlossarySubstitution) {
+	substitutions := []GlossarySubstitution{}
+	var out strings.Builder
+	last, chars := 0, 0
+	for _, h := range g.findHits(text) {
+		replacement := matchLeadingCase(h.span.Text, h.entry.Preferred)
+		out.WriteString(text[last:h.span.Start])
+		start := chars + utf8.RuneCountInString(text[last:h.span.Start])
+		out.WriteString(replacement)
+		chars = start + utf8.RuneCountInString(replacement)
+		last = h.span.End
+		substitutions = append(substitutions, GlossarySubstitution{
+			Original:    h.span.Text,
+			Replacement: replacement,
+			Start:       start,
+			End:         chars,
+		})
+	}
+	out.WriteString(text[last:])
+	return out.String(), substitutions
+}
+
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
+
+func matchLeadingCase(original, replacement string) string {
+	o, _ := utf8.DecodeRuneInString(original)
+	r, size := utf8.DecodeRuneInString(replacement)
+	if unicode.IsUpper(o) && unicode.IsLower(r) {
+		return string(unicode.ToUpper(r)) + replacement[size:]
+	}
+	return replacement
+}
+
+// GlossaryStore keeps a glossary for each workspace. Workspaces belong to
+// users, so every method takes the owner, the user's ID or "" without
+// accounts, along with the workspace's name.
+type GlossaryStore struct {
+	mu         sync.RWMutex
+	glossaries map[string][]GlossaryEntry
+}
+
+func NewGlossaryStore() *GlossaryStore {
+	return &GlossaryStore{glossaries: make(map[string][]GlossaryEntry)}
+}
+
+func normalizeWorkspace(workspace string) string {
+	workspace = strings.TrimSpace(workspace)
+	if workspace == "" {
+		return DefaultWorkspace
+	}
+	return workspace
+}
+
+// workspaceKey identifies owner's workspace; different users' workspaces are
+// separate even when they have the same name.
+func workspaceKey(owner, workspace string) string {
+	return owner + "\x00" + normalizeWorkspace(workspace)
+}
+
+// Get returns the workspace's glossary. The second result is false when the
+// workspace has no entries.
+func (s *GlossaryStore) Get(owner, workspace string) (*Glossary, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	entries := s.glossaries[workspaceKey(owner, workspace)]
+	return &Glossary{Workspace: normalizeWorkspace(workspace), Entries: append([]GlossaryEntry{}, entries...)}, len(entries) > 0
+}
+
+// Replace sets the workspace's full glossary.
+func (s *GlossaryStore) Replace(owner, workspace string, entries []GlossaryEntry) (*Glossary, error) {
+	for _, e := range entries {
+		if err := e.validate(); err != nil {
+			return nil, err
+		}
+	}
+	s.mu.Lock()
+	s.glossaries[workspaceKey(owner, workspace)] = append([]GlossaryEntry{}, entries...)
+	s.mu.Unlock()
+	glossary, _ := s.Get(owner, workspace)
+	return glossary, nil
+}
+
+// Upsert adds entries to the workspace's glossary, replacing any existing
+// entry for the same term.
+func (s *GlossaryStore) Upsert(owner, workspace string, entries []GlossaryEntry) (*Glossary, error) {
+	for _, e := range entries {
+		if err := e.validate(); err != nil {
+			return nil, err
+		}
+	}
+	key := workspaceKey(owner, workspace)
+	s.mu.Lock()
+	current := s.glossaries[key]
+	for _, e := range entries {
+		replaced := false
+		for i := range current {
+			if strings.EqualFold(current[i].Term, e.Term) {
+				current[i] = e
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			current = append(current, e)
+		}
+	}
+	s.glossaries[key] = current
+	s.mu.Unlock()
+	glossary, _ := s.Get(owner, workspace)
+	return glossary, nil
+}
+
+func (s *GlossaryStore) Remove(owner, workspace, term string) bool {
+	key := workspaceKey(owner, workspace)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current := s.glossaries[key]
+	for i := range current {
+		if strings.EqualFold(current[i].Term, term) {
+			s.glossaries[key] = append(current[:i], current[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
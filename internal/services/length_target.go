@@ -0,0 +1,144 @@
+package services
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/victor-butita/rephrase/internal/textutil"
+)
+
+const (
+	defaultLengthTolerance = 10
+	maxLengthRetries       = 1
+)
+
+// LengthTarget is the resolved word count a rewrite should land on, with an
+// accepted band of ±Tolerance percent around it.
+type LengthTarget struct {
+	Words     int `json:"target_words"`
+	Tolerance int `json:"tolerance_percent"`
+}
+
+type LengthReport struct {
+	LengthTarget
+	MinWords        int  `json:"min_words"`
+	MaxWords        int  `json:"max_words"`
+	OriginalWords   int  `json:"original_words"`
+	ActualWords     int  `json:"actual_words"`
+	WithinTolerance bool `json:"within_tolerance"`
+	Attempts        int  `json:"attempts"`
+}
+
+type lengthCorrection struct {
+	draft      string
+	draftWords int
+}
+
+// ResolveLengthTarget turns an absolute word count or a percentage of the
+// original text into a LengthTarget. Exactly one of words and percent may be
+// set; both zero means no target.
+func ResolveLengthTarget(original string, words, percent, tolerance int) (*LengthTarget, error) {
+	if words == 0 && percent == 0 {
+		return nil, nil
+	}
+	if words != 0 && percent != 0 {
+		return nil, fmt.Errorf("set either target_words or target_percent, not both")
+	}
+	if tolerance == 0 {
+		tolerance = defaultLengthTolerance
+	}
+	if tolerance < 1 || tolerance > 50 {
+		return nil, fmt.Errorf("length tolerance must be between 1 and 50 percent")
+	}
+	if percent != 0 {
+		if percent < 10 || percent > 500 {
+			return nil, fmt.Errorf("target_percent must be between 10 and 500")
+		}
+		words = int(math.Round(float64(textutil.WordCount(original)) * float64(percent) / 100))
+		if words < 1 {
+			words = 1
+		}
+	}
+	if words < 1 || words > 2000 {
+		return nil, fmt.Errorf("target_words must be between 1 and 2000")
+	}
+	return &LengthTarget{Words: words, Tolerance: tolerance}, nil
+}
+
+func (t *LengthTarget) Bounds() (int, int) {
+	slack := float64(t.Words) * float64(t.Tolerance) / 100
+	lo := int(math.Floor(float64(t.Words) - slack))
+	hi := int(math.Ceil(float64(t.Words) + slack))
+	if lo < 1 {
+		lo = 1
+	}
+	return lo, hi
+}
+
+// Measure fills in the report's actual length for text.
+func (r *LengthReport) Measure(text string) {
+	r.ActualWords = textutil.WordCount(text)
+	r.WithinTolerance = r.ActualWords >= r.MinWords && r.ActualWords <= r.MaxWords
+}
+
+// RephraseToLength rewrites text like RephraseText and then verifies the
+// result against opts.Length. When the draft misses the tolerance band it asks
+// the model for a corrective revision of that draft.
+func (s *GeminiService) RephraseToLength(text string, opts RephraseOptions) (string, *LengthReport, error) {
+	if opts.Length == nil {
+		return "", nil, fmt.Errorf("no length target set")
+	}
+	lo, hi := opts.Length.Bounds()
+	report := &LengthReport{
+		LengthTarget:  *opts.Length,
+		MinWords:      lo,
+		MaxWords:      hi,
+		OriginalWords: textutil.WordCount(text),
+	}
+
+	draft, err := s.RephraseText(text, opts)
+	if err != nil {
+		return "", nil, err
+	}
+	report.Attempts = 1
+	report.Measure(draft)
+
+	for i := 0; i < maxLengthRetries && !report.WithinTolerance; i++ {
+		opts.correction = &lengthCorrection{draft: draft, draftWords: report.ActualWords}
+		revised, err := s.RephraseText(text, opts)
+		if err != nil {
+			return "", nil, err
+		}
+		report.Attempts++
+		previous := report.ActualWords
+		report.Measure(revised)
+		// Keep whichever draft is closer to the target.
+		if abs(report.ActualWords-opts.Length.Words) <= abs(previous-opts.Length.Words) {
+			draft = revised
+		} else {
+			report.Measure(draft)
+		}
+	}
+	return draft, report, nil
+}
+
+func (t *LengthTarget) promptDirective() string {
+	lo, hi := t.Bounds()
+	return fmt.Sprintf("8.  **Length (Strict):** The rewritten text must be approximately %d words long, and in any case between %d and %d words. Shorten by cutting redundancy, or expand with relevant detail, examples, and elaboration — never with filler.\n", t.Words, lo, hi)
+}
+
+func (c *lengthCorrection) promptSection(t *LengthTarget) string {
+	lo, hi := t.Bounds()
+	direction := "expand"
+	if c.draftWords > hi {
+		direction = "shorten"
+	}
+	return fmt.Sprintf("\n# LENGTH CORRECTION:\nA previous draft (below) has %d words, which misses the required %d–%d word range. Revise it to %s it to about %d words while keeping every directive above.\n---\n%s\n---\n", c.draftWords, lo, hi, direction, t.Words, c.draft)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
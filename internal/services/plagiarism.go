@@ -0,0 +1,171 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strings"
+
+	"github.com/victor-butita/rephrase/internal/plagiarism"
+	"github.com/victor-butita/rephrase/internal/textutil"
+)
+
+const (
+	DefaultPlagiarismSources = "local_corpus,search,paraphrase"
+	llmSourceName            = "llm"
+	searchSourceName         = "search"
+)
+
+// EmbeddingConfig selects the embedder for paraphrase detection: a local
+// OpenAI-compatible endpoint when URL is set, or Gemini's embedding API when
+// Provider is "gemini".
+type EmbeddingConfig struct {
+	URL       string
+	Model     string
+	Provider  string
+	Threshold float64
+}
+
+// LLMSourceProvider adapts CheckPlagiarism to the plagiarism.SourceProvider
+// interface. Its matches are the model's recollection of its training data,
+// not verifiable passages, so they carry no source document.
+type LLMSourceProvider struct {
+	Gemini *GeminiService
+}
+
+func (p *LLMSourceProvider) Name() string { return llmSourceName }
+
+func (p *LLMSourceProvider) Find(text string) ([]plagiarism.Match, error) {
+	result, err := p.Gemini.CheckPlagiarism(text)
+	if err != nil {
+		return nil, err
+	}
+	var matches []plagiarism.Match
+	for _, m := range result.Matches {
+		match := plagiarism.Match{
+			Kind:          plagiarism.KindReported,
+			DocumentTitle: m.PotentialSource,
+			Text:          m.MatchingText,
+			Start:         -1,
+			End:           -1,
+			Words:         textutil.WordCount(m.MatchingText),
+			Confidence:    float64(m.Confidence),
+		}
+		if spans := textutil.FindWord(text, strings.TrimSpace(m.MatchingText)); len(spans) > 0 {
+			match.Start = textutil.CharOffset(text, spans[0].Start)
+			match.End = textutil.CharOffset(text, spans[0].End)
+		}
+		matches = append(matches, match)
+	}
+	return matches, nil
+}
+
+// NewPlagiarismChecker builds the plagiarism pipeline from a comma-separated
+// list of sources: local_corpus, search, paraphrase and llm. The search
+// source needs a search engine (elasticsearch, opensearch or meilisearch) and
+// the paraphrase source an embedder; each is skipped when not configured.
+func NewPlagiarismChecker(gs *GeminiService, corpus *plagiarism.Index, sourceSpec, engine string, search plagiarism.SearchConfig, embedding EmbeddingConfig) (*plagiarism.Checker, error) {
+	if sourceSpec == "" {
+		sourceSpec = DefaultPlagiarismSources
+	}
+	var providers []plagiarism.SourceProvider
+	for _, name := range strings.Split(sourceSpec, ",") {
+		switch name = strings.TrimSpace(name); name {
+		case "":
+		case plagiarism.LocalCorpusProvider:
+			providers = append(providers, corpus)
+		case llmSourceName:
+			if gs == nil {
+				return nil, fmt.Errorf("the llm plagiarism source needs a Gemini API key")
+			}
+			providers = append(providers, &LLMSourceProvider{Gemini: gs})
+		case plagiarism.ParaphraseProvider:
+			var embedder plagiarism.Embedder
+			switch {
+			case embedding.URL != "":
+				embedder = plagiarism.NewHTTPEmbedder(embedding.URL, embedding.Model)
+			case embedding.Provider == "gemini":
+				if gs == nil {
+					return nil, fmt.Errorf("gemini embeddings need a Gemini API key")
+				}
+				embedder = &GeminiEmbedder{Gemini: gs}
+			case embedding.Provider != "":
+				return nil, fmt.Errorf("unknown embedding provider %q", embedding.Provider)
+			default:
+				continue
+			}
+			providers = append(providers, plagiarism.NewParaphraseSource(corpus, embedder, embedding.Threshold))
+		case searchSourceName:
+			if search.URL == "" {
+				continue
+			}
+			if search.Index == "" {
+				return nil, fmt.Errorf("SEARCH_INDEX is required for the search plagiarism source")
+			}
+			switch engine {
+			case "elasticsearch", "opensearch":
+				providers = append(providers, plagiarism.NewSearchProvider(engine, plagiarism.NewElasticsearchEngine(search)))
+			case "meilisearch":
+				providers = append(providers, plagiarism.NewSearchProvider(engine, plagiarism.NewMeilisearchEngine(search)))
+			default:
+				return nil, fmt.Errorf("unknown search engine %q (expected elasticsearch, opensearch or meilisearch)", engine)
+			}
+		default:
+			return nil, fmt.Errorf("unknown plagiarism source %q", name)
+		}
+	}
+	if len(providers) == 0 {
+		return nil, fmt.Errorf("no plagiarism sources are configured")
+	}
+	checker := plagiarism.NewChecker(providers...)
+	log.Printf("Plagiarism sources: %s", strings.Join(checker.Names(), ","))
+	return checker, nil
+}
+
+// NewPlagiarismResult shapes a merged plagiarism report for the API. The
+// overall confidence is the share of the text covered by matches, weighted
+// by how confident the best covering match is; exact matches count fully.
+func NewPlagiarismResult(r *plagiarism.Report, documentsSearched int) *PlagiarismResult {
+	result := &PlagiarismResult{
+		IsSimilarityFound: len(r.Matches) > 0,
+		Matches:           []PlagiarismMatch{},
+		Sources:           r.Sources,
+		DocumentsSearched: documentsSearched,
+	}
+	if r.TotalWords > 0 {
+		result.Coverage = float32(math.Round(float64(r.MatchedWords)/float64(r.TotalWords)*1000) / 1000)
+	}
+	var best float64
+	for _, m := range r.Matches {
+		best = math.Max(best, m.Confidence)
+		match := PlagiarismMatch{
+			MatchingText:    m.Text,
+			PotentialSource: m.DocumentTitle,
+			Confidence:      float32(m.Confidence),
+			Kind:            m.Kind,
+			Similarity:      float32(m.Similarity),
+			Source:          m.Provider,
+			CorroboratedBy:  m.CorroboratedBy,
+		}
+		if m.SourceText != "" {
+			match.Location = &PlagiarismLocation{
+				DocumentID:   m.DocumentID,
+				DocumentDate: m.DocumentDate,
+				URL:          m.URL,
+				Start:        m.Start,
+				End:          m.End,
+				SourceText:   m.SourceText,
+				SourceStart:  m.SourceStart,
+				SourceEnd:    m.SourceEnd,
+				Words:        m.Words,
+			}
+		}
+		result.Matches = append(result.Matches, match)
+	}
+	if result.Coverage > 0 {
+		result.OverallConfidence = float32(math.Min(1, float64(result.Coverage)*best))
+	} else {
+		result.OverallConfidence = float32(best)
+	}
+	return result
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/victor-butita/rephrase/internal/textutil"
+)
+
+// ProofreadIssue is a single problem in the proofread text. Start and End are
+// character offsets into the original text.
+type ProofreadIssue struct {
+	Start       int      `json:"start"`
+	End         int      `json:"end"`
+	Text        string   `json:"text"`
+	Category    string   `json:"category"`
+	Message     string   `json:"message"`
+	Suggestions []string `json:"suggestions"`
+}
+
+type ProofreadResult struct {
+	Dialect string           `json:"dialect"`
+	Issues  []ProofreadIssue `json:"issues"`
+}
+
+// rawProofreadIssue is what the model returns. Models are unreliable at
+// counting characters, so it quotes the offending text and says which
+// occurrence it means; offsets are resolved here.
+type rawProofreadIssue struct {
+	Text        string   `json:"text"`
+	Occurrence  int      `json:"occurrence"`
+	Category    string   `json:"category"`
+	Message     string   `json:"message"`
+	Suggestions []string `json:"suggestions"`
+}
+
+var proofreadCategories = map[string]bool{"spelling": true, "grammar": true, "punctuation": true, "style": true}
+
+func (s *GeminiService) ProofreadText(text, dialect string) (*ProofreadResult, error) {
+	if dialect == "" {
+		dialect = "American English (Default)"
+	}
+	dialectName := strings.TrimSuffix(dialect, " (Default)")
+
+	prompt := fmt.Sprintf(`
+You are a meticulous proofreader. Find spelling, grammar, and punctuation errors, plus clear style problems (e.g. repeated words, wrong word choice). Do NOT rewrite the text and do NOT flag correct text.
+
+%sSpellings and usages that are correct in %s are NOT errors; spellings from other varieties of English ARE spelling errors.
+
+You MUST respond with ONLY a valid, minified JSON object. Do not include markdown or any text outside the JSON structure.
+
+JSON Schema:
+{
+  "issues": [
+    {
+      "text": "<string, the exact erroneous span copied verbatim from the input, as short as possible>",
+      "occurrence": <int, 1 for the first time this exact span appears in the input, 2 for the second, ...>,
+      "category": "<one of: spelling, grammar, punctuation, style>",
+      "message": "<string, a short explanation of the problem>",
+      "suggestions": [<string, replacement text for the span, best first>]
+    }
+  ]
+}
+
+If there are no problems, return an empty "issues" array.
+
+Text to Proofread:
+---
+%s
+---
+`, dialectRule(dialect), dialectName, text)
+
+	var raw struct {
+		Issues []rawProofreadIssue `json:"issues"`
+	}
+	if err := s.generateStructuredContent(prompt, &raw); err != nil {
+		return nil, fmt.Errorf("failed to get or parse proofread result: %w", err)
+	}
+	return &ProofreadResult{Dialect: dialect, Issues: resolveProofreadIssues(text, raw.Issues)}, nil
+}
+
+func resolveProofreadIssues(text string, raw []rawProofreadIssue) []ProofreadIssue {
+	issues := []ProofreadIssue{}
+	used := map[int]bool{}
+	for _, r := range raw {
+		if r.Text == "" {
+			continue
+		}
+		start := nthIndex(text, r.Text, r.Occurrence)
+		if start < 0 || used[start] {
+			continue
+		}
+		used[start] = true
+		category := strings.ToLower(r.Category)
+		if !proofreadCategories[category] {
+			category = "style"
+		}
+		if r.Suggestions == nil {
+			r.Suggestions = []string{}
+		}
+		end := start + len(r.Text)
+		issues = append(issues, ProofreadIssue{
+			Start:       textutil.CharOffset(text, start),
+			End:         textutil.CharOffset(text, end),
+			Text:        r.Text,
+			Category:    category,
+			Message:     r.Message,
+			Suggestions: r.Suggestions,
+		})
+	}
+	sort.Slice(issues, func(i, j int) bool { return issues[i].Start < issues[j].Start })
+	return issues
+}
+
+// nthIndex returns the byte offset of the n-th (1-based) occurrence of substr,
+// falling back to the first occurrence when n is out of range.
+func nthIndex(s, substr string, n int) int {
+	first := strings.Index(s, substr)
+	if first < 0 || n <= 1 {
+		return first
+	}
+	offset := first
+	for i := 1; i < n; i++ {
+		next := strings.Index(s[offset+len(substr):], substr)
+		if next < 0 {
+			return first
+		}
+		offset += len(substr) + next
+	}
+	return offset
+}
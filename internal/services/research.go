@@ -0,0 +1,563 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/victor-butita/rephrase/internal/research"
+)
+
+const (
+	DepthQuick    = "quick"
+	DepthStandard = "standard"
+	DepthDeep     = "deep"
+
+	maxResearchSections = 10
+)
+
+var (
+	// ErrInvalidResearch wraps problems with the research request itself,
+	// as opposed to failures while carrying it out.
+	ErrInvalidResearch = errors.New("invalid research request")
+	// ErrNoRelevantPassages is returned when a grounded briefing finds
+	// nothing in its collection to draw on.
+	ErrNoRelevantPassages = errors.New("no passages in the collection are relevant to this topic")
+)
+
+type researchDepth struct {
+	Items        string // claims per list section
+	Sentences    string // claims per paragraph section
+	Passages     int    // passages retrieved per query when grounded
+	SubQuestions int
+	Style        string
+}
+
+var researchDepths = map[string]researchDepth{
+	DepthQuick:    {Items: "2-3", Sentences: "1-2", Passages: 6, SubQuestions: 2, Style: "Be brief: only the most important points."},
+	DepthStandard: {Items: "3-5", Sentences: "2-3", Passages: 12, SubQuestions: 3, Style: "Be balanced and reasonably thorough."},
+	DepthDeep:     {Items: "6-10", Sentences: "4-6", Passages: 20, SubQuestions: 5, Style: "Be exhaustive: include specifics, figures, names, and nuance."},
+}
+
+func ValidResearchDepth(depth string) bool {
+	_, ok := researchDepths[depth]
+	return ok
+}
+
+// ResearchSection is one section of a briefing. Paragraph sections fill
+// Content; list sections fill Points.
+type ResearchSection struct {
+	Key     string   `json:"key"`
+	Title   string   `json:"title"`
+	Format  string   `json:"format"`
+	Content string   `json:"content,omitempty"`
+	Points  []string `json:"points,omitempty"`
+}
+
+const (
+	sectionParagraph = "paragraph"
+	sectionList      = "list"
+)
+
+type sectionSpec struct {
+	Key         string
+	Title       string
+	Format      string
+	Instruction string
+}
+
+// researchSections are the built-in sections, in the order they are
+// rendered. The first five make up the default briefing.
+var researchSections = []sectionSpec{
+	{"executive_summary", "Executive Summary", sectionParagraph, "a concise overview suitable for a busy executive, stating the topic's significance"},
+	{"historical_context", "Historical Context", sectionParagraph, "the origin and evolution of the topic"},
+	{"core_concepts", "Core Concepts", sectionList, "the fundamental principles, technologies, or ideas that define the topic"},
+	{"controversies_and_critiques", "Controversies & Critiques", sectionList, "the primary debates, opposing viewpoints, or criticisms"},
+	{"practical_applications", "Practical Applications", sectionList, "real-world examples, case studies, or uses"},
+	{"key_players", "Key Players", sectionList, "the most influential people, organizations, or groups and their roles"},
+	{"timeline", "Timeline", sectionList, "dated milestones in chronological order, each starting with its date"},
+	{"risks", "Risks", sectionList, "risks, threats, and failure modes, with their likely impact"},
+	{"open_questions", "Open Questions", sectionList, "unresolved questions and areas of active uncertainty"},
+}
+
+const defaultResearchSections = 5
+
+// resolveSections maps requested names to section specs. Names match a
+// built-in section by key or title; anything else becomes a custom list
+// section with that title.
+func resolveSections(names []string) ([]sectionSpec, error) {
+	if len(names) == 0 {
+		return researchSections[:defaultResearchSections], nil
+	}
+	var specs []sectionSpec
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if len([]rune(name)) > 60 {
+			return nil, fmt.Errorf("%w: section name '%s' is too long", ErrInvalidResearch, name)
+		}
+		spec := sectionSpec{Key: sectionKey(name), Title: name, Format: sectionList, Instruction: fmt.Sprintf("points about \"%s\" as it relates to the topic", name)}
+		for _, s := range researchSections {
+			if s.Key == spec.Key || strings.EqualFold(s.Title, name) {
+				spec = s
+				break
+			}
+		}
+		if spec.Key == "" || seen[spec.Key] {
+			continue
+		}
+		seen[spec.Key] = true
+		specs = append(specs, spec)
+	}
+	if len(specs) == 0 {
+		return researchSections[:defaultResearchSections], nil
+	}
+	if len(specs) > maxResearchSections {
+		return nil, fmt.Errorf("%w: at most %d sections can be requested", ErrInvalidResearch, maxResearchSections)
+	}
+	return specs, nil
+}
+
+// sectionKey turns a title such as "Key players" into "key_players".
+func sectionKey(title string) string {
+	var b strings.Builder
+	pending := false
+	for _, r := range strings.ToLower(title) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if pending && b.Len() > 0 {
+				b.WriteByte('_')
+			}
+			b.WriteRune(r)
+			pending = false
+		} else {
+			pending = true
+		}
+	}
+	return b.String()
+}
+
+type ResearchOptions struct {
+	Depth     string
+	Sections  []string
+	Decompose bool
+}
+
+// Retriever returns the passages most relevant to a query. Research is
+// grounded in a document collection when one is supplied.
+type Retriever func(query string, k int) ([]research.Hit, error)
+
+// ResearchClaim is one statement in a grounded briefing together with the
+// numbers of the Citations that support it.
+type ResearchClaim struct {
+	Section   string `json:"section"`
+	Text      string `json:"text"`
+	Citations []int  `json:"citations"`
+}
+
+// Citation points a claim back at the passage of an uploaded document it
+// was drawn from.
+type Citation struct {
+	Number        int    `json:"number"`
+	DocumentID    string `json:"document_id"`
+	DocumentTitle string `json:"document_title"`
+	PassageID     string `json:"passage_id"`
+	Passage       string `json:"passage"`
+}
+
+// ResearchSubQuestion is one part of a decomposed topic and what was found
+// for it before the findings were merged into the briefing.
+type ResearchSubQuestion struct {
+	Question string   `json:"question"`
+	Findings []string `json:"findings"`
+	Error    string   `json:"error,omitempty"`
+}
+
+type briefingClaim struct {
+	Text    string `json:"text"`
+	Sources []int  `json:"sources"`
+}
+
+// passagePool numbers retrieved passages so that every sub-question and the
+// final briefing cite the same passage with the same number.
+type passagePool struct {
+	mu     sync.Mutex
+	hits   []research.Hit
+	number map[string]int
+}
+
+func (p *passagePool) add(hits []research.Hit) []int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.number == nil {
+		p.number = make(map[string]int)
+	}
+	numbers := make([]int, 0, len(hits))
+	for _, h := range hits {
+		n, ok := p.number[h.Passage.ID]
+		if !ok {
+			p.hits = append(p.hits, h)
+			n = len(p.hits)
+			p.number[h.Passage.ID] = n
+		}
+		numbers = append(numbers, n)
+	}
+	return numbers
+}
+
+func (p *passagePool) format(numbers []int) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var b strings.Builder
+	for _, n := range numbers {
+		h := p.hits[n-1]
+		fmt.Fprintf(&b, "[%d] (%s)\n%s\n\n", n, h.DocumentTitle, h.Passage.Text)
+	}
+	return b.String()
+}
+
+// ResearchTopic writes a briefing on topic with the requested depth and
+// sections. With a retriever the briefing uses only retrieved passages:
+// every claim must cite at least one of them, and claims the model leaves
+// uncited, or cites with numbers it was not given, are dropped. With
+// Decompose the topic is split into sub-questions that are researched in
+// parallel and then merged.
+func (s *GeminiService) ResearchTopic(topic string, opts ResearchOptions, retrieve Retriever) (*ResearchResult, error) {
+	if opts.Depth == "" {
+		opts.Depth = DepthStandard
+	}
+	depth, ok := researchDepths[opts.Depth]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown research depth '%s'", ErrInvalidResearch, opts.Depth)
+	}
+	specs, err := resolveSections(opts.Sections)
+	if err != nil {
+		return nil, err
+	}
+	grounded := retrieve != nil
+	pool := &passagePool{}
+
+	var evidence string
+	var subQuestions []ResearchSubQuestion
+	if opts.Decompose {
+		subQuestions, err = s.researchSubQuestions(topic, depth, retrieve, pool)
+		if err != nil {
+			return nil, err
+		}
+		var b strings.Builder
+		for _, q := range subQuestions {
+			if q.Error != "" {
+				continue
+			}
+			fmt.Fprintf(&b, "Q: %s\n", q.Question)
+			for _, f := range q.Findings {
+				fmt.Fprintf(&b, "- %s\n", f)
+			}
+			b.WriteString("\n")
+		}
+		evidence = "Findings from researching the topic's sub-questions (merge, de-duplicate, and organize them; keep their source numbers):\n---\n" + b.String() + "---\n"
+		if grounded {
+			all := make([]int, len(pool.hits))
+			for i := range all {
+				all[i] = i + 1
+			}
+			evidence += "\nSources:\n---\n" + pool.format(all) + "---\n"
+		}
+	} else if grounded {
+		hits, err := retrieve(topic, depth.Passages)
+		if err != nil {
+			return nil, err
+		}
+		if len(hits) == 0 {
+			return nil, ErrNoRelevantPassages
+		}
+		evidence = "Sources:\n---\n" + pool.format(pool.add(hits)) + "---\n"
+	}
+
+	var schema, rules strings.Builder
+	schema.WriteString("{\n  \"topic\": \"<string, the topic provided>\",\n  \"sections\": {\n")
+	for i, spec := range specs {
+		count := depth.Items
+		if spec.Format == sectionParagraph {
+			count = depth.Sentences
+		}
+		fmt.Fprintf(&schema, "    \"%s\": [<claim, %s claims: %s>]", spec.Key, count, spec.Instruction)
+		if i < len(specs)-1 {
+			schema.WriteString(",")
+		}
+		schema.WriteString("\n")
+	}
+	schema.WriteString("  }\n}")
+	if grounded {
+		rules.WriteString("The briefing must be grounded ONLY in the numbered source passages provided. Do not use outside knowledge. Every claim must cite the passage numbers that support it. If the sources say nothing about a section, leave that section as an empty list.\n\nEach claim is an object: {\"text\": \"<string, one sentence>\", \"sources\": [<int, passage numbers>]}")
+	} else {
+		rules.WriteString("Each claim is an object: {\"text\": \"<string, one sentence>\"}")
+	}
+
+	prompt := fmt.Sprintf(`
+You are a professional research analyst tasked with generating a comprehensive and balanced executive briefing on a given topic. The briefing must be structured, objective, and multi-faceted. %s
+
+%s
+
+You MUST respond with ONLY a valid, minified JSON object. Do not include markdown or any text outside the JSON structure.
+
+The JSON schema for the executive briefing is as follows:
+%s
+
+%s
+Generate this executive briefing for the following topic.
+
+Topic:
+---
+%s
+---
+`, depth.Style, rules.String(), schema.String(), evidence, topic)
+
+	var briefing struct {
+		Topic    string                     `json:"topic"`
+		Sections map[string][]briefingClaim `json:"sections"`
+	}
+	if err := s.generateStructuredContent(prompt, &briefing); err != nil {
+		return nil, fmt.Errorf("failed to get or parse research result: %w", err)
+	}
+
+	result := &ResearchResult{
+		Topic:                     briefing.Topic,
+		CoreConcepts:              []string{},
+		ControversiesAndCritiques: []string{},
+		PracticalApplications:     []string{},
+		Depth:                     opts.Depth,
+		SubQuestions:              subQuestions,
+	}
+	if result.Topic == "" {
+		result.Topic = topic
+	}
+	var allowed map[int]bool
+	if grounded {
+		allowed = make(map[int]bool)
+		for i := range pool.hits {
+			allowed[i+1] = true
+		}
+	}
+	cited := make(map[int]bool)
+	for _, spec := range specs {
+		var texts []string
+		for _, c := range briefing.Sections[spec.Key] {
+			text, numbers := checkClaim(c, allowed)
+			if text == "" {
+				continue
+			}
+			for _, n := range numbers {
+				cited[n] = true
+			}
+			if grounded {
+				result.Claims = append(result.Claims, ResearchClaim{Section: spec.Key, Text: text, Citations: numbers})
+			}
+			texts = append(texts, text+citationMarkers(numbers))
+		}
+		section := ResearchSection{Key: spec.Key, Title: spec.Title, Format: spec.Format}
+		if spec.Format == sectionParagraph {
+			section.Content = strings.Join(texts, " ")
+		} else {
+			section.Points = texts
+		}
+		result.Sections = append(result.Sections, section)
+
+		switch spec.Key {
+		case "executive_summary":
+			result.ExecutiveSummary = section.Content
+		case "historical_context":
+			result.HistoricalContext = section.Content
+		case "core_concepts":
+			result.CoreConcepts = append(result.CoreConcepts, texts...)
+		case "controversies_and_critiques":
+			result.ControversiesAndCritiques = append(result.ControversiesAndCritiques, texts...)
+		case "practical_applications":
+			result.PracticalApplications = append(result.PracticalApplications, texts...)
+		}
+	}
+	if grounded && len(result.Claims) == 0 {
+		return nil, fmt.Errorf("the model did not produce any claims supported by the collection")
+	}
+
+	for i, h := range pool.hits {
+		if !cited[i+1] {
+			continue
+		}
+		result.Citations = append(result.Citations, Citation{
+			Number:        i + 1,
+			DocumentID:    h.Passage.DocumentID,
+			DocumentTitle: h.DocumentTitle,
+			PassageID:     h.Passage.ID,
+			Passage:       h.Passage.Text,
+		})
+	}
+	return result, nil
+}
+
+// researchSubQuestions splits the topic into sub-questions and researches
+// them concurrently. A sub-question that fails is reported with its error;
+// the call fails only if all of them do.
+func (s *GeminiService) researchSubQuestions(topic string, depth researchDepth, retrieve Retriever, pool *passagePool) ([]ResearchSubQuestion, error) {
+	prompt := fmt.Sprintf(`
+You are a research lead planning an investigation. Break the topic below into %d distinct, non-overlapping sub-questions that together cover it.
+
+You MUST respond with ONLY a valid, minified JSON object. Do not include markdown or any text outside the JSON structure.
+
+JSON Schema:
+{"questions": [<string>]}
+
+Topic:
+---
+%s
+---
+`, depth.SubQuestions, topic)
+	var plan struct {
+		Questions []string `json:"questions"`
+	}
+	if err := s.generateStructuredContent(prompt, &plan); err != nil {
+		return nil, fmt.Errorf("failed to decompose research topic: %w", err)
+	}
+	var questions []string
+	for _, q := range plan.Questions {
+		if q = strings.TrimSpace(q); q != "" && len(questions) < depth.SubQuestions {
+			questions = append(questions, q)
+		}
+	}
+	if len(questions) == 0 {
+		return nil, fmt.Errorf("failed to decompose research topic: no sub-questions returned")
+	}
+
+	results := make([]ResearchSubQuestion, len(questions))
+	errs := make([]error, len(questions))
+	var wg sync.WaitGroup
+	for i, q := range questions {
+		wg.Add(1)
+		go func(i int, q string) {
+			defer wg.Done()
+			findings, err := s.researchSubQuestion(topic, q, depth, retrieve, pool)
+			results[i] = ResearchSubQuestion{Question: q, Findings: findings}
+			if err != nil {
+				results[i].Error, errs[i] = err.Error(), err
+			}
+		}(i, q)
+	}
+	wg.Wait()
+
+	relevant := false
+	for i, r := range results {
+		if r.Error == "" {
+			return results, nil
+		}
+		relevant = relevant || !errors.Is(errs[i], ErrNoRelevantPassages)
+	}
+	if !relevant {
+		return nil, ErrNoRelevantPassages
+	}
+	return nil, fmt.Errorf("research failed for every sub-question: %s", results[0].Error)
+}
+
+func (s *GeminiService) researchSubQuestion(topic, question string, depth researchDepth, retrieve Retriever, pool *passagePool) ([]string, error) {
+	var allowed map[int]bool
+	rules := "Each finding is an object: {\"text\": \"<string, one sentence>\"}"
+	sources := ""
+	if retrieve != nil {
+		hits, err := retrieve(question, depth.Passages)
+		if err != nil {
+			return nil, err
+		}
+		if len(hits) == 0 {
+			return nil, ErrNoRelevantPassages
+		}
+		rules = "Use ONLY the numbered source passages below; do not use outside knowledge. Each finding is an object: {\"text\": \"<string, one sentence>\", \"sources\": [<int, passage numbers that support it>]}"
+		numbers := pool.add(hits)
+		allowed = make(map[int]bool, len(numbers))
+		for _, n := range numbers {
+			allowed[n] = true
+		}
+		sources = "Sources:\n---\n" + pool.format(numbers) + "---\n"
+	}
+
+	prompt := fmt.Sprintf(`
+You are a research analyst answering one sub-question of a larger investigation into "%s". %s Give %s findings.
+
+%s
+
+You MUST respond with ONLY a valid, minified JSON object. Do not include markdown or any text outside the JSON structure.
+
+JSON Schema:
+{"findings": [<finding>]}
+
+%s
+Sub-question:
+---
+%s
+---
+`, topic, depth.Style, depth.Items, rules, sources, question)
+
+	var answer struct {
+		Findings []briefingClaim `json:"findings"`
+	}
+	if err := s.generateStructuredContent(prompt, &answer); err != nil {
+		return nil, err
+	}
+	findings := []string{}
+	for _, f := range answer.Findings {
+		if text, numbers := checkClaim(f, allowed); text != "" {
+			findings = append(findings, text+citationMarkers(numbers))
+		}
+	}
+	if len(findings) == 0 {
+		return nil, fmt.Errorf("no supported findings")
+	}
+	return findings, nil
+}
+
+// checkClaim trims a claim and keeps the distinct source numbers it was
+// allowed to cite. When research is grounded (allowed is non-nil) a claim
+// without any is rejected by returning empty text.
+func checkClaim(c briefingClaim, allowed map[int]bool) (string, []int) {
+	text := strings.TrimSpace(c.Text)
+	if allowed == nil {
+		return text, nil
+	}
+	var numbers []int
+	seen := make(map[int]bool)
+	for _, n := range c.Sources {
+		if allowed[n] && !seen[n] {
+			seen[n] = true
+			numbers = append(numbers, n)
+		}
+	}
+	if text == "" || len(numbers) == 0 {
+		return "", nil
+	}
+	sort.Ints(numbers)
+	return text, numbers
+}
+
+func citationMarkers(numbers []int) string {
+	var b strings.Builder
+	for _, n := range numbers {
+		fmt.Fprintf(&b, " [%d]", n)
+	}
+	return b.String()
+}
+
+// UnmarshalJSON also accepts a bare string, which models sometimes return
+// for claims that carry no sources.
+func (c *briefingClaim) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		c.Text = text
+		return nil
+	}
+	type plain briefingClaim
+	return json.Unmarshal(data, (*plain)(c))
+}
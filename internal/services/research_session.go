@@ -0,0 +1,269 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/victor-butita/rephrase/internal/storeutil"
+)
+
+const (
+	maxResearchSessions = 200
+	// Only the most recent follow-up exchanges are replayed to the model;
+	// the briefing itself is always included.
+	maxFollowUpHistory = 10
+	followUpPassages   = 6
+)
+
+// ResearchTurn is one message of a research conversation.
+type ResearchTurn struct {
+	Role      string     `json:"role"`
+	Text      string     `json:"text"`
+	Citations []Citation `json:"citations,omitempty"`
+	At        time.Time  `json:"at"`
+
+	// prompt is what was actually sent for a user turn, including any
+	// passages retrieved for it.
+	prompt string
+}
+
+// ResearchSession keeps a briefing and the follow-up conversation about it.
+// Only its owner, the user who asked for the briefing, can see or continue
+// it.
+type ResearchSession struct {
+	ID           string          `json:"id"`
+	Owner        string          `json:"-"`
+	Topic        string          `json:"topic"`
+	CollectionID string          `json:"collection_id,omitempty"`
+	Result       *ResearchResult `json:"result"`
+	Turns        []ResearchTurn  `json:"turns"`
+	CreatedAt    time.Time       `json:"created_at"`
+	UpdatedAt    time.Time       `json:"updated_at"`
+
+	// mu guards the exported fields; ask serializes follow-ups, which are
+	// the only writers of the fields below.
+	mu  sync.Mutex
+	ask sync.Mutex
+	// citations holds every passage shown to the model in this session by
+	// number, so follow-ups keep citing the briefing's numbering.
+	citations map[int]Citation
+	passages  map[string]int
+}
+
+// Snapshot copies the session for serialization.
+func (rs *ResearchSession) Snapshot() *ResearchSession {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return &ResearchSession{
+		ID:           rs.ID,
+		Owner:        rs.Owner,
+		Topic:        rs.Topic,
+		CollectionID: rs.CollectionID,
+		Result:       rs.Result,
+		Turns:        append([]ResearchTurn{}, rs.Turns...),
+		CreatedAt:    rs.CreatedAt,
+		UpdatedAt:    rs.UpdatedAt,
+	}
+}
+
+type ResearchSessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]*ResearchSession
+}
+
+func NewResearchSessionStore() *ResearchSessionStore {
+	return &ResearchSessionStore{sessions: make(map[string]*ResearchSession)}
+}
+
+// Create opens a session of owner's for a finished briefing and records its
+// ID on the result. The least recently used session is dropped once the
+// store is full.
+func (st *ResearchSessionStore) Create(owner string, result *ResearchResult) *ResearchSession {
+	now := time.Now().UTC()
+	rs := &ResearchSession{
+		ID:           storeutil.NewID(),
+		Owner:        owner,
+		Topic:        result.Topic,
+		CollectionID: result.CollectionID,
+		Result:       result,
+		Turns:        []ResearchTurn{},
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		citations:    make(map[int]Citation),
+		passages:     make(map[string]int),
+	}
+	for _, c := range result.Citations {
+		rs.citations[c.Number] = c
+		rs.passages[c.PassageID] = c.Number
+	}
+	result.SessionID = rs.ID
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if len(st.sessions) >= maxResearchSessions {
+		var oldest *ResearchSession
+		for _, s := range st.sessions {
+			if oldest == nil || s.lastUsed().Before(oldest.lastUsed()) {
+				oldest = s
+			}
+		}
+		delete(st.sessions, oldest.ID)
+	}
+	st.sessions[rs.ID] = rs
+	return rs
+}
+
+// Get returns one of owner's sessions.
+func (st *ResearchSessionStore) Get(owner, id string) (*ResearchSession, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	rs, ok := st.sessions[id]
+	if !ok || rs.Owner != owner {
+		return nil, false
+	}
+	return rs, true
+}
+
+func (st *ResearchSessionStore) Delete(owner, id string) bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if rs, ok := st.sessions[id]; !ok || rs.Owner != owner {
+		return false
+	}
+	delete(st.sessions, id)
+	return true
+}
+
+func (rs *ResearchSession) lastUsed() time.Time {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return rs.UpdatedAt
+}
+
+var citationMarker = regexp.MustCompile(`\[(\d+)\]`)
+
+// AskFollowUp continues a research session with question and returns the
+// model's reply. The conversation is replayed as alternating user and model
+// turns, starting with the briefing. In a grounded session the passages
+// most relevant to the question are retrieved and the reply may cite them
+// alongside the briefing's sources. Follow-ups on one session run one at a
+// time.
+func (s *GeminiService) AskFollowUp(rs *ResearchSession, question string, retrieve Retriever) (*ResearchTurn, error) {
+	question = strings.TrimSpace(question)
+	if question == "" {
+		return nil, fmt.Errorf("%w: follow-up question cannot be empty", ErrInvalidResearch)
+	}
+	rs.ask.Lock()
+	defer rs.ask.Unlock()
+
+	grounded := retrieve != nil
+	prompt := question
+	if grounded {
+		hits, err := retrieve(question, followUpPassages)
+		if err != nil {
+			return nil, err
+		}
+		var b strings.Builder
+		for _, h := range hits {
+			n, ok := rs.passages[h.Passage.ID]
+			if !ok {
+				n = len(rs.citations) + 1
+				for rs.citations[n].Number != 0 {
+					n++
+				}
+				rs.passages[h.Passage.ID] = n
+				rs.citations[n] = Citation{Number: n, DocumentID: h.Passage.DocumentID, DocumentTitle: h.DocumentTitle, PassageID: h.Passage.ID, Passage: h.Passage.Text}
+			}
+			fmt.Fprintf(&b, "[%d] (%s)\n%s\n\n", n, h.DocumentTitle, h.Passage.Text)
+		}
+		if b.Len() > 0 {
+			prompt = fmt.Sprintf("Additional sources:\n---\n%s---\n\nQuestion: %s", b.String(), question)
+		}
+	}
+
+	contents := []GeminiContent{
+		{Role: "user", Parts: []GeminiPart{{Text: rs.sessionPreamble(grounded)}}},
+		{Role: "model", Parts: []GeminiPart{{Text: briefingMarkdown(rs.Result)}}},
+	}
+	history := rs.Turns
+	if len(history) > 2*maxFollowUpHistory {
+		history = history[len(history)-2*maxFollowUpHistory:]
+	}
+	for _, t := range history {
+		text := t.Text
+		if t.prompt != "" {
+			text = t.prompt
+		}
+		contents = append(contents, GeminiContent{Role: t.Role, Parts: []GeminiPart{{Text: text}}})
+	}
+	contents = append(contents, GeminiContent{Role: "user", Parts: []GeminiPart{{Text: prompt}}})
+
+	answer, err := s.generateChat(contents, 4096, 0.4)
+	if err != nil {
+		return nil, fmt.Errorf("failed to answer follow-up: %w", err)
+	}
+	answer = strings.TrimSpace(answer)
+
+	now := time.Now().UTC()
+	reply := ResearchTurn{Role: "model", Text: answer, At: now}
+	if grounded {
+		seen := make(map[int]bool)
+		for _, m := range citationMarker.FindAllStringSubmatch(answer, -1) {
+			n, _ := strconv.Atoi(m[1])
+			if c, ok := rs.citations[n]; ok && !seen[n] {
+				seen[n] = true
+				reply.Citations = append(reply.Citations, c)
+			}
+		}
+		sort.Slice(reply.Citations, func(i, j int) bool { return reply.Citations[i].Number < reply.Citations[j].Number })
+	}
+	rs.mu.Lock()
+	rs.Turns = append(rs.Turns, ResearchTurn{Role: "user", Text: question, At: now, prompt: prompt}, reply)
+	rs.UpdatedAt = now
+	rs.mu.Unlock()
+	return &reply, nil
+}
+
+func (rs *ResearchSession) sessionPreamble(grounded bool) string {
+	var b strings.Builder
+	b.WriteString("You are a professional research analyst. You wrote the executive briefing that follows on the topic below, and will now answer the user's follow-up questions about it: expanding on points, comparing with other subjects, or clarifying. Points in the briefing are numbered within each section, so \"controversy #2\" means the second point under Controversies & Critiques. Answer in concise Markdown.\n")
+	if grounded {
+		b.WriteString("\nThe briefing is grounded in the numbered source passages below, and follow-up questions may bring additional numbered sources. Use ONLY these sources, never outside knowledge, and cite the passage numbers that support each statement as [n]. If the sources do not cover the question, say so.\n")
+		numbers := make([]int, 0, len(rs.Result.Citations))
+		for _, c := range rs.Result.Citations {
+			numbers = append(numbers, c.Number)
+		}
+		sort.Ints(numbers)
+		b.WriteString("\nSources:\n---\n")
+		for _, n := range numbers {
+			c := rs.citations[n]
+			fmt.Fprintf(&b, "[%d] (%s)\n%s\n\n", n, c.DocumentTitle, c.Passage)
+		}
+		b.WriteString("---\n")
+	}
+	fmt.Fprintf(&b, "\nTopic:\n---\n%s\n---\n", rs.Topic)
+	return b.String()
+}
+
+// briefingMarkdown renders a briefing with numbered points, as the model is
+// shown it during follow-ups.
+func briefingMarkdown(r *ResearchResult) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Research on: %s\n", r.Topic)
+	for _, section := range r.Sections {
+		fmt.Fprintf(&b, "\n## %s\n", section.Title)
+		if section.Format == sectionParagraph {
+			b.WriteString(section.Content + "\n")
+			continue
+		}
+		for i, p := range section.Points {
+			fmt.Fprintf(&b, "%d. %s\n", i+1, p)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/victor-butita/rephrase/internal/markup"
+	"github.com/victor-butita/rephrase/internal/textutil"
+)
+
+// Segments are sent in batches small enough for the reply to fit comfortably
+// in one response.
+const structuredBatchWords = 1200
+
+// RephraseDocument rewrites the prose units of a Markdown or HTML document
+// with the same directives as RephraseText and returns them in order, ready
+// for doc.Render. Units without prose are returned unchanged. A length
+// target is applied proportionally to every unit and measured on the prose
+// alone; there is no corrective retry.
+func (s *GeminiService) RephraseDocument(doc *markup.Document, opts RephraseOptions) ([]string, *LengthReport, error) {
+	units := doc.Units()
+	rewritten := append([]string(nil), units...)
+
+	var report *LengthReport
+	lengthRule := ""
+	if opts.Length != nil {
+		lo, hi := opts.Length.Bounds()
+		report = &LengthReport{
+			LengthTarget:  *opts.Length,
+			MinWords:      lo,
+			MaxWords:      hi,
+			OriginalWords: textutil.WordCount(doc.Prose()),
+			Attempts:      1,
+		}
+		percent := 100
+		if report.OriginalWords > 0 {
+			percent = int(math.Round(float64(opts.Length.Words) * 100 / float64(report.OriginalWords)))
+		}
+		lengthRule = fmt.Sprintf("8.  **Length (Strict):** Make each segment about %d%% of its current length, so that all segments together come to about %d words (between %d and %d). Shorten by cutting redundancy, or expand with relevant detail — never with filler.\n", percent, opts.Length.Words, lo, hi)
+		opts.Length = nil
+	}
+
+	var batch []int
+	words := 0
+	send := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		segments := make([]string, len(batch))
+		for i, idx := range batch {
+			segments[i] = units[idx]
+		}
+		out, err := s.rephraseSegments(segments, doc.Format, opts, lengthRule)
+		if err != nil {
+			return err
+		}
+		for i, idx := range batch {
+			rewritten[idx] = out[i]
+		}
+		batch, words = nil, 0
+		return nil
+	}
+	for i, u := range units {
+		if !markup.HasProse(u) {
+			continue
+		}
+		n := textutil.WordCount(u)
+		if words+n > structuredBatchWords {
+			if err := send(); err != nil {
+				return nil, nil, err
+			}
+		}
+		batch = append(batch, i)
+		words += n
+	}
+	if err := send(); err != nil {
+		return nil, nil, err
+	}
+
+	if report != nil {
+		report.Measure(markup.Prose(rewritten))
+	}
+	return rewritten, report, nil
+}
+
+func (s *GeminiService) rephraseSegments(segments []string, format string, opts RephraseOptions, lengthRule string) ([]string, error) {
+	input, err := json.Marshal(segments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode segments: %w", err)
+	}
+	name := "Markdown"
+	if format == markup.FormatHTML {
+		name = "HTML"
+	}
+
+	promptBuilder := strings.Builder{}
+	promptBuilder.WriteString(fmt.Sprintf("You are a world-class senior editor and copywriter. The text below comes from a %s document and has been split into numbered segments: headings, paragraphs, list items and quotes. Perform a deep rewrite of every segment based on a strict set of directives. Your goal is not a simple rephrasing, but a professional transformation of the content.\n\n# DIRECTIVES:\n", name))
+	promptBuilder.WriteString(rephraseDirectives(opts))
+	promptBuilder.WriteString(lengthRule)
+	promptBuilder.WriteString("\n# SEGMENT RULES:\n")
+	promptBuilder.WriteString("- Placeholders such as {{1}} and {{/1}} stand for formatting, links, code and URLs. Every placeholder in a segment must appear in your rewrite of that segment exactly once, written exactly as given.\n")
+	promptBuilder.WriteString("- Text between {{n}} and {{/n}} is wrapped, e.g. as link text or emphasis. You may reword it and move the pair within the segment, but keep it around the words it belongs to and keep pairs nested as they are.\n")
+	promptBuilder.WriteString("- A lone {{n}} stands for content that must not change, such as a code span, image or URL. Keep it where it makes sense in the sentence.\n")
+	promptBuilder.WriteString(fmt.Sprintf("- Do not add %s markup of your own. A heading stays a short heading and a list item stays a single item.\n", name))
+	promptBuilder.WriteString(fmt.Sprintf("\n# OUTPUT FORMAT:\n- Respond with ONLY a JSON array of exactly %d strings: the rewritten segments, in the same order.\n\n", len(segments)))
+	promptBuilder.WriteString(fmt.Sprintf("# SEGMENTS TO REWRITE:\n%s", input))
+
+	responseText, err := s.generateContent(promptBuilder.String(), 8192, 0.7)
+	if err != nil {
+		return nil, fmt.Errorf("gemini API call failed: %w", err)
+	}
+	var out []string
+	if err := decodeStructured(responseText, &out); err != nil {
+		return nil, err
+	}
+	if len(out) != len(segments) {
+		return nil, fmt.Errorf("AI returned %d segments, expected %d", len(out), len(segments))
+	}
+	return out, nil
+}
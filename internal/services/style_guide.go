@@ -0,0 +1,351 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/victor-butita/rephrase/internal/storeutil"
+	"github.com/victor-butita/rephrase/internal/textutil"
+)
+
+const (
+	OxfordCommaRequired = "required"
+	OxfordCommaOmitted  = "omitted"
+)
+
+type BannedWord struct {
+	Word       string `json:"word"`
+	Suggestion string `json:"suggestion,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+type PreferredTerm struct {
+	Avoid  string `json:"avoid"`
+	Prefer string `json:"prefer"`
+}
+
+// StyleGuide is an organization's written style guide expressed as rules
+// that can be both fed to the model and checked deterministically. Everyone
+// can use a guide, but only its Owner, the user who uploaded it, and admins
+// may change or remove it.
+type StyleGuide struct {
+	ID                     string          `json:"id,omitempty"`
+	Owner                  string          `json:"-"`
+	Name                   string          `json:"name"`
+	BannedWords            []BannedWord    `json:"banned_words,omitempty"`
+	PreferredTerms         []PreferredTerm `json:"preferred_terms,omitempty"`
+	OxfordComma            string          `json:"oxford_comma,omitempty"`
+	MaxSentenceWords       int             `json:"max_sentence_words,omitempty"`
+	CapitalizedTerms       []string        `json:"capitalized_terms,omitempty"`
+	RequireSentenceCapital bool            `json:"require_sentence_capital,omitempty"`
+}
+
+type StyleViolation struct {
+	Rule       string `json:"rule"`
+	Message    string `json:"message"`
+	Text       string `json:"text"`
+	Start      int    `json:"start"`
+	End        int    `json:"end"`
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+type StyleReport struct {
+	GuideID    string           `json:"guide_id"`
+	GuideName  string           `json:"guide_name"`
+	Violations []StyleViolation `json:"violations"`
+}
+
+func (g *StyleGuide) Validate() error {
+	if strings.TrimSpace(g.Name) == "" {
+		return fmt.Errorf("style guide name is required")
+	}
+	switch g.OxfordComma {
+	case "", OxfordCommaRequired, OxfordCommaOmitted:
+	default:
+		return fmt.Errorf("oxford_comma must be %q, %q or empty", OxfordCommaRequired, OxfordCommaOmitted)
+	}
+	if g.MaxSentenceWords < 0 {
+		return fmt.Errorf("max_sentence_words cannot be negative")
+	}
+	for _, b := range g.BannedWords {
+		if strings.TrimSpace(b.Word) == "" {
+			return fmt.Errorf("banned words cannot be empty")
+		}
+	}
+	for _, p := range g.PreferredTerms {
+		if strings.TrimSpace(p.Avoid) == "" || strings.TrimSpace(p.Prefer) == "" {
+			return fmt.Errorf("preferred terms need both 'avoid' and 'prefer'")
+		}
+	}
+	return nil
+}
+
+// PromptDirectives renders the guide as a bullet list for the rewrite prompt.
+func (g *StyleGuide) PromptDirectives() string {
+	var b strings.Builder
+	for _, banned := range g.BannedWords {
+		if banned.Suggestion != "" {
+			b.WriteString(fmt.Sprintf("    - Never use the word '%s'; use '%s' instead.\n", banned.Word, banned.Suggestion))
+		} else {
+			b.WriteString(fmt.Sprintf("    - Never use the word '%s'.\n", banned.Word))
+		}
+	}
+	for _, term := range g.PreferredTerms {
+		b.WriteString(fmt.Sprintf("    - Write '%s', not '%s'.\n", term.Prefer, term.Avoid))
+	}
+	switch g.OxfordComma {
+	case OxfordCommaRequired:
+		b.WriteString("    - Always use the Oxford (serial) comma in lists of three or more items.\n")
+	case OxfordCommaOmitted:
+		b.WriteString("    - Never use the Oxford (serial) comma; omit the comma before the final 'and'/'or' in a list.\n")
+	}
+	if g.MaxSentenceWords > 0 {
+		b.WriteString(fmt.Sprintf("    - No sentence may exceed %d words.\n", g.MaxSentenceWords))
+	}
+	if len(g.CapitalizedTerms) > 0 {
+		b.WriteString(fmt.Sprintf("    - Always capitalize these terms exactly as shown: %s.\n", strings.Join(g.CapitalizedTerms, ", ")))
+	}
+	if g.RequireSentenceCapital {
+		b.WriteString("    - Every sentence must begin with a capital letter.\n")
+	}
+	return b.String()
+}
+
+var (
+	missingOxfordComma = regexp.MustCompile(`,\s+[^,.;:!?\n]+?\s+(and|or)\s+\S`)
+	presentOxfordComma = regexp.MustCompile(`,[^,.;:!?\n]+,\s+(and|or)\s+\S`)
+)
+
+// CheckStyle runs the deterministic post-check of a style guide over text and
+// returns every violation, ordered by position. Offsets are character offsets.
+func CheckStyle(text string, guide *StyleGuide) []StyleViolation {
+	violations := []StyleViolation{}
+	add := func(rule, message, suggestion string, start, end int) {
+		violations = append(violations, StyleViolation{
+			Rule:       rule,
+			Message:    message,
+			Text:       text[start:end],
+			Start:      textutil.CharOffset(text, start),
+			End:        textutil.CharOffset(text, end),
+			Suggestion: suggestion,
+		})
+	}
+
+	for _, banned := range guide.BannedWords {
+		for _, m := range textutil.FindWord(text, banned.Word) {
+			msg := fmt.Sprintf("'%s' is a banned word.", m.Text)
+			if banned.Reason != "" {
+				msg = fmt.Sprintf("'%s' is a banned word: %s", m.Text, banned.Reason)
+			}
+			add("banned_word", msg, banned.Suggestion, m.Start, m.End)
+		}
+	}
+
+	for _, term := range guide.PreferredTerms {
+		for _, m := range textutil.FindWord(text, term.Avoid) {
+			add("preferred_term", fmt.Sprintf("Use '%s' instead of '%s'.", term.Prefer, m.Text), term.Prefer, m.Start, m.End)
+		}
+	}
+
+	for _, sentence := range textutil.Sentences(text) {
+		switch guide.OxfordComma {
+		case OxfordCommaRequired:
+			for _, loc := range missingOxfordComma.FindAllStringSubmatchIndex(sentence.Text, -1) {
+				item := sentence.Text[loc[0]+1 : loc[2]]
+				if !looksLikeList(sentence.Text[:loc[0]], item, sentence.Text[loc[3]:]) {
+					continue
+				}
+				start := sentence.Start + loc[0] + 1 + len(strings.TrimRight(item, " \t"))
+				end := sentence.Start + loc[3]
+				add("oxford_comma", "Missing Oxford comma before the final list item.", ", "+sentence.Text[loc[2]:loc[3]], start, end)
+			}
+		case OxfordCommaOmitted:
+			for _, loc := range presentOxfordComma.FindAllStringSubmatchIndex(sentence.Text, -1) {
+				commaPos := strings.LastIndex(sentence.Text[:loc[2]], ",")
+				if !looksLikeList(sentence.Text[:loc[0]], sentence.Text[loc[0]+1:commaPos], sentence.Text[loc[3]:]) {
+					continue
+				}
+				start, end := sentence.Start+commaPos, sentence.Start+loc[3]
+				add("oxford_comma", "Omit the Oxford comma before the final list item.", " "+sentence.Text[loc[2]:loc[3]], start, end)
+			}
+		}
+
+		if guide.MaxSentenceWords > 0 {
+			if n := textutil.WordCount(sentence.Text); n > guide.MaxSentenceWords {
+				add("sentence_length", fmt.Sprintf("Sentence has %d words; the limit is %d.", n, guide.MaxSentenceWords), "", sentence.Start, sentence.End)
+			}
+		}
+
+		if guide.RequireSentenceCapital {
+			first, size := firstLetter(sentence.Text)
+			if size > 0 && unicode.IsLower(first.r) {
+				start := sentence.Start + first.offset
+				add("capitalization", "Sentence should begin with a capital letter.", string(unicode.ToUpper(first.r)), start, start+size)
+			}
+		}
+	}
+
+	for _, term := range guide.CapitalizedTerms {
+		for _, m := range textutil.FindWord(text, term) {
+			if m.Text != term {
+				add("capitalization", fmt.Sprintf("Capitalize as '%s'.", term), term, m.Start, m.End)
+			}
+		}
+	}
+
+	sort.SliceStable(violations, func(i, j int) bool { return violations[i].Start < violations[j].Start })
+	return violations
+}
+
+var introductoryWords = map[string]bool{
+	"however": true, "moreover": true, "also": true, "furthermore": true, "additionally": true,
+	"therefore": true, "yes": true, "no": true, "well": true, "first": true, "second": true,
+	"finally": true, "meanwhile": true, "instead": true, "still": true, "then": true, "so": true,
+}
+
+// Words that open a clause rather than a list item: subject pronouns and
+// subordinating conjunctions.
+var (
+	subjectPronouns = map[string]bool{
+		"i": true, "you": true, "he": true, "she": true, "it": true, "we": true, "they": true, "there": true,
+	}
+	subordinators = map[string]bool{
+		"when": true, "whenever": true, "while": true, "if": true, "because": true, "although": true,
+		"though": true, "since": true, "after": true, "before": true, "unless": true, "until": true,
+		"once": true, "as": true, "where": true, "wherever": true,
+	}
+)
+
+// looksLikeList decides whether the comma before "<item> and <final>"
+// belongs to a list such as "apples, pears and plums" rather than to a
+// sentence of clauses like "When we arrived, we ate and slept" or "Sadly, he
+// left, and she stayed". before is the sentence up to the comma ahead of
+// item. A list needs at least two items ahead of the conjunction: item, and
+// another that ends before; both, and final, must read as list items rather
+// than clauses.
+func looksLikeList(before, item, final string) bool {
+	if !isListItem(item) || opensClause(final) {
+		return false
+	}
+	// Earlier items that look like item are part of the list too; the
+	// segment before them holds its first item, after any lead-in.
+	segments := strings.Split(before, ",")
+	i := len(segments) - 1
+	for i > 0 && isListItem(segments[i]) {
+		i--
+	}
+	head := strings.Fields(segments[i])
+	if len(head) == 0 {
+		return false
+	}
+	first := strings.ToLower(strings.Trim(head[0], `"'(`))
+	if subordinators[first] {
+		return false
+	}
+	if len(head) == 1 {
+		// A lone sentence adverb, unless the items are adverbs too, as in
+		// "quickly, quietly and carefully".
+		itemWords := strings.Fields(item)
+		adverbs := strings.HasSuffix(strings.ToLower(itemWords[len(itemWords)-1]), "ly")
+		if introductoryWords[first] || (strings.HasSuffix(first, "ly") && !adverbs) {
+			return false
+		}
+	}
+	return true
+}
+
+// isListItem reports whether s is short and does not open a clause.
+func isListItem(s string) bool {
+	n := len(strings.Fields(s))
+	return n > 0 && n <= 4 && !opensClause(s) && !introductoryWords[strings.ToLower(strings.Fields(s)[0])]
+}
+
+// opensClause reports whether s starts with a subject pronoun or a
+// subordinating conjunction, looking past a leading adverb as in "then we".
+func opensClause(s string) bool {
+	words := strings.Fields(s)
+	for i := 0; i < len(words) && i < 2; i++ {
+		word := strings.ToLower(strings.Trim(words[i], `"'(,`))
+		if subjectPronouns[word] || subordinators[word] {
+			return true
+		}
+		if !introductoryWords[word] {
+			break
+		}
+	}
+	return false
+}
+
+type letterPos struct {
+	r      rune
+	offset int
+}
+
+func firstLetter(s string) (letterPos, int) {
+	for i, r := range s {
+		if unicode.IsLetter(r) {
+			return letterPos{r: r, offset: i}, utf8.RuneLen(r)
+		}
+		if unicode.IsDigit(r) {
+			return letterPos{}, 0
+		}
+	}
+	return letterPos{}, 0
+}
+
+type StyleGuideStore struct {
+	mu     sync.RWMutex
+	guides map[string]*StyleGuide
+}
+
+func NewStyleGuideStore() *StyleGuideStore {
+	return &StyleGuideStore{guides: make(map[string]*StyleGuide)}
+}
+
+// Save stores a guide, assigning an ID when it does not have one. Saving a
+// guide with an existing ID replaces it.
+func (s *StyleGuideStore) Save(guide *StyleGuide) (*StyleGuide, error) {
+	if err := guide.Validate(); err != nil {
+		return nil, err
+	}
+	if guide.ID == "" {
+		guide.ID = storeutil.NewID()
+	}
+	s.mu.Lock()
+	s.guides[guide.ID] = guide
+	s.mu.Unlock()
+	return guide, nil
+}
+
+func (s *StyleGuideStore) Get(id string) (*StyleGuide, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	guide, ok := s.guides[id]
+	return guide, ok
+}
+
+func (s *StyleGuideStore) List() []*StyleGuide {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	guides := make([]*StyleGuide, 0, len(s.guides))
+	for _, g := range s.guides {
+		guides = append(guides, g)
+	}
+	sort.Slice(guides, func(i, j int) bool { return guides[i].Name < guides[j].Name })
+	return guides
+}
+
+func (s *StyleGuideStore) Delete(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.guides[id]; !ok {
+		return false
+	}
+	delete(s.guides, id)
+	return true
+}
@@ -0,0 +1,50 @@
+package services
+
+import "testing"
+
+func TestCheckStyleOxfordComma(t *testing.T) {
+	tests := []struct {
+		name  string
+		mode  string
+		text  string
+		flags []string // the text of each reported violation
+	}{
+		{"required: list without serial comma", OxfordCommaRequired, "We bought apples, pears and plums.", []string{" and"}},
+		{"required: longer list", OxfordCommaRequired, "Bring tents, stoves, maps and water.", []string{" and"}},
+		{"required: list with or", OxfordCommaRequired, "Choose red, green or blue.", []string{" or"}},
+		{"required: verb list after a clause", OxfordCommaRequired, "When we arrived, we ate, drank and slept.", []string{" and"}},
+		{"required: list of adverbs", OxfordCommaRequired, "She worked quickly, quietly and carefully.", []string{" and"}},
+		{"required: list with serial comma", OxfordCommaRequired, "We bought apples, pears, and plums.", nil},
+		{"required: introductory clause", OxfordCommaRequired, "When we arrived, we ate and slept.", nil},
+		{"required: introductory clause before a pair", OxfordCommaRequired, "When we arrived, apples and pears were served.", nil},
+		{"required: sentence adverb", OxfordCommaRequired, "However, we ate and drank.", nil},
+		{"required: -ly sentence adverb", OxfordCommaRequired, "Sadly, the shop and the cafe closed.", nil},
+		{"required: two clauses", OxfordCommaRequired, "I called, she answered and we talked.", nil},
+		{"required: pair", OxfordCommaRequired, "Salt and pepper are on the table.", nil},
+
+		{"omitted: list with serial comma", OxfordCommaOmitted, "We bought apples, pears, and plums.", []string{", and"}},
+		{"omitted: list without serial comma", OxfordCommaOmitted, "We bought apples, pears and plums.", nil},
+		{"omitted: sentence adverb and clauses", OxfordCommaOmitted, "Sadly, he left, and she stayed.", nil},
+		{"omitted: introductory clause and clauses", OxfordCommaOmitted, "When it rained, we stayed in, and they went out.", nil},
+		{"omitted: conjunction before a clause", OxfordCommaOmitted, "We packed, loaded the car, and then we left.", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations := CheckStyle(tt.text, &StyleGuide{Name: "test", OxfordComma: tt.mode})
+			var got []string
+			for _, v := range violations {
+				if v.Rule == "oxford_comma" {
+					got = append(got, v.Text)
+				}
+			}
+			if len(got) != len(tt.flags) {
+				t.Fatalf("CheckStyle(%q) flagged %q, want %q", tt.text, got, tt.flags)
+			}
+			for i := range got {
+				if got[i] != tt.flags[i] {
+					t.Errorf("CheckStyle(%q) flagged %q, want %q", tt.text, got, tt.flags)
+				}
+			}
+		})
+	}
+}
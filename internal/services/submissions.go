@@ -0,0 +1,192 @@
+package services
+
+import (
+	"crypto/sha256"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/victor-butita/rephrase/internal/history"
+	"github.com/victor-butita/rephrase/internal/plagiarism"
+	"github.com/victor-butita/rephrase/internal/textutil"
+)
+
+const (
+	maxSubmissionsPerWorkspace = 500
+	submissionTitleWords       = 8
+	// HistoryProvider labels self-plagiarism matches.
+	HistoryProvider = "history"
+)
+
+// SubmissionStore keeps each workspace's previously submitted and saved
+// texts in its own plagiarism index, so writers can check new drafts against
+// their own earlier work. With a history database the texts are stored there
+// too, and the indexes are rebuilt from it at startup. Like glossaries,
+// workspaces are identified by their owner and name.
+type SubmissionStore struct {
+	mu      sync.Mutex
+	db      *history.Store
+	indexes map[string]*plagiarism.Index
+	seen    map[string]map[[32]byte]string
+}
+
+// NewSubmissionStore loads the submissions stored in db, which may be nil to
+// keep them in memory only.
+func NewSubmissionStore(db *history.Store) (*SubmissionStore, error) {
+	s := &SubmissionStore{db: db, indexes: make(map[string]*plagiarism.Index), seen: make(map[string]map[[32]byte]string)}
+	if db == nil {
+		return s, nil
+	}
+	subs, err := db.Submissions()
+	if err != nil {
+		return nil, err
+	}
+	for _, sub := range subs {
+		ws := workspaceKey(sub.UserID, sub.Workspace)
+		doc := s.index(ws).Put(plagiarism.Document{ID: sub.ID, Owner: sub.UserID, Title: sub.Title, Text: sub.Text, AddedAt: sub.CreatedAt})
+		s.seen[ws][submissionKey(sub.Text)] = doc.ID
+	}
+	return s, nil
+}
+
+func submissionKey(text string) [32]byte {
+	return sha256.Sum256([]byte(strings.TrimSpace(text)))
+}
+
+// Index returns the workspace's submission index, creating it if needed.
+func (s *SubmissionStore) Index(owner, workspace string) *plagiarism.Index {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.index(workspaceKey(owner, workspace))
+}
+
+// index returns the index of the workspace with key ws.
+func (s *SubmissionStore) index(ws string) *plagiarism.Index {
+	ix, ok := s.indexes[ws]
+	if !ok {
+		ix = plagiarism.NewNamedIndex(HistoryProvider)
+		s.indexes[ws] = ix
+		s.seen[ws] = make(map[[32]byte]string)
+	}
+	return ix
+}
+
+// Record stores text unless the workspace already has an identical copy. An
+// empty title is derived from the text's first words. The oldest texts are
+// dropped once a workspace exceeds its limit.
+func (s *SubmissionStore) Record(owner, workspace, title, text string) (*plagiarism.Document, error) {
+	ws := workspaceKey(owner, workspace)
+	key := submissionKey(text)
+	if title = strings.TrimSpace(title); title == "" {
+		title = Excerpt(text, submissionTitleWords)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ix := s.index(ws)
+	if id, ok := s.seen[ws][key]; ok {
+		if doc, ok := ix.Get(id); ok {
+			doc.Text = ""
+			return doc, nil
+		}
+	}
+	doc, err := ix.Add(owner, title, text)
+	if err != nil {
+		return nil, err
+	}
+	if s.db != nil {
+		sub := &history.Submission{ID: doc.ID, UserID: owner, Workspace: normalizeWorkspace(workspace), Title: doc.Title, Text: text, CreatedAt: doc.AddedAt}
+		if err := s.db.AddSubmission(sub); err != nil {
+			ix.Delete(doc.ID)
+			return nil, err
+		}
+	}
+	s.seen[ws][key] = doc.ID
+	if docs := ix.List(); len(docs) > maxSubmissionsPerWorkspace {
+		for _, old := range docs[maxSubmissionsPerWorkspace:] {
+			if _, err := s.forget(owner, ws, old.ID); err != nil {
+				log.Printf("Could not drop old submission %s: %v", old.ID, err)
+			}
+		}
+	}
+	return doc, nil
+}
+
+// Source returns the workspace's submissions as a plagiarism source for
+// checking text, along with how many documents it searches. Stored copies of
+// text itself are left out, so a text never matches itself however often it
+// has been submitted.
+func (s *SubmissionStore) Source(owner, workspace, text string) (plagiarism.SourceProvider, int) {
+	ws := workspaceKey(owner, workspace)
+	key := submissionKey(text)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ix := s.index(ws)
+	src := &excludingSource{Index: ix, exclude: s.seen[ws][key]}
+	n := ix.Len()
+	if _, ok := ix.Get(src.exclude); ok {
+		n--
+	}
+	return src, n
+}
+
+// excludingSource is an index that ignores one of its documents.
+type excludingSource struct {
+	*plagiarism.Index
+	exclude string
+}
+
+func (e *excludingSource) Find(text string) ([]plagiarism.Match, error) {
+	matches, err := e.Index.Find(text)
+	if err != nil || e.exclude == "" {
+		return matches, err
+	}
+	kept := matches[:0]
+	for _, m := range matches {
+		if m.DocumentID != e.exclude {
+			kept = append(kept, m)
+		}
+	}
+	return kept, nil
+}
+
+// Delete removes a text, reporting false if the workspace has no such text.
+func (s *SubmissionStore) Delete(owner, workspace, id string) (bool, error) {
+	ws := workspaceKey(owner, workspace)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.indexes[ws]; !ok {
+		return false, nil
+	}
+	return s.forget(owner, ws, id)
+}
+
+func (s *SubmissionStore) forget(owner, ws, id string) (bool, error) {
+	if _, ok := s.indexes[ws].Get(id); !ok {
+		return false, nil
+	}
+	if s.db != nil {
+		if err := s.db.DeleteSubmission(owner, id); err != nil {
+			return false, err
+		}
+	}
+	s.indexes[ws].Delete(id)
+	for key, docID := range s.seen[ws] {
+		if docID == id {
+			delete(s.seen[ws], key)
+		}
+	}
+	return true, nil
+}
+
+// Excerpt returns the first words of text, for use as a title.
+func Excerpt(text string, words int) string {
+	spans := textutil.Words(text)
+	if len(spans) == 0 {
+		return "Untitled"
+	}
+	if len(spans) <= words {
+		return text[spans[0].Start:spans[len(spans)-1].End]
+	}
+	return text[spans[0].Start:spans[words-1].End] + "…"
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	SummaryTLDR     = "tldr"
+	SummaryBullets  = "bullets"
+	SummaryAbstract = "abstract"
+	SummaryHeadline = "headline"
+)
+
+type SummaryResult struct {
+	Format  string   `json:"format"`
+	Summary string   `json:"summary"`
+	Points  []string `json:"points,omitempty"`
+}
+
+var summaryInstructions = map[string]string{
+	SummaryTLDR:     "A TL;DR: one or two plain sentences capturing the single most important takeaway. Put it in \"summary\" and leave \"points\" empty.",
+	SummaryBullets:  "A bullet-point summary: 3-7 short, parallel, self-contained key points in reading order. Put them in \"points\" and a one-sentence lead-in in \"summary\".",
+	SummaryAbstract: "An executive abstract: a single well-structured paragraph covering purpose, key findings, and implications, written for a busy decision-maker. Put it in \"summary\" and leave \"points\" empty.",
+	SummaryHeadline: "A headline: one punchy, accurate title of at most 12 words, with no trailing period. Put it in \"summary\" and leave \"points\" empty.",
+}
+
+func ValidSummaryFormat(format string) bool {
+	_, ok := summaryInstructions[format]
+	return ok
+}
+
+func (s *GeminiService) SummarizeText(text, format string, length *LengthTarget) (*SummaryResult, error) {
+	if format == "" {
+		format = SummaryTLDR
+	}
+	instruction, ok := summaryInstructions[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown summary format '%s'", format)
+	}
+
+	lengthRule := ""
+	if length != nil && format != SummaryHeadline {
+		lo, hi := length.Bounds()
+		lengthRule = fmt.Sprintf("\n- **Length:** The summary (all points combined, if any) must be about %d words, between %d and %d words.", length.Words, lo, hi)
+	}
+
+	prompt := fmt.Sprintf(`
+You are an expert editor who writes faithful, concise summaries. Never add facts, opinions, or claims that are not in the source text.
+
+# FORMAT:
+- %s%s
+
+You MUST respond with ONLY a valid, minified JSON object. Do not include markdown or any text outside the JSON structure.
+
+JSON Schema:
+{
+  "summary": "<string>",
+  "points": [<string>]
+}
+
+Text to Summarize:
+---
+%s
+---
+`, instruction, lengthRule, text)
+
+	var result SummaryResult
+	if err := s.generateStructuredContent(prompt, &result); err != nil {
+		return nil, fmt.Errorf("failed to get or parse summary result: %w", err)
+	}
+	result.Format = format
+	result.Summary = strings.TrimSpace(result.Summary)
+	return &result, nil
+}
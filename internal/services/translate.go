@@ -0,0 +1,76 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+)
+
+const AutoDetectLanguage = "auto"
+
+type TranslateOptions struct {
+	SourceLanguage string
+	TargetLanguage string
+	Tone           string
+	FreezeKeywords string
+	Glossary       *Glossary
+}
+
+type TranslationResult struct {
+	SourceLanguage  string   `json:"source_language"`
+	DetectedSource  bool     `json:"detected_source"`
+	TargetLanguage  string   `json:"target_language"`
+	Text            string   `json:"text"`
+	MissingKeywords []string `json:"missing_keywords"`
+}
+
+func (s *GeminiService) TranslateText(text string, opts TranslateOptions) (*TranslationResult, error) {
+	if strings.TrimSpace(opts.TargetLanguage) == "" {
+		return nil, fmt.Errorf("a target language is required")
+	}
+	detect := opts.SourceLanguage == "" || strings.EqualFold(opts.SourceLanguage, AutoDetectLanguage)
+
+	promptBuilder := strings.Builder{}
+	promptBuilder.WriteString("You are a professional translator and localization specialist. Translate the text below faithfully, preserving meaning, intent, formatting, and paragraph breaks. Produce natural, idiomatic text a native speaker would write, not a word-for-word rendering.\n\n# DIRECTIVES:\n")
+	if detect {
+		promptBuilder.WriteString("1.  **Source Language:** Identify the language (and regional variety, if clear) of the original text.\n")
+	} else {
+		promptBuilder.WriteString(fmt.Sprintf("1.  **Source Language:** The original text is written in %s.\n", opts.SourceLanguage))
+	}
+	promptBuilder.WriteString(fmt.Sprintf("2.  **Target Language & Dialect:** Translate into %s. Strictly follow that variety's spelling, grammar, vocabulary, and conventions (dates, numbers, punctuation).\n", opts.TargetLanguage))
+	if opts.Tone != "" {
+		promptBuilder.WriteString(fmt.Sprintf("3.  **Tone & Voice:** The translation must embody a '%s' tone.\n", opts.Tone))
+	} else {
+		promptBuilder.WriteString("3.  **Tone & Voice:** Keep the tone and register of the original.\n")
+	}
+	if rule := keywordIntegrityRule(opts.FreezeKeywords); rule != "" {
+		promptBuilder.WriteString("4.  " + rule + "    - Do NOT translate them.\n")
+	}
+	if opts.Glossary != nil {
+		promptBuilder.WriteString("5.  **Terminology:** Where the translation uses any of these terms, use the preferred form.\n")
+		promptBuilder.WriteString(opts.Glossary.PromptDirectives())
+	}
+
+	promptBuilder.WriteString(`
+You MUST respond with ONLY a valid, minified JSON object. Do not include markdown or any text outside the JSON structure.
+
+JSON Schema:
+{
+  "source_language": "<string, the English name of the source language, e.g. 'Spanish (Mexico)'>",
+  "text": "<string, the translated text>"
+}
+
+`)
+	promptBuilder.WriteString(fmt.Sprintf("Text to Translate:\n---\n%s\n---", text))
+
+	var result TranslationResult
+	if err := s.generateStructuredContent(promptBuilder.String(), &result); err != nil {
+		return nil, fmt.Errorf("failed to get or parse translation result: %w", err)
+	}
+	if !detect {
+		result.SourceLanguage = opts.SourceLanguage
+	}
+	result.DetectedSource = detect
+	result.TargetLanguage = opts.TargetLanguage
+	result.MissingKeywords = MissingFrozenKeywords(result.Text, opts.FreezeKeywords)
+	return &result, nil
+}
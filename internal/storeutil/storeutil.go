@@ -0,0 +1,88 @@
+// Package storeutil holds the helpers shared by the packages that store
+// data: schema migrations and row scanning for the SQLite databases, and the
+// random IDs every store hands out.
+package storeutil
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Migrate applies migrations in order. The database's user_version records
+// how many have run, so each runs once. name identifies the database in
+// errors.
+func Migrate(db *sql.DB, name string, migrations []string) error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("failed to read %s schema version: %w", name, err)
+	}
+	for ; version < len(migrations); version++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(migrations[version]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("%s migration %d failed: %w", name, version+1, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", version+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PrefixColumns qualifies each of a comma-separated list of columns, e.g.
+// with a table alias.
+func PrefixColumns(prefix, columns string) string {
+	fields := strings.Split(columns, ", ")
+	for i, f := range fields {
+		fields[i] = prefix + f
+	}
+	return strings.Join(fields, ", ")
+}
+
+// RowScanner is satisfied by both *sql.Row and *sql.Rows.
+type RowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// FromMillis converts a stored timestamp, in milliseconds, to UTC.
+func FromMillis(ms int64) time.Time {
+	return time.UnixMilli(ms).UTC()
+}
+
+// OptionalMillis is FromMillis for timestamps that may be unset, which are
+// stored as zero.
+func OptionalMillis(ms int64) *time.Time {
+	if ms == 0 {
+		return nil
+	}
+	t := FromMillis(ms)
+	return &t
+}
+
+// ToMillis is the inverse of OptionalMillis.
+func ToMillis(t *time.Time) int64 {
+	if t == nil {
+		return 0
+	}
+	return t.UnixMilli()
+}
+
+// NewID returns a random 16-character hex ID.
+func NewID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
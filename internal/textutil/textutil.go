@@ -0,0 +1,191 @@
+package textutil
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Span is a slice of a source text. Start and End are byte offsets into the
+// original string, so text[Start:End] == Text.
+type Span struct {
+	Text  string
+	Start int
+	End   int
+}
+
+var abbreviations = map[string]bool{
+	"mr": true, "mrs": true, "ms": true, "dr": true, "prof": true, "sr": true, "jr": true,
+	"st": true, "vs": true, "etc": true, "e.g": true, "i.e": true, "inc": true, "ltd": true,
+	"co": true, "corp": true, "approx": true, "no": true, "fig": true,
+}
+
+// Sentences splits text into sentences. A sentence ends at '.', '!' or '?'
+// followed by whitespace (or the end of the text) and at line breaks.
+// Common abbreviations such as "e.g." and "Mr." do not end a sentence.
+func Sentences(text string) []Span {
+	var spans []Span
+	start := 0
+	emit := func(end int) {
+		raw := text[start:end]
+		trimmed := strings.TrimSpace(raw)
+		if trimmed != "" {
+			offset := start + strings.Index(raw, trimmed)
+			spans = append(spans, Span{Text: trimmed, Start: offset, End: offset + len(trimmed)})
+		}
+		start = end
+	}
+
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		switch {
+		case r == '\n':
+			emit(i)
+		case r == '.' || r == '!' || r == '?':
+			end := i + size
+			// Swallow runs of terminators and closing quotes/brackets.
+			for end < len(text) {
+				next, nsize := utf8.DecodeRuneInString(text[end:])
+				if !strings.ContainsRune(".!?\"'”’)]", next) {
+					break
+				}
+				end += nsize
+			}
+			if end < len(text) {
+				next, _ := utf8.DecodeRuneInString(text[end:])
+				if !unicode.IsSpace(next) {
+					i = end
+					continue
+				}
+			}
+			if r == '.' && isAbbreviation(text[start:i]) {
+				i = end
+				continue
+			}
+			emit(end)
+			i = end
+			continue
+		}
+		i += size
+	}
+	emit(len(text))
+	return spans
+}
+
+func isAbbreviation(before string) bool {
+	fields := strings.Fields(before)
+	if len(fields) == 0 {
+		return false
+	}
+	last := strings.ToLower(strings.TrimLeft(fields[len(fields)-1], "(\"'"))
+	if abbreviations[last] {
+		return true
+	}
+	// Single initials such as "J. R. Tolkien".
+	return utf8.RuneCountInString(last) == 1 && unicode.IsLetter([]rune(last)[0])
+}
+
+// Words returns the word tokens of text. Apostrophes and hyphens inside a
+// word are kept, so "don't" and "well-known" are single tokens.
+func Words(text string) []Span {
+	var spans []Span
+	start := -1
+	for i, r := range text {
+		inWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if !inWord && start >= 0 && (r == '\'' || r == '’' || r == '-') {
+			next, _ := utf8.DecodeRuneInString(text[i+utf8.RuneLen(r):])
+			inWord = unicode.IsLetter(next) || unicode.IsDigit(next)
+		}
+		switch {
+		case inWord && start < 0:
+			start = i
+		case !inWord && start >= 0:
+			spans = append(spans, Span{Text: text[start:i], Start: start, End: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, Span{Text: text[start:], Start: start, End: len(text)})
+	}
+	return spans
+}
+
+// WordCount counts words the same way the API's input limit does.
+func WordCount(text string) int {
+	return len(strings.Fields(text))
+}
+
+// CharOffset converts a byte offset in text to a character (rune) offset,
+// which is what clients index strings by.
+func CharOffset(text string, byteOffset int) int {
+	return utf8.RuneCountInString(text[:byteOffset])
+}
+
+// FindWord finds phrase in text as a whole word or phrase, ignoring case.
+// It returns the byte offsets of every match.
+func FindWord(text, phrase string) []Span {
+	phrase = strings.TrimSpace(phrase)
+	if phrase == "" {
+		return nil
+	}
+	lowerText := strings.ToLower(text)
+	lowerPhrase := strings.ToLower(phrase)
+	if len(lowerText) != len(text) {
+		// Case folding changed byte lengths; fall back to a rune-aware scan.
+		return findWordFold(text, phrase)
+	}
+
+	var spans []Span
+	for from := 0; from < len(text); {
+		idx := strings.Index(lowerText[from:], lowerPhrase)
+		if idx < 0 {
+			break
+		}
+		start := from + idx
+		end := start + len(lowerPhrase)
+		if isBoundary(text, start, end) {
+			spans = append(spans, Span{Text: text[start:end], Start: start, End: end})
+			from = end
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(text[start:])
+		from = start + size
+	}
+	return spans
+}
+
+func findWordFold(text, phrase string) []Span {
+	var spans []Span
+	n := utf8.RuneCountInString(phrase)
+	for start := 0; start < len(text); {
+		end := start
+		for i := 0; i < n && end < len(text); i++ {
+			_, size := utf8.DecodeRuneInString(text[end:])
+			end += size
+		}
+		if strings.EqualFold(text[start:end], phrase) && isBoundary(text, start, end) {
+			spans = append(spans, Span{Text: text[start:end], Start: start, End: end})
+			start = end
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(text[start:])
+		start += size
+	}
+	return spans
+}
+
+func isBoundary(text string, start, end int) bool {
+	if start > 0 {
+		r, _ := utf8.DecodeLastRuneInString(text[:start])
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return false
+		}
+	}
+	if end < len(text) {
+		r, _ := utf8.DecodeRuneInString(text[end:])
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
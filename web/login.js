@@ -0,0 +1,48 @@
+document.addEventListener('DOMContentLoaded', () => {
+    const form = document.getElementById('loginForm');
+    const usernameInput = document.getElementById('username');
+    const passwordInput = document.getElementById('password');
+    const loginButton = document.getElementById('loginButton');
+    const errorMessage = document.getElementById('error-message');
+    const ssoSection = document.getElementById('ssoSection');
+    const ssoButton = document.getElementById('ssoButton');
+
+    // A failed single sign-on comes back here with the reason.
+    const ssoError = new URLSearchParams(window.location.search).get('error');
+    if (ssoError) {
+        errorMessage.textContent = ssoError;
+        window.history.replaceState(null, '', window.location.pathname);
+    }
+
+    fetch('/api/auth/providers')
+        .then(response => (response.ok ? response.json() : {}))
+        .then(providers => {
+            if (!providers.sso) return;
+            ssoButton.href = providers.sso.login_url;
+            ssoButton.textContent = `Sign in with ${providers.sso.name}`;
+            ssoSection.classList.remove('hidden');
+        })
+        .catch(() => {});
+
+    form.addEventListener('submit', async (e) => {
+        e.preventDefault();
+        errorMessage.textContent = '';
+        loginButton.disabled = true;
+        try {
+            const response = await fetch('/api/auth/login', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ username: usernameInput.value, password: passwordInput.value }),
+            });
+            const data = await response.json().catch(() => ({}));
+            if (!response.ok) throw new Error(data.error || 'Could not sign in.');
+            window.location.replace('/');
+        } catch (err) {
+            errorMessage.textContent = err.message;
+            passwordInput.value = '';
+            passwordInput.focus();
+        } finally {
+            loginButton.disabled = false;
+        }
+    });
+});
@@ -18,6 +18,59 @@ document.addEventListener('DOMContentLoaded', () => {
     const complexitySelect = document.getElementById('complexity');
     const dialectSelect = document.getElementById('dialect');
     const freezeKeywordsInput = document.getElementById('freezeKeywords');
+    const styleGuideSelect = document.getElementById('styleGuide');
+    const textFormatSelect = document.getElementById('textFormat');
+    const workspaceInput = document.getElementById('workspace');
+    const lengthValueInput = document.getElementById('lengthValue');
+    const lengthModeSelect = document.getElementById('lengthMode');
+    const summaryFormatSelect = document.getElementById('summaryFormat');
+    const summaryLengthInput = document.getElementById('summaryLength');
+    const sourceLanguageSelect = document.getElementById('sourceLanguage');
+    const targetLanguageInput = document.getElementById('targetLanguage');
+    const translateToneSelect = document.getElementById('translateTone');
+    const translateFreezeKeywordsInput = document.getElementById('translateFreezeKeywords');
+    const proofreadDialectSelect = document.getElementById('proofreadDialect');
+    const inputHighlights = document.getElementById('inputHighlights');
+    const detectionModeSelect = document.getElementById('detectionMode');
+    const plagiarismModeSelect = document.getElementById('plagiarismMode');
+    const corpusTitleInput = document.getElementById('corpusTitle');
+    const corpusStatus = document.getElementById('corpusStatus');
+    const addToCorpusButton = document.getElementById('addToCorpusButton');
+    const researchCollectionSelect = document.getElementById('researchCollection');
+    const researchDepthSelect = document.getElementById('researchDepth');
+    const researchDecomposeInput = document.getElementById('researchDecompose');
+    const researchSectionsEl = document.getElementById('researchSections');
+    const customSectionsInput = document.getElementById('customSections');
+    const collectionStatus = document.getElementById('collectionStatus');
+    const collectionNameInput = document.getElementById('collectionName');
+    const createCollectionButton = document.getElementById('createCollectionButton');
+    const collectionFilesInput = document.getElementById('collectionFiles');
+    const uploadDocumentsButton = document.getElementById('uploadDocumentsButton');
+    const exportBar = document.getElementById('exportBar');
+    const documentUploadInput = document.getElementById('documentUpload');
+    const uploadStatus = document.getElementById('uploadStatus');
+    const historyPanel = document.getElementById('historyPanel');
+    const historySearchInput = document.getElementById('historySearch');
+    const historyList = document.getElementById('historyList');
+    const workspaceList = document.getElementById('workspaceList');
+    const sharesPanel = document.getElementById('sharesPanel');
+    const shareList = document.getElementById('shareList');
+    const shareControls = document.getElementById('shareControls');
+    const shareExpirySelect = document.getElementById('shareExpiry');
+    const shareButton = document.getElementById('shareButton');
+    const accountMenu = document.getElementById('accountMenu');
+    const accountName = document.getElementById('accountName');
+    const logoutButton = document.getElementById('logoutButton');
+    // Pages served for a shared link carry their result and show nothing else.
+    const sharedResultEl = document.getElementById('shared-result');
+    const readOnly = sharedResultEl !== null;
+    let lastResult = null;
+    // The history revision the shown result is stored as, which is what a
+    // share link publishes.
+    let lastRevisionId = null;
+    // The revision the next humanize run rewrites, when one was picked from
+    // a document's revision tree.
+    let rewriteFrom = null;
     
     // --- WebSocket for Live Stats ---
     function connectWebSocket() {
@@ -30,6 +83,9 @@ document.addEventListener('DOMContentLoaded', () => {
                     document.getElementById('stat-detect').textContent = data.detect_count || 0;
                     document.getElementById('stat-plagiarize').textContent = data.plagiarize_count || 0;
                     document.getElementById('stat-research').textContent = data.research_count || 0;
+                    document.getElementById('stat-summarize').textContent = data.summarize_count || 0;
+                    document.getElementById('stat-translate').textContent = data.translate_count || 0;
+                    document.getElementById('stat-proofread').textContent = data.proofread_count || 0;
                 }
             } catch (e) {
                 console.error("Failed to parse websocket message:", e);
@@ -53,7 +109,17 @@ document.addEventListener('DOMContentLoaded', () => {
         });
     });
 
-    inputText.addEventListener('input', validateInputs);
+    inputText.addEventListener('input', () => {
+        clearInputHighlights();
+        validateInputs();
+    });
+    inputText.addEventListener('scroll', () => { inputHighlights.scrollTop = inputText.scrollTop; });
+    workspaceInput.value = localStorage.getItem('workspace') || '';
+    workspaceInput.addEventListener('change', () => {
+        localStorage.setItem('workspace', workspaceInput.value.trim());
+        loadHistory();
+        loadShares();
+    });
     processButton.addEventListener('click', handleProcessRequest);
 
     // --- Core Functions ---
@@ -69,15 +135,24 @@ document.addEventListener('DOMContentLoaded', () => {
     function updateUIForAction() {
         const actionText = {
             humanize: 'Humanizer', detect: 'AI Detector', 
-            plagiarize: 'Plagiarism Check', research: 'AI Research'
+            plagiarize: 'Plagiarism Check', research: 'AI Research',
+            consistency: 'Consistency Check', summarize: 'Summarizer',
+            translate: 'Translator', proofread: 'Proofreader'
         }[currentAction];
 
         pageTitle.textContent = actionText;
-        processButton.textContent = currentAction === 'research' ? 'Research Topic' : actionText;
+        processButton.textContent = { research: 'Research Topic', summarize: 'Summarize', translate: 'Translate', proofread: 'Proofread' }[currentAction] || actionText;
         
-        optionsWrapper.style.display = currentAction === 'humanize' ? 'block' : 'none';
+        const actionOptions = document.getElementById(`${currentAction}-options`);
+        optionsWrapper.querySelectorAll('.options-container').forEach(el => el.classList.toggle('hidden', el !== actionOptions));
+        optionsWrapper.style.display = actionOptions ? 'block' : 'none';
         wordCountEl.style.display = currentAction === 'research' ? 'none' : 'block';
         
+        clearInputHighlights();
+        lastResult = null;
+        lastRevisionId = null;
+        rewriteFrom = null;
+        exportBar.classList.add('hidden');
         resultsContainer.innerHTML = '';
         resultsContainer.appendChild(outputPlaceholder);
         outputPlaceholder.classList.remove('hidden');
@@ -100,14 +175,65 @@ document.addEventListener('DOMContentLoaded', () => {
             tone: toneSelect.value,
             complexity: complexitySelect.value,
             dialect: dialectSelect.value,
-            freeze_keywords: freezeKeywordsInput.value
+            freeze_keywords: freezeKeywordsInput.value,
+            style_guide_id: styleGuideSelect.value,
+            workspace: workspaceInput.value.trim()
         };
+        const lengthValue = parseInt(lengthValueInput.value, 10);
+        if (currentAction === 'humanize' && lengthValue > 0) {
+            requestBody[lengthModeSelect.value === 'percent' ? 'target_percent' : 'target_words'] = lengthValue;
+        }
+        if (currentAction === 'humanize' && textFormatSelect.value !== 'plain') {
+            requestBody.text_format = textFormatSelect.value;
+        }
+        if (currentAction === 'humanize' && rewriteFrom) {
+            requestBody.revision_id = rewriteFrom;
+        }
+        if (currentAction === 'translate') {
+            Object.assign(requestBody, {
+                source_language: sourceLanguageSelect.value,
+                target_language: targetLanguageInput.value.trim(),
+                tone: translateToneSelect.value,
+                freeze_keywords: translateFreezeKeywordsInput.value
+            });
+        }
+        if (currentAction === 'detect') {
+            requestBody.detection_mode = detectionModeSelect.value;
+        }
+        if (currentAction === 'plagiarize') {
+            requestBody.plagiarism_mode = plagiarismModeSelect.value;
+        }
+        if (currentAction === 'research') {
+            const sections = Array.from(researchSectionsEl.querySelectorAll('input:checked')).map(i => i.value)
+                .concat(customSectionsInput.value.split(',').map(t => t.trim()).filter(Boolean));
+            Object.assign(requestBody, {
+                research_depth: researchDepthSelect.value,
+                research_sections: sections,
+                decompose: researchDecomposeInput.checked
+            });
+            if (researchCollectionSelect.value) requestBody.collection_id = researchCollectionSelect.value;
+        }
+        if (currentAction === 'proofread') {
+            requestBody.dialect = proofreadDialectSelect.value;
+        }
+        if (currentAction === 'summarize') {
+            requestBody.summary_format = summaryFormatSelect.value;
+            const summaryLength = parseInt(summaryLengthInput.value, 10);
+            if (summaryLength > 0) requestBody.target_words = summaryLength;
+        }
 
         try {
             const response = await fetch('/api/process', { method: 'POST', headers: { 'Content-Type': 'application/json' }, body: JSON.stringify(requestBody) });
+            if (response.status === 401) {
+                window.location.replace('/login.html');
+                return;
+            }
             const data = await response.json();
             if (!response.ok) throw new Error(data.error || 'An unknown error occurred.');
-            renderResults(data);
+            rewriteFrom = null;
+            renderResults(data, requestBody.text, data.revision_id);
+            if (data.result_type === 'humanize' && data.document_id) loadRevisions(data.document_id, data.revision_id);
+            loadHistory();
         } catch (error) {
             errorMessage.textContent = error.message;
         } finally {
@@ -116,13 +242,41 @@ document.addEventListener('DOMContentLoaded', () => {
         }
     }
     
-    function renderResults(data) {
+    function renderResults(data, requestText, revisionId) {
+        lastResult = { result: data, text: requestText };
+        lastRevisionId = revisionId || null;
+        if (!readOnly) exportBar.classList.remove('hidden');
         resultsContainer.innerHTML = '';
+        clearInputHighlights();
         switch(data.result_type) {
             case 'humanize':
                 // **UI FIX:** Use a div, escape HTML, then replace newlines with <br> to preserve paragraphs without breaking layout.
                 const humanizedText = escapeHtml(data.text).replace(/\n/g, '<br>');
                 resultsContainer.innerHTML = `<div class="humanize-result">${humanizedText}</div>`;
+                if (data.format_warnings && data.format_warnings.length) {
+                    resultsContainer.innerHTML += `<div class="style-report"><h4>Kept as written (${escapeHtml(data.text_format)})</h4><ul>${data.format_warnings.map(w => `<li>${escapeHtml(w)}</li>`).join('')}</ul></div>`;
+                }
+                if (data.length_report) {
+                    resultsContainer.innerHTML += createLengthReportHTML(data.length_report);
+                }
+                if (data.style_report) {
+                    resultsContainer.innerHTML += createStyleReportHTML(data.style_report);
+                }
+                if (data.glossary_report) {
+                    resultsContainer.innerHTML += createGlossaryReportHTML(data.glossary_report);
+                }
+                break;
+            case 'summarize':
+                resultsContainer.innerHTML = createSummaryHTML(data.summary_result);
+                break;
+            case 'translate':
+                resultsContainer.innerHTML = createTranslationHTML(data.translation_result);
+                break;
+            case 'proofread':
+                renderProofreadResult(data.proofread_result, requestText);
+                break;
+            case 'consistency':
+                resultsContainer.innerHTML = `<div class="humanize-result">${escapeHtml(data.text).replace(/\n/g, '<br>')}</div>` + createGlossaryReportHTML(data.glossary_report);
                 break;
             case 'detect':
                 const detection = data.detection_result;
@@ -130,26 +284,22 @@ document.addEventListener('DOMContentLoaded', () => {
                     errorMessage.textContent = "Received an invalid detection result.";
                     return;
                 }
-                // **UI FIX:** This function now returns raw HTML without <pre> tags.
-                const highlightedHTML = createHighlightedTextHTML(inputText.value, detection.red_flags || []);
+                // Sentence offsets refer to the submitted text; fall back to matching red flags if it was edited since.
+                const highlightedHTML = detection.sentences && inputText.value === requestText
+                    ? createSentenceScoresHTML(requestText, detection.sentences)
+                    : createHighlightedTextHTML(inputText.value, detection.red_flags || []);
                 const gaugeHTML = createGaugeHTML(detection.overall_score);
                 resultsContainer.innerHTML = `<div class="detect-header">${gaugeHTML}<p class="ai-analysis">${escapeHtml(detection.analysis)}</p></div><div class="highlighted-text-container">${highlightedHTML}</div>`;
+                resultsContainer.innerHTML += createDetectorBreakdownHTML(detection.detectors || []);
                 break;
             case 'plagiarize':
                 // **LOGIC FIX:** This function correctly processes the new structured object.
                 resultsContainer.innerHTML = createPlagiarismReportHTML(data.plagiarism_result);
+                highlightPlagiarismOverlaps(data.plagiarism_result, requestText);
                 break;
             case 'research':
-                // **LOGIC FIX:** This function correctly builds markdown from the structured object.
-                const researchData = data.research_result;
-                let markdownString = `## Research on: ${researchData.topic}\n\n`;
-                markdownString += `### Executive Summary\n${researchData.executive_summary}\n\n`;
-                markdownString += `### Historical Context\n${researchData.historical_context}\n\n`;
-                markdownString += `### Core Concepts\n` + researchData.core_concepts.map(c => `- ${c}`).join('\n') + `\n\n`;
-                markdownString += `### Controversies & Critiques\n` + researchData.controversies_and_critiques.map(c => `- ${c}`).join('\n') + `\n\n`;
-                markdownString += `### Practical Applications\n` + researchData.practical_applications.map(c => `- ${c}`).join('\n');
-                const researchHTML = marked.parse(markdownString);
-                resultsContainer.innerHTML = `<div class="research-result">${researchHTML}</div>`;
+                resultsContainer.innerHTML = createResearchHTML(data.research_result);
+                if (!readOnly) attachFollowUp(data.research_result);
                 break;
         }
     }
@@ -158,6 +308,26 @@ document.addEventListener('DOMContentLoaded', () => {
         return unsafe.replace(/&/g, "&amp;").replace(/</g, "&lt;").replace(/>/g, "&gt;").replace(/"/g, "&quot;").replace(/'/g, "&#039;");
     }
 
+    // Markdown comes from the model, and through shared links from other
+    // people, so the HTML it renders to is sanitized and its links may only
+    // lead to web pages or within the page. Without the sanitizer the text
+    // is shown as is.
+    function renderMarkdown(markdown) {
+        if (typeof DOMPurify === 'undefined') return `<p>${escapeHtml(markdown).replace(/\n/g, '<br>')}</p>`;
+        return DOMPurify.sanitize(marked.parse(markdown), { ALLOWED_URI_REGEXP: /^(?:https?:|#)/i });
+    }
+
+    // Returns url resolved against this page if it is an http(s) URL, and ''
+    // for anything else, such as javascript: URLs.
+    function safeUrl(url) {
+        try {
+            const parsed = new URL(url, location.origin);
+            return parsed.protocol === 'http:' || parsed.protocol === 'https:' ? parsed.href : '';
+        } catch (e) {
+            return '';
+        }
+    }
+
     // **UI FIX:** This helper no longer wraps the output in <pre> tags.
     function createHighlightedTextHTML(originalText, sentencesToHighlight) {
         let highlightedText = escapeHtml(originalText);
@@ -171,6 +341,42 @@ document.addEventListener('DOMContentLoaded', () => {
         return highlightedText.replace(/\n/g, '<br>');
     }
 
+    function createSentenceScoresHTML(text, sentences) {
+        const chars = Array.from(text);
+        let html = '';
+        let last = 0;
+        sentences.forEach(sentence => {
+            html += escapeHtml(chars.slice(last, sentence.start).join(''));
+            const breakdown = Object.entries(sentence.detectors || {}).map(([name, score]) => `${name}: ${Math.round(score)}%`).join(', ');
+            const level = sentence.score > 65 ? 'high' : sentence.score > 35 ? 'medium' : 'low';
+            html += `<span class="sentence-score sentence-${level}" title="${Math.round(sentence.score)}% AI likelihood${breakdown ? ` (${escapeHtml(breakdown)})` : ''}">${escapeHtml(chars.slice(sentence.start, sentence.end).join(''))}</span>`;
+            last = sentence.end;
+        });
+        html += escapeHtml(chars.slice(last).join(''));
+        return html.replace(/\n/g, '<br>');
+    }
+
+    function createDetectorBreakdownHTML(detectors) {
+        const rows = detectors.map(d => {
+            const score = d.score === null || d.score === undefined ? `<span class="style-rule">failed</span>` : `${Math.round(d.score)}%`;
+            return `<tr><td>${escapeHtml(d.name)} <small>(weight ${d.weight})</small></td><td>${score}</td></tr>`;
+        }).join('');
+        let html = `<div class="style-report"><h4>Detectors</h4><table class="feature-table">${rows}</table>`;
+        const heuristic = detectors.find(d => d.name === 'heuristic' && d.details);
+        if (heuristic) {
+            const f = heuristic.details.features;
+            const featureRows = [
+                ['Burstiness', f.burstiness], ['Sentence length variance', f.sentence_length_variance],
+                ['Sentence length CV', f.sentence_length_cv], ['Type-token ratio', f.type_token_ratio],
+                ['Function-word divergence', f.function_word_divergence], ['Repeated trigram rate', f.repeated_ngram_rate],
+                ['Transition phrases / sentence', f.transition_density]
+            ].map(([label, value]) => `<tr><td>${label}</td><td>${value}</td></tr>`).join('');
+            const reliability = heuristic.details.reliable ? '' : ' <small>(short text, low reliability)</small>';
+            html += `<h4>Statistical features${reliability}</h4><table class="feature-table">${featureRows}</table>`;
+        }
+        return html + '</div>';
+    }
+
     function createGaugeHTML(score) {
         let scoreMessage = `Low AI Likelihood`;
         let color = 'var(--green)';
@@ -179,19 +385,39 @@ document.addEventListener('DOMContentLoaded', () => {
         return `<div class="ai-score-container"><div class="ai-score-circle" style="--score-color: ${color}; --score-percent: ${score}"><span>${score}%</span></div><p class="ai-score-text">${scoreMessage}</p></div>`;
     }
     
-    // **LOGIC FIX:** This function correctly processes the structured plagiarism object from the backend.
     function createPlagiarismReportHTML(report) {
+        const failed = ((report && report.sources) || []).filter(src => src.error).map(src => escapeHtml(src.name));
+        const searched = `Searched ${(report && report.documents_searched) || 0} reference document(s).` + (failed.length ? ` Unavailable sources: ${failed.join(', ')}.` : '');
         if (!report || !report.is_similarity_found) {
-            return `<div class="plagiarism-unique"><h3>No Significant Plagiarism Found</h3><p>The provided text appears to be unique based on our analysis.</p><p class="confidence-score">Overall Confidence: ${((report.overall_confidence || 0) * 100).toFixed(0)}%</p></div>`;
+            return `<div class="plagiarism-unique"><h3>No Matching Passages Found</h3><p>No passage of this text appears in the reference corpus.</p><p class="confidence-score">${searched}</p></div>`;
         }
 
-        let html = `<h3>Potential Matches Found</h3><p class="confidence-score">Overall Confidence: ${(report.overall_confidence * 100).toFixed(0)}%</p><ul class="plagiarism-list">`;
+        let html = `<h3>Matching Passages Found</h3><p class="confidence-score">${(report.coverage * 100).toFixed(0)}% of the text matches the reference corpus. ${searched}</p><ul class="plagiarism-list">`;
         report.matches.forEach(match => {
+            const loc = match.location;
+            const sourceURL = loc && loc.url ? safeUrl(loc.url) : '';
+            const sourceName = sourceURL
+                ? `<a href="${escapeHtml(sourceURL)}" target="_blank" rel="noopener">${escapeHtml(match.potential_source)}</a>`
+                : escapeHtml(match.potential_source);
+            const where = loc
+                ? `<div class="plagiarism-location">${match.kind === 'paraphrased' ? `Source sentence: "${escapeHtml(loc.source_text)}"<br>` : ''}Your text chars ${loc.start}&ndash;${loc.end} &middot; source chars ${loc.source_start}&ndash;${loc.source_end} &middot; ${loc.words} words${loc.document_id ? ` &middot; document <code>${escapeHtml(loc.document_id)}</code>` : ''}</div>`
+                : '<div class="plagiarism-location">Unverified: reported by the model without a source passage.</div>';
+            const original = loc && loc.document_date && loc.url && loc.url.startsWith('/api/submissions')
+                ? `<div class="plagiarism-location">Originally submitted ${escapeHtml(new Date(loc.document_date).toLocaleString())} &middot; <a href="#" class="view-original" data-url="${escapeHtml(loc.url)}" data-start="${loc.source_start}" data-end="${loc.source_end}">View original</a></div><div class="original-document hidden"></div>`
+                : '';
+            const foundBy = [match.source, ...(match.corroborated_by || [])].filter(Boolean).map(escapeHtml).join(', ');
+            const kind = match.kind === 'paraphrased'
+                ? `<span class="match-kind match-paraphrased">Paraphrased &middot; ${(match.similarity * 100).toFixed(0)}% similar</span>`
+                : match.kind === 'exact' ? '<span class="match-kind match-exact">Exact</span>' : '';
             html += `<li>
+                ${kind}
                 <p class="plagiarism-text">"${escapeHtml(match.matching_text)}"</p>
                 <div class="plagiarism-source">
-                    <strong>Possible Source:</strong> ${escapeHtml(match.potential_source)}<br>
-                    <strong>Confidence:</strong> ${(match.confidence * 100).toFixed(0)}%
+                    <strong>Source:</strong> ${sourceName}<br>
+                    <strong>Confidence:</strong> ${(match.confidence * 100).toFixed(0)}%<br>
+                    <strong>Found by:</strong> ${foundBy}
+                    ${where}
+                    ${original}
                 </div>
             </li>`;
         });
@@ -199,7 +425,740 @@ document.addEventListener('DOMContentLoaded', () => {
         return html;
     }
 
+    // Matched passages are shaded on the textarea backdrop, like proofread
+    // issues, as long as the text has not been edited since the check.
+    function highlightPlagiarismOverlaps(report, requestText) {
+        if (!report || inputText.value !== requestText) return;
+        const spans = (report.matches || [])
+            .map(m => m.location)
+            .filter(loc => loc && loc.start >= 0)
+            .sort((a, b) => a.start - b.start);
+        if (spans.length === 0) return;
+        const chars = Array.from(requestText);
+        let html = '';
+        let last = 0;
+        spans.forEach(span => {
+            if (span.end <= last) return;
+            const start = Math.max(span.start, last);
+            html += escapeHtml(chars.slice(last, start).join(''));
+            html += `<mark class="plagiarism-overlap">${escapeHtml(chars.slice(start, span.end).join(''))}</mark>`;
+            last = span.end;
+        });
+        html += escapeHtml(chars.slice(last).join(''));
+        inputHighlights.innerHTML = html + '\n';
+        inputHighlights.scrollTop = inputText.scrollTop;
+    }
+
+    async function showOriginalDocument(link) {
+        const container = link.closest('li').querySelector('.original-document');
+        if (!container.classList.contains('hidden')) {
+            container.classList.add('hidden');
+            return;
+        }
+        try {
+            const response = await fetch(link.dataset.url);
+            const doc = await response.json();
+            if (!response.ok) throw new Error(doc.error || 'Could not load the original text.');
+            const chars = Array.from(doc.text);
+            const start = Number(link.dataset.start), end = Number(link.dataset.end);
+            container.innerHTML = `<h4>${escapeHtml(doc.title)}</h4><p>${escapeHtml(chars.slice(0, start).join(''))}<mark>${escapeHtml(chars.slice(start, end).join(''))}</mark>${escapeHtml(chars.slice(end).join(''))}</p>`;
+            container.classList.remove('hidden');
+        } catch (e) {
+            errorMessage.textContent = e.message;
+        }
+    }
+
+    resultsContainer.addEventListener('click', (e) => {
+        const link = e.target.closest('.view-original');
+        if (!link) return;
+        e.preventDefault();
+        showOriginalDocument(link);
+    });
+
+    function createStyleReportHTML(report) {
+        const violations = report.violations || [];
+        if (violations.length === 0) {
+            return `<div class="style-report"><h4>${escapeHtml(report.guide_name)}</h4><p class="style-clean">No remaining style guide violations.</p></div>`;
+        }
+        const items = violations.map(v => {
+            const suggestion = v.suggestion ? ` &rarr; <strong>${escapeHtml(v.suggestion)}</strong>` : '';
+            return `<li><span class="style-rule">${escapeHtml(v.rule.replace(/_/g, ' '))}</span> "${escapeHtml(v.text)}"${suggestion}<br><small>${escapeHtml(v.message)} (chars ${v.start}&ndash;${v.end})</small></li>`;
+        }).join('');
+        return `<div class="style-report"><h4>${escapeHtml(report.guide_name)}: ${violations.length} remaining violation(s)</h4><ul>${items}</ul></div>`;
+    }
+
+    // Proofread issues carry character offsets into the submitted text. They are
+    // drawn as underlines on a backdrop that mirrors the textarea.
+    function renderProofreadResult(result, requestText) {
+        const issues = result.issues || [];
+        if (issues.length === 0) {
+            resultsContainer.innerHTML = `<div class="plagiarism-unique"><h3>No Issues Found</h3><p>No spelling, grammar, or punctuation problems for ${escapeHtml(result.dialect)}.</p></div>`;
+            return;
+        }
+        if (inputText.value === requestText) {
+            const chars = Array.from(requestText);
+            let html = '';
+            let last = 0;
+            issues.forEach((issue, i) => {
+                if (issue.start < last) return;
+                html += escapeHtml(chars.slice(last, issue.start).join(''));
+                html += `<mark class="proof-${issue.category}" data-issue="${i}">${escapeHtml(chars.slice(issue.start, issue.end).join(''))}</mark>`;
+                last = issue.end;
+            });
+            html += escapeHtml(chars.slice(last).join(''));
+            inputHighlights.innerHTML = html + '\n';
+            inputHighlights.scrollTop = inputText.scrollTop;
+        }
+        const items = issues.map((issue, i) => {
+            const buttons = readOnly ? '' : (issue.suggestions || []).map(s => `<button class="suggestion-btn" data-issue="${i}" data-suggestion="${escapeHtml(s)}">${escapeHtml(s)}</button>`).join('');
+            return `<li class="proof-item proof-item-${issue.category}"><span class="style-rule">${escapeHtml(issue.category)}</span> "${escapeHtml(issue.text)}"<br><small>${escapeHtml(issue.message)}</small><div>${buttons}</div></li>`;
+        }).join('');
+        resultsContainer.innerHTML = `<div class="style-report proofread-report"><h4>${issues.length} issue(s) found</h4><ul>${items}</ul></div>`;
+        resultsContainer.querySelectorAll('.suggestion-btn').forEach(btn => {
+            btn.addEventListener('click', () => applySuggestion(issues[btn.dataset.issue], btn.dataset.suggestion, requestText));
+        });
+    }
+
+    function applySuggestion(issue, suggestion, requestText) {
+        if (inputText.value !== requestText) {
+            errorMessage.textContent = 'The text has changed since it was proofread. Run the check again.';
+            return;
+        }
+        const chars = Array.from(inputText.value);
+        inputText.value = chars.slice(0, issue.start).join('') + suggestion + chars.slice(issue.end).join('');
+        clearInputHighlights();
+        validateInputs();
+        errorMessage.textContent = 'Suggestion applied. Run the check again to refresh the remaining issues.';
+    }
+
+    function clearInputHighlights() {
+        inputHighlights.innerHTML = '';
+    }
+
+    function createTranslationHTML(translation) {
+        const source = translation.detected_source ? `Detected ${escapeHtml(translation.source_language)}` : escapeHtml(translation.source_language);
+        let warning = '';
+        if (translation.missing_keywords && translation.missing_keywords.length > 0) {
+            warning = `<div class="style-report"><span class="style-rule">Frozen keywords missing</span> ${translation.missing_keywords.map(escapeHtml).join(', ')}</div>`;
+        }
+        return `<div class="translation-meta">${source} &rarr; ${escapeHtml(translation.target_language)}</div><div class="humanize-result">${escapeHtml(translation.text).replace(/\n/g, '<br>')}</div>${warning}`;
+    }
+
+    function createSummaryHTML(summary) {
+        const formatLabel = { tldr: 'TL;DR', bullets: 'Key Points', abstract: 'Executive Abstract', headline: 'Headline' }[summary.format] || 'Summary';
+        let body = summary.format === 'headline'
+            ? `<h2 class="summary-headline">${escapeHtml(summary.summary)}</h2>`
+            : `<p>${escapeHtml(summary.summary)}</p>`;
+        if (summary.points && summary.points.length > 0) {
+            body += `<ul>${summary.points.map(p => `<li>${escapeHtml(p)}</li>`).join('')}</ul>`;
+        }
+        return `<div class="research-result"><h3>${formatLabel}</h3>${body}</div>`;
+    }
+
+    function createLengthReportHTML(report) {
+        const status = report.within_tolerance
+            ? `<span class="style-clean">On target</span>`
+            : `<span class="style-rule">Outside tolerance</span>`;
+        return `<div class="style-report"><h4>Length: ${report.actual_words} words ${status}</h4><small>Target ${report.target_words} words (${report.min_words}&ndash;${report.max_words}), original ${report.original_words} words, ${report.attempts} attempt(s).</small></div>`;
+    }
+
+    function createGlossaryReportHTML(report) {
+        const issues = report.issues || [];
+        const substitutions = report.substitutions || [];
+        if (issues.length === 0 && substitutions.length === 0) {
+            return `<div class="style-report"><h4>Glossary (${escapeHtml(report.workspace)})</h4><p class="style-clean">All terminology matches the glossary.</p></div>`;
+        }
+        const issueItems = issues.map(i => `<li>"${escapeHtml(i.text)}" should be <strong>${escapeHtml(i.preferred)}</strong> <small>(chars ${i.start}&ndash;${i.end})</small></li>`).join('');
+        const substitutionItems = substitutions.map(s => {
+            const where = s.start >= 0 ? ` <small>(chars ${s.start}&ndash;${s.end})</small>` : '';
+            return `<li>"${escapeHtml(s.original)}" &rarr; <strong>${escapeHtml(s.replacement)}</strong>${where}</li>`;
+        }).join('');
+        return `<div class="style-report"><h4>Glossary (${escapeHtml(report.workspace)})</h4>
+            <p><span class="style-rule">Non-compliant terms</span> ${issues.length}</p><ul>${issueItems}</ul>
+            <p><span class="style-rule">Substitutions made</span> ${substitutions.length}</p><ul>${substitutionItems}</ul></div>`;
+    }
+
+    async function loadStyleGuides() {
+        try {
+            const response = await fetch('/api/style-guides');
+            if (!response.ok) return;
+            const guides = await response.json();
+            guides.forEach(guide => {
+                const option = document.createElement('option');
+                option.value = guide.id;
+                option.textContent = guide.name;
+                styleGuideSelect.appendChild(option);
+            });
+        } catch (e) {
+            console.error("Failed to load style guides:", e);
+        }
+    }
+
+    async function loadCorpusStatus() {
+        try {
+            const response = await fetch('/api/corpus');
+            if (!response.ok) return;
+            const docs = await response.json();
+            corpusStatus.textContent = docs.length === 0
+                ? 'No reference documents yet.'
+                : `${docs.length} reference document(s) indexed.`;
+        } catch (e) {
+            console.error("Failed to load corpus:", e);
+        }
+    }
+
+    async function addToCorpus() {
+        errorMessage.textContent = '';
+        try {
+            const response = await fetch('/api/corpus', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ title: corpusTitleInput.value, text: inputText.value }),
+            });
+            const data = await response.json();
+            if (!response.ok) throw new Error(data.error || 'Could not add the document.');
+            corpusTitleInput.value = '';
+            loadCorpusStatus();
+        } catch (e) {
+            errorMessage.textContent = e.message;
+        }
+    }
+
+    addToCorpusButton.addEventListener('click', addToCorpus);
+
+    function createResearchHTML(research) {
+        let markdownString = `## Research on: ${research.topic}\n\n`;
+        (research.sections || []).forEach(section => {
+            markdownString += `### ${section.title}\n`;
+            if (section.format === 'paragraph') {
+                markdownString += `${section.content || '_Nothing found._'}\n\n`;
+            } else {
+                const points = section.points || [];
+                markdownString += (points.length ? points.map(p => `- ${p}`).join('\n') : '_Nothing found._') + '\n\n';
+            }
+        });
+        const researchHTML = renderMarkdown(linkCitations(markdownString, research.citations));
+        let subQuestionsHTML = '';
+        if (research.sub_questions && research.sub_questions.length) {
+            const items = research.sub_questions.map(q => `
+                <li><strong>${escapeHtml(q.question)}</strong>${q.error
+                    ? ` <em>(${escapeHtml(q.error)})</em>`
+                    : `<ul>${q.findings.map(f => `<li>${linkCitations(escapeHtml(f), research.citations)}</li>`).join('')}</ul>`}</li>`).join('');
+            subQuestionsHTML = `<details class="sub-questions"><summary>Sub-questions researched (${research.sub_questions.length})</summary><ol>${items}</ol></details>`;
+        }
+        return `<div class="research-result">${researchHTML}${subQuestionsHTML}${createCitationsHTML(research.citations)}</div>`;
+    }
+
+    // Adds a follow-up box under a briefing; answers continue the research session.
+    function attachFollowUp(research) {
+        if (!research.session_id) return;
+        const container = resultsContainer.querySelector('.research-result');
+        container.insertAdjacentHTML('beforeend', `
+            <div class="research-followup">
+                <h3>Follow-up Questions</h3>
+                <div class="followup-thread"></div>
+                <div class="followup-form">
+                    <input type="text" class="followup-input" placeholder="e.g. Expand on controversy #2, or compare with...">
+                    <button class="btn btn-secondary followup-button" type="button">Ask</button>
+                </div>
+            </div>`);
+        const thread = container.querySelector('.followup-thread');
+        const input = container.querySelector('.followup-input');
+        const button = container.querySelector('.followup-button');
+        const ask = async () => {
+            const question = input.value.trim();
+            if (!question) return;
+            button.disabled = true;
+            errorMessage.textContent = '';
+            thread.insertAdjacentHTML('beforeend', `<div class="followup-question">${escapeHtml(question)}</div>`);
+            try {
+                const response = await fetch(`/api/research/sessions?id=${encodeURIComponent(research.session_id)}`, {
+                    method: 'POST',
+                    headers: { 'Content-Type': 'application/json' },
+                    body: JSON.stringify({ question }),
+                });
+                const turn = await response.json();
+                if (!response.ok) throw new Error(turn.error || 'Could not answer the follow-up.');
+                input.value = '';
+                const citations = turn.citations || [];
+                thread.insertAdjacentHTML('beforeend', `<div class="followup-answer">${renderMarkdown(linkCitations(turn.text, citations))}${createCitationsHTML(citations)}</div>`);
+            } catch (e) {
+                thread.lastElementChild.remove();
+                errorMessage.textContent = e.message;
+            } finally {
+                button.disabled = false;
+            }
+        };
+        button.addEventListener('click', ask);
+        input.addEventListener('keydown', e => { if (e.key === 'Enter') ask(); });
+    }
+
+    // Turns the [n] markers of a grounded briefing into links to its sources.
+    function linkCitations(markdown, citations) {
+        if (!citations || citations.length === 0) return markdown;
+        const numbers = new Set(citations.map(c => c.number));
+        return markdown.replace(/\[(\d+)\]/g, (marker, n) => numbers.has(Number(n))
+            ? `<sup class="citation"><a href="#citation-${n}">[${n}]</a></sup>`
+            : marker);
+    }
+
+    function createCitationsHTML(citations) {
+        if (!citations || citations.length === 0) return '';
+        const items = citations.map(c => `
+            <li id="citation-${c.number}">
+                <strong>[${c.number}] ${escapeHtml(c.document_title)}</strong>
+                <details><summary>Show passage</summary><p class="citation-passage">${escapeHtml(c.passage)}</p></details>
+            </li>`).join('');
+        return `<h3>Sources</h3><ol class="citation-list">${items}</ol>`;
+    }
+
+    async function loadCollections(selectID) {
+        try {
+            const response = await fetch('/api/collections');
+            if (!response.ok) return;
+            const collections = await response.json();
+            const selected = selectID || researchCollectionSelect.value;
+            researchCollectionSelect.querySelectorAll('option:not([value=""])').forEach(o => o.remove());
+            collections.forEach(c => {
+                const option = document.createElement('option');
+                option.value = c.id;
+                option.textContent = `${c.name} (${c.documents.length} docs)`;
+                researchCollectionSelect.appendChild(option);
+            });
+            researchCollectionSelect.value = collections.some(c => c.id === selected) ? selected : '';
+        } catch (e) {
+            console.error("Failed to load collections:", e);
+        }
+    }
+
+    async function createCollection() {
+        errorMessage.textContent = '';
+        try {
+            const response = await fetch('/api/collections', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ name: collectionNameInput.value }),
+            });
+            const data = await response.json();
+            if (!response.ok) throw new Error(data.error || 'Could not create the collection.');
+            collectionNameInput.value = '';
+            loadCollections(data.id);
+        } catch (e) {
+            errorMessage.textContent = e.message;
+        }
+    }
+
+    async function uploadDocuments() {
+        errorMessage.textContent = '';
+        if (!researchCollectionSelect.value) {
+            errorMessage.textContent = 'Choose or create a collection first.';
+            return;
+        }
+        if (collectionFilesInput.files.length === 0) return;
+        const form = new FormData();
+        Array.from(collectionFilesInput.files).forEach(f => form.append('file', f));
+        collectionStatus.textContent = 'Indexing...';
+        try {
+            const response = await fetch(`/api/collections/documents?collection=${encodeURIComponent(researchCollectionSelect.value)}`, { method: 'POST', body: form });
+            const data = await response.json();
+            if (!response.ok) throw new Error(data.error || 'Upload failed.');
+            const warnings = data.flatMap(d => (d.warnings || []).map(w => `${d.filename}: ${w}`));
+            collectionStatus.textContent = `Indexed ${data.length} document(s).` + (warnings.length ? ' ' + warnings.join(' ') : '');
+            collectionFilesInput.value = '';
+            loadCollections();
+        } catch (e) {
+            collectionStatus.textContent = '';
+            errorMessage.textContent = e.message;
+        }
+    }
+
+    async function exportResult(format) {
+        if (!lastResult) return;
+        errorMessage.textContent = '';
+        try {
+            const response = await fetch(`/api/export?format=${format}`, {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify(lastResult),
+            });
+            if (!response.ok) {
+                const data = await response.json();
+                throw new Error(data.error || 'Export failed.');
+            }
+            const match = /filename="([^"]+)"/.exec(response.headers.get('Content-Disposition') || '');
+            const link = document.createElement('a');
+            link.href = URL.createObjectURL(await response.blob());
+            link.download = match ? match[1] : `result.${format}`;
+            link.click();
+            URL.revokeObjectURL(link.href);
+        } catch (e) {
+            errorMessage.textContent = e.message;
+        }
+    }
+
+    // Extracts text from an uploaded document into the editor, for any action.
+    async function uploadDocument() {
+        const file = documentUploadInput.files[0];
+        if (!file) return;
+        errorMessage.textContent = '';
+        uploadStatus.textContent = `Reading ${file.name}...`;
+        uploadStatus.title = '';
+        const form = new FormData();
+        form.append('file', file);
+        try {
+            const response = await fetch('/api/upload', { method: 'POST', body: form });
+            const data = await response.json();
+            if (!response.ok) throw new Error(data.error || 'Could not read the document.');
+            inputText.value = data.text;
+            inputText.dispatchEvent(new Event('input'));
+            const warnings = data.warnings || [];
+            uploadStatus.textContent = warnings.length ? `${data.filename}: ${warnings.length} warning(s)` : data.filename;
+            uploadStatus.title = warnings.join('\n');
+            uploadStatus.classList.toggle('has-warnings', warnings.length > 0);
+        } catch (e) {
+            uploadStatus.textContent = '';
+            errorMessage.textContent = e.message;
+        } finally {
+            documentUploadInput.value = '';
+        }
+    }
+
+    // History is stored server-side per workspace; the panel is hidden when
+    // the server runs without a history database.
+    async function loadHistory() {
+        const params = new URLSearchParams({ workspace: workspaceInput.value.trim() || 'default', limit: 30 });
+        const search = historySearchInput.value.trim();
+        if (search) params.set('q', search);
+        try {
+            const response = await fetch(`/api/history?${params}`);
+            if (response.status === 404) {
+                historyPanel.classList.add('hidden');
+                return;
+            }
+            const data = await response.json();
+            if (!response.ok) throw new Error(data.error);
+            historyList.innerHTML = data.documents.length === 0
+                ? `<li class="history-empty">${search ? 'No matches.' : 'Nothing yet.'}</li>`
+                : data.documents.map(d => `<li data-id="${escapeHtml(d.id)}" title="${escapeHtml(d.snippet || '')}">
+                    <span class="history-title">${escapeHtml(d.title)}<small>${escapeHtml(d.action)} &middot; ${new Date(d.updated_at).toLocaleString()}</small></span>
+                    <button type="button" data-delete="${escapeHtml(d.id)}" title="Delete">&times;</button></li>`).join('');
+        } catch (e) {
+            console.error('Failed to load history:', e);
+        }
+        loadWorkspaces();
+    }
+
+    async function loadWorkspaces() {
+        try {
+            const response = await fetch('/api/workspaces');
+            if (!response.ok) return;
+            const workspaces = await response.json();
+            workspaceList.innerHTML = workspaces.map(ws => `<option value="${escapeHtml(ws.name)}">${ws.documents} document(s)</option>`).join('');
+        } catch (e) {
+            console.error('Failed to load workspaces:', e);
+        }
+    }
+
+    // Opening a history entry switches to its tool and shows its head
+    // revision as if it had just been run.
+    async function openHistoryDocument(id) {
+        try {
+            const response = await fetch(`/api/history?id=${encodeURIComponent(id)}`);
+            const doc = await response.json();
+            if (!response.ok) throw new Error(doc.error || 'Could not load the document.');
+            const revision = doc.revisions.find(r => r.id === doc.head_revision_id) || doc.revisions[doc.revisions.length - 1];
+            const link = document.querySelector(`.nav-link[data-action="${revision.action}"]`);
+            if (link) link.click();
+            showRevision(doc, revision);
+        } catch (e) {
+            errorMessage.textContent = e.message;
+        }
+    }
+
+    function showRevision(doc, revision) {
+        inputText.value = revision.input;
+        inputText.dispatchEvent(new Event('input'));
+        outputPlaceholder.classList.add('hidden');
+        renderResults(revision.result, revision.input, revision.id);
+        if (doc.revisions.length > 1 || revision.action === 'humanize') renderRevisions(doc, revision.id);
+    }
+
+    async function loadRevisions(documentId, activeId) {
+        try {
+            const response = await fetch(`/api/history?id=${encodeURIComponent(documentId)}`);
+            const doc = await response.json();
+            if (!response.ok) throw new Error(doc.error);
+            renderRevisions(doc, activeId);
+        } catch (e) {
+            console.error('Failed to load revisions:', e);
+        }
+    }
+
+    // Lists a document's revisions as a tree, each under the revision it
+    // rewrote, with controls to view, rewrite, restore and compare them.
+    function renderRevisions(doc, activeId) {
+        const number = new Map(doc.revisions.map((r, i) => [r.id, i + 1]));
+        const children = new Map();
+        doc.revisions.forEach(r => {
+            const parent = number.has(r.parent_id) ? r.parent_id : '';
+            if (!children.has(parent)) children.set(parent, []);
+            children.get(parent).push(r);
+        });
+        const items = [];
+        const walk = (parent, depth) => (children.get(parent) || []).forEach(r => {
+            items.push({ revision: r, depth });
+            walk(r.id, depth + 1);
+        });
+        walk('', 0);
+
+        const panel = document.createElement('div');
+        panel.className = 'style-report revision-panel';
+        panel.innerHTML = `<h4>Revisions (${doc.revisions.length})</h4>
+            <ul class="revision-tree">${items.map(({ revision: r, depth }) => {
+                const tags = [r.id === activeId ? 'showing' : '', r.id === doc.head_revision_id ? 'head' : ''].filter(Boolean);
+                return `<li data-id="${escapeHtml(r.id)}" class="${r.id === activeId ? 'active' : ''}" style="padding-left: ${depth * 1.25}rem">
+                    <label><input type="checkbox" data-compare="${escapeHtml(r.id)}" title="Select two to compare">
+                    <strong>#${number.get(r.id)}</strong> ${escapeHtml(truncate(r.text || r.output || r.input, 80))}
+                    <small>${new Date(r.created_at).toLocaleString()}${tags.length ? ' &middot; ' + tags.join(', ') : ''}</small></label>
+                    <span class="revision-actions">
+                        <button type="button" data-view="${escapeHtml(r.id)}">View</button>
+                        ${r.action === 'humanize' ? `<button type="button" data-rewrite="${escapeHtml(r.id)}" title="Load this draft and rewrite it">Rewrite</button>` : ''}
+                        ${r.id !== doc.head_revision_id ? `<button type="button" data-restore="${escapeHtml(r.id)}">Restore</button>` : ''}
+                    </span></li>`;
+            }).join('')}</ul>
+            <button type="button" class="suggestion-btn" data-diff disabled>Compare selected</button>
+            <div class="revision-diff hidden"></div>`;
+        resultsContainer.querySelectorAll('.revision-panel').forEach(el => el.remove());
+        resultsContainer.appendChild(panel);
+
+        const byId = new Map(doc.revisions.map(r => [r.id, r]));
+        const compareButton = panel.querySelector('[data-diff]');
+        panel.addEventListener('change', () => {
+            compareButton.disabled = panel.querySelectorAll('[data-compare]:checked').length !== 2;
+        });
+        compareButton.addEventListener('click', () => {
+            const [from, to] = Array.from(panel.querySelectorAll('[data-compare]:checked')).map(i => i.dataset.compare)
+                .sort((a, b) => number.get(a) - number.get(b));
+            showRevisionDiff(panel.querySelector('.revision-diff'), from, to, number);
+        });
+        panel.addEventListener('click', async (e) => {
+            const view = e.target.closest('[data-view]');
+            const rewrite = e.target.closest('[data-rewrite]');
+            const restore = e.target.closest('[data-restore]');
+            if (view) {
+                showRevision(doc, byId.get(view.dataset.view));
+            } else if (rewrite) {
+                const revision = byId.get(rewrite.dataset.rewrite);
+                inputText.value = revision.text || revision.input;
+                inputText.dispatchEvent(new Event('input'));
+                rewriteFrom = revision.id;
+                errorMessage.textContent = '';
+                inputText.focus();
+            } else if (restore) {
+                const response = await fetch(`/api/history/revisions?id=${encodeURIComponent(restore.dataset.restore)}`, { method: 'POST' });
+                const restored = await response.json();
+                if (!response.ok) {
+                    errorMessage.textContent = restored.error || 'Could not restore the revision.';
+                    return;
+                }
+                showRevision(restored, byId.get(restore.dataset.restore));
+                loadHistory();
+            }
+        });
+    }
+
+    async function showRevisionDiff(container, from, to, number) {
+        try {
+            const params = new URLSearchParams({ from, to });
+            const response = await fetch(`/api/history/revisions?${params}`);
+            const diff = await response.json();
+            if (!response.ok) throw new Error(diff.error || 'Could not compare the revisions.');
+            const body = diff.ops.map(op => {
+                const text = escapeHtml(op.text).replace(/\n/g, '<br>');
+                return op.op === 'insert' ? `<ins>${text}</ins>` : op.op === 'delete' ? `<del>${text}</del>` : text;
+            }).join('');
+            container.innerHTML = `<p><small>#${number.get(from)} &rarr; #${number.get(to)}: ${diff.insertions} word(s) added, ${diff.deletions} removed</small></p><div class="revision-diff-text">${body}</div>`;
+            container.classList.remove('hidden');
+        } catch (e) {
+            errorMessage.textContent = e.message;
+        }
+    }
+
+    function truncate(text, length) {
+        text = text.replace(/\s+/g, ' ').trim();
+        return text.length > length ? text.slice(0, length).trim() + '…' : text;
+    }
+
+    async function shareResult() {
+        if (!lastResult) return;
+        errorMessage.textContent = '';
+        if (!lastRevisionId) {
+            errorMessage.textContent = 'Only results saved in the history can be shared.';
+            return;
+        }
+        shareButton.disabled = true;
+        try {
+            const response = await fetch('/api/shares', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({
+                    revision_id: lastRevisionId,
+                    expires_in_hours: parseInt(shareExpirySelect.value, 10)
+                }),
+            });
+            const share = await response.json();
+            if (!response.ok) throw new Error(share.error || 'Could not create the link.');
+            const url = new URL(share.url, location.origin).href;
+            let copied = false;
+            try {
+                await navigator.clipboard.writeText(url);
+                copied = true;
+            } catch (e) { /* clipboard access can be denied; the link is shown anyway */ }
+            resultsContainer.querySelectorAll('.share-link').forEach(el => el.remove());
+            resultsContainer.insertAdjacentHTML('afterbegin', `<div class="share-link">${copied ? 'Link copied' : 'Read-only link'}: <a href="${escapeHtml(url)}" target="_blank" rel="noopener">${escapeHtml(url)}</a></div>`);
+            loadShares();
+        } catch (e) {
+            errorMessage.textContent = e.message;
+        } finally {
+            shareButton.disabled = false;
+        }
+    }
+
+    // Shared links live in the history database too; without it the panel
+    // and the share button stay hidden.
+    async function loadShares() {
+        try {
+            const params = new URLSearchParams({ workspace: workspaceInput.value.trim() || 'default' });
+            const response = await fetch(`/api/shares?${params}`);
+            const available = response.status !== 404;
+            sharesPanel.classList.toggle('hidden', !available);
+            shareControls.classList.toggle('hidden', !available);
+            if (!available) return;
+            const shares = await response.json();
+            if (!response.ok) throw new Error(shares.error);
+            shareList.innerHTML = shares.length === 0
+                ? '<li class="history-empty">No shared links.</li>'
+                : shares.map(s => {
+                    const status = s.revoked_at ? 'revoked'
+                        : !s.active ? 'expired'
+                        : s.expires_at ? `until ${new Date(s.expires_at).toLocaleDateString()}` : 'no expiry';
+                    const url = new URL(s.url, location.origin).href;
+                    return `<li class="${s.active ? '' : 'revoked'}" data-url="${escapeHtml(url)}" title="${escapeHtml(url)}">
+                        <span class="history-title">${escapeHtml(s.title)}<small>${s.access_count} view(s) &middot; ${status}</small></span>
+                        ${s.active ? `<button type="button" data-revoke="${escapeHtml(s.id)}" title="Revoke">&times;</button>` : ''}</li>`;
+                }).join('');
+        } catch (e) {
+            console.error('Failed to load shared links:', e);
+        }
+    }
+
+    async function revokeShare(id) {
+        if (!confirm('Revoke this link? Anyone who has it will no longer be able to open it.')) return;
+        const response = await fetch(`/api/shares?id=${encodeURIComponent(id)}`, { method: 'DELETE' });
+        if (!response.ok) {
+            const data = await response.json().catch(() => ({}));
+            errorMessage.textContent = data.error || 'Could not revoke the link.';
+        }
+        loadShares();
+    }
+
+    shareList.addEventListener('click', (e) => {
+        const revoke = e.target.closest('[data-revoke]');
+        if (revoke) {
+            e.stopPropagation();
+            revokeShare(revoke.dataset.revoke);
+            return;
+        }
+        const item = e.target.closest('li[data-url]');
+        if (item) window.open(item.dataset.url, '_blank', 'noopener');
+    });
+    shareButton.addEventListener('click', shareResult);
+
+    // A shared link shows its result on its own: the sidebar, editor and
+    // workspace picker are removed rather than hidden.
+    function showSharedResult(shared) {
+        document.body.classList.add('read-only');
+        document.querySelector('.sidebar').remove();
+        document.querySelector('.workspace-column').remove();
+        document.querySelector('.workspace-picker').remove();
+        document.title = `${shared.title} - Rephrase AI`;
+        pageTitle.textContent = shared.title;
+        inputText.value = shared.text || '';
+        outputPlaceholder.classList.add('hidden');
+        renderResults(shared.result, shared.text || '');
+        const expires = shared.expires_at ? ` It is available until ${new Date(shared.expires_at).toLocaleString()}.` : '';
+        resultsContainer.insertAdjacentHTML('beforebegin', `<div class="share-banner">Read-only result, shared ${new Date(shared.created_at).toLocaleString()}.${expires}</div>`);
+    }
+
+    async function deleteHistoryDocument(id) {
+        if (!confirm('Delete this document and all of its revisions?')) return;
+        const response = await fetch(`/api/history?id=${encodeURIComponent(id)}`, { method: 'DELETE' });
+        if (!response.ok) {
+            const data = await response.json().catch(() => ({}));
+            errorMessage.textContent = data.error || 'Could not delete the document.';
+        }
+        loadHistory();
+    }
+
+    historyList.addEventListener('click', (e) => {
+        const del = e.target.closest('[data-delete]');
+        if (del) {
+            e.stopPropagation();
+            deleteHistoryDocument(del.dataset.delete);
+            return;
+        }
+        const item = e.target.closest('li[data-id]');
+        if (item) openHistoryDocument(item.dataset.id);
+    });
+    let historySearchTimer;
+    historySearchInput.addEventListener('input', () => {
+        clearTimeout(historySearchTimer);
+        historySearchTimer = setTimeout(loadHistory, 300);
+    });
+
+    documentUploadInput.addEventListener('change', uploadDocument);
+    exportBar.querySelectorAll('button[data-format]').forEach(b => b.addEventListener('click', () => exportResult(b.dataset.format)));
+    createCollectionButton.addEventListener('click', createCollection);
+    uploadDocumentsButton.addEventListener('click', uploadDocuments);
+
+    // Sends visitors without a session to the login page. A 404 means the
+    // server runs without accounts, and everyone may use it.
+    async function checkSession() {
+        try {
+            const response = await fetch('/api/auth/me');
+            if (response.status === 401) {
+                window.location.replace('/login.html');
+                return false;
+            }
+            if (response.ok) {
+                const user = await response.json();
+                accountName.textContent = user.role === 'admin' ? `${user.username} (admin)` : user.username;
+                accountMenu.classList.remove('hidden');
+            }
+        } catch (e) {
+            console.error('Failed to check session:', e);
+        }
+        return true;
+    }
+
+    logoutButton.addEventListener('click', async () => {
+        await fetch('/api/auth/logout', { method: 'POST' }).catch(() => {});
+        window.location.replace('/login.html');
+    });
+
     // --- Initial Setup ---
+    if (readOnly) {
+        showSharedResult(JSON.parse(sharedResultEl.textContent));
+        return;
+    }
     updateUIForAction();
-    connectWebSocket();
+    checkSession().then(signedIn => {
+        if (!signedIn) return;
+        loadStyleGuides();
+        loadCorpusStatus();
+        loadCollections();
+        loadHistory();
+        loadShares();
+        connectWebSocket();
+    });
 });
\ No newline at end of file